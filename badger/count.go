@@ -1,6 +1,7 @@
 package badger
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
 	"log"
@@ -12,6 +13,17 @@ import (
 )
 
 func (b *BadgerBackend) CountEvents(ctx context.Context, filter nostr.Filter) (int64, error) {
+	var count int64
+	err := b.view(func(txn *badger.Txn) error {
+		var err error
+		count, err = b.count(txn, filter)
+		return err
+	})
+	return count, err
+}
+
+// count is like CountEvents, but inside a transaction we already have.
+func (b *BadgerBackend) count(txn *badger.Txn, filter nostr.Filter) (int64, error) {
 	var count int64 = 0
 
 	queries, extraFilter, since, err := prepareQueries(filter)
@@ -19,72 +31,73 @@ func (b *BadgerBackend) CountEvents(ctx context.Context, filter nostr.Filter) (i
 		return 0, err
 	}
 
-	err = b.View(func(txn *badger.Txn) error {
-		// iterate only through keys and in reverse order
-		opts := badger.IteratorOptions{
-			Reverse: true,
-		}
+	// iterate only through keys and in reverse order
+	opts := badger.IteratorOptions{
+		Reverse: true,
+	}
 
-		// actually iterate
-		for _, q := range queries {
-			it := txn.NewIterator(opts)
-			defer it.Close()
+	// actually iterate
+	for _, q := range queries {
+		it := txn.NewIterator(opts)
+		defer it.Close()
 
-			for it.Seek(q.startingPoint); it.ValidForPrefix(q.prefix); it.Next() {
-				item := it.Item()
-				key := item.Key()
+		for it.Seek(q.startingPoint); it.ValidForPrefix(q.prefix); it.Next() {
+			item := it.Item()
+			key := item.Key()
 
-				idxOffset := len(key) - 4 // this is where the idx actually starts
+			idxOffset := len(key) - 8 // this is where the idx actually starts
 
-				// "id" indexes don't contain a timestamp
-				if !q.skipTimestamp {
-					createdAt := binary.BigEndian.Uint32(key[idxOffset-4 : idxOffset])
-					if createdAt < since {
-						break
-					}
+			// "id" indexes don't contain a timestamp
+			if !q.skipTimestamp {
+				createdAt := binary.BigEndian.Uint32(key[idxOffset-4 : idxOffset])
+				if createdAt < since {
+					break
 				}
+			}
 
-				idx := make([]byte, 5)
-				idx[0] = rawEventStorePrefix
-				copy(idx[1:], key[idxOffset:])
+			idx := make([]byte, 1+8)
+			idx[0] = rawEventStorePrefix
+			copy(idx[1:], key[idxOffset:])
+
+			if extraFilter == nil && q.fullId == nil {
+				count++
+			} else {
+				// fetch actual event
+				item, err := txn.Get(idx)
+				if err != nil {
+					if err == badger.ErrDiscardedTxn {
+						return 0, err
+					}
+					log.Printf("badger: count (%v) failed to get %d from raw event store: %s\n", q, idx, err)
+					return 0, err
+				}
 
-				if extraFilter == nil {
-					count++
-				} else {
-					// fetch actual event
-					item, err := txn.Get(idx)
+				err = item.Value(func(val []byte) error {
+					v, err := bin.NewView(val)
 					if err != nil {
-						if err == badger.ErrDiscardedTxn {
-							return err
-						}
-						log.Printf("badger: count (%v) failed to get %d from raw event store: %s\n", q, idx, err)
 						return err
 					}
 
-					err = item.Value(func(val []byte) error {
-						evt := &nostr.Event{}
-						if err := bin.Unmarshal(val, evt); err != nil {
-							return err
-						}
-
-						// check if this matches the other filters that were not part of the index
-						if extraFilter.Matches(evt) {
-							count++
-						}
-
+					// check if this is really the event we want, not only one with the same id prefix
+					if q.fullId != nil && !bytes.Equal(v.ID(), q.fullId) {
 						return nil
-					})
-					if err != nil {
-						log.Printf("badger: count value read error: %s\n", err)
 					}
+
+					// check if this matches the other filters that were not part of the index
+					if extraFilter == nil || viewMatchesFilter(extraFilter, v) {
+						count++
+					}
+
+					return nil
+				})
+				if err != nil {
+					log.Printf("badger: count value read error: %s\n", err)
 				}
 			}
 		}
+	}
 
-		return nil
-	})
-
-	return count, err
+	return count, nil
 }
 
 func (b *BadgerBackend) CountEventsHLL(ctx context.Context, filter nostr.Filter, offset int) (int64, *hyperloglog.HyperLogLog, error) {
@@ -97,7 +110,7 @@ func (b *BadgerBackend) CountEventsHLL(ctx context.Context, filter nostr.Filter,
 
 	hll := hyperloglog.New(offset)
 
-	err = b.View(func(txn *badger.Txn) error {
+	err = b.view(func(txn *badger.Txn) error {
 		// iterate only through keys and in reverse order
 		opts := badger.IteratorOptions{
 			Reverse: true,
@@ -112,7 +125,7 @@ func (b *BadgerBackend) CountEventsHLL(ctx context.Context, filter nostr.Filter,
 				item := it.Item()
 				key := item.Key()
 
-				idxOffset := len(key) - 4 // this is where the idx actually starts
+				idxOffset := len(key) - 8 // this is where the idx actually starts
 
 				// "id" indexes don't contain a timestamp
 				if !q.skipTimestamp {
@@ -122,7 +135,7 @@ func (b *BadgerBackend) CountEventsHLL(ctx context.Context, filter nostr.Filter,
 					}
 				}
 
-				idx := make([]byte, 5)
+				idx := make([]byte, 1+8)
 				idx[0] = rawEventStorePrefix
 				copy(idx[1:], key[idxOffset:])
 
@@ -138,19 +151,18 @@ func (b *BadgerBackend) CountEventsHLL(ctx context.Context, filter nostr.Filter,
 
 				err = item.Value(func(val []byte) error {
 					if extraFilter == nil {
-						hll.AddBytes(val[32:64])
+						hll.AddBytes(val[bin.PubKeyOffset:bin.SigOffset])
 						count++
 						return nil
 					}
 
-					evt := &nostr.Event{}
-					if err := bin.Unmarshal(val, evt); err != nil {
+					v, err := bin.NewView(val)
+					if err != nil {
 						return err
 					}
-					if extraFilter.Matches(evt) {
-						hll.Add(evt.PubKey)
+					if viewMatchesFilter(extraFilter, v) {
+						hll.AddBytes(v.PubKey())
 						count++
-						return nil
 					}
 
 					return nil
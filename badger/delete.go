@@ -14,7 +14,7 @@ var serialDelete uint32 = 0
 func (b *BadgerBackend) DeleteEvent(ctx context.Context, evt *nostr.Event) error {
 	deletionHappened := false
 
-	err := b.Update(func(txn *badger.Txn) error {
+	err := b.update(func(txn *badger.Txn) error {
 		var err error
 		deletionHappened, err = b.delete(txn, evt)
 		return err
@@ -37,26 +37,15 @@ func (b *BadgerBackend) DeleteEvent(ctx context.Context, evt *nostr.Event) error
 }
 
 func (b *BadgerBackend) delete(txn *badger.Txn, evt *nostr.Event) (bool, error) {
-	idx := make([]byte, 1, 5)
-	idx[0] = rawEventStorePrefix
-
 	// query event by id to get its idx
-	idPrefix8, _ := hex.DecodeString(evt.ID[0 : 8*2])
-	prefix := make([]byte, 1+8)
-	prefix[0] = indexIdPrefix
-	copy(prefix[1:], idPrefix8)
-	opts := badger.IteratorOptions{
-		PrefetchValues: false,
-	}
-	it := txn.NewIterator(opts)
-	it.Seek(prefix)
-	if it.ValidForPrefix(prefix) {
-		idx = append(idx, it.Item().Key()[1+8:]...)
+	id, _ := hex.DecodeString(evt.ID)
+	idx, err := getIdxForId(txn, id)
+	if err != nil {
+		return false, err
 	}
-	it.Close()
 
 	// if no idx was found, end here, this event doesn't exist
-	if len(idx) == 1 {
+	if idx == nil {
 		return false, nil
 	}
 
@@ -0,0 +1,78 @@
+package badger
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"iter"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/fiatjaf/eventstore"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+var _ eventstore.Exporter = (*BadgerBackend)(nil)
+
+// ExportEvents goes through the created_at index oldest first, from a single read transaction,
+// so what it yields is a point-in-time snapshot.
+func (b *BadgerBackend) ExportEvents(ctx context.Context, filter nostr.Filter) iter.Seq2[*nostr.Event, error] {
+	return func(yield func(*nostr.Event, error) bool) {
+		if filter.Search != "" {
+			return
+		}
+
+		var count int
+		err := b.view(func(txn *badger.Txn) error {
+			it := txn.NewIterator(badger.IteratorOptions{
+				Prefix: []byte{indexCreatedAtPrefix},
+			})
+			defer it.Close()
+
+			start := make([]byte, 1+4)
+			start[0] = indexCreatedAtPrefix
+			if filter.Since != nil {
+				binary.BigEndian.PutUint32(start[1:], uint32(*filter.Since))
+			}
+
+			idx := make([]byte, 1+8)
+			idx[0] = rawEventStorePrefix
+			for it.Seek(start); it.ValidForPrefix(start[0:1]); it.Next() {
+				key := it.Item().Key()
+				if filter.Until != nil && nostr.Timestamp(binary.BigEndian.Uint32(key[1:5])) > *filter.Until {
+					return nil
+				}
+				copy(idx[1:], key[5:])
+
+				item, err := txn.Get(idx)
+				if err != nil {
+					return fmt.Errorf("%w: failed to get event %x from the created_at index: %w", eventstore.ErrCorrupted, idx[1:], err)
+				}
+				evt := &nostr.Event{}
+				if err := item.Value(func(val []byte) error { return bin.Decode(val, evt) }); err != nil {
+					return fmt.Errorf("%w: event %x can't be decoded: %w", eventstore.ErrCorrupted, idx[1:], err)
+				}
+				if !filter.Matches(evt) {
+					continue
+				}
+
+				if !yield(evt, nil) {
+					return nil
+				}
+				count++
+				if count == filter.Limit {
+					return nil
+				}
+				if count%1000 == 0 {
+					if err := ctx.Err(); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
@@ -56,7 +56,7 @@ func FuzzQuery(f *testing.F) {
 			if it.Valid() {
 				key := it.Item().Key()
 				idx := key[1:]
-				serial := binary.BigEndian.Uint32(idx)
+				serial := binary.BigEndian.Uint64(idx)
 				db.serial.Store(serial)
 			}
 			it.Close()
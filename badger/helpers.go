@@ -1,12 +1,15 @@
 package badger
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
 	"iter"
 	"strconv"
 	"strings"
 
+	"github.com/dgraph-io/badger/v4"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
 	"github.com/nbd-wtf/go-nostr"
 	"golang.org/x/exp/slices"
 )
@@ -19,7 +22,7 @@ func getTagIndexPrefix(tagName string, tagValue string) ([]byte, int) {
 
 	if kind, pkb, d := getAddrTagElements(tagValue); len(pkb) == 32 {
 		// store value in the new special "a"-style tag index
-		k = make([]byte, 1+1+2+8+len(d)+4+4)
+		k = make([]byte, 1+1+2+8+len(d)+4+8)
 		k[0] = indexTagAddrPrefix
 		k[1] = letterPrefix
 		binary.BigEndian.PutUint16(k[1:], kind)
@@ -28,14 +31,14 @@ func getTagIndexPrefix(tagName string, tagValue string) ([]byte, int) {
 		offset = 1 + 1 + 2 + 8 + len(d)
 	} else if vb, _ := hex.DecodeString(tagValue); len(vb) == 32 {
 		// store value as bytes with tag name prefix
-		k = make([]byte, 1+1+8+4+4)
+		k = make([]byte, 1+1+8+4+8)
 		k[0] = indexTag32Prefix
 		k[1] = letterPrefix
 		copy(k[2:], vb[0:8])
 		offset = 1 + 1 + 8
 	} else {
 		// store whatever as utf-8 with tag name prefix
-		k = make([]byte, 1+1+len(tagValue)+4+4)
+		k = make([]byte, 1+1+len(tagValue)+4+8)
 		k[0] = indexTagPrefix
 		k[1] = letterPrefix
 		copy(k[2:], tagValue)
@@ -50,7 +53,7 @@ func (b *BadgerBackend) getIndexKeysForEvent(evt *nostr.Event, idx []byte) iter.
 		{
 			// ~ by id
 			idPrefix8, _ := hex.DecodeString(evt.ID[0 : 8*2])
-			k := make([]byte, 1+8+4)
+			k := make([]byte, 1+8+8)
 			k[0] = indexIdPrefix
 			copy(k[1:], idPrefix8)
 			copy(k[1+8:], idx)
@@ -62,7 +65,7 @@ func (b *BadgerBackend) getIndexKeysForEvent(evt *nostr.Event, idx []byte) iter.
 		{
 			// ~ by pubkey+date
 			pubkeyPrefix8, _ := hex.DecodeString(evt.PubKey[0 : 8*2])
-			k := make([]byte, 1+8+4+4)
+			k := make([]byte, 1+8+4+8)
 			k[0] = indexPubkeyPrefix
 			copy(k[1:], pubkeyPrefix8)
 			binary.BigEndian.PutUint32(k[1+8:], uint32(evt.CreatedAt))
@@ -74,11 +77,11 @@ func (b *BadgerBackend) getIndexKeysForEvent(evt *nostr.Event, idx []byte) iter.
 
 		{
 			// ~ by kind+date
-			k := make([]byte, 1+2+4+4)
+			k := make([]byte, 1+4+4+8)
 			k[0] = indexKindPrefix
-			binary.BigEndian.PutUint16(k[1:], uint16(evt.Kind))
-			binary.BigEndian.PutUint32(k[1+2:], uint32(evt.CreatedAt))
-			copy(k[1+2+4:], idx)
+			binary.BigEndian.PutUint32(k[1:], uint32(evt.Kind))
+			binary.BigEndian.PutUint32(k[1+4:], uint32(evt.CreatedAt))
+			copy(k[1+4+4:], idx)
 			if !yield(k) {
 				return
 			}
@@ -87,12 +90,12 @@ func (b *BadgerBackend) getIndexKeysForEvent(evt *nostr.Event, idx []byte) iter.
 		{
 			// ~ by pubkey+kind+date
 			pubkeyPrefix8, _ := hex.DecodeString(evt.PubKey[0 : 8*2])
-			k := make([]byte, 1+8+2+4+4)
+			k := make([]byte, 1+8+4+4+8)
 			k[0] = indexPubkeyKindPrefix
 			copy(k[1:], pubkeyPrefix8)
-			binary.BigEndian.PutUint16(k[1+8:], uint16(evt.Kind))
-			binary.BigEndian.PutUint32(k[1+8+2:], uint32(evt.CreatedAt))
-			copy(k[1+8+2+4:], idx)
+			binary.BigEndian.PutUint32(k[1+8:], uint32(evt.Kind))
+			binary.BigEndian.PutUint32(k[1+8+4:], uint32(evt.CreatedAt))
+			copy(k[1+8+4+4:], idx)
 			if !yield(k) {
 				return
 			}
@@ -136,7 +139,7 @@ func (b *BadgerBackend) getIndexKeysForEvent(evt *nostr.Event, idx []byte) iter.
 
 		{
 			// ~ by date only
-			k := make([]byte, 1+4+4)
+			k := make([]byte, 1+4+8)
 			k[0] = indexCreatedAtPrefix
 			binary.BigEndian.PutUint32(k[1:], uint32(evt.CreatedAt))
 			copy(k[1+4:], idx)
@@ -159,11 +162,75 @@ func getAddrTagElements(tagValue string) (kind uint16, pkb []byte, d string) {
 	return 0, nil, ""
 }
 
-func filterMatchesTags(ef *nostr.Filter, event *nostr.Event) bool {
-	for f, v := range ef.Tags {
-		if v != nil && !event.Tags.ContainsAny(f, v) {
+// getIdxForId finds the raw event key of the event with exactly this id, or nil if we don't have it.
+// the id index only has the first 8 bytes of each id, so more than one event may be stored
+// under the same prefix and we have to check the full id on the raw events.
+func getIdxForId(txn *badger.Txn, id []byte) ([]byte, error) {
+	prefix := make([]byte, 1+8)
+	prefix[0] = indexIdPrefix
+	copy(prefix[1:], id[0:8])
+
+	it := txn.NewIterator(badger.IteratorOptions{
+		PrefetchValues: false,
+		Prefix:         prefix,
+	})
+	defer it.Close()
+
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		idx := make([]byte, 1+8)
+		idx[0] = rawEventStorePrefix
+		copy(idx[1:], it.Item().Key()[1+8:])
+
+		item, err := txn.Get(idx)
+		if err == badger.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var matches bool
+		if err := item.Value(func(val []byte) error {
+			matches = len(val) >= bin.PubKeyOffset && bytes.Equal(val[bin.IDOffset:bin.PubKeyOffset], id)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		if matches {
+			return idx, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// viewMatchesFilter checks the parts of the filter that were not part of the index directly
+// against the raw event, so we only have to decode the events that pass.
+func viewMatchesFilter(ef *nostr.Filter, v bin.View) bool {
+	if ef.Kinds != nil && !slices.Contains(ef.Kinds, int(v.Kind())) {
+		return false
+	}
+
+	if ef.Authors != nil {
+		var pubkey [64]byte
+		hex.Encode(pubkey[:], v.PubKey())
+		found := false
+		for _, author := range ef.Authors {
+			if author == string(pubkey[:]) {
+				found = true
+				break
+			}
+		}
+		if !found {
 			return false
 		}
 	}
+
+	for f, values := range ef.Tags {
+		if values != nil && !v.ContainsAnyTag(f, values) {
+			return false
+		}
+	}
+
 	return true
 }
@@ -1,26 +1,32 @@
 package badger
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
+	"log"
+	"math"
 	"sync/atomic"
 
 	"github.com/dgraph-io/badger/v4"
 	"github.com/fiatjaf/eventstore"
+	"github.com/fiatjaf/eventstore/internal/bloom"
+	"github.com/fiatjaf/eventstore/internal/inflight"
 	"github.com/nbd-wtf/go-nostr"
 )
 
 const (
-	dbVersionKey          byte = 255
-	rawEventStorePrefix   byte = 0
-	indexCreatedAtPrefix  byte = 1
-	indexIdPrefix         byte = 2
-	indexKindPrefix       byte = 3
-	indexPubkeyPrefix     byte = 4
-	indexPubkeyKindPrefix byte = 5
-	indexTagPrefix        byte = 6
-	indexTag32Prefix      byte = 7
-	indexTagAddrPrefix    byte = 8
+	dbVersionKey           byte = 255
+	migrationCheckpointKey byte = 254
+	rawEventStorePrefix    byte = 0
+	indexCreatedAtPrefix   byte = 1
+	indexIdPrefix          byte = 2
+	indexKindPrefix        byte = 3
+	indexPubkeyPrefix      byte = 4
+	indexPubkeyKindPrefix  byte = 5
+	indexTagPrefix         byte = 6
+	indexTag32Prefix       byte = 7
+	indexTagAddrPrefix     byte = 8
 )
 
 var _ eventstore.Store = (*BadgerBackend)(nil)
@@ -31,14 +37,27 @@ type BadgerBackend struct {
 	MaxLimitNegentropy    int
 	BadgerOptionsModifier func(badger.Options) badger.Options
 
+	// MigrationBatchSize is how many events each migration transaction goes through before it
+	// commits and saves a checkpoint, defaults to 1000.
+	MigrationBatchSize int
+
+	// OnMigrationProgress, if set, is called by Init after every batch of every migration.
+	OnMigrationProgress func(eventstore.MigrationProgress)
+
 	// Experimental
 	SkipIndexingTag func(event *nostr.Event, tagName string, tagValue string) bool
 	// Experimental
 	IndexLongerTag func(event *nostr.Event, tagName string, tagValue string) bool
 
+	// EnableBloomFilter keeps an in-memory bloom filter of all the ids we have so HasEvents
+	// can answer negatively without touching the database.
+	EnableBloomFilter bool
+	bloom             *bloom.Filter
+
 	*badger.DB
+	inflight inflight.Tracker
 
-	serial atomic.Uint32
+	serial atomic.Uint64
 }
 
 func (b *BadgerBackend) Init() error {
@@ -75,7 +94,7 @@ func (b *BadgerBackend) Init() error {
 		if it.Valid() {
 			key := it.Item().Key()
 			idx := key[1:]
-			serial := binary.BigEndian.Uint32(idx)
+			serial := binary.BigEndian.Uint64(idx)
 			b.serial.Store(serial)
 		}
 		it.Close()
@@ -84,17 +103,83 @@ func (b *BadgerBackend) Init() error {
 		return fmt.Errorf("error initializing serial: %w", err)
 	}
 
+	if b.EnableBloomFilter {
+		if err := b.loadBloomFilter(); err != nil {
+			return fmt.Errorf("error loading bloom filter: %w", err)
+		}
+	}
+
 	return nil
 }
 
+func (b *BadgerBackend) loadBloomFilter() error {
+	return b.DB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.IteratorOptions{
+			Prefix:         []byte{indexIdPrefix},
+			PrefetchValues: false,
+		})
+		defer it.Close()
+
+		prefixes := make([][]byte, 0, 1024)
+		for it.Rewind(); it.Valid(); it.Next() {
+			prefixes = append(prefixes, it.Item().KeyCopy(nil)[1:1+8])
+		}
+
+		// leave some room for growth, past that the filter grows by itself
+		b.bloom = bloom.New(len(prefixes)*2, 0.01)
+		for _, prefix := range prefixes {
+			b.bloom.Add(prefix)
+		}
+
+		return nil
+	})
+}
+
+// Close is CloseContext with a timeout of inflight.CloseTimeout.
 func (b *BadgerBackend) Close() {
-	b.DB.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), inflight.CloseTimeout)
+	defer cancel()
+	if err := b.CloseContext(ctx); err != nil {
+		log.Printf("badger: failed to close: %s\n", err)
+	}
+}
+
+// CloseContext closes the database once the running operations are done.
+// See [eventstore.GracefulCloser].
+func (b *BadgerBackend) CloseContext(ctx context.Context) error {
+	return b.inflight.Close(ctx, b.DB.Close)
+}
+
+// view and update wrap transactions so closing can wait for them.
+func (b *BadgerBackend) view(fn func(txn *badger.Txn) error) error {
+	if err := b.inflight.Acquire(); err != nil {
+		return err
+	}
+	defer b.inflight.Release()
+	return b.DB.View(fn)
 }
 
-func (b *BadgerBackend) Serial() []byte {
-	next := b.serial.Add(1)
-	vb := make([]byte, 5)
-	vb[0] = rawEventStorePrefix
-	binary.BigEndian.PutUint32(vb[1:], next)
-	return vb
+func (b *BadgerBackend) update(fn func(txn *badger.Txn) error) error {
+	if err := b.inflight.Acquire(); err != nil {
+		return err
+	}
+	defer b.inflight.Release()
+	return b.DB.Update(fn)
+}
+
+// Serial returns the key for the next raw event, it fails instead of wrapping around when
+// all the serials have been used.
+func (b *BadgerBackend) Serial() ([]byte, error) {
+	for {
+		last := b.serial.Load()
+		if last == math.MaxUint64 {
+			return nil, eventstore.ErrSerialOverflow
+		}
+		if b.serial.CompareAndSwap(last, last+1) {
+			vb := make([]byte, 1+8)
+			vb[0] = rawEventStorePrefix
+			binary.BigEndian.PutUint64(vb[1:], last+1)
+			return vb, nil
+		}
+	}
 }
@@ -1,106 +1,360 @@
 package badger
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"log"
+	"slices"
 
 	"github.com/dgraph-io/badger/v4"
+	"github.com/fiatjaf/eventstore"
 	bin "github.com/fiatjaf/eventstore/internal/binary"
 	"github.com/nbd-wtf/go-nostr"
 )
 
+type migration struct {
+	version     uint16
+	description string
+	steps       []migrationStep
+}
+
+// migrationStep either has a run function, called once, or a forEach function, called for every
+// raw event in transactions of MigrationBatchSize events. run must be safe to call again, as it
+// will be if the process stops before the step is marked as done.
+type migrationStep struct {
+	run     func() error
+	forEach func(txn *badger.Txn, idx []byte, val []byte) error
+
+	// reindex is set on the steps made by reindexSteps, and reindexAll when they rebuild every index
+	reindex, reindexAll bool
+}
+
+// reindexesAll tells if the migration rebuilds every index from the raw events.
+func (m migration) reindexesAll() bool {
+	return slices.ContainsFunc(m.steps, func(step migrationStep) bool { return step.reindexAll })
+}
+
+// onlyReindexes tells if all the migration does is rebuilding some indexes.
+func (m migration) onlyReindexes() bool {
+	return !slices.ContainsFunc(m.steps, func(step migrationStep) bool { return !step.reindex })
+}
+
+// checkpoint is saved along with each batch of a migration, so it can resume from there.
+type checkpoint struct {
+	version uint16
+	step    uint8
+	done    uint64
+	last    []byte
+}
+
+func (b *BadgerBackend) migrations() []migration {
+	return []migration{
+		// the 5 first migrations go to trash because on version 5 we need to export and import all the data anyway
+		{5, "delete all indexes and recreate them", b.reindexSteps(bin.Unmarshal)},
+		{6, "rewrite raw events using the v2 binary encoding", []migrationStep{
+			{forEach: func(txn *badger.Txn, idx []byte, val []byte) error {
+				evt := &nostr.Event{}
+				if err := bin.Unmarshal(val, evt); err != nil {
+					return fmt.Errorf("error decoding event: %w", err)
+				}
+				encoded, err := bin.Encode(evt)
+				if err != nil {
+					return fmt.Errorf("error encoding event %s: %w", evt.ID, err)
+				}
+				return txn.Set(idx, encoded)
+			}},
+		}},
+		{7, "use 8-byte serials as raw event keys", append([]migrationStep{
+			// the new keys all sort before the old ones (serials start at 1), so we never see them
+			// again as we go forward
+			{forEach: func(txn *badger.Txn, idx []byte, val []byte) error {
+				if len(idx) != 1+4 {
+					return nil
+				}
+				newIdx := make([]byte, 1+8)
+				newIdx[0] = rawEventStorePrefix
+				copy(newIdx[1+4:], idx[1:])
+				if err := txn.Set(newIdx, val); err != nil {
+					return fmt.Errorf("failed to move event to %x: %w", newIdx, err)
+				}
+				return txn.Delete(idx)
+			}},
+		}, b.reindexSteps(bin.Decode)...)}, // all the indexes end with the old keys
+		{8, "use 4 bytes for kinds in indexes", b.reindexSteps(bin.Decode, indexKindPrefix, indexPubkeyKindPrefix)},
+	}
+}
+
+// runMigrations brings the database to the latest version, resuming an interrupted migration
+// from its last checkpoint.
 func (b *BadgerBackend) runMigrations() error {
+	if b.MigrationBatchSize == 0 {
+		b.MigrationBatchSize = 1000
+	}
+
 	var version uint16
-	if err := b.View(func(txn *badger.Txn) error {
+	var cp checkpoint
+	if err := b.DB.View(func(txn *badger.Txn) error {
 		item, err := txn.Get([]byte{dbVersionKey})
-		if err == badger.ErrKeyNotFound {
-			return nil
-		} else if err != nil {
+		if err == nil {
+			if err := item.Value(func(val []byte) error {
+				version = binary.BigEndian.Uint16(val)
+				return nil
+			}); err != nil {
+				return err
+			}
+		} else if err != badger.ErrKeyNotFound {
 			return err
 		}
-		return item.Value(func(val []byte) error {
-			version = binary.BigEndian.Uint16(val)
-			return nil
-		})
+
+		item, err = txn.Get([]byte{migrationCheckpointKey})
+		if err == nil {
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if len(val) < 2+1+8 {
+				return fmt.Errorf("%w: migration checkpoint is too short", eventstore.ErrCorrupted)
+			}
+			cp.version = binary.BigEndian.Uint16(val[0:2])
+			cp.step = val[2]
+			cp.done = binary.BigEndian.Uint64(val[3:11])
+			if len(val) > 11 {
+				cp.last = val[11:]
+			}
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		return nil
 	}); err != nil {
 		return err
 	}
 
+	// a full reindex writes the indexes the way the current code does, so after one of those the
+	// migrations that only rebuild some indexes have nothing left to do
+	var reindexed bool
+
 	// do the migrations in increasing steps (there is no rollback)
-	//
+	for _, m := range b.migrations() {
+		if m.version <= version {
+			continue
+		}
 
-	// the 5 first migrations go to trash because on version 5 we need to export and import all the data anyway
-	if version < 5 {
-		log.Println("[badger] migration 5: delete all indexes and recreate them")
+		if reindexed && m.onlyReindexes() {
+			log.Printf("[badger] migration %d: %s (already done by the reindex above)\n", m.version, m.description)
+			if err := b.DB.Update(func(txn *badger.Txn) error {
+				return b.bumpVersion(txn, m.version)
+			}); err != nil {
+				return err
+			}
+			continue
+		}
 
-		// delete all index entries (in a WriteBatch so we don't blow past the txn size limit)
-		prefixes := []byte{
-			indexIdPrefix,
-			indexCreatedAtPrefix,
-			indexKindPrefix,
-			indexPubkeyPrefix,
-			indexPubkeyKindPrefix,
-			indexTagPrefix,
-			indexTag32Prefix,
-			indexTagAddrPrefix,
+		start := checkpoint{version: m.version}
+		if cp.version == m.version {
+			start = cp
+			log.Printf("[badger] migration %d: %s (resuming from step %d, after %d events)\n",
+				m.version, m.description, cp.step+1, cp.done)
+		} else {
+			log.Printf("[badger] migration %d: %s\n", m.version, m.description)
 		}
 
-		wb := b.NewWriteBatch()
-		for _, prefix := range prefixes {
-			err := b.View(func(txn *badger.Txn) error {
-				it := txn.NewIterator(badger.IteratorOptions{
-					PrefetchValues: false,
-					Prefix:         []byte{prefix},
-				})
-				defer it.Close()
-
-				for it.Seek([]byte{prefix}); it.ValidForPrefix([]byte{prefix}); it.Next() {
-					key := it.Item().KeyCopy(nil)
-					if err := wb.Delete(key); err != nil {
-						return fmt.Errorf("failed to delete index key %x: %w", key, err)
-					}
+		for s := int(start.step); s < len(m.steps); s++ {
+			step := m.steps[s]
+			if step.forEach != nil {
+				if err := b.migrateEvents(m, s, start); err != nil {
+					return fmt.Errorf("migration %d failed: %w", m.version, err)
 				}
-				return nil
-			})
-			if err != nil {
-				wb.Cancel()
+			} else {
+				if err := step.run(); err != nil {
+					return fmt.Errorf("migration %d failed: %w", m.version, err)
+				}
+				if err := b.DB.Update(func(txn *badger.Txn) error {
+					return b.setCheckpoint(txn, checkpoint{version: m.version, step: uint8(s + 1)})
+				}); err != nil {
+					return err
+				}
+				b.reportMigrationProgress(m, s, 0, 0)
+			}
+			start = checkpoint{version: m.version}
+		}
+		reindexed = reindexed || m.reindexesAll()
+
+		// bump version
+		if err := b.DB.Update(func(txn *badger.Txn) error {
+			if err := txn.Delete([]byte{migrationCheckpointKey}); err != nil {
 				return err
 			}
+			return b.bumpVersion(txn, m.version)
+		}); err != nil {
+			return err
 		}
-		if err := wb.Flush(); err != nil {
-			return fmt.Errorf("failed to flush index deletions on migration 5: %w", err)
+	}
+
+	return nil
+}
+
+// migrateEvents calls the forEach function of a step for every raw event after the checkpoint,
+// committing and saving a new checkpoint every MigrationBatchSize events.
+func (b *BadgerBackend) migrateEvents(m migration, s int, from checkpoint) error {
+	prefix := []byte{rawEventStorePrefix}
+
+	var total int64
+	if err := b.DB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.IteratorOptions{
+			PrefetchValues: false,
+			Prefix:         prefix,
+		})
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			total++
 		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	next := from
+	for {
+		finished := false
+		if err := b.DB.Update(func(txn *badger.Txn) error {
+			finished = false
+			cp := next
 
-		// recreate indexes (also in a WriteBatch)
-		wb = b.NewWriteBatch()
-		err := b.View(func(txn *badger.Txn) error {
 			it := txn.NewIterator(badger.IteratorOptions{
 				PrefetchValues: true,
-				Prefix:         []byte{rawEventStorePrefix},
+				Prefix:         prefix,
 			})
 			defer it.Close()
 
-			for it.Seek([]byte{rawEventStorePrefix}); it.ValidForPrefix([]byte{rawEventStorePrefix}); it.Next() {
+			if cp.last == nil {
+				it.Seek(prefix)
+			} else {
+				it.Seek(cp.last)
+				if it.ValidForPrefix(prefix) && bytes.Equal(it.Item().Key(), cp.last) {
+					it.Next()
+				}
+			}
+
+			for n := 0; it.ValidForPrefix(prefix) && n < b.MigrationBatchSize; n++ {
 				item := it.Item()
 				idx := item.KeyCopy(nil)
+				val, err := item.ValueCopy(nil)
+				if err != nil {
+					return fmt.Errorf("failed to read event %x: %w", idx, err)
+				}
+				if err := m.steps[s].forEach(txn, idx, val); err != nil {
+					return fmt.Errorf("event %x: %w", idx, err)
+				}
+				cp.done++
+				cp.last = idx
+
+				it.Next()
+			}
+
+			var err error
+			if !it.ValidForPrefix(prefix) {
+				// went through all the events
+				finished = true
+				err = b.setCheckpoint(txn, checkpoint{version: m.version, step: uint8(s + 1)})
+			} else {
+				err = b.setCheckpoint(txn, cp)
+			}
+			if err != nil {
+				return err
+			}
 
-				err := item.Value(func(val []byte) error {
-					evt := &nostr.Event{}
-					if err := bin.Unmarshal(val, evt); err != nil {
-						return fmt.Errorf("error decoding event %x on migration 5: %w", idx, err)
-					}
+			next = cp
+			return nil
+		}); err != nil {
+			return err
+		}
 
-					for key := range b.getIndexKeysForEvent(evt, idx[1:]) {
-						if err := wb.Set(key, nil); err != nil {
-							return fmt.Errorf("failed to save index for event %s on migration 5: %w", evt.ID, err)
-						}
-					}
+		b.reportMigrationProgress(m, s, int64(next.done), max(total, int64(next.done)))
+		if finished {
+			return nil
+		}
+	}
+}
 
-					return nil
-				})
-				if err != nil {
-					return err
+func (b *BadgerBackend) reportMigrationProgress(m migration, s int, done int64, total int64) {
+	if b.OnMigrationProgress != nil {
+		b.OnMigrationProgress(eventstore.MigrationProgress{
+			Version:     int(m.version),
+			Description: m.description,
+			Step:        s + 1,
+			Steps:       len(m.steps),
+			Done:        done,
+			Total:       total,
+		})
+	}
+}
+
+func (b *BadgerBackend) setCheckpoint(txn *badger.Txn, cp checkpoint) error {
+	buf := make([]byte, 2+1+8, 2+1+8+len(cp.last))
+	binary.BigEndian.PutUint16(buf[0:2], cp.version)
+	buf[2] = cp.step
+	binary.BigEndian.PutUint64(buf[3:11], cp.done)
+	buf = append(buf, cp.last...)
+	return txn.Set([]byte{migrationCheckpointKey}, buf)
+}
+
+// reindexSteps deletes the entries of the indexes with the given prefixes (or of all of them if none
+// is given) and recreates them from the raw events, which are read with decode as the encoding
+// depends on the migration we're in.
+func (b *BadgerBackend) reindexSteps(decode func([]byte, *nostr.Event) error, prefixes ...byte) []migrationStep {
+	all := len(prefixes) == 0
+	if all {
+		prefixes = []byte{
+			indexIdPrefix,
+			indexCreatedAtPrefix,
+			indexKindPrefix,
+			indexPubkeyPrefix,
+			indexPubkeyKindPrefix,
+			indexTagPrefix,
+			indexTag32Prefix,
+			indexTagAddrPrefix,
+		}
+	}
+
+	return []migrationStep{
+		{reindex: true, reindexAll: all, run: func() error { return b.deleteIndexes(prefixes) }},
+		{reindex: true, reindexAll: all, forEach: func(txn *badger.Txn, idx []byte, val []byte) error {
+			evt := &nostr.Event{}
+			if err := decode(val, evt); err != nil {
+				return fmt.Errorf("error decoding event: %w", err)
+			}
+
+			for key := range b.getIndexKeysForEvent(evt, idx[1:]) {
+				if !slices.Contains(prefixes, key[0]) {
+					continue
+				}
+				if err := txn.Set(key, nil); err != nil {
+					return fmt.Errorf("failed to save index for event %s: %w", evt.ID, err)
+				}
+			}
+			return nil
+		}},
+	}
+}
+
+// deleteIndexes deletes all the entries with the given prefixes, in a WriteBatch so we don't blow
+// past the transaction size limit.
+func (b *BadgerBackend) deleteIndexes(prefixes []byte) error {
+	wb := b.NewWriteBatch()
+	for _, prefix := range prefixes {
+		err := b.DB.View(func(txn *badger.Txn) error {
+			it := txn.NewIterator(badger.IteratorOptions{
+				PrefetchValues: false,
+				Prefix:         []byte{prefix},
+			})
+			defer it.Close()
+
+			for it.Seek([]byte{prefix}); it.ValidForPrefix([]byte{prefix}); it.Next() {
+				key := it.Item().KeyCopy(nil)
+				if err := wb.Delete(key); err != nil {
+					return fmt.Errorf("failed to delete index key %x: %w", key, err)
 				}
 			}
 			return nil
@@ -109,16 +363,9 @@ func (b *BadgerBackend) runMigrations() error {
 			wb.Cancel()
 			return err
 		}
-		if err := wb.Flush(); err != nil {
-			return fmt.Errorf("failed to flush index creation on migration 5: %w", err)
-		}
-
-		// bump version
-		if err := b.Update(func(txn *badger.Txn) error {
-			return b.bumpVersion(txn, 5)
-		}); err != nil {
-			return err
-		}
+	}
+	if err := wb.Flush(); err != nil {
+		return fmt.Errorf("failed to flush index deletions: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,283 @@
+package badger
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/fiatjaf/eventstore"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationToV2Encoding(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/badgertest-migration"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db := &BadgerBackend{Path: path}
+	require.NoError(t, db.Init())
+
+	// write events the way older versions did
+	sk := nostr.GeneratePrivateKey()
+	events := make([]*nostr.Event, 10)
+	require.NoError(t, db.DB.Update(func(txn *badger.Txn) error {
+		for i := range events {
+			evt := &nostr.Event{CreatedAt: nostr.Timestamp(1000 + i), Kind: 1, Tags: nostr.Tags{{"t", "old"}}, Content: "old"}
+			evt.Sign(sk)
+			events[i] = evt
+
+			val, err := bin.Marshal(evt)
+			require.NoError(t, err)
+			idx, err := db.Serial()
+			require.NoError(t, err)
+			require.NoError(t, txn.Set(idx, val))
+			for k := range db.getIndexKeysForEvent(evt, idx[1:]) {
+				require.NoError(t, txn.Set(k, nil))
+			}
+		}
+		return db.bumpVersion(txn, 5)
+	}))
+	db.Close()
+
+	db = &BadgerBackend{Path: path}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Tags: nostr.TagMap{"t": []string{"old"}}})
+	require.NoError(t, err)
+	require.Len(t, res, len(events))
+	for i, evt := range res {
+		require.Equal(t, events[len(events)-1-i].ID, evt.ID)
+		ok, _ := evt.CheckSignature()
+		require.True(t, ok)
+	}
+}
+
+func TestMigrationTo8ByteSerials(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/badgertest-migration-serials"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db := &BadgerBackend{Path: path}
+	require.NoError(t, db.Init())
+
+	// write events with 4-byte serials like older versions did
+	sk := nostr.GeneratePrivateKey()
+	events := make([]*nostr.Event, 10)
+	require.NoError(t, db.DB.Update(func(txn *badger.Txn) error {
+		for i := range events {
+			evt := &nostr.Event{CreatedAt: nostr.Timestamp(1000 + i), Kind: 1, Tags: nostr.Tags{{"t", "old"}}, Content: "old"}
+			evt.Sign(sk)
+			events[i] = evt
+
+			val, err := bin.Encode(evt)
+			require.NoError(t, err)
+			idx := binary.BigEndian.AppendUint32([]byte{rawEventStorePrefix}, uint32(300+i))
+			require.NoError(t, txn.Set(idx, val))
+			for k := range db.getIndexKeysForEvent(evt, idx[1:]) {
+				require.NoError(t, txn.Set(k[0:len(k)-4], nil))
+			}
+		}
+		return db.bumpVersion(txn, 6)
+	}))
+	db.Close()
+
+	// small batches so the events are moved over many transactions
+	var progress []eventstore.MigrationProgress
+	db = &BadgerBackend{
+		Path:                path,
+		MigrationBatchSize:  3,
+		OnMigrationProgress: func(p eventstore.MigrationProgress) { progress = append(progress, p) },
+	}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	// the kind indexes were rebuilt along with all the others, so migration 8 had nothing to do
+	require.NotEmpty(t, progress)
+	for _, p := range progress {
+		require.NotEqual(t, 8, p.Version)
+	}
+
+	res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Tags: nostr.TagMap{"t": []string{"old"}}})
+	require.NoError(t, err)
+	require.Len(t, res, len(events))
+	for i, evt := range res {
+		require.Equal(t, events[len(events)-1-i].ID, evt.ID)
+	}
+
+	// new events continue after the highest old serial
+	evt := &nostr.Event{CreatedAt: 2000, Kind: 1, Tags: nostr.Tags{}, Content: "new"}
+	evt.Sign(sk)
+	require.NoError(t, db.SaveEvent(ctx, evt))
+	require.NoError(t, db.DeleteEvent(ctx, events[0]))
+	require.NoError(t, db.DB.View(func(txn *badger.Txn) error {
+		id, _ := hex.DecodeString(evt.ID)
+		idx, err := getIdxForId(txn, id)
+		require.Equal(t, binary.BigEndian.AppendUint64([]byte{rawEventStorePrefix}, 310), idx)
+		return err
+	}))
+
+	res, err = eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Kinds: []int{1}})
+	require.NoError(t, err)
+	require.Len(t, res, len(events))
+	require.Equal(t, evt.ID, res[0].ID)
+}
+
+func TestSerialOverflow(t *testing.T) {
+	path := "/tmp/badgertest-serial-overflow"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db := &BadgerBackend{Path: path}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	db.serial.Store(math.MaxUint64 - 1)
+
+	evt := &nostr.Event{CreatedAt: 1000, Kind: 1, Tags: nostr.Tags{}}
+	evt.Sign(nostr.GeneratePrivateKey())
+	require.NoError(t, db.SaveEvent(context.Background(), evt))
+
+	evt = &nostr.Event{CreatedAt: 1001, Kind: 1, Tags: nostr.Tags{}}
+	evt.Sign(nostr.GeneratePrivateKey())
+	require.ErrorIs(t, db.SaveEvent(context.Background(), evt), eventstore.ErrSerialOverflow)
+}
+
+func TestMigrationTo4ByteKinds(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/badgertest-migration-kinds"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db := &BadgerBackend{Path: path}
+	require.NoError(t, db.Init())
+
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	events := make([]*nostr.Event, 10)
+	for i := range events {
+		evt := &nostr.Event{CreatedAt: nostr.Timestamp(1000 + i), Kind: 1 + i%2, Tags: nostr.Tags{}, Content: "old"}
+		evt.Sign(sk)
+		events[i] = evt
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+
+	// rewrite the kind indexes with 2-byte kinds like older versions did
+	require.NoError(t, db.DB.DropPrefix([]byte{indexKindPrefix}, []byte{indexPubkeyKindPrefix}))
+	require.NoError(t, db.DB.Update(func(txn *badger.Txn) error {
+		for _, evt := range events {
+			id, _ := hex.DecodeString(evt.ID)
+			idx, err := getIdxForId(txn, id)
+			require.NoError(t, err)
+
+			k := binary.BigEndian.AppendUint16([]byte{indexKindPrefix}, uint16(evt.Kind))
+			k = binary.BigEndian.AppendUint32(k, uint32(evt.CreatedAt))
+			require.NoError(t, txn.Set(append(k, idx[1:]...), nil))
+
+			pkb, _ := hex.DecodeString(evt.PubKey[0 : 8*2])
+			k = append([]byte{indexPubkeyKindPrefix}, pkb...)
+			k = binary.BigEndian.AppendUint16(k, uint16(evt.Kind))
+			k = binary.BigEndian.AppendUint32(k, uint32(evt.CreatedAt))
+			require.NoError(t, txn.Set(append(k, idx[1:]...), nil))
+		}
+		return db.bumpVersion(txn, 7)
+	}))
+	db.Close()
+
+	db = &BadgerBackend{Path: path}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	for _, filter := range []nostr.Filter{{Kinds: []int{2}}, {Kinds: []int{2}, Authors: []string{pk}}} {
+		res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, filter)
+		require.NoError(t, err)
+		require.Len(t, res, len(events)/2)
+		for _, evt := range res {
+			require.Equal(t, 2, evt.Kind)
+		}
+	}
+}
+
+func TestMigrationResume(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/badgertest-migration-resume"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db := &BadgerBackend{Path: path}
+	require.NoError(t, db.Init())
+
+	sk := nostr.GeneratePrivateKey()
+	events := make([]*nostr.Event, 10)
+	for i := range events {
+		evt := &nostr.Event{CreatedAt: nostr.Timestamp(1000 + i), Kind: 1 + i%2, Tags: nostr.Tags{}, Content: "old"}
+		evt.Sign(sk)
+		events[i] = evt
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+
+	// pretend we stopped in the middle of migration 8, with only the first 4 events reindexed
+	require.NoError(t, db.DB.DropPrefix([]byte{indexKindPrefix}, []byte{indexPubkeyKindPrefix}))
+	require.NoError(t, db.DB.Update(func(txn *badger.Txn) error {
+		for _, evt := range events[0:4] {
+			id, _ := hex.DecodeString(evt.ID)
+			idx, err := getIdxForId(txn, id)
+			require.NoError(t, err)
+			for k := range db.getIndexKeysForEvent(evt, idx[1:]) {
+				if k[0] == indexKindPrefix || k[0] == indexPubkeyKindPrefix {
+					require.NoError(t, txn.Set(k, nil))
+				}
+			}
+		}
+		require.NoError(t, db.bumpVersion(txn, 7))
+		return db.setCheckpoint(txn, checkpoint{
+			version: 8,
+			step:    1,
+			done:    4,
+			last:    binary.BigEndian.AppendUint64([]byte{rawEventStorePrefix}, 4),
+		})
+	}))
+	db.Close()
+
+	var progress []eventstore.MigrationProgress
+	db = &BadgerBackend{
+		Path:                path,
+		MigrationBatchSize:  4,
+		OnMigrationProgress: func(p eventstore.MigrationProgress) { progress = append(progress, p) },
+	}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	// the step that deletes the indexes didn't run again, and we went on from the fifth event
+	require.Len(t, progress, 2)
+	for _, p := range progress {
+		require.Equal(t, 8, p.Version)
+		require.Equal(t, 2, p.Step)
+		require.Equal(t, 2, p.Steps)
+		require.Equal(t, int64(10), p.Total)
+	}
+	require.Equal(t, int64(8), progress[0].Done)
+	require.Equal(t, int64(10), progress[1].Done)
+
+	report, err := db.Verify(ctx, eventstore.VerifyOptions{})
+	require.NoError(t, err)
+	require.True(t, report.OK(), report.Problems)
+
+	require.NoError(t, db.DB.View(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte{migrationCheckpointKey})
+		require.ErrorIs(t, err, badger.ErrKeyNotFound)
+		return nil
+	}))
+
+	res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Kinds: []int{2}})
+	require.NoError(t, err)
+	require.Len(t, res, len(events)/2)
+}
@@ -1,11 +1,13 @@
 package badger
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"iter"
 	"log"
 
 	"github.com/dgraph-io/badger/v4"
@@ -13,7 +15,6 @@ import (
 	"github.com/fiatjaf/eventstore/internal"
 	bin "github.com/fiatjaf/eventstore/internal/binary"
 	"github.com/nbd-wtf/go-nostr"
-	"golang.org/x/exp/slices"
 )
 
 var batchFilled = errors.New("batch-filled")
@@ -21,12 +22,71 @@ var batchFilled = errors.New("batch-filled")
 func (b *BadgerBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (chan *nostr.Event, error) {
 	ch := make(chan *nostr.Event)
 
-	if filter.Search != "" {
+	limit := b.getLimit(ctx, filter)
+	if limit == 0 {
 		close(ch)
 		return ch, nil
 	}
 
-	// max number of events we'll return
+	// fmt.Println("limit", limit)
+
+	// collect everything first so the transaction is released before the results are consumed
+	var results []internal.IterEvent
+	if err := b.view(func(txn *badger.Txn) error {
+		var err error
+		results, err = b.query(txn, filter, limit, false)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer close(ch)
+		for _, evt := range results {
+			select {
+			case ch <- evt.Event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// QueryIDs is like QueryEvents, but only the id and created_at of each event are read from the raw values.
+// Events are only fully decoded when there are tags that must be checked and couldn't be used as an index.
+func (b *BadgerBackend) QueryIDs(ctx context.Context, filter nostr.Filter) iter.Seq2[eventstore.IDTimestamp, error] {
+	return func(yield func(eventstore.IDTimestamp, error) bool) {
+		limit := b.getLimit(ctx, filter)
+		if limit == 0 {
+			return
+		}
+
+		var results []internal.IterEvent
+		if err := b.view(func(txn *badger.Txn) error {
+			var err error
+			results, err = b.query(txn, filter, limit, true)
+			return err
+		}); err != nil {
+			yield(eventstore.IDTimestamp{}, err)
+			return
+		}
+
+		for _, ie := range results {
+			if !yield(eventstore.IDTimestamp{ID: ie.ID, CreatedAt: ie.CreatedAt}, nil) {
+				return
+			}
+		}
+	}
+}
+
+// getLimit returns the maximum number of events we'll return for this filter, 0 means nothing should be returned
+func (b *BadgerBackend) getLimit(ctx context.Context, filter nostr.Filter) int {
+	if filter.Search != "" {
+		return 0
+	}
+
 	maxLimit := b.MaxLimit
 	var limit int
 	if eventstore.IsNegentropySession(ctx) {
@@ -38,34 +98,16 @@ func (b *BadgerBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (c
 	if filter.Limit > 0 && filter.Limit <= maxLimit {
 		limit = filter.Limit
 	}
-	if tlimit := nostr.GetTheoreticalLimit(filter); tlimit == 0 {
-		close(ch)
-		return ch, nil
-	} else if tlimit > 0 {
+	if tlimit := nostr.GetTheoreticalLimit(filter); tlimit >= 0 {
 		limit = tlimit
 	}
 
-	// fmt.Println("limit", limit)
-
-	go b.View(func(txn *badger.Txn) error {
-		defer close(ch)
-
-		results, err := b.query(txn, filter, limit)
-		if err != nil {
-			return err
-		}
-
-		for _, evt := range results {
-			ch <- evt.Event
-		}
-
-		return nil
-	})
-
-	return ch, nil
+	return limit
 }
 
-func (b *BadgerBackend) query(txn *badger.Txn, filter nostr.Filter, limit int) ([]internal.IterEvent, error) {
+// query runs the given filter inside txn. when idsOnly is true the returned events will only have
+// their ID and CreatedAt fields set (unless we had to decode them for some reason).
+func (b *BadgerBackend) query(txn *badger.Txn, filter nostr.Filter, limit int, idsOnly bool) ([]internal.IterEvent, error) {
 	queries, extraFilter, since, err := prepareQueries(filter)
 	if err != nil {
 		return nil, err
@@ -118,7 +160,7 @@ func (b *BadgerBackend) query(txn *badger.Txn, filter nostr.Filter, limit int) (
 	}
 
 	// we will reuse this throughout the iteration
-	valIdx := make([]byte, 5)
+	valIdx := make([]byte, 1+8)
 
 	// fmt.Println("queries", len(queries))
 
@@ -149,7 +191,7 @@ func (b *BadgerBackend) query(txn *badger.Txn, filter nostr.Filter, limit int) (
 				item := it.Item()
 				key := item.Key()
 
-				idxOffset := len(key) - 4 // this is where the idx actually starts
+				idxOffset := len(key) - 8 // this is where the idx actually starts
 
 				// "id" indexes don't contain a timestamp
 				if !query.skipTimestamp {
@@ -176,32 +218,32 @@ func (b *BadgerBackend) query(txn *badger.Txn, filter nostr.Filter, limit int) (
 				}
 
 				if err := item.Value(func(val []byte) error {
-					// fmt.Println("      event", hex.EncodeToString(val[0:4]), "kind", binary.BigEndian.Uint16(val[132:134]), "author", hex.EncodeToString(val[32:36]), "ts", nostr.Timestamp(binary.BigEndian.Uint32(val[128:132])))
+					v, err := bin.NewView(val)
+					if err != nil {
+						log.Printf("badger: value read error (idx %x): %s\n", valIdx, err)
+						return fmt.Errorf("%w: event %x can't be read: %w", eventstore.ErrCorrupted, valIdx[1:], err)
+					}
 
-					// check it against pubkeys without decoding the entire thing
-					if extraFilter != nil && extraFilter.Authors != nil &&
-						!slices.Contains(extraFilter.Authors, hex.EncodeToString(val[32:64])) {
-						// fmt.Println("        skipped (authors)")
+					// check if this is really the event we want, not only one with the same id prefix
+					if query.fullId != nil && !bytes.Equal(v.ID(), query.fullId) {
 						return nil
 					}
 
-					// check it against kinds without decoding the entire thing
-					if extraFilter != nil && extraFilter.Kinds != nil &&
-						!slices.Contains(extraFilter.Kinds, int(binary.BigEndian.Uint16(val[132:134]))) {
-						// fmt.Println("        skipped (kinds)")
+					// check if this matches the other filters that were not part of the index
+					// (without decoding the entire thing)
+					if extraFilter != nil && !viewMatchesFilter(extraFilter, v) {
+						// fmt.Println("        skipped (filter)", extraFilter)
 						return nil
 					}
 
+					// only now that we know we want this event we decode it
 					event := &nostr.Event{}
-					if err := bin.Unmarshal(val, event); err != nil {
-						log.Printf("badger: value read error (id %x): %s\n", val[0:32], err)
-						return err
-					}
-
-					// check if this matches the other filters that were not part of the index
-					if extraFilter != nil && !filterMatchesTags(extraFilter, event) {
-						// fmt.Println("        skipped (filter)", extraFilter, event)
-						return nil
+					if idsOnly {
+						// we don't need anything else, so just read the id and the timestamp
+						event.ID = hex.EncodeToString(v.ID())
+						event.CreatedAt = v.CreatedAt()
+					} else {
+						v.Decode(event)
 					}
 
 					// this event is good to be used
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"math"
 
 	"github.com/fiatjaf/eventstore/internal"
 	"github.com/nbd-wtf/go-nostr"
@@ -14,6 +15,9 @@ type query struct {
 	prefix        []byte
 	startingPoint []byte
 	skipTimestamp bool
+
+	// only on id queries, since the index only has the first 8 bytes of the id
+	fullId []byte
 }
 
 func prepareQueries(filter nostr.Filter) (
@@ -36,7 +40,12 @@ func prepareQueries(filter nostr.Filter) (
 		}
 
 		for i, q := range queries {
-			queries[i].startingPoint = binary.BigEndian.AppendUint32(q.prefix, uint32(until))
+			if q.skipTimestamp {
+				// id index keys end with the idx, not a timestamp, so start from the very end of the prefix
+				queries[i].startingPoint = binary.BigEndian.AppendUint64(q.prefix, math.MaxUint64)
+			} else {
+				queries[i].startingPoint = binary.BigEndian.AppendUint32(q.prefix, uint32(until))
+			}
 		}
 
 		// this is where we'll end the iteration
@@ -52,18 +61,28 @@ func prepareQueries(filter nostr.Filter) (
 	if len(filter.IDs) > 0 {
 		queries = make([]query, len(filter.IDs))
 		for i, idHex := range filter.IDs {
-			prefix := make([]byte, 1+8)
-			prefix[0] = indexIdPrefix
 			if len(idHex) != 64 {
 				return nil, nil, 0, fmt.Errorf("invalid id '%s'", idHex)
 			}
-			hex.Decode(prefix[1:], []byte(idHex[0:8*2]))
-			queries[i] = query{i: i, prefix: prefix, skipTimestamp: true}
+			id := make([]byte, 32)
+			if _, err := hex.Decode(id, []byte(idHex)); err != nil {
+				return nil, nil, 0, fmt.Errorf("invalid id '%s'", idHex)
+			}
+			prefix := make([]byte, 1+8)
+			prefix[0] = indexIdPrefix
+			copy(prefix[1:], id[0:8])
+			queries[i] = query{i: i, prefix: prefix, skipTimestamp: true, fullId: id}
 		}
 
 		return queries, extraFilter, since, nil
 	}
 
+	if filter.Kinds != nil {
+		if filter.Kinds = internal.StorableKinds(filter.Kinds); len(filter.Kinds) == 0 {
+			return []query{}, nil, 0, nil
+		}
+	}
+
 	if len(filter.Tags) > 0 {
 		// we will select ONE tag to query with
 		tagKey, tagValues, goodness := internal.ChooseNarrowestTag(filter)
@@ -114,10 +133,10 @@ pubkeyMatching:
 						return nil, nil, 0, fmt.Errorf("invalid pubkey '%s'", pubkeyHex)
 					}
 
-					prefix := make([]byte, 1+8+2)
+					prefix := make([]byte, 1+8+4)
 					prefix[0] = indexPubkeyKindPrefix
 					hex.Decode(prefix[1:], []byte(pubkeyHex[0:8*2]))
-					binary.BigEndian.PutUint16(prefix[1+8:], uint16(kind))
+					binary.BigEndian.PutUint32(prefix[1+8:], uint32(kind))
 					queries[i] = query{i: i, prefix: prefix}
 					i++
 				}
@@ -128,9 +147,9 @@ pubkeyMatching:
 		index = indexKindPrefix
 		queries = make([]query, len(filter.Kinds))
 		for i, kind := range filter.Kinds {
-			prefix := make([]byte, 1+2)
+			prefix := make([]byte, 1+4)
 			prefix[0] = index
-			binary.BigEndian.PutUint16(prefix[1:], uint16(kind))
+			binary.BigEndian.PutUint32(prefix[1:], uint32(kind))
 			queries[i] = query{i: i, prefix: prefix}
 		}
 		extraFilter = &nostr.Filter{Tags: filter.Tags}
@@ -0,0 +1,22 @@
+package badger
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/fiatjaf/eventstore"
+)
+
+func init() {
+	eventstore.Register(eventstore.Driver{
+		Name:    "badger",
+		Schemes: []string{"badger"},
+		Detect: func(path string) bool {
+			_, err := os.Stat(filepath.Join(path, "MANIFEST"))
+			return err == nil
+		},
+		New: func(uri string, opts eventstore.OpenOptions) (eventstore.Store, error) {
+			return &BadgerBackend{Path: eventstore.TrimScheme(uri, "badger"), MaxLimit: opts.MaxLimit}, nil
+		},
+	})
+}
@@ -12,11 +12,11 @@ import (
 
 func (b *BadgerBackend) ReplaceEvent(ctx context.Context, evt *nostr.Event) error {
 	// sanity checking
-	if evt.CreatedAt > math.MaxUint32 || evt.Kind > math.MaxUint16 {
+	if evt.CreatedAt > math.MaxUint32 || evt.Kind < 0 || evt.Kind > math.MaxUint32 {
 		return fmt.Errorf("event with values out of expected boundaries")
 	}
 
-	return b.Update(func(txn *badger.Txn) error {
+	return b.update(func(txn *badger.Txn) error {
 		filter := nostr.Filter{Limit: 1, Kinds: []int{evt.Kind}, Authors: []string{evt.PubKey}}
 		if nostr.IsAddressableKind(evt.Kind) {
 			// when addressable, add the "d" tag to the filter
@@ -24,7 +24,7 @@ func (b *BadgerBackend) ReplaceEvent(ctx context.Context, evt *nostr.Event) erro
 		}
 
 		// now we fetch the past events, whatever they are, delete them and then save the new
-		results, err := b.query(txn, filter, 10) // in theory limit could be just 1 and this should work
+		results, err := b.query(txn, filter, 10, false) // in theory limit could be just 1 and this should work
 		if err != nil {
 			return fmt.Errorf("failed to query past events with %s: %w", filter, err)
 		}
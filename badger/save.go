@@ -14,36 +14,40 @@ import (
 
 func (b *BadgerBackend) SaveEvent(ctx context.Context, evt *nostr.Event) error {
 	// sanity checking
-	if evt.CreatedAt > math.MaxUint32 || evt.Kind > math.MaxUint16 {
+	if evt.CreatedAt > math.MaxUint32 || evt.Kind < 0 || evt.Kind > math.MaxUint32 {
 		return fmt.Errorf("event with values out of expected boundaries")
 	}
 
-	return b.Update(func(txn *badger.Txn) error {
-		// query event by id to ensure we don't save duplicates
-		id, _ := hex.DecodeString(evt.ID)
-		prefix := make([]byte, 1+8)
-		prefix[0] = indexIdPrefix
-		copy(prefix[1:], id)
-		it := txn.NewIterator(badger.IteratorOptions{})
-		defer it.Close()
-		it.Seek(prefix)
-		if it.ValidForPrefix(prefix) {
-			// event exists
-			return eventstore.ErrDupEvent
-		}
-
-		return b.save(txn, evt)
+	return b.update(func(txn *badger.Txn) error {
+		return b.saveEvent(txn, evt)
 	})
 }
 
+// saveEvent does everything SaveEvent does except the sanity checks, inside a transaction we already have.
+func (b *BadgerBackend) saveEvent(txn *badger.Txn, evt *nostr.Event) error {
+	// query event by id to ensure we don't save duplicates
+	id, _ := hex.DecodeString(evt.ID)
+	if idx, err := getIdxForId(txn, id); err != nil {
+		return fmt.Errorf("failed to check for duplicates: %w", err)
+	} else if idx != nil {
+		// event exists
+		return eventstore.ErrDupEvent
+	}
+
+	return b.save(txn, evt)
+}
+
 func (b *BadgerBackend) save(txn *badger.Txn, evt *nostr.Event) error {
 	// encode to binary
-	bin, err := bin.Marshal(evt)
+	bin, err := bin.Encode(evt)
 	if err != nil {
 		return err
 	}
 
-	idx := b.Serial()
+	idx, err := b.Serial()
+	if err != nil {
+		return err
+	}
 	// raw event store
 	if err := txn.Set(idx, bin); err != nil {
 		return err
@@ -55,5 +59,10 @@ func (b *BadgerBackend) save(txn *badger.Txn, evt *nostr.Event) error {
 		}
 	}
 
+	if b.bloom != nil {
+		id, _ := hex.DecodeString(evt.ID)
+		b.bloom.Add(id)
+	}
+
 	return nil
 }
@@ -0,0 +1,94 @@
+package badger
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/fiatjaf/eventstore"
+	"github.com/fiatjaf/eventstore/internal"
+)
+
+var prefixNames = map[byte]string{
+	rawEventStorePrefix:   "raw",
+	indexCreatedAtPrefix:  "created_at",
+	indexIdPrefix:         "id",
+	indexKindPrefix:       "kind",
+	indexPubkeyPrefix:     "pubkey",
+	indexPubkeyKindPrefix: "pubkeyKind",
+	indexTagPrefix:        "tag",
+	indexTag32Prefix:      "tag32",
+	indexTagAddrPrefix:    "tagAddr",
+}
+
+// Stats walks over every key in the database, so it takes longer as the database grows.
+// Index sizes are estimates of the space taken by keys and values before compression.
+func (b *BadgerBackend) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	stats := eventstore.StoreStats{
+		EventsPerKind: make(map[int]int64),
+		Indexes:       make(map[string]eventstore.IndexStats),
+	}
+
+	err := b.view(func(txn *badger.Txn) error {
+		if item, err := txn.Get([]byte{dbVersionKey}); err == nil {
+			if err := item.Value(func(val []byte) error {
+				stats.SchemaVersion = int(binary.BigEndian.Uint16(val))
+				return nil
+			}); err != nil {
+				return err
+			}
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		i := 0
+		for it.Rewind(); it.Valid(); it.Next() {
+			if i++; i%10000 == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+
+			item := it.Item()
+			key := item.Key()
+			name, ok := prefixNames[key[0]]
+			if !ok {
+				continue
+			}
+
+			s := stats.Indexes[name]
+			s.Entries++
+			s.Size += int64(len(key)) + item.ValueSize()
+			stats.Indexes[name] = s
+
+			switch key[0] {
+			case rawEventStorePrefix:
+				stats.Events++
+			case indexKindPrefix:
+				stats.EventsPerKind[int(binary.BigEndian.Uint32(key[1:5]))]++
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return stats, err
+		}
+		return stats, fmt.Errorf("%w: %w", eventstore.ErrStorageUnavailable, err)
+	}
+
+	if !b.DB.Opts().InMemory {
+		if stats.DiskSize, err = internal.DirSize(b.DB.Opts().Dir); err != nil {
+			return stats, fmt.Errorf("failed to get the size of %s: %w", b.DB.Opts().Dir, err)
+		}
+	}
+
+	return stats, nil
+}
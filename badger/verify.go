@@ -0,0 +1,171 @@
+package badger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/fiatjaf/eventstore"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+var _ eventstore.Verifier = (*BadgerBackend)(nil)
+
+// Verify checks that every raw event can be decoded and has all the index keys it should,
+// then that every index key points to an event that would have produced it.
+// Everything is checked on a single snapshot and the fixes are written in a batch at the end,
+// so repairs should be done while nothing else is writing to the database.
+func (b *BadgerBackend) Verify(ctx context.Context, opts eventstore.VerifyOptions) (eventstore.VerifyReport, error) {
+	var report eventstore.VerifyReport
+
+	var wb *badger.WriteBatch
+	if opts.Repair {
+		wb = b.NewWriteBatch()
+		defer wb.Cancel()
+	}
+
+	err := b.view(func(txn *badger.Txn) error {
+		if err := b.verifyEvents(ctx, txn, opts, wb, &report); err != nil {
+			return err
+		}
+		return b.verifyIndexes(ctx, txn, wb, &report)
+	})
+	if err != nil {
+		return report, err
+	}
+
+	if opts.Repair {
+		if err := wb.Flush(); err != nil {
+			return report, fmt.Errorf("%w: failed to write repairs: %w", eventstore.ErrStorageUnavailable, err)
+		}
+		report.Repaired = true
+	}
+
+	return report, nil
+}
+
+func (b *BadgerBackend) verifyEvents(ctx context.Context, txn *badger.Txn, opts eventstore.VerifyOptions, wb *badger.WriteBatch, report *eventstore.VerifyReport) error {
+	it := txn.NewIterator(badger.IteratorOptions{
+		PrefetchValues: true,
+		Prefix:         []byte{rawEventStorePrefix},
+	})
+	defer it.Close()
+
+	for it.Seek([]byte{rawEventStorePrefix}); it.ValidForPrefix([]byte{rawEventStorePrefix}); it.Next() {
+		if report.Events%10000 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		report.Events++
+
+		item := it.Item()
+		idx := item.KeyCopy(nil)
+
+		evt := &nostr.Event{}
+		if err := item.Value(func(val []byte) error { return bin.Decode(val, evt) }); err != nil {
+			report.Undecodable++
+			report.AddProblem("event %x can't be decoded: %s", idx[1:], err)
+			if wb != nil {
+				if err := wb.Delete(idx); err != nil {
+					return fmt.Errorf("failed to delete event %x: %w", idx[1:], err)
+				}
+			}
+			continue
+		}
+
+		if opts.CheckSignatures {
+			if !evt.CheckID() {
+				report.InvalidEvents++
+				report.AddProblem("event %x has id %s, which doesn't match its contents", idx[1:], evt.ID)
+			} else if ok, _ := evt.CheckSignature(); !ok {
+				report.InvalidEvents++
+				report.AddProblem("event %s has an invalid signature", evt.ID)
+			}
+		}
+
+		for key := range b.getIndexKeysForEvent(evt, idx[1:]) {
+			if _, err := txn.Get(key); err == nil {
+				continue
+			} else if err != badger.ErrKeyNotFound {
+				return fmt.Errorf("%w: failed to look for index key %x: %w", eventstore.ErrStorageUnavailable, key, err)
+			}
+
+			report.MissingIndexEntries++
+			report.AddProblem("event %s is missing index key %x", evt.ID, key)
+			if wb != nil {
+				if err := wb.Set(key, nil); err != nil {
+					return fmt.Errorf("failed to save index for event %s: %w", evt.ID, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (b *BadgerBackend) verifyIndexes(ctx context.Context, txn *badger.Txn, wb *badger.WriteBatch, report *eventstore.VerifyReport) error {
+	it := txn.NewIterator(badger.IteratorOptions{PrefetchValues: false})
+	defer it.Close()
+
+	i := 0
+	for it.Rewind(); it.Valid(); it.Next() {
+		key := it.Item().Key()
+		if key[0] < indexCreatedAtPrefix || key[0] > indexTagAddrPrefix {
+			// not an index
+			continue
+		}
+		if i++; i%10000 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		problem := ""
+		if len(key) < 1+8 {
+			problem = "is too short"
+		} else {
+			idx := make([]byte, 1+8)
+			idx[0] = rawEventStorePrefix
+			copy(idx[1:], key[len(key)-8:])
+
+			item, err := txn.Get(idx)
+			if err == badger.ErrKeyNotFound {
+				problem = "points to missing event"
+			} else if err != nil {
+				return fmt.Errorf("%w: failed to get event %x: %w", eventstore.ErrStorageUnavailable, idx[1:], err)
+			} else {
+				evt := &nostr.Event{}
+				if err := item.Value(func(val []byte) error { return bin.Decode(val, evt) }); err != nil {
+					problem = "points to an event that can't be decoded"
+				} else if !b.hasIndexKey(evt, idx[1:], key) {
+					problem = fmt.Sprintf("isn't one of the index keys of event %s", evt.ID)
+				}
+			}
+		}
+		if problem == "" {
+			continue
+		}
+
+		report.OrphanedIndexEntries++
+		report.AddProblem("index key %x %s", key, problem)
+		if wb != nil {
+			if err := wb.Delete(it.Item().KeyCopy(nil)); err != nil {
+				return fmt.Errorf("failed to delete index key %x: %w", key, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (b *BadgerBackend) hasIndexKey(evt *nostr.Event, idx []byte, k []byte) bool {
+	for key := range b.getIndexKeysForEvent(evt, idx) {
+		if bytes.Equal(key, k) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package badger
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/fiatjaf/eventstore"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/badgertest-verify"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db := &BadgerBackend{Path: path}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	sk := nostr.GeneratePrivateKey()
+	events := make([]*nostr.Event, 10)
+	for i := range events {
+		evt := &nostr.Event{CreatedAt: nostr.Timestamp(1000 + i), Kind: 1, Tags: nostr.Tags{{"t", "verify"}}, Content: "hello"}
+		evt.Sign(sk)
+		events[i] = evt
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+
+	report, err := db.Verify(ctx, eventstore.VerifyOptions{CheckSignatures: true})
+	require.NoError(t, err)
+	require.True(t, report.OK(), report.Problems)
+	require.Equal(t, int64(10), report.Events)
+
+	serial := func(n uint64) []byte {
+		idx := make([]byte, 1+8)
+		idx[0] = rawEventStorePrefix
+		binary.BigEndian.PutUint64(idx[1:], n)
+		return idx
+	}
+
+	// break things in all the ways we know of
+	require.NoError(t, db.DB.Update(func(txn *badger.Txn) error {
+		// an event that lost its tag index key
+		id, _ := hex.DecodeString(events[3].ID)
+		idx, err := getIdxForId(txn, id)
+		require.NoError(t, err)
+		for k := range db.getIndexKeysForEvent(events[3], idx[1:]) {
+			if k[0] == indexTagPrefix {
+				require.NoError(t, txn.Delete(k))
+			}
+		}
+
+		// an index key pointing nowhere
+		require.NoError(t, txn.Set(append([]byte{indexCreatedAtPrefix, 0, 0, 0, 1}, serial(999999)[1:]...), nil))
+
+		// an event that can't be decoded, with an index key
+		require.NoError(t, txn.Set(serial(888888), []byte("garbage")))
+		require.NoError(t, txn.Set(append([]byte{indexCreatedAtPrefix, 0, 0, 0, 2}, serial(888888)[1:]...), nil))
+
+		// an event with a bad signature
+		forged := *events[5]
+		forged.Content = "forged"
+		val, err := bin.Encode(&forged)
+		require.NoError(t, err)
+		require.NoError(t, txn.Set(serial(777777), val))
+		for k := range db.getIndexKeysForEvent(&forged, serial(777777)[1:]) {
+			require.NoError(t, txn.Set(k, nil))
+		}
+
+		return nil
+	}))
+
+	report, err = db.Verify(ctx, eventstore.VerifyOptions{CheckSignatures: true})
+	require.NoError(t, err)
+	require.False(t, report.OK())
+	require.False(t, report.Repaired)
+	require.Equal(t, int64(12), report.Events)
+	require.Equal(t, int64(1), report.Undecodable)
+	require.Equal(t, int64(1), report.InvalidEvents)
+	require.Equal(t, int64(1), report.MissingIndexEntries)
+	require.Equal(t, int64(2), report.OrphanedIndexEntries)
+	require.Len(t, report.Problems, 5)
+
+	report, err = db.Verify(ctx, eventstore.VerifyOptions{Repair: true})
+	require.NoError(t, err)
+	require.True(t, report.Repaired)
+
+	// only the forged event is left to complain about
+	report, err = db.Verify(ctx, eventstore.VerifyOptions{CheckSignatures: true})
+	require.NoError(t, err)
+	require.Equal(t, int64(11), report.Events)
+	require.Equal(t, int64(1), report.InvalidEvents)
+	require.Zero(t, report.Undecodable)
+	require.Zero(t, report.MissingIndexEntries)
+	require.Zero(t, report.OrphanedIndexEntries)
+
+	res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Tags: nostr.TagMap{"t": []string{"verify"}}})
+	require.NoError(t, err)
+	require.Len(t, res, 11)
+}
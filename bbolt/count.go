@@ -0,0 +1,204 @@
+package bbolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip45"
+	"github.com/nbd-wtf/go-nostr/nip45/hyperloglog"
+	"go.etcd.io/bbolt"
+	"golang.org/x/exp/slices"
+)
+
+func (b *BBoltBackend) CountEvents(ctx context.Context, filter nostr.Filter) (int64, error) {
+	var count int64
+	err := b.view(func(txn *bbolt.Tx) error {
+		var err error
+		count, err = b.count(txn, filter)
+		return err
+	})
+	return count, err
+}
+
+// count is like CountEvents, but inside a transaction we already have.
+func (b *BBoltBackend) count(txn *bbolt.Tx, filter nostr.Filter) (int64, error) {
+	var count int64 = 0
+
+	queries, extraAuthors, extraKinds, extraTagKey, extraTagValues, since, err := b.prepareQueries(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	// actually iterate
+	raw := txn.Bucket(bucketRaw)
+	for _, q := range queries {
+		it := &iterator{cursor: txn.Bucket(q.bucket).Cursor()}
+		it.seek(q.startingPoint)
+
+		for {
+			// we already have a key and an idx from the cursor setup, so check and use these
+			if it.key == nil ||
+				len(it.key) != q.keySize ||
+				!bytes.HasPrefix(it.key, q.prefix) {
+				// either we reached the start of the bucket or the end of this prefix
+				break // stop this cursor and move to the next one
+			}
+
+			// "id" indexes don't contain a timestamp
+			if q.timestampSize == 4 {
+				createdAt := binary.BigEndian.Uint32(it.key[len(it.key)-4:])
+				if createdAt < since {
+					break
+				}
+			}
+
+			if extraAuthors == nil && extraKinds == nil && extraTagValues == nil && q.fullId == nil {
+				count++
+				it.next()
+				continue
+			}
+
+			// fetch actual event
+			v, err := getRaw(raw, it.valIdx)
+			if err != nil {
+				return 0, err
+			}
+
+			// check everything against the raw event without decoding it
+			if (q.fullId == nil || bytes.Equal(v.ID(), q.fullId)) &&
+				(extraAuthors == nil || slices.Contains(extraAuthors, [32]byte(v.PubKey()))) &&
+				(extraKinds == nil || slices.Contains(extraKinds, v.Kind())) &&
+				(extraTagValues == nil || v.ContainsAnyTag(extraTagKey, extraTagValues)) {
+				count++
+			}
+			it.next()
+		}
+	}
+
+	return count, nil
+}
+
+// CountEventsHLL is like CountEvents, but it will build a hyperloglog value while iterating through results, following NIP-45
+func (b *BBoltBackend) CountEventsHLL(ctx context.Context, filter nostr.Filter, offset int) (int64, *hyperloglog.HyperLogLog, error) {
+	if b.EnableHLLCacheFor != nil {
+		if useCache, _ := b.EnableHLLCacheFor(filter.Kinds[0]); useCache {
+			return b.countEventsHLLCached(filter)
+		}
+	}
+
+	var count int64 = 0
+
+	// this is different than CountEvents because some of these extra checks are not applicable in HLL-valid filters
+	queries, _, extraKinds, extraTagKey, extraTagValues, since, err := b.prepareQueries(filter)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	hll := hyperloglog.New(offset)
+
+	err = b.view(func(txn *bbolt.Tx) error {
+		// actually iterate
+		raw := txn.Bucket(bucketRaw)
+		for _, q := range queries {
+			it := &iterator{cursor: txn.Bucket(q.bucket).Cursor()}
+			it.seek(q.startingPoint)
+
+			for {
+				// we already have a key and an idx from the cursor setup, so check and use these
+				if it.key == nil ||
+					len(it.key) != q.keySize ||
+					!bytes.HasPrefix(it.key, q.prefix) {
+					// either we reached the start of the bucket or the end of this prefix
+					break // stop this cursor and move to the next one
+				}
+
+				// "id" indexes don't contain a timestamp
+				if q.timestampSize == 4 {
+					createdAt := binary.BigEndian.Uint32(it.key[len(it.key)-4:])
+					if createdAt < since {
+						break
+					}
+				}
+
+				// fetch actual event (we need it regardless because we need the pubkey for the hll)
+				v, err := getRaw(raw, it.valIdx)
+				if err != nil {
+					return err
+				}
+
+				// check everything against the raw event without decoding it
+				if (extraKinds == nil || slices.Contains(extraKinds, v.Kind())) &&
+					(extraTagValues == nil || v.ContainsAnyTag(extraTagKey, extraTagValues)) {
+					count++
+					hll.AddBytes(v.PubKey())
+				}
+				it.next()
+			}
+		}
+
+		return nil
+	})
+
+	return count, hll, err
+}
+
+// countEventsHLLCached will just return a cached value from disk (and presumably we don't even have the events required to compute this anymore).
+func (b *BBoltBackend) countEventsHLLCached(filter nostr.Filter) (int64, *hyperloglog.HyperLogLog, error) {
+	cacheKey := make([]byte, 2+8)
+	binary.BigEndian.PutUint16(cacheKey[0:2], uint16(filter.Kinds[0]))
+	switch filter.Kinds[0] {
+	case 3:
+		hex.Decode(cacheKey[2:2+8], []byte(filter.Tags["p"][0][0:8*2]))
+	case 7:
+		hex.Decode(cacheKey[2:2+8], []byte(filter.Tags["e"][0][0:8*2]))
+	case 1111:
+		hex.Decode(cacheKey[2:2+8], []byte(filter.Tags["E"][0][0:8*2]))
+	}
+
+	var count int64
+	var hll *hyperloglog.HyperLogLog
+
+	err := b.view(func(txn *bbolt.Tx) error {
+		val := txn.Bucket(bucketHLLCache).Get(cacheKey)
+		if val == nil {
+			return nil
+		}
+		// values are only valid inside the transaction
+		hll = hyperloglog.NewWithRegisters(bytes.Clone(val), 0) // offset doesn't matter here
+		count = int64(hll.Count())
+		return nil
+	})
+
+	return count, hll, err
+}
+
+func (b *BBoltBackend) updateHyperLogLogCachedValues(txn *bbolt.Tx, evt *nostr.Event) error {
+	bucket := txn.Bucket(bucketHLLCache)
+
+	for ref, offset := range nip45.HyperLogLogEventPubkeyOffsetsAndReferencesForEvent(evt) {
+		// bbolt keeps the keys we give it until the transaction is over, so no reusing buffers
+		cacheKey := make([]byte, 2+8)
+		binary.BigEndian.PutUint16(cacheKey[0:2], uint16(evt.Kind))
+		hex.Decode(cacheKey[2:2+8], []byte(ref[0:8*2]))
+
+		// fetch hll value from cache db, the registers are modified in place so they must be copied
+		// out of the read-only memory map
+		hll := hyperloglog.New(offset)
+		if val := bucket.Get(cacheKey); val != nil {
+			hll.SetRegisters(bytes.Clone(val))
+		}
+
+		// add this event
+		hll.Add(evt.PubKey)
+
+		// save values back again
+		if err := bucket.Put(cacheKey, hll.GetRegisters()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package bbolt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHLLCache(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/bbolttest-hll"
+	os.Remove(path)
+	defer os.Remove(path)
+
+	// reactions only go to the cache, follow lists are saved as well
+	db := &BBoltBackend{Path: path, EnableHLLCacheFor: func(kind int) (bool, bool) {
+		return kind == 7 || kind == 3, kind == 7
+	}}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	// fixed keys, so the estimates (which depend on the pubkeys and on the note id) don't vary
+	// from run to run
+	target := fmt.Sprintf("%064x", 1000)
+	targetPk, _ := nostr.GetPublicKey(target)
+	note := &nostr.Event{CreatedAt: 1000, Kind: 1, Tags: nostr.Tags{}, Content: "hello"}
+	note.Sign(target)
+	require.NoError(t, db.SaveEvent(ctx, note))
+
+	for i := 0; i < 20; i++ {
+		sk := fmt.Sprintf("%064x", i+1)
+
+		reaction := &nostr.Event{CreatedAt: nostr.Timestamp(2000 + i), Kind: 7, Tags: nostr.Tags{{"e", note.ID}}, Content: "+"}
+		reaction.Sign(sk)
+		require.NoError(t, db.SaveEvent(ctx, reaction))
+
+		follows := &nostr.Event{CreatedAt: nostr.Timestamp(2000 + i), Kind: 3, Tags: nostr.Tags{{"p", targetPk}}}
+		follows.Sign(sk)
+		require.NoError(t, db.SaveEvent(ctx, follows))
+	}
+
+	reactions := nostr.Filter{Kinds: []int{7}, Tags: nostr.TagMap{"e": []string{note.ID}}}
+	count, hll, err := db.CountEventsHLL(ctx, reactions, 0)
+	require.NoError(t, err)
+	require.InDelta(t, 20, count, 2)
+	require.NotNil(t, hll)
+
+	n, err := db.CountEvents(ctx, reactions)
+	require.NoError(t, err)
+	require.Zero(t, n, "reactions were not saved")
+
+	followers := nostr.Filter{Kinds: []int{3}, Tags: nostr.TagMap{"p": []string{targetPk}}}
+	count, _, err = db.CountEventsHLL(ctx, followers, 0)
+	require.NoError(t, err)
+	require.InDelta(t, 20, count, 2)
+
+	n, err = db.CountEvents(ctx, followers)
+	require.NoError(t, err)
+	require.Equal(t, int64(20), n)
+}
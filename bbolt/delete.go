@@ -0,0 +1,39 @@
+package bbolt
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/nbd-wtf/go-nostr"
+	"go.etcd.io/bbolt"
+)
+
+func (b *BBoltBackend) DeleteEvent(ctx context.Context, evt *nostr.Event) error {
+	return b.update(func(txn *bbolt.Tx) error {
+		return b.delete(txn, evt)
+	})
+}
+
+func (b *BBoltBackend) delete(txn *bbolt.Tx, evt *nostr.Event) error {
+	id, _ := hex.DecodeString(evt.ID)
+	idx := getIdxForId(txn, id)
+	if idx == nil {
+		// we already do not have this
+		return nil
+	}
+
+	// calculate all index keys we have for this event and delete them
+	for k := range getIndexKeysForEvent(evt) {
+		if err := txn.Bucket(k.bucket).Delete(k.withIdx(idx)); err != nil {
+			return fmt.Errorf("failed to delete index entry %s for %x: %w", k, evt.ID[0:8*2], err)
+		}
+	}
+
+	// delete the raw event
+	if err := txn.Bucket(bucketRaw).Delete(idx); err != nil {
+		return fmt.Errorf("failed to delete raw event %x (idx %x): %w", evt.ID[0:8*2], idx, err)
+	}
+
+	return nil
+}
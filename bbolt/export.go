@@ -0,0 +1,73 @@
+package bbolt
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"iter"
+
+	"github.com/fiatjaf/eventstore"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
+	"github.com/nbd-wtf/go-nostr"
+	"go.etcd.io/bbolt"
+)
+
+var _ eventstore.Exporter = (*BBoltBackend)(nil)
+
+// ExportEvents goes through the created_at index oldest first, from a single read transaction,
+// so what it yields is a point-in-time snapshot. Writes can go on while it runs, but the file
+// can't be remapped to grow until it is done, so they may have to wait for it.
+func (b *BBoltBackend) ExportEvents(ctx context.Context, filter nostr.Filter) iter.Seq2[*nostr.Event, error] {
+	return func(yield func(*nostr.Event, error) bool) {
+		if filter.Search != "" {
+			return
+		}
+
+		var count int
+		err := b.view(func(txn *bbolt.Tx) error {
+			raw := txn.Bucket(bucketRaw)
+			cursor := txn.Bucket(bucketIndexCreatedAt).Cursor()
+
+			start := make([]byte, 4)
+			if filter.Since != nil {
+				binary.BigEndian.PutUint32(start, uint32(*filter.Since))
+			}
+
+			for k, _ := cursor.Seek(start); k != nil; k, _ = cursor.Next() {
+				if filter.Until != nil && nostr.Timestamp(binary.BigEndian.Uint32(k[0:4])) > *filter.Until {
+					return nil
+				}
+
+				idx := k[4:]
+				val := raw.Get(idx)
+				if val == nil {
+					return fmt.Errorf("%w: created_at index points to missing event %x", eventstore.ErrCorrupted, idx)
+				}
+				evt := &nostr.Event{}
+				if err := bin.Decode(val, evt); err != nil {
+					return fmt.Errorf("%w: event %x can't be decoded: %w", eventstore.ErrCorrupted, idx, err)
+				}
+				if !filter.Matches(evt) {
+					continue
+				}
+
+				if !yield(evt, nil) {
+					return nil
+				}
+				count++
+				if count == filter.Limit {
+					return nil
+				}
+				if count%1000 == 0 {
+					if err := ctx.Err(); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package bbolt
+
+import (
+	"context"
+	"encoding/hex"
+
+	"go.etcd.io/bbolt"
+)
+
+func (b *BBoltBackend) HasEvents(ctx context.Context, ids []string) ([]bool, error) {
+	results := make([]bool, len(ids))
+
+	err := b.view(func(txn *bbolt.Tx) error {
+		for i, idHex := range ids {
+			if len(idHex) != 64 {
+				continue
+			}
+			id, err := hex.DecodeString(idHex)
+			if err != nil {
+				continue
+			}
+
+			results[i] = getIdxForId(txn, id) != nil
+		}
+
+		return nil
+	})
+
+	return results, err
+}
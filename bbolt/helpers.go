@@ -0,0 +1,247 @@
+package bbolt
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+
+	"github.com/fiatjaf/eventstore"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
+	"github.com/nbd-wtf/go-nostr"
+	"go.etcd.io/bbolt"
+	"golang.org/x/exp/slices"
+)
+
+// indexBuckets are the buckets built from the raw events by getIndexKeysForEvent.
+var indexBuckets = [][]byte{
+	bucketIndexId,
+	bucketIndexCreatedAt,
+	bucketIndexKind,
+	bucketIndexPTagKind,
+	bucketIndexPubkey,
+	bucketIndexPubkeyKind,
+	bucketIndexTag,
+	bucketIndexTag32,
+	bucketIndexTagAddr,
+}
+
+// this iterator always goes backwards. the index keys end with the 8-byte serial of the event,
+// which is split from them into valIdx so they look like the keys of the lmdb indexes.
+type iterator struct {
+	cursor *bbolt.Cursor
+	key    []byte
+	valIdx []byte
+}
+
+func (it *iterator) seek(key []byte) {
+	if k, _ := it.cursor.Seek(key); k == nil {
+		// we're at the end and we just want notes before this,
+		// so we just need to set the cursor the last key
+		it.set(it.cursor.Last())
+	} else {
+		// move one back as the first step
+		it.set(it.cursor.Prev())
+	}
+}
+
+func (it *iterator) next() {
+	// move one back (we'll look into key and valIdx in the next iteration)
+	it.set(it.cursor.Prev())
+}
+
+func (it *iterator) set(k []byte, _ []byte) {
+	if len(k) < 8 {
+		// reached the start of the bucket
+		it.key, it.valIdx = nil, nil
+		return
+	}
+	it.key, it.valIdx = k[0:len(k)-8], k[len(k)-8:]
+}
+
+// getRaw fetches the raw event an index entry points to.
+func getRaw(raw *bbolt.Bucket, idx []byte) (bin.View, error) {
+	val := raw.Get(idx)
+	if val == nil {
+		return bin.View{}, fmt.Errorf("%w: index points to missing event %x", eventstore.ErrCorrupted, idx)
+	}
+
+	v, err := bin.NewView(val)
+	if err != nil {
+		return bin.View{}, fmt.Errorf("%w: event %x can't be read: %w", eventstore.ErrCorrupted, idx, err)
+	}
+
+	return v, nil
+}
+
+// getIdxForId finds the idx of the event with exactly this id, or nil if we don't have it.
+// the id index only has the first 8 bytes of each id, so more than one event may be stored
+// under the same key and we have to check the full id on the raw events.
+func getIdxForId(txn *bbolt.Tx, id []byte) []byte {
+	raw := txn.Bucket(bucketRaw)
+	cursor := txn.Bucket(bucketIndexId).Cursor()
+
+	for k, _ := cursor.Seek(id[0:8]); k != nil && len(k) == 8+8 && bytes.HasPrefix(k, id[0:8]); k, _ = cursor.Next() {
+		idx := k[8:]
+		val := raw.Get(idx)
+		if len(val) >= bin.PubKeyOffset && bytes.Equal(val[bin.IDOffset:bin.PubKeyOffset], id) {
+			// the keys are only valid while the cursor doesn't move and we may delete things next
+			return bytes.Clone(idx)
+		}
+	}
+
+	return nil
+}
+
+type key struct {
+	bucket []byte
+	key    []byte
+}
+
+func (key key) String() string {
+	return fmt.Sprintf("<bucket=%s key=%x>", key.bucket, key.key)
+}
+
+// withIdx is the actual key an index entry is stored under.
+func (key key) withIdx(idx []byte) []byte {
+	k := make([]byte, len(key.key)+len(idx))
+	copy(k, key.key)
+	copy(k[len(key.key):], idx)
+	return k
+}
+
+func getIndexKeysForEvent(evt *nostr.Event) iter.Seq[key] {
+	return func(yield func(key) bool) {
+		{
+			// ~ by id
+			k := make([]byte, 8)
+			hex.Decode(k[0:8], []byte(evt.ID[0:8*2]))
+			if !yield(key{bucket: bucketIndexId, key: k[0:8]}) {
+				return
+			}
+		}
+
+		{
+			// ~ by pubkey+date
+			k := make([]byte, 8+4)
+			hex.Decode(k[0:8], []byte(evt.PubKey[0:8*2]))
+			binary.BigEndian.PutUint32(k[8:8+4], uint32(evt.CreatedAt))
+			if !yield(key{bucket: bucketIndexPubkey, key: k[0 : 8+4]}) {
+				return
+			}
+		}
+
+		{
+			// ~ by kind+date
+			k := make([]byte, 4+4)
+			binary.BigEndian.PutUint32(k[0:4], uint32(evt.Kind))
+			binary.BigEndian.PutUint32(k[4:4+4], uint32(evt.CreatedAt))
+			if !yield(key{bucket: bucketIndexKind, key: k[0 : 4+4]}) {
+				return
+			}
+		}
+
+		{
+			// ~ by pubkey+kind+date
+			k := make([]byte, 8+4+4)
+			hex.Decode(k[0:8], []byte(evt.PubKey[0:8*2]))
+			binary.BigEndian.PutUint32(k[8:8+4], uint32(evt.Kind))
+			binary.BigEndian.PutUint32(k[8+4:8+4+4], uint32(evt.CreatedAt))
+			if !yield(key{bucket: bucketIndexPubkeyKind, key: k[0 : 8+4+4]}) {
+				return
+			}
+		}
+
+		// ~ by tagvalue+date
+		// ~ by p-tag+kind+date
+		for i, tag := range evt.Tags {
+			if len(tag) < 2 || len(tag[0]) != 1 || len(tag[1]) == 0 || len(tag[1]) > 100 {
+				// not indexable
+				continue
+			}
+			firstIndex := slices.IndexFunc(evt.Tags, func(t nostr.Tag) bool {
+				return len(t) >= 2 && t[0] == tag[0] && t[1] == tag[1]
+			})
+			if firstIndex != i {
+				// duplicate
+				continue
+			}
+
+			// get key prefix (with full length) and offset where to write the created_at
+			bucket, k, offset := getTagIndexPrefix(tag[0], tag[1])
+			binary.BigEndian.PutUint32(k[offset:], uint32(evt.CreatedAt))
+			if !yield(key{bucket: bucket, key: k}) {
+				return
+			}
+
+			// now the p-tag+kind+date
+			if bytes.Equal(bucket, bucketIndexTag32) && tag[0] == "p" {
+				k := make([]byte, 8+4+4)
+				hex.Decode(k[0:8], []byte(tag[1][0:8*2]))
+				binary.BigEndian.PutUint32(k[8:8+4], uint32(evt.Kind))
+				binary.BigEndian.PutUint32(k[8+4:8+4+4], uint32(evt.CreatedAt))
+				if !yield(key{bucket: bucketIndexPTagKind, key: k[0 : 8+4+4]}) {
+					return
+				}
+			}
+		}
+
+		{
+			// ~ by date only
+			k := make([]byte, 4)
+			binary.BigEndian.PutUint32(k[0:4], uint32(evt.CreatedAt))
+			if !yield(key{bucket: bucketIndexCreatedAt, key: k[0:4]}) {
+				return
+			}
+		}
+	}
+}
+
+func getTagIndexPrefix(tagName string, tagValue string) ([]byte, []byte, int) {
+	var k []byte   // the key with full length for created_at at the end, but not filled with it
+	var offset int // the offset -- i.e. where the prefix ends and the created_at would start
+
+	letterPrefix := byte(int(tagName[0]) % 256)
+
+	// if it's 32 bytes as hex, save it as bytes
+	if len(tagValue) == 64 {
+		// but we actually only use the first 8 bytes, with letter (tag name) prefix
+		k = make([]byte, 1+8+4)
+		if _, err := hex.Decode(k[1:1+8], []byte(tagValue[0:8*2])); err == nil {
+			k[0] = letterPrefix
+			offset = 1 + 8
+			return bucketIndexTag32, k[0 : 1+8+4], offset
+		}
+	}
+
+	// if it looks like an "a" tag, index it in this special format, with letter (tag name) prefix
+	spl := strings.Split(tagValue, ":")
+	if len(spl) == 3 && len(spl[1]) == 64 {
+		k = make([]byte, 1+2+8+30+4)
+		if _, err := hex.Decode(k[1+2:1+2+8], []byte(tagValue[0:8*2])); err == nil {
+			if kind, err := strconv.ParseUint(spl[0], 10, 16); err == nil {
+				k[0] = byte(letterPrefix)
+				k[1] = byte(kind >> 8)
+				k[2] = byte(kind)
+				// limit "d" identifier to 30 bytes (so we don't have to grow our byte slice)
+				n := copy(k[1+2+8:1+2+8+30], spl[2])
+				offset = 1 + 2 + 8 + n
+				return bucketIndexTagAddr, k[0 : offset+4], offset
+			}
+		}
+	}
+
+	// index whatever else as a md5 hash of the contents, with letter (tag name) prefix
+	h := md5.New()
+	h.Write([]byte(tagValue))
+	k = make([]byte, 1, 1+16+4)
+	k[0] = letterPrefix
+	k = h.Sum(k)
+	offset = 1 + 16
+
+	return bucketIndexTag, k[0 : 1+16+4], offset
+}
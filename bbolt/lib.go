@@ -0,0 +1,147 @@
+package bbolt
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/fiatjaf/eventstore/internal/inflight"
+	"go.etcd.io/bbolt"
+)
+
+var (
+	bucketSettings        = []byte("settings")
+	bucketRaw             = []byte("raw")
+	bucketIndexCreatedAt  = []byte("created_at")
+	bucketIndexId         = []byte("id")
+	bucketIndexKind       = []byte("kind")
+	bucketIndexPubkey     = []byte("pubkey")
+	bucketIndexPubkeyKind = []byte("pubkeyKind")
+	bucketIndexTag        = []byte("tag")
+	bucketIndexTag32      = []byte("tag32")
+	bucketIndexTagAddr    = []byte("tagaddr")
+	bucketIndexPTagKind   = []byte("ptagKind")
+	bucketHLLCache        = []byte("hllCache")
+)
+
+var _ eventstore.Store = (*BBoltBackend)(nil)
+
+// BBoltBackend keeps the same indexes as the lmdb backend, in a single bbolt file, without cgo.
+// bbolt has no duplicate keys, so the index entries have the serial of the raw event appended
+// to them instead of having it as their value.
+type BBoltBackend struct {
+	Path               string
+	MaxLimit           int
+	MaxLimitNegentropy int
+
+	// NoSync skips the fsync after each write transaction, which is much faster but can lose
+	// the last writes (never corrupt the file) if the machine crashes.
+	NoSync bool
+
+	// MigrationBatchSize is how many events each migration transaction goes through before it
+	// commits and saves a checkpoint, defaults to 10000.
+	MigrationBatchSize int
+
+	// OnMigrationProgress, if set, is called by Init after every batch of every migration.
+	OnMigrationProgress func(eventstore.MigrationProgress)
+
+	EnableHLLCacheFor func(kind int) (useCache bool, skipSavingActualEvent bool)
+
+	db       *bbolt.DB
+	inflight inflight.Tracker
+}
+
+func (b *BBoltBackend) Init() error {
+	if b.MaxLimit != 0 {
+		b.MaxLimitNegentropy = b.MaxLimit
+	} else {
+		b.MaxLimit = 1500
+		if b.MaxLimitNegentropy == 0 {
+			b.MaxLimitNegentropy = 16777216
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(b.Path), 0755); err != nil {
+		return err
+	}
+
+	// bbolt holds a lock on the file, fail instead of waiting forever if someone else has it
+	db, err := bbolt.Open(b.Path, 0644, &bbolt.Options{Timeout: 5 * time.Second, NoSync: b.NoSync})
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", b.Path, err)
+	}
+	b.db = db
+
+	if err := b.db.Update(func(txn *bbolt.Tx) error {
+		for _, name := range append([][]byte{bucketSettings, bucketRaw, bucketHLLCache}, indexBuckets...) {
+			if _, err := txn.CreateBucketIfNotExists(name); err != nil {
+				return fmt.Errorf("failed to create bucket %s: %w", name, err)
+			}
+		}
+		return nil
+	}); err != nil {
+		b.db.Close()
+		return err
+	}
+
+	if err := b.runMigrations(); err != nil {
+		b.db.Close()
+		return err
+	}
+
+	return nil
+}
+
+// Close is CloseContext with a timeout of inflight.CloseTimeout.
+func (b *BBoltBackend) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), inflight.CloseTimeout)
+	defer cancel()
+	if err := b.CloseContext(ctx); err != nil {
+		log.Printf("[bbolt] failed to close: %s\n", err)
+	}
+}
+
+// CloseContext closes the database once the running operations are done.
+// See [eventstore.GracefulCloser].
+func (b *BBoltBackend) CloseContext(ctx context.Context) error {
+	return b.inflight.Close(ctx, b.db.Close)
+}
+
+// view wraps a read transaction so the database can't be closed while it runs.
+func (b *BBoltBackend) view(fn func(txn *bbolt.Tx) error) error {
+	if err := b.inflight.Acquire(); err != nil {
+		return err
+	}
+	defer b.inflight.Release()
+
+	return b.db.View(fn)
+}
+
+// update is like view, but for a write transaction. bbolt only runs one of these at a time.
+func (b *BBoltBackend) update(fn func(txn *bbolt.Tx) error) error {
+	if err := b.inflight.Acquire(); err != nil {
+		return err
+	}
+	defer b.inflight.Release()
+
+	return b.db.Update(fn)
+}
+
+// serial returns the key for the next raw event. It comes from the sequence of the raw bucket,
+// so it is only used up if the transaction commits.
+func serial(raw *bbolt.Bucket) ([]byte, error) {
+	if raw.Sequence() == math.MaxUint64 {
+		return nil, eventstore.ErrSerialOverflow
+	}
+	next, err := raw.NextSequence()
+	if err != nil {
+		return nil, err
+	}
+	return binary.BigEndian.AppendUint64(make([]byte, 0, 8), next), nil
+}
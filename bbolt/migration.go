@@ -0,0 +1,261 @@
+package bbolt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/fiatjaf/eventstore"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
+	"github.com/nbd-wtf/go-nostr"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	DB_VERSION              byte = 'v'
+	DB_MIGRATION_CHECKPOINT byte = 'm'
+)
+
+type migration struct {
+	version     uint16
+	description string
+	steps       []migrationStep
+}
+
+// migrationStep either has a run function, called once in a transaction of its own, or a forEach
+// function, called for every raw event in transactions of MigrationBatchSize events. run must be
+// safe to call again, as it will be if the process stops before the step is marked as done.
+type migrationStep struct {
+	run     func(txn *bbolt.Tx) error
+	forEach func(txn *bbolt.Tx, idx []byte, val []byte) error
+}
+
+// checkpoint is saved along with each batch of a migration, so it can resume from there.
+type checkpoint struct {
+	version uint16
+	step    uint8
+	done    uint64
+	last    []byte
+}
+
+// migrations are run in order on databases with a lower version, new databases go through all of
+// them too, which is cheap when there are no events yet.
+func (b *BBoltBackend) migrations() []migration {
+	return []migration{
+		{1, "build all indexes", b.reindexSteps()},
+	}
+}
+
+// runMigrations brings the database to the latest version, resuming an interrupted migration
+// from its last checkpoint.
+func (b *BBoltBackend) runMigrations() error {
+	if b.MigrationBatchSize == 0 {
+		b.MigrationBatchSize = 10000
+	}
+
+	var version uint16
+	var cp checkpoint
+	if err := b.db.View(func(txn *bbolt.Tx) error {
+		settings := txn.Bucket(bucketSettings)
+		if v := settings.Get([]byte{DB_VERSION}); v != nil {
+			version = binary.BigEndian.Uint16(v)
+		}
+
+		if v := settings.Get([]byte{DB_MIGRATION_CHECKPOINT}); v != nil {
+			if len(v) < 2+1+8 {
+				return fmt.Errorf("%w: migration checkpoint is too short", eventstore.ErrCorrupted)
+			}
+			cp.version = binary.BigEndian.Uint16(v[0:2])
+			cp.step = v[2]
+			cp.done = binary.BigEndian.Uint64(v[3:11])
+			cp.last = bytes.Clone(v[11:])
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// do the migrations in increasing steps (there is no rollback)
+	for _, m := range b.migrations() {
+		if m.version <= version {
+			continue
+		}
+
+		start := checkpoint{version: m.version}
+		if cp.version == m.version {
+			start = cp
+			log.Printf("[bbolt] migration %d: %s (resuming from step %d, after %d events)\n",
+				m.version, m.description, cp.step+1, cp.done)
+		} else {
+			log.Printf("[bbolt] migration %d: %s\n", m.version, m.description)
+		}
+
+		for s := int(start.step); s < len(m.steps); s++ {
+			step := m.steps[s]
+			if step.forEach != nil {
+				if err := b.migrateEvents(m, s, start); err != nil {
+					return fmt.Errorf("migration %d failed: %w", m.version, err)
+				}
+			} else {
+				if err := b.db.Update(func(txn *bbolt.Tx) error {
+					if err := step.run(txn); err != nil {
+						return err
+					}
+					return setCheckpoint(txn, checkpoint{version: m.version, step: uint8(s + 1)})
+				}); err != nil {
+					return fmt.Errorf("migration %d failed: %w", m.version, err)
+				}
+				b.reportMigrationProgress(m, s, 0, 0)
+			}
+			start = checkpoint{version: m.version}
+		}
+
+		// bump version
+		if err := b.db.Update(func(txn *bbolt.Tx) error {
+			if err := txn.Bucket(bucketSettings).Delete([]byte{DB_MIGRATION_CHECKPOINT}); err != nil {
+				return err
+			}
+			return setVersion(txn, m.version)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateEvents calls the forEach function of a step for every raw event after the checkpoint,
+// committing and saving a new checkpoint every MigrationBatchSize events.
+func (b *BBoltBackend) migrateEvents(m migration, s int, from checkpoint) error {
+	var total int64
+	if err := b.db.View(func(txn *bbolt.Tx) error {
+		total = int64(txn.Bucket(bucketRaw).Stats().KeyN)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	next := from
+	for {
+		finished := false
+		if err := b.db.Update(func(txn *bbolt.Tx) error {
+			cursor := txn.Bucket(bucketRaw).Cursor()
+
+			// a cursor can't be trusted after the bucket it is on is modified, so the batch is
+			// read first and only then handed to forEach
+			type entry struct{ idx, val []byte }
+			batch := make([]entry, 0, b.MigrationBatchSize)
+
+			var idx, val []byte
+			if next.last == nil {
+				idx, val = cursor.First()
+			} else {
+				idx, val = cursor.Seek(next.last)
+				if idx != nil && bytes.Equal(idx, next.last) {
+					idx, val = cursor.Next()
+				}
+			}
+			for ; idx != nil && len(batch) < b.MigrationBatchSize; idx, val = cursor.Next() {
+				batch = append(batch, entry{bytes.Clone(idx), bytes.Clone(val)})
+			}
+			finished = idx == nil
+
+			cp := next
+			for _, e := range batch {
+				if err := m.steps[s].forEach(txn, e.idx, e.val); err != nil {
+					return fmt.Errorf("event %x: %w", e.idx, err)
+				}
+				cp.done++
+				cp.last = e.idx
+			}
+
+			if finished {
+				// went through all the events
+				if err := setCheckpoint(txn, checkpoint{version: m.version, step: uint8(s + 1)}); err != nil {
+					return err
+				}
+			} else if err := setCheckpoint(txn, cp); err != nil {
+				return err
+			}
+
+			next = cp
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		b.reportMigrationProgress(m, s, int64(next.done), max(total, int64(next.done)))
+		if finished {
+			return nil
+		}
+	}
+}
+
+func (b *BBoltBackend) reportMigrationProgress(m migration, s int, done int64, total int64) {
+	if b.OnMigrationProgress != nil {
+		b.OnMigrationProgress(eventstore.MigrationProgress{
+			Version:     int(m.version),
+			Description: m.description,
+			Step:        s + 1,
+			Steps:       len(m.steps),
+			Done:        done,
+			Total:       total,
+		})
+	}
+}
+
+func setCheckpoint(txn *bbolt.Tx, cp checkpoint) error {
+	buf := make([]byte, 2+1+8, 2+1+8+len(cp.last))
+	binary.BigEndian.PutUint16(buf[0:2], cp.version)
+	buf[2] = cp.step
+	binary.BigEndian.PutUint64(buf[3:11], cp.done)
+	buf = append(buf, cp.last...)
+	return txn.Bucket(bucketSettings).Put([]byte{DB_MIGRATION_CHECKPOINT}, buf)
+}
+
+// reindexSteps empties the given index buckets (or all of them if none is given) and rebuilds
+// them from the raw events.
+func (b *BBoltBackend) reindexSteps(buckets ...[]byte) []migrationStep {
+	if len(buckets) == 0 {
+		buckets = indexBuckets
+	}
+
+	return []migrationStep{
+		{run: func(txn *bbolt.Tx) error {
+			for _, name := range buckets {
+				if err := txn.DeleteBucket(name); err != nil {
+					return err
+				}
+				if _, err := txn.CreateBucket(name); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{forEach: func(txn *bbolt.Tx, idx []byte, val []byte) error {
+			evt := &nostr.Event{}
+			if err := bin.Decode(val, evt); err != nil {
+				return fmt.Errorf("error decoding event: %w", err)
+			}
+
+			for key := range getIndexKeysForEvent(evt) {
+				if !slices.ContainsFunc(buckets, func(name []byte) bool { return bytes.Equal(name, key.bucket) }) {
+					continue
+				}
+				if err := txn.Bucket(key.bucket).Put(key.withIdx(idx), nil); err != nil {
+					return fmt.Errorf("failed to save index %s for event %s: %w", key, evt.ID, err)
+				}
+			}
+			return nil
+		}},
+	}
+}
+
+func setVersion(txn *bbolt.Tx, version uint16) error {
+	buf := make([]byte, 2)
+	binary.BigEndian.PutUint16(buf, version)
+	return txn.Bucket(bucketSettings).Put([]byte{DB_VERSION}, buf)
+}
@@ -0,0 +1,100 @@
+package bbolt
+
+import (
+	"context"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+func TestMigrationRebuildsIndexes(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/bbolttest-migration"
+	os.Remove(path)
+	defer os.Remove(path)
+
+	db := &BBoltBackend{Path: path}
+	require.NoError(t, db.Init())
+
+	// write raw events without any indexes, as if the first migration had been interrupted
+	// after its first step and a few events
+	sk := nostr.GeneratePrivateKey()
+	events := make([]*nostr.Event, 10)
+	require.NoError(t, db.db.Update(func(txn *bbolt.Tx) error {
+		raw := txn.Bucket(bucketRaw)
+		for i := range events {
+			evt := &nostr.Event{CreatedAt: nostr.Timestamp(1000 + i), Kind: 1, Tags: nostr.Tags{{"t", "old"}}, Content: "old"}
+			evt.Sign(sk)
+			events[i] = evt
+
+			val, err := bin.Encode(evt)
+			require.NoError(t, err)
+			idx, err := serial(raw)
+			require.NoError(t, err)
+			require.NoError(t, raw.Put(idx, val))
+
+			// only the events before the checkpoint are indexed
+			if i < 4 {
+				for k := range getIndexKeysForEvent(evt) {
+					require.NoError(t, txn.Bucket(k.bucket).Put(k.withIdx(idx), nil))
+				}
+			}
+			if i == 3 {
+				require.NoError(t, setCheckpoint(txn, checkpoint{version: 1, step: 1, done: 4, last: idx}))
+			}
+		}
+		return txn.Bucket(bucketSettings).Delete([]byte{DB_VERSION})
+	}))
+	db.Close()
+
+	// small batches so the events are indexed over many transactions
+	var progress []eventstore.MigrationProgress
+	db = &BBoltBackend{Path: path, MigrationBatchSize: 3, OnMigrationProgress: func(p eventstore.MigrationProgress) {
+		progress = append(progress, p)
+	}}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	require.Len(t, progress, 2)
+	require.Equal(t, int64(10), progress[len(progress)-1].Done)
+
+	res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Tags: nostr.TagMap{"t": []string{"old"}}})
+	require.NoError(t, err)
+	require.Len(t, res, len(events))
+	for i, evt := range res {
+		require.Equal(t, events[len(events)-1-i].ID, evt.ID)
+	}
+
+	stats, err := db.Stats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, stats.SchemaVersion)
+	require.Equal(t, int64(len(events)), stats.EventsPerKind[1])
+}
+
+func TestSerialOverflow(t *testing.T) {
+	path := "/tmp/bbolttest-serial-overflow"
+	os.Remove(path)
+	defer os.Remove(path)
+
+	db := &BBoltBackend{Path: path}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	require.NoError(t, db.db.Update(func(txn *bbolt.Tx) error {
+		return txn.Bucket(bucketRaw).SetSequence(math.MaxUint64 - 1)
+	}))
+
+	evt := &nostr.Event{CreatedAt: 1000, Kind: 1, Tags: nostr.Tags{}}
+	evt.Sign(nostr.GeneratePrivateKey())
+	require.NoError(t, db.SaveEvent(context.Background(), evt))
+
+	evt = &nostr.Event{CreatedAt: 1001, Kind: 1, Tags: nostr.Tags{}}
+	evt.Sign(nostr.GeneratePrivateKey())
+	require.ErrorIs(t, db.SaveEvent(context.Background(), evt), eventstore.ErrSerialOverflow)
+}
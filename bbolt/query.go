@@ -0,0 +1,425 @@
+package bbolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"iter"
+	"log"
+	"slices"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/fiatjaf/eventstore/internal"
+	"github.com/nbd-wtf/go-nostr"
+	"go.etcd.io/bbolt"
+)
+
+func (b *BBoltBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (chan *nostr.Event, error) {
+	ch := make(chan *nostr.Event)
+
+	limit := b.getLimit(ctx, filter)
+	if limit == 0 {
+		close(ch)
+		return ch, nil
+	}
+
+	// collect everything first so we don't keep the transaction open while the results are consumed,
+	// this way we can also return any error directly
+	var results []internal.IterEvent
+	if err := b.view(func(txn *bbolt.Tx) error {
+		var err error
+		results, err = b.query(txn, filter, limit, false)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer close(ch)
+		for _, ie := range results {
+			select {
+			case ch <- ie.Event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// QueryIDs is like QueryEvents, but only the id and created_at of each event are read from the raw values.
+// Events are only fully decoded when there is a tag that must be checked and couldn't be used as an index.
+func (b *BBoltBackend) QueryIDs(ctx context.Context, filter nostr.Filter) iter.Seq2[eventstore.IDTimestamp, error] {
+	return func(yield func(eventstore.IDTimestamp, error) bool) {
+		limit := b.getLimit(ctx, filter)
+		if limit == 0 {
+			return
+		}
+
+		var results []internal.IterEvent
+		if err := b.view(func(txn *bbolt.Tx) error {
+			var err error
+			results, err = b.query(txn, filter, limit, true)
+			return err
+		}); err != nil {
+			yield(eventstore.IDTimestamp{}, err)
+			return
+		}
+
+		for _, ie := range results {
+			if !yield(eventstore.IDTimestamp{ID: ie.ID, CreatedAt: ie.CreatedAt}, nil) {
+				return
+			}
+		}
+	}
+}
+
+// getLimit returns the maximum number of events we'll return for this filter, 0 means nothing should be returned
+func (b *BBoltBackend) getLimit(ctx context.Context, filter nostr.Filter) int {
+	if filter.Search != "" {
+		return 0
+	}
+
+	maxLimit := b.MaxLimit
+	var limit int
+	if eventstore.IsNegentropySession(ctx) {
+		maxLimit = b.MaxLimitNegentropy
+		limit = maxLimit
+	} else {
+		limit = maxLimit / 4
+	}
+	if filter.Limit > 0 && filter.Limit <= maxLimit {
+		limit = filter.Limit
+	}
+	if tlimit := nostr.GetTheoreticalLimit(filter); tlimit >= 0 {
+		limit = tlimit
+	}
+
+	return limit
+}
+
+// query runs the given filter inside txn. when idsOnly is true the returned events will only have
+// their ID and CreatedAt fields set (unless we had to decode them for some reason).
+func (b *BBoltBackend) query(txn *bbolt.Tx, filter nostr.Filter, limit int, idsOnly bool) ([]internal.IterEvent, error) {
+	queries, extraAuthors, extraKinds, extraTagKey, extraTagValues, since, err := b.prepareQueries(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	iterators := make([]*iterator, len(queries))
+	exhausted := make([]bool, len(queries)) // indicates that a query won't be used anymore
+	results := make([][]internal.IterEvent, len(queries))
+	pulledPerQuery := make([]int, len(queries))
+
+	// these are kept updated so we never pull from the iterator that is at further distance
+	// (i.e. the one that has the oldest event among all)
+	// we will continue to pull from it as soon as some other iterator takes the position
+	oldest := internal.IterEvent{Q: -1}
+
+	secondPhase := false // after we have gathered enough events we will change the way we iterate
+	secondBatch := make([][]internal.IterEvent, 0, len(queries)+1)
+	secondPhaseParticipants := make([]int, 0, len(queries)+1)
+
+	// while merging results in the second phase we will alternate between these two lists
+	//   to avoid having to create new lists all the time
+	var secondPhaseResultsA []internal.IterEvent
+	var secondPhaseResultsB []internal.IterEvent
+	var secondPhaseResultsToggle bool // this is just a dummy thing we use to keep track of the alternating
+	var secondPhaseHasResultsPending bool
+
+	remainingUnexhausted := len(queries) // when all queries are exhausted we can finally end this thing
+	batchSizePerQuery := internal.BatchSizePerNumberOfQueries(limit, remainingUnexhausted)
+	firstPhaseTotalPulled := 0
+
+	exhaust := func(q int) {
+		exhausted[q] = true
+		remainingUnexhausted--
+		if q == oldest.Q {
+			oldest = internal.IterEvent{Q: -1}
+		}
+	}
+
+	var firstPhaseResults []internal.IterEvent
+
+	raw := txn.Bucket(bucketRaw)
+	for q := range queries {
+		iterators[q] = &iterator{cursor: txn.Bucket(queries[q].bucket).Cursor()}
+		iterators[q].seek(queries[q].startingPoint)
+		results[q] = make([]internal.IterEvent, 0, batchSizePerQuery*2)
+	}
+
+	for c := 0; ; c++ {
+		batchSizePerQuery = internal.BatchSizePerNumberOfQueries(limit, remainingUnexhausted)
+
+		// we will go through all the iterators in batches until we have pulled all the required results
+		for q, query := range queries {
+			if exhausted[q] {
+				continue
+			}
+			if oldest.Q == q && remainingUnexhausted > 1 {
+				continue
+			}
+
+			it := iterators[q]
+			pulledThisIteration := 0
+
+			for {
+				// we already have a key and an idx from the cursor setup, so check and use these
+				if it.key == nil ||
+					len(it.key) != query.keySize ||
+					!bytes.HasPrefix(it.key, query.prefix) {
+					// either we reached the start of the bucket or the end of this prefix
+					exhaust(q)
+					break
+				}
+
+				// "id" indexes don't contain a timestamp
+				if query.timestampSize == 4 {
+					createdAt := binary.BigEndian.Uint32(it.key[len(it.key)-4:])
+					if createdAt < since {
+						exhaust(q)
+						break
+					}
+				}
+
+				// fetch actual event
+				v, err := getRaw(raw, it.valIdx)
+				if err != nil {
+					log.Printf("bbolt: failed to read event (idx %x) on query prefix %x sp %x bucket %s: %s\n", it.valIdx,
+						query.prefix, query.startingPoint, query.bucket, err)
+					return nil, err
+				}
+
+				// check if this is really the event we want, not only one with the same id prefix
+				if query.fullId != nil && !bytes.Equal(v.ID(), query.fullId) {
+					it.next()
+					continue
+				}
+
+				// check it against pubkeys without decoding the entire thing
+				if extraAuthors != nil && !slices.Contains(extraAuthors, [32]byte(v.PubKey())) {
+					it.next()
+					continue
+				}
+
+				// check it against kinds without decoding the entire thing
+				if extraKinds != nil && !slices.Contains(extraKinds, v.Kind()) {
+					it.next()
+					continue
+				}
+
+				// if there is still a tag to be checked, do it now
+				if extraTagValues != nil && !v.ContainsAnyTag(extraTagKey, extraTagValues) {
+					it.next()
+					continue
+				}
+
+				// only now that we know we want this event we decode it
+				event := &nostr.Event{}
+				if idsOnly {
+					// we don't need anything else, so just read the id and the timestamp
+					event.ID = hex.EncodeToString(v.ID())
+					event.CreatedAt = v.CreatedAt()
+				} else {
+					v.Decode(event)
+				}
+
+				// this event is good to be used
+				evt := internal.IterEvent{Event: event, Q: q}
+				//
+				//
+				if secondPhase {
+					// do the process described below at HIWAWVRTP.
+					// if we've reached here this means we've already passed the `since` check.
+					// now we have to eliminate the event currently at the `since` threshold.
+					nextThreshold := firstPhaseResults[len(firstPhaseResults)-2]
+					if oldest.Event == nil {
+						// BRANCH WHEN WE DON'T HAVE THE OLDEST EVENT (BWWDHTOE)
+						// when we don't have the oldest set, we will keep the results
+						//   and not change the cutting point -- it's bad, but hopefully not that bad.
+						results[q] = append(results[q], evt)
+						secondPhaseHasResultsPending = true
+					} else if nextThreshold.CreatedAt > oldest.CreatedAt {
+						// one of the events we have stored is the actual next threshold
+						// eliminate last, update since with oldest
+						firstPhaseResults = firstPhaseResults[0 : len(firstPhaseResults)-1]
+						since = uint32(oldest.CreatedAt)
+						//  we null the oldest Event as we can't rely on it anymore
+						//   (we'll fall under BWWDHTOE above) until we have a new oldest set.
+						oldest = internal.IterEvent{Q: -1}
+						// anything we got that would be above this won't trigger an update to
+						//   the oldest anyway, because it will be discarded as being after the limit.
+						//
+						// finally
+						// add this to the results to be merged later
+						results[q] = append(results[q], evt)
+						secondPhaseHasResultsPending = true
+					} else if nextThreshold.CreatedAt < evt.CreatedAt {
+						// the next last event in the firstPhaseResults is the next threshold
+						// eliminate last, update since with the antelast
+						firstPhaseResults = firstPhaseResults[0 : len(firstPhaseResults)-1]
+						since = uint32(nextThreshold.CreatedAt)
+						// add this to the results to be merged later
+						results[q] = append(results[q], evt)
+						secondPhaseHasResultsPending = true
+						// update the oldest event
+						if evt.CreatedAt < oldest.CreatedAt {
+							oldest = evt
+						}
+					} else {
+						// oops, _we_ are the next `since` threshold
+						firstPhaseResults[len(firstPhaseResults)-1] = evt
+						since = uint32(evt.CreatedAt)
+						// do not add us to the results to be merged later
+						//   as we're already inhabiting the firstPhaseResults slice
+					}
+				} else {
+					results[q] = append(results[q], evt)
+					firstPhaseTotalPulled++
+
+					// update the oldest event
+					if oldest.Event == nil || evt.CreatedAt < oldest.CreatedAt {
+						oldest = evt
+					}
+				}
+
+				pulledPerQuery[q]++
+				pulledThisIteration++
+				if pulledThisIteration > batchSizePerQuery {
+					// batch filled
+					it.next()
+					break
+				}
+				if pulledPerQuery[q] >= limit {
+					// batch filled + reached limit for this query (which is the global limit)
+					exhaust(q)
+					it.next()
+					break
+				}
+
+				it.next()
+			}
+		}
+
+		// we will do this check if we don't accumulated the requested number of events yet
+		if secondPhase && secondPhaseHasResultsPending && (oldest.Event == nil || remainingUnexhausted == 0) {
+			// when we are in the second phase we will aggressively aggregate results on every iteration
+			//
+			secondBatch = secondBatch[:0]
+			for s := 0; s < len(secondPhaseParticipants); s++ {
+				q := secondPhaseParticipants[s]
+
+				if len(results[q]) > 0 {
+					secondBatch = append(secondBatch, results[q])
+				}
+
+				if exhausted[q] {
+					secondPhaseParticipants = internal.SwapDelete(secondPhaseParticipants, s)
+					s--
+				}
+			}
+
+			// every time we get here we will alternate between these A and B lists
+			//   combining everything we have into a new partial results list.
+			// after we've done that we can again set the oldest.
+			if secondPhaseResultsToggle {
+				secondBatch = append(secondBatch, secondPhaseResultsB)
+				secondPhaseResultsA = internal.MergeSortMultiple(secondBatch, limit, secondPhaseResultsA)
+				oldest = secondPhaseResultsA[len(secondPhaseResultsA)-1]
+			} else {
+				secondBatch = append(secondBatch, secondPhaseResultsA)
+				secondPhaseResultsB = internal.MergeSortMultiple(secondBatch, limit, secondPhaseResultsB)
+				oldest = secondPhaseResultsB[len(secondPhaseResultsB)-1]
+			}
+			secondPhaseResultsToggle = !secondPhaseResultsToggle
+
+			since = uint32(oldest.CreatedAt)
+
+			// reset the `results` list so we can keep using it
+			results = results[:len(queries)]
+			for _, q := range secondPhaseParticipants {
+				results[q] = results[q][:0]
+			}
+		} else if !secondPhase && firstPhaseTotalPulled >= limit && remainingUnexhausted > 0 {
+			// we will exclude this oldest number as it is not relevant anymore
+			// (we now want to keep track only of the oldest among the remaining iterators)
+			oldest = internal.IterEvent{Q: -1}
+
+			// HOW IT WORKS AFTER WE'VE REACHED THIS POINT (HIWAWVRTP)
+			// now we can combine the results we have and check what is our current oldest event.
+			// we also discard anything that is after the current cutting point (`limit`).
+			// so if we have [1,2,3], [10, 15, 20] and [7, 21, 49] but we only want 6 total
+			//   we can just keep [1,2,3,7,10,15] and discard [20, 21, 49],
+			//   and also adjust our `since` parameter to `15`, discarding anything we get after it
+			//   and immediately declaring that iterator exhausted.
+			// also every time we get result that is more recent than this updated `since` we can
+			//   keep it but also discard the previous since, moving the needle one back -- for example,
+			//   if we get an `8` we can keep it and move the `since` parameter to `10`, discarding `15`
+			//   in the process.
+			all := make([][]internal.IterEvent, len(results))
+			copy(all, results) // we have to use this otherwise internal.MergeSortMultiple will scramble our results slice
+			firstPhaseResults = internal.MergeSortMultiple(all, limit, nil)
+			oldest = firstPhaseResults[limit-1]
+			since = uint32(oldest.CreatedAt)
+
+			for q := range queries {
+				if exhausted[q] {
+					continue
+				}
+
+				// we also automatically exhaust any of the iterators that have already passed the
+				// cutting point (`since`)
+				if results[q][len(results[q])-1].CreatedAt < oldest.CreatedAt {
+					exhausted[q] = true
+					remainingUnexhausted--
+					continue
+				}
+
+				// for all the remaining iterators,
+				// since we have merged all the events in this `firstPhaseResults` slice, we can empty the
+				//   current `results` slices and reuse them.
+				results[q] = results[q][:0]
+
+				// build this index of indexes with everybody who remains
+				secondPhaseParticipants = append(secondPhaseParticipants, q)
+			}
+
+			// we create these two lists and alternate between them so we don't have to create a
+			//   a new one every time
+			secondPhaseResultsA = make([]internal.IterEvent, 0, limit*2)
+			secondPhaseResultsB = make([]internal.IterEvent, 0, limit*2)
+
+			// from now on we won't run this block anymore
+			secondPhase = true
+		}
+
+		if remainingUnexhausted == 0 {
+			break
+		}
+	}
+
+	var combinedResults []internal.IterEvent
+
+	if secondPhase {
+		// when we reach this point either secondPhaseResultsA or secondPhaseResultsB will be full of stuff,
+		//   the other will be empty
+		var secondPhaseResults []internal.IterEvent
+		if secondPhaseResultsToggle {
+			secondPhaseResults = secondPhaseResultsB
+			combinedResults = secondPhaseResultsA[0:limit] // reuse this
+		} else {
+			secondPhaseResults = secondPhaseResultsA
+			combinedResults = secondPhaseResultsB[0:limit] // reuse this
+		}
+
+		all := [][]internal.IterEvent{firstPhaseResults, secondPhaseResults}
+		combinedResults = internal.MergeSortMultiple(all, limit, combinedResults)
+	} else {
+		combinedResults = make([]internal.IterEvent, limit)
+		combinedResults = internal.MergeSortMultiple(results, limit, combinedResults)
+	}
+
+	return combinedResults, nil
+}
@@ -0,0 +1,35 @@
+package bbolt
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/fiatjaf/eventstore"
+)
+
+// magic is what bbolt writes to the meta pages at the start of the file, right after the page header.
+const magic uint32 = 0xED0CDAED
+
+func init() {
+	eventstore.Register(eventstore.Driver{
+		Name:    "bbolt",
+		Schemes: []string{"bbolt", "bolt"},
+		Detect: func(path string) bool {
+			f, err := os.Open(path)
+			if err != nil {
+				return false
+			}
+			defer f.Close()
+			header := make([]byte, 16+4)
+			if _, err := io.ReadFull(f, header); err != nil {
+				return false
+			}
+			// it's in the byte order of the machine that created the file
+			return binary.LittleEndian.Uint32(header[16:]) == magic || binary.BigEndian.Uint32(header[16:]) == magic
+		},
+		New: func(uri string, opts eventstore.OpenOptions) (eventstore.Store, error) {
+			return &BBoltBackend{Path: eventstore.TrimScheme(uri, "bbolt", "bolt"), MaxLimit: opts.MaxLimit}, nil
+		},
+	})
+}
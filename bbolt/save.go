@@ -0,0 +1,80 @@
+package bbolt
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math"
+
+	"github.com/fiatjaf/eventstore"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
+	"github.com/nbd-wtf/go-nostr"
+	"go.etcd.io/bbolt"
+)
+
+func (b *BBoltBackend) SaveEvent(ctx context.Context, evt *nostr.Event) error {
+	// sanity checking
+	if evt.CreatedAt > math.MaxUint32 || evt.Kind < 0 || evt.Kind > math.MaxUint32 {
+		return fmt.Errorf("event with values out of expected boundaries")
+	}
+
+	return b.update(func(txn *bbolt.Tx) error {
+		return b.saveEvent(txn, evt)
+	})
+}
+
+// saveEvent does everything SaveEvent does except the sanity checks, inside a transaction we already have.
+func (b *BBoltBackend) saveEvent(txn *bbolt.Tx, evt *nostr.Event) error {
+	if b.EnableHLLCacheFor != nil {
+		// modify hyperloglog caches relative to this
+		useCache, skipSaving := b.EnableHLLCacheFor(evt.Kind)
+
+		if useCache {
+			err := b.updateHyperLogLogCachedValues(txn, evt)
+			if err != nil {
+				return fmt.Errorf("failed to update hll cache: %w", err)
+			}
+			if skipSaving {
+				return nil
+			}
+		}
+	}
+
+	// check if we already have this id
+	id, _ := hex.DecodeString(evt.ID)
+	if idx := getIdxForId(txn, id); idx != nil {
+		return eventstore.ErrDupEvent
+	}
+
+	return b.save(txn, evt)
+}
+
+func (b *BBoltBackend) save(txn *bbolt.Tx, evt *nostr.Event) error {
+	// encode to binary form so we'll save it
+	bin, err := bin.Encode(evt)
+	if err != nil {
+		return err
+	}
+
+	raw := txn.Bucket(bucketRaw)
+	idx, err := serial(raw)
+	if err != nil {
+		return err
+	}
+	// raw event store (refusing to overwrite anything, a serial can never be reused)
+	if raw.Get(idx) != nil {
+		return fmt.Errorf("%w: serial %x is already taken", eventstore.ErrCorrupted, idx)
+	}
+	if err := raw.Put(idx, bin); err != nil {
+		return err
+	}
+
+	// put indexes
+	for k := range getIndexKeysForEvent(evt) {
+		if err := txn.Bucket(k.bucket).Put(k.withIdx(idx), nil); err != nil {
+			return fmt.Errorf("failed to save index entry %s: %w", k, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package bbolt
+
+import (
+	"context"
+	"encoding/binary"
+
+	"github.com/fiatjaf/eventstore"
+	"go.etcd.io/bbolt"
+)
+
+// Stats reports the keys and pages used by each bucket. Events per kind are counted by walking
+// the kind index, so this takes longer as the database grows.
+func (b *BBoltBackend) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	stats := eventstore.StoreStats{
+		EventsPerKind: make(map[int]int64),
+		Indexes:       make(map[string]eventstore.IndexStats),
+	}
+
+	err := b.view(func(txn *bbolt.Tx) error {
+		pageSize := int64(txn.DB().Info().PageSize)
+
+		for _, name := range append([][]byte{bucketSettings, bucketRaw, bucketHLLCache}, indexBuckets...) {
+			s := txn.Bucket(name).Stats()
+			stats.Indexes[string(name)] = eventstore.IndexStats{
+				Entries: int64(s.KeyN),
+				Size:    int64(s.BranchPageN+s.BranchOverflowN+s.LeafPageN+s.LeafOverflowN) * pageSize,
+			}
+		}
+		stats.Events = stats.Indexes[string(bucketRaw)].Entries
+
+		if v := txn.Bucket(bucketSettings).Get([]byte{DB_VERSION}); v != nil {
+			stats.SchemaVersion = int(binary.BigEndian.Uint16(v))
+		}
+
+		// each event is in the kind index once, under its kind and created_at
+		cursor := txn.Bucket(bucketIndexKind).Cursor()
+		n := 0
+		for k, _ := cursor.First(); k != nil; k, _ = cursor.Next() {
+			stats.EventsPerKind[int(binary.BigEndian.Uint32(k[0:4]))]++
+			if n++; n%10000 == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+		}
+
+		// the file doesn't shrink, so this includes the free pages
+		stats.DiskSize = txn.Size()
+
+		return nil
+	})
+
+	return stats, err
+}
@@ -0,0 +1,62 @@
+package bbolt
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+	"go.etcd.io/bbolt"
+)
+
+var _ eventstore.Transactor = (*BBoltBackend)(nil)
+
+// Update runs fn inside a single write transaction.
+// Only one of these runs at a time, and no other writes happen while it does.
+func (b *BBoltBackend) Update(ctx context.Context, fn func(eventstore.Tx) error) error {
+	return b.update(func(txn *bbolt.Tx) error {
+		return fn(&tx{b: b, ctx: ctx, txn: txn})
+	})
+}
+
+type tx struct {
+	b   *BBoltBackend
+	ctx context.Context
+	txn *bbolt.Tx
+}
+
+func (t *tx) Query(filter nostr.Filter) ([]*nostr.Event, error) {
+	limit := t.b.getLimit(t.ctx, filter)
+	if limit == 0 {
+		return nil, nil
+	}
+
+	results, err := t.b.query(t.txn, filter, limit, false)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*nostr.Event, len(results))
+	for i, ie := range results {
+		events[i] = ie.Event
+	}
+	return events, nil
+}
+
+func (t *tx) Count(filter nostr.Filter) (int64, error) {
+	return t.b.count(t.txn, filter)
+}
+
+func (t *tx) Save(evt *nostr.Event) error {
+	// sanity checking
+	if evt.CreatedAt > math.MaxUint32 || evt.Kind < 0 || evt.Kind > math.MaxUint32 {
+		return fmt.Errorf("event with values out of expected boundaries")
+	}
+
+	return t.b.saveEvent(t.txn, evt)
+}
+
+func (t *tx) Delete(evt *nostr.Event) error {
+	return t.b.delete(t.txn, evt)
+}
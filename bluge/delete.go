@@ -7,5 +7,11 @@ import (
 )
 
 func (b *BlugeBackend) DeleteEvent(ctx context.Context, evt *nostr.Event) error {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	return b.writer.Delete(eventIdentifier(evt.ID))
 }
@@ -1,12 +1,15 @@
 package bluge
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"sync"
 
 	"github.com/blugelabs/bluge"
 	"github.com/blugelabs/bluge/analysis/token"
 	"github.com/fiatjaf/eventstore"
+	"github.com/fiatjaf/eventstore/internal/inflight"
 	"golang.org/x/text/unicode/norm"
 )
 
@@ -23,10 +26,23 @@ type BlugeBackend struct {
 
 	searchConfig bluge.Config
 	writer       *bluge.Writer
+
+	inflight inflight.Tracker
 }
 
+// Close is CloseContext with a timeout of inflight.CloseTimeout.
 func (b *BlugeBackend) Close() {
-	defer b.writer.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), inflight.CloseTimeout)
+	defer cancel()
+	if err := b.CloseContext(ctx); err != nil {
+		log.Printf("bluge: failed to close: %s\n", err)
+	}
+}
+
+// CloseContext closes the index once the running operations are done.
+// See [eventstore.GracefulCloser].
+func (b *BlugeBackend) CloseContext(ctx context.Context) error {
+	return b.inflight.Close(ctx, b.writer.Close)
 }
 
 func (b *BlugeBackend) Init() error {
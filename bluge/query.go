@@ -7,15 +7,26 @@ import (
 	"strconv"
 
 	"github.com/blugelabs/bluge"
-	"github.com/blugelabs/bluge/search"
 	"github.com/nbd-wtf/go-nostr"
 )
 
-func (b *BlugeBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (chan *nostr.Event, error) {
-	ch := make(chan *nostr.Event)
+func (b *BlugeBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (ch chan *nostr.Event, err error) {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// otherwise the goroutine below will release it
+		if err != nil {
+			done()
+		}
+	}()
+
+	ch = make(chan *nostr.Event)
 
 	if len(filter.Search) < 2 {
 		close(ch)
+		done()
 		return ch, nil
 	}
 
@@ -83,7 +94,7 @@ func (b *BlugeBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (ch
 
 	req := bluge.NewTopNSearch(limit, q)
 
-	dmi, err := reader.Search(context.Background(), req)
+	dmi, err := reader.Search(ctx, req)
 	if err != nil {
 		close(ch)
 		reader.Close()
@@ -91,11 +102,12 @@ func (b *BlugeBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (ch
 	}
 
 	go func() {
+		defer done()
 		defer reader.Close()
 		defer close(ch)
 
-		var next *search.DocumentMatch
-		for next, err = dmi.Next(); next != nil; next, err = dmi.Next() {
+		for next, err := dmi.Next(); next != nil && err == nil; next, err = dmi.Next() {
+			cancelled := false
 			next.VisitStoredFields(func(field string, value []byte) bool {
 				id := hex.EncodeToString(value)
 				rawch, err := b.RawEventStore.QueryEvents(ctx, nostr.Filter{IDs: []string{id}})
@@ -103,13 +115,18 @@ func (b *BlugeBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (ch
 					return false
 				}
 				for evt := range rawch {
-					ch <- evt
+					select {
+					case ch <- evt:
+					case <-ctx.Done():
+						cancelled = true
+						return false
+					}
 				}
 				return false
 			})
-		}
-		if err != nil {
-			return
+			if cancelled {
+				return
+			}
 		}
 	}()
 
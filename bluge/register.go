@@ -0,0 +1,56 @@
+package bluge
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/fiatjaf/eventstore/wrappers/search"
+)
+
+func init() {
+	eventstore.Register(eventstore.Driver{
+		Name:    "bluge",
+		Schemes: []string{"bluge"},
+		// the index only has ids, so the store with the events is opened too, from the "raw"
+		// parameter, as in "bluge:///var/lib/index?raw=lmdb:///var/lib/events", escaped if it has
+		// parameters of its own. it isn't closed along with the index.
+		New: func(uri string, opts eventstore.OpenOptions) (eventstore.Store, error) {
+			path, query, _ := strings.Cut(eventstore.TrimScheme(uri, "bluge"), "?")
+			params, err := url.ParseQuery(query)
+			if err != nil {
+				return nil, err
+			}
+			raw := params.Get("raw")
+			if raw == "" {
+				return nil, fmt.Errorf("missing 'raw' parameter with the uri of the store with the events")
+			}
+
+			rawStore, err := eventstore.Open(raw, eventstore.OpenOptions{MaxLimit: opts.MaxLimit})
+			if err != nil {
+				return nil, fmt.Errorf("failed to open raw event store: %w", err)
+			}
+			return &BlugeBackend{Path: path, RawEventStore: rawStore}, nil
+		},
+	})
+
+	// as a wrapper it indexes the events of the store it wraps and answers its search queries
+	eventstore.RegisterWrapper("bluge", func(inner eventstore.Store, options func(any) error) (eventstore.Store, error) {
+		var opts struct {
+			Path string `json:"path"`
+		}
+		if err := options(&opts); err != nil {
+			return nil, err
+		}
+		if opts.Path == "" {
+			return nil, fmt.Errorf("path: missing")
+		}
+
+		index := &BlugeBackend{Path: opts.Path, RawEventStore: inner}
+		if err := index.Init(); err != nil {
+			return nil, err
+		}
+		return search.Wrapper{Store: inner, Search: index}, nil
+	})
+}
@@ -10,6 +10,12 @@ import (
 )
 
 func (b *BlugeBackend) SaveEvent(ctx context.Context, evt *nostr.Event) error {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	id := eventIdentifier(evt.ID)
 	doc := &bluge.Document{
 		bluge.NewKeywordFieldBytes(id.Field(), id.Term()).Sortable().StoreValue(),
@@ -0,0 +1,66 @@
+package bluge
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/fiatjaf/eventstore/internal"
+)
+
+// Stats describes the search index only, the events themselves are in RawEventStore.
+// Counts per kind come from the term dictionary and may include deleted documents until
+// their segments are merged.
+func (b *BlugeBackend) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return eventstore.StoreStats{}, err
+	}
+	defer done()
+
+	stats := eventstore.StoreStats{
+		EventsPerKind: make(map[int]int64),
+		Indexes:       make(map[string]eventstore.IndexStats),
+	}
+
+	reader, err := b.writer.Reader()
+	if err != nil {
+		return stats, fmt.Errorf("unable to open reader: %w", err)
+	}
+	defer reader.Close()
+
+	count, err := reader.Count()
+	if err != nil {
+		return stats, fmt.Errorf("failed to count documents: %w", err)
+	}
+	stats.Events = int64(count)
+
+	dict, err := reader.DictionaryIterator(kindField, nil, nil, nil)
+	if err != nil {
+		return stats, fmt.Errorf("failed to read kinds: %w", err)
+	}
+	defer dict.Close()
+	for {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		entry, err := dict.Next()
+		if err != nil {
+			return stats, fmt.Errorf("failed to read kinds: %w", err)
+		}
+		if entry == nil {
+			break
+		}
+		if kind, err := strconv.Atoi(entry.Term()); err == nil {
+			stats.EventsPerKind[kind] = int64(entry.Count())
+		}
+	}
+
+	if stats.DiskSize, err = internal.DirSize(b.Path); err != nil {
+		return stats, fmt.Errorf("failed to get the size of %s: %w", b.Path, err)
+	}
+	stats.Indexes["search"] = eventstore.IndexStats{Entries: stats.Events, Size: stats.DiskSize}
+
+	return stats, nil
+}
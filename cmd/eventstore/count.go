@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/fiatjaf/eventstore/wrappers/count"
+	"github.com/urfave/cli/v3"
+)
+
+var count_ = &cli.Command{
+	Name:        "count",
+	ArgsUsage:   "[<filter-json>]",
+	Usage:       "counts the events matching a filter, takes a filter as argument or built from flags",
+	Description: "prints how many events in the currently open eventstore match each filter, one number per line.\ntakes filters the same way `query` does. stores that can't count natively have their events queried and counted.",
+	Flags:       filterFlags,
+	Action: func(ctx context.Context, c *cli.Command) error {
+		hasError := false
+		counter := count.Wrapper{Store: db}
+
+		for filter, err := range getFilters(c) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+				hasError = true
+				continue
+			}
+
+			n, err := counter.CountEvents(ctx, filter)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error counting: %s\n", err)
+				hasError = true
+				continue
+			}
+			fmt.Println(n)
+		}
+
+		if hasError {
+			os.Exit(123)
+		}
+		return nil
+	},
+}
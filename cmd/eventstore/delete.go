@@ -13,17 +13,43 @@ var delete_ = &cli.Command{
 	Name:        "delete",
 	ArgsUsage:   "[<id>]",
 	Usage:       "deletes an event by id and all its associated index entries",
-	Description: "takes an id either as an argument or reads a stream of ids from stdin and deletes them from the currently open eventstore.",
+	Description: "takes an id either as an argument or reads a stream of ids from stdin and deletes them from the currently open eventstore.\nthe filter flags narrow down which of these are deleted, or, with no ids given, delete all the events they match, like in `eventstore -d ./data delete -k 7 --until 30d`.",
+	Flags:       filterFlags,
 	Action: func(ctx context.Context, c *cli.Command) error {
 		hasError := false
-		for line := range getStdinLinesOrFirstArgument(c) {
-			f := nostr.Filter{IDs: []string{line}}
+
+		var lines chan string
+		if hasFilterFlags(c) && c.Args().First() == "" && !isPiped() {
+			lines = make(chan string, 1)
+			lines <- ""
+			close(lines)
+		} else {
+			lines = getStdinLinesOrFirstArgument(c)
+		}
+
+		for line := range lines {
+			f := nostr.Filter{}
+			if line != "" {
+				f.IDs = []string{line}
+			}
+			if err := applyFilterFlags(c, &f); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+				os.Exit(123)
+			}
+
 			ch, err := db.QueryEvents(ctx, f)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "error querying for %s: %s\n", f, err)
 				hasError = true
+				continue
 			}
+
+			// collect them first so we're not deleting while the query is still reading
+			var events []*nostr.Event
 			for evt := range ch {
+				events = append(events, evt)
+			}
+			for _, evt := range events {
 				if err := db.DeleteEvent(ctx, evt); err != nil {
 					fmt.Fprintf(os.Stderr, "error deleting %s: %s\n", evt, err)
 					hasError = true
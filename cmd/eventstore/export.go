@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/mailru/easyjson"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/urfave/cli/v3"
+)
+
+var export = &cli.Command{
+	Name:        "export",
+	ArgsUsage:   "[<filter-json>]",
+	Usage:       "writes all the events in the store, or the ones matching a filter, as JSONL",
+	Description: "streams every event matching the filter, one per line, ignoring the query limits of the store.\nlmdb and badger are read from a single snapshot, sql databases are paged through in order, other stores are paged through backwards in time.",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "file to write to instead of stdout",
+		},
+	},
+	Action: func(ctx context.Context, c *cli.Command) error {
+		filter := nostr.Filter{}
+		if arg := c.Args().First(); arg != "" {
+			if err := easyjson.Unmarshal([]byte(arg), &filter); err != nil {
+				fmt.Fprintf(os.Stderr, "invalid filter '%s': %s\n", arg, err)
+				os.Exit(123)
+			}
+		}
+
+		var w io.Writer = os.Stdout
+		if output := c.String("output"); output != "" {
+			f, err := os.Create(output)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to create '%s': %s\n", output, err)
+				os.Exit(123)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		pw := &progressWriter{w: w}
+		n, err := eventstore.Export(ctx, db, pw, filter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error exporting after %d events: %s\n", n, err)
+			os.Exit(123)
+		}
+
+		fmt.Fprintf(os.Stderr, "exported %d events\n", n)
+		return nil
+	},
+}
+
+// progressWriter counts the lines written through it and reports them on stderr every few seconds.
+type progressWriter struct {
+	w          io.Writer
+	lines      int64
+	lastReport time.Time
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if b == '\n' {
+			pw.lines++
+		}
+	}
+
+	if time.Since(pw.lastReport) > 5*time.Second {
+		if !pw.lastReport.IsZero() {
+			fmt.Fprintf(os.Stderr, "exported %d events...\n", pw.lines)
+		}
+		pw.lastReport = time.Now()
+	}
+
+	return pw.w.Write(p)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mailru/easyjson"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/urfave/cli/v3"
+)
+
+// filterFlags build a filter like nak does, they are applied on top of the JSON filters given.
+// --tag has no short alias, since -t is the global --type.
+var filterFlags = []cli.Flag{
+	&cli.StringSliceFlag{
+		Name:     "id",
+		Aliases:  []string{"i"},
+		Usage:    "only events with this id (can be repeated)",
+		Category: "FILTER",
+	},
+	&cli.StringSliceFlag{
+		Name:     "author",
+		Aliases:  []string{"a"},
+		Usage:    "only events by this pubkey (can be repeated)",
+		Category: "FILTER",
+	},
+	&cli.IntSliceFlag{
+		Name:     "kind",
+		Aliases:  []string{"k"},
+		Usage:    "only events of this kind (can be repeated)",
+		Category: "FILTER",
+	},
+	&cli.StringSliceFlag{
+		Name:     "tag",
+		Usage:    "only events with this tag, as key=value (can be repeated)",
+		Category: "FILTER",
+	},
+	&cli.StringFlag{
+		Name:     "since",
+		Aliases:  []string{"s"},
+		Usage:    "only events created at or after this time, a unix timestamp, a date, 'now' or a duration ago like '2d' or '3h'",
+		Category: "FILTER",
+	},
+	&cli.StringFlag{
+		Name:     "until",
+		Aliases:  []string{"u"},
+		Usage:    "only events created at or before this time, in the same formats as --since",
+		Category: "FILTER",
+	},
+	&cli.IntFlag{
+		Name:     "limit",
+		Aliases:  []string{"l"},
+		Usage:    "at most this many events",
+		Category: "FILTER",
+	},
+	&cli.StringFlag{
+		Name:     "search",
+		Usage:    "a NIP-50 search string",
+		Category: "FILTER",
+	},
+}
+
+// hasFilterFlags tells if any of the filterFlags was given.
+func hasFilterFlags(c *cli.Command) bool {
+	for _, fl := range filterFlags {
+		if c.IsSet(fl.Names()[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+// getFilters yields the filters given as an argument or through stdin, or a single empty filter
+// if there are none, with the filter flags applied to each.
+func getFilters(c *cli.Command) iter.Seq2[nostr.Filter, error] {
+	return func(yield func(nostr.Filter, error) bool) {
+		var lines chan string
+		if c.Args().First() != "" {
+			lines = getStdinLinesOrFirstArgument(c)
+		} else {
+			lines = getStdinLinesOrBlank()
+		}
+
+		for line := range lines {
+			filter := nostr.Filter{}
+			if line != "" {
+				if err := easyjson.Unmarshal([]byte(line), &filter); err != nil {
+					if !yield(filter, fmt.Errorf("invalid filter '%s': %w", line, err)) {
+						return
+					}
+					continue
+				}
+			}
+
+			err := applyFilterFlags(c, &filter)
+			if !yield(filter, err) {
+				return
+			}
+		}
+	}
+}
+
+func applyFilterFlags(c *cli.Command, filter *nostr.Filter) error {
+	filter.IDs = append(filter.IDs, c.StringSlice("id")...)
+	filter.Authors = append(filter.Authors, c.StringSlice("author")...)
+	for _, kind := range c.IntSlice("kind") {
+		filter.Kinds = append(filter.Kinds, int(kind))
+	}
+
+	for _, tag := range c.StringSlice("tag") {
+		key, value, ok := strings.Cut(tag, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid tag '%s', should be key=value", tag)
+		}
+		if filter.Tags == nil {
+			filter.Tags = make(nostr.TagMap)
+		}
+		filter.Tags[key] = append(filter.Tags[key], value)
+	}
+
+	for _, bound := range []struct {
+		name   string
+		target **nostr.Timestamp
+	}{
+		{"since", &filter.Since},
+		{"until", &filter.Until},
+	} {
+		if v := c.String(bound.name); v != "" {
+			ts, err := parseTimestamp(v)
+			if err != nil {
+				return fmt.Errorf("invalid --%s: %w", bound.name, err)
+			}
+			*bound.target = &ts
+		}
+	}
+
+	if c.IsSet("limit") {
+		filter.Limit = int(c.Int("limit"))
+		filter.LimitZero = filter.Limit == 0
+	}
+	if search := c.String("search"); search != "" {
+		filter.Search = search
+	}
+
+	return nil
+}
+
+// parseTimestamp takes a unix timestamp, 'now', a duration ago like '2d', '3h' or '1w2d', or a
+// date like '2024-01-31' or '2024-01-31T12:00:00Z'.
+func parseTimestamp(v string) (nostr.Timestamp, error) {
+	if v == "now" {
+		return nostr.Now(), nil
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return nostr.Timestamp(n), nil
+	}
+	if ago, err := parseDuration(v); err == nil {
+		return nostr.Timestamp(time.Now().Add(-ago).Unix()), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return nostr.Timestamp(t.Unix()), nil
+		}
+	}
+	return 0, fmt.Errorf("'%s' is not a timestamp, a date or a duration", v)
+}
+
+// parseDuration is like time.ParseDuration, but also takes days ('d') and weeks ('w').
+func parseDuration(v string) (time.Duration, error) {
+	var total time.Duration
+	rest := v
+	for rest != "" {
+		i := strings.IndexAny(rest, "dw")
+		if i == -1 {
+			d, err := time.ParseDuration(rest)
+			if err != nil {
+				return 0, err
+			}
+			return total + d, nil
+		}
+
+		n, err := strconv.Atoi(rest[:i])
+		if err != nil {
+			// days and weeks must come first, as in '1w2d3h'
+			return 0, fmt.Errorf("invalid duration '%s'", v)
+		}
+		unit := 24 * time.Hour
+		if rest[i] == 'w' {
+			unit *= 7
+		}
+		total += time.Duration(n) * unit
+		rest = rest[i+1:]
+	}
+	return total, nil
+}
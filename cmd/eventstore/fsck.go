@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/urfave/cli/v3"
+)
+
+var fsck = &cli.Command{
+	Name:        "fsck",
+	Usage:       "checks that the indexes match the stored events and optionally repairs them",
+	Description: "goes through all the stored events checking that they can be decoded and that all their index entries exist, then through all the index entries checking that they point to events that exist. prints a JSON report and exits with an error if problems were found and not repaired.\nonly works with lmdb and badger stores.",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "repair",
+			Usage: "add missing index entries, remove orphaned ones and delete events that can't be decoded",
+		},
+		&cli.BoolFlag{
+			Name:  "check-signatures",
+			Usage: "also check event ids and signatures (events that fail are only reported)",
+		},
+	},
+	Action: func(ctx context.Context, c *cli.Command) error {
+		verifier, ok := db.(eventstore.Verifier)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "this store can't be checked\n")
+			os.Exit(123)
+		}
+
+		report, err := verifier.Verify(ctx, eventstore.VerifyOptions{
+			Repair:          c.Bool("repair"),
+			CheckSignatures: c.Bool("check-signatures"),
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error verifying: %s\n", err)
+			os.Exit(123)
+		}
+
+		j, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(j))
+
+		// events with bad signatures are never repaired
+		if (!report.OK() && !report.Repaired) || report.InvalidEvents > 0 {
+			os.Exit(123)
+		}
+		return nil
+	},
+}
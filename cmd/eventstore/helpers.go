@@ -3,7 +3,6 @@ package main
 import (
 	"bufio"
 	"bytes"
-	"fmt"
 	"io"
 	"os"
 	"strings"
@@ -15,42 +14,6 @@ const (
 	LINE_PROCESSING_ERROR = iota
 )
 
-func detect(dir string) (string, error) {
-	f, err := os.Stat(dir)
-	if err != nil {
-		return "", err
-	}
-	if !f.IsDir() {
-		f, err := os.Open(dir)
-		if err != nil {
-			return "", err
-		}
-		buf := make([]byte, 15)
-		f.Read(buf)
-		if string(buf) == "SQLite format 3" {
-			return "sqlite", nil
-		}
-
-		return "", fmt.Errorf("unknown db format")
-	}
-
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return "", err
-	}
-
-	for _, entry := range entries {
-		if strings.HasSuffix(entry.Name(), ".mdb") {
-			return "lmdb", nil
-		}
-		if strings.HasSuffix(entry.Name(), ".vlog") {
-			return "badger", nil
-		}
-	}
-
-	return "", fmt.Errorf("undetected")
-}
-
 func getStdin() string {
 	stat, _ := os.Stdin.Stat()
 	if (stat.Mode() & os.ModeCharDevice) == 0 {
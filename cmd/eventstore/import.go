@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/urfave/cli/v3"
+)
+
+var import_ = &cli.Command{
+	Name:        "import",
+	ArgsUsage:   "[<file>]",
+	Usage:       "saves events from a JSONL file or stdin, skipping the ones already in the store",
+	Description: "reads events one per line and saves them in batches, in a single transaction per batch when the store supports it.\nwith --checkpoint the number of lines done is written to a file after each batch, so running the same command again after an interruption resumes from there. the checkpoint file is removed when the import finishes.",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "batch-size",
+			Usage: "how many events to save at a time",
+			Value: 1000,
+		},
+		&cli.StringFlag{
+			Name:  "checkpoint",
+			Usage: "file where progress is saved and resumed from",
+		},
+		&cli.BoolFlag{
+			Name:  "check-signatures",
+			Usage: "skip events with a bad id or signature",
+		},
+	},
+	Action: func(ctx context.Context, c *cli.Command) error {
+		var r io.Reader = os.Stdin
+		if path := c.Args().First(); path != "" {
+			f, err := os.Open(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to open '%s': %s\n", path, err)
+				os.Exit(123)
+			}
+			defer f.Close()
+			r = f
+		}
+
+		// counts from previous runs, when resuming
+		var previous eventstore.ImportProgress
+		checkpoint := c.String("checkpoint")
+		if checkpoint != "" {
+			if b, err := os.ReadFile(checkpoint); err == nil {
+				if err := json.Unmarshal(b, &previous); err != nil {
+					fmt.Fprintf(os.Stderr, "invalid checkpoint file '%s': %s\n", checkpoint, err)
+					os.Exit(123)
+				}
+				fmt.Fprintf(os.Stderr, "resuming from line %d\n", previous.Lines)
+			} else if !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "failed to read checkpoint file '%s': %s\n", checkpoint, err)
+				os.Exit(123)
+			}
+		}
+
+		total := func(p eventstore.ImportProgress) eventstore.ImportProgress {
+			p.Saved += previous.Saved
+			p.Duplicates += previous.Duplicates
+			p.Invalid += previous.Invalid
+			return p
+		}
+
+		progress, err := eventstore.Import(ctx, db, r, eventstore.ImportOptions{
+			BatchSize:       int(c.Int("batch-size")),
+			Skip:            previous.Lines,
+			CheckSignatures: c.Bool("check-signatures"),
+			OnProgress: func(p eventstore.ImportProgress) {
+				p = total(p)
+				fmt.Fprintf(os.Stderr, "line %d: %d saved, %d duplicates, %d invalid\n",
+					p.Lines, p.Saved, p.Duplicates, p.Invalid)
+				if checkpoint != "" {
+					if err := writeJSONFile(checkpoint, p); err != nil {
+						fmt.Fprintf(os.Stderr, "failed to write checkpoint: %s\n", err)
+					}
+				}
+			},
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error importing: %s\n", err)
+			os.Exit(123)
+		}
+
+		if checkpoint != "" {
+			os.Remove(checkpoint)
+		}
+
+		j, _ := json.MarshalIndent(total(progress), "", "  ")
+		fmt.Println(string(j))
+		return nil
+	},
+}
+
+// writeJSONFile replaces the file at path with v as JSON, so a checkpoint is never left half-written.
+func writeJSONFile(path string, v any) error {
+	j, _ := json.Marshal(v)
+	if err := os.WriteFile(path+".tmp", j, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
@@ -10,15 +10,26 @@ import (
 	"strings"
 
 	"github.com/fiatjaf/eventstore"
-	"github.com/fiatjaf/eventstore/badger"
-	"github.com/fiatjaf/eventstore/dynamodb"
-	"github.com/fiatjaf/eventstore/elasticsearch"
-	"github.com/fiatjaf/eventstore/lmdb"
-	"github.com/fiatjaf/eventstore/mysql"
-	"github.com/fiatjaf/eventstore/postgresql"
+	_ "github.com/fiatjaf/eventstore/badger"
+	_ "github.com/fiatjaf/eventstore/bbolt"
+	_ "github.com/fiatjaf/eventstore/bluge"
+	_ "github.com/fiatjaf/eventstore/dynamodb"
+	_ "github.com/fiatjaf/eventstore/edgedb"
+	_ "github.com/fiatjaf/eventstore/elasticsearch"
+	_ "github.com/fiatjaf/eventstore/jsonlstore"
+	_ "github.com/fiatjaf/eventstore/lmdb"
+	_ "github.com/fiatjaf/eventstore/memstore"
+	_ "github.com/fiatjaf/eventstore/mongo"
+	_ "github.com/fiatjaf/eventstore/mysql"
+	_ "github.com/fiatjaf/eventstore/opensearch"
+	_ "github.com/fiatjaf/eventstore/pebble"
+	_ "github.com/fiatjaf/eventstore/postgresql"
 	"github.com/fiatjaf/eventstore/slicestore"
-	"github.com/fiatjaf/eventstore/sqlite3"
-	"github.com/fiatjaf/eventstore/strfry"
+	_ "github.com/fiatjaf/eventstore/sqlite3"
+	_ "github.com/fiatjaf/eventstore/strfry"
+	_ "github.com/fiatjaf/eventstore/turso"
+	_ "github.com/fiatjaf/eventstore/wrappers/disablesearch"
+	_ "github.com/fiatjaf/eventstore/wrappers/skipevent"
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/urfave/cli/v3"
 )
@@ -28,138 +39,99 @@ var db eventstore.Store
 var app = &cli.Command{
 	Name:      "eventstore",
 	Usage:     "a CLI for all the eventstore backends",
-	UsageText: "eventstore -d ./data/sqlite <query|save|delete> ...",
+	UsageText: "eventstore -d ./data/sqlite <query|count|save|delete|stats|fsck|export|import|migrate|serve> ...",
 	Flags: []cli.Flag{
 		&cli.StringFlag{
-			Name:     "store",
-			Aliases:  []string{"d"},
-			Usage:    "path to the database file or directory or database connection uri",
-			Required: true,
+			Name:    "store",
+			Aliases: []string{"d"},
+			Usage:   "path to the database file or directory or database connection uri",
+		},
+		&cli.StringFlag{
+			Name:    "config",
+			Aliases: []string{"c"},
+			Usage:   "JSON file describing a store and the wrappers around it, used instead of --store",
 		},
 		&cli.StringFlag{
 			Name:    "type",
 			Aliases: []string{"t"},
-			Usage:   "store type ('sqlite', 'lmdb', 'badger', 'postgres', 'mysql', 'elasticsearch', 'dynamodb')",
+			Usage:   "store type (" + strings.Join(append(eventstore.Drivers(), "file"), ", ") + "), detected from the uri scheme or the files on disk when not given",
 		},
 	},
 	Before: func(ctx context.Context, c *cli.Command) (context.Context, error) {
-		path := strings.Trim(c.String("store"), "/")
-		typ := c.String("type")
-		if typ != "" {
-			// bypass automatic detection
-			// this also works for creating disk databases from scratch
-		} else {
-			// try to detect based on url scheme
-			switch {
-			case strings.HasPrefix(path, "postgres://"), strings.HasPrefix(path, "postgresql://"):
-				typ = "postgres"
-			case strings.HasPrefix(path, "mysql://"):
-				typ = "mysql"
-			case strings.HasPrefix(path, "https://"):
-				// if we ever add something else that uses URLs we'll have to modify this
-				typ = "elasticsearch"
-			case strings.HasSuffix(path, ".conf"):
-				typ = "strfry"
-			case strings.HasSuffix(path, ".jsonl"):
-				typ = "file"
-			default:
-				// try to detect based on the form and names of disk files
-				dbname, err := detect(path)
-				if err != nil {
-					if os.IsNotExist(err) {
-						return ctx, fmt.Errorf(
-							"'%s' does not exist, to create a store there specify the --type argument", path)
-					}
-					return ctx, fmt.Errorf("failed to detect store type: %w", err)
-				}
-				typ = dbname
-			}
+		if config := c.String("config"); config != "" {
+			var err error
+			db, err = eventstore.LoadConfig(config)
+			return ctx, err
 		}
 
-		switch typ {
-		case "sqlite":
-			db = &sqlite3.SQLite3Backend{
-				DatabaseURL:       path,
-				QueryLimit:        1_000_000,
-				QueryAuthorsLimit: 1_000_000,
-				QueryKindsLimit:   1_000_000,
-				QueryIDsLimit:     1_000_000,
-				QueryTagsLimit:    1_000_000,
-			}
-		case "lmdb":
-			db = &lmdb.LMDBBackend{Path: path, MaxLimit: 1_000_000}
-		case "badger":
-			db = &badger.BadgerBackend{Path: path, MaxLimit: 1_000_000}
-		case "postgres", "postgresql":
-			db = &postgresql.PostgresBackend{
-				DatabaseURL:       path,
-				QueryLimit:        1_000_000,
-				QueryAuthorsLimit: 1_000_000,
-				QueryKindsLimit:   1_000_000,
-				QueryIDsLimit:     1_000_000,
-				QueryTagsLimit:    1_000_000,
-			}
-		case "mysql":
-			db = &mysql.MySQLBackend{
-				DatabaseURL:       path,
-				QueryLimit:        1_000_000,
-				QueryAuthorsLimit: 1_000_000,
-				QueryKindsLimit:   1_000_000,
-				QueryIDsLimit:     1_000_000,
-				QueryTagsLimit:    1_000_000,
+		if c.String("store") == "" {
+			if c.Args().First() == "migrate" {
+				// migrate opens its own stores
+				return ctx, nil
 			}
-		case "elasticsearch":
-			db = &elasticsearch.ElasticsearchStorage{URL: path}
-		case "strfry":
-			db = &strfry.StrfryBackend{ConfigPath: path}
-		case "dynamodb":
-			db = &dynamodb.DynamoDBBackend{
-				DatabaseURL: path,
-			}
-		case "file":
-			db = &slicestore.SliceStore{}
-
-			// run this after we've called db.Init()
-			defer func() {
-				f, err := os.Open(path)
-				if err != nil {
-					log.Printf("failed to file at '%s': %s\n", path, err)
-					os.Exit(3)
-				}
-				scanner := bufio.NewScanner(f)
-				scanner.Buffer(make([]byte, 16*1024*1024), 256*1024*1024)
-				i := 0
-				for scanner.Scan() {
-					var evt nostr.Event
-					if err := json.Unmarshal(scanner.Bytes(), &evt); err != nil {
-						log.Printf("invalid event read at line %d: %s (`%s`)\n", i, err, scanner.Text())
-					}
-					db.SaveEvent(ctx, &evt)
-					i++
-				}
-			}()
-		case "":
-			return ctx, fmt.Errorf("couldn't determine store type, you can use --type to specify it manually")
-		default:
-			return ctx, fmt.Errorf("'%s' store type is not supported by this CLI", typ)
-		}
-
-		if err := db.Init(); err != nil {
-			return ctx, err
+			return ctx, fmt.Errorf("--store or --config is required")
 		}
 
-		return ctx, nil
+		var err error
+		db, err = openStore(ctx, c.String("store"), c.String("type"))
+		return ctx, err
 	},
 	Commands: []*cli.Command{
 		queryOrSave,
 		query,
+		count_,
 		save,
 		delete_,
 		neg,
+		stats,
+		fsck,
+		export,
+		import_,
+		migrate,
+		serve,
 	},
 	DefaultCommand: "query-or-save",
 }
 
+// openStore opens and initializes the store at path, detecting its type unless typ is given.
+func openStore(ctx context.Context, path string, typ string) (eventstore.Store, error) {
+	path = strings.Trim(path, "/")
+	if typ == "file" || (typ == "" && !strings.Contains(path, "://") && strings.HasSuffix(path, ".jsonl")) {
+		// only read, jsonl:// or --type jsonl are needed to write to the file
+		return openFile(ctx, path)
+	}
+
+	return eventstore.Open(path, eventstore.OpenOptions{Type: typ, MaxLimit: 1_000_000})
+}
+
+// openFile loads the events in a JSONL file into memory.
+func openFile(ctx context.Context, path string) (eventstore.Store, error) {
+	db := &slicestore.SliceStore{MaxLimit: 1_000_000}
+	if err := db.Init(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file at '%s': %w", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 16*1024*1024), 256*1024*1024)
+	i := 0
+	for scanner.Scan() {
+		var evt nostr.Event
+		if err := json.Unmarshal(scanner.Bytes(), &evt); err != nil {
+			log.Printf("invalid event read at line %d: %s (`%s`)\n", i, err, scanner.Text())
+		}
+		db.SaveEvent(ctx, &evt)
+		i++
+	}
+
+	return db, scanner.Err()
+}
+
 func main() {
 	if err := app.Run(context.Background(), os.Args); err != nil {
 		fmt.Println(err)
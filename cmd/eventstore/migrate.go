@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/mailru/easyjson"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/urfave/cli/v3"
+)
+
+var migrate = &cli.Command{
+	Name:        "migrate",
+	ArgsUsage:   "[<filter-json>]",
+	Usage:       "copies all the events, or the ones matching a filter, from one store to another",
+	Description: "streams every event from the source store, oldest first when the source supports it, and saves them to the destination in parallel batches, skipping the ones it already has. replaceable and addressable events are saved with ReplaceEvent so only the newest of each is kept.\nwhen it's done the events in both stores are counted and it fails if the destination is missing some.\nwith --checkpoint the position reached is written to a file after each batch, so running the same command again after an interruption resumes from there. the checkpoint file is removed when the migration finishes.\n--store is not used, --from and --to take the same values it does.",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "from",
+			Usage:    "path or connection uri of the store to read from",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "from-type",
+			Usage: "type of the store to read from, when it can't be detected",
+		},
+		&cli.StringFlag{
+			Name:     "to",
+			Usage:    "path or connection uri of the store to write to",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "to-type",
+			Usage: "type of the store to write to, needed to create it if it doesn't exist",
+		},
+		&cli.IntFlag{
+			Name:  "batch-size",
+			Usage: "how many events to save at a time",
+			Value: 1000,
+		},
+		&cli.IntFlag{
+			Name:  "parallel",
+			Usage: "how many batches to save at the same time",
+			Value: 4,
+		},
+		&cli.StringFlag{
+			Name:  "checkpoint",
+			Usage: "file where progress is saved and resumed from",
+		},
+	},
+	Action: func(ctx context.Context, c *cli.Command) error {
+		filter := nostr.Filter{}
+		if arg := c.Args().First(); arg != "" {
+			if err := easyjson.Unmarshal([]byte(arg), &filter); err != nil {
+				fmt.Fprintf(os.Stderr, "invalid filter '%s': %s\n", arg, err)
+				os.Exit(123)
+			}
+		}
+
+		src, err := openStore(ctx, c.String("from"), c.String("from-type"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open source store: %s\n", err)
+			os.Exit(123)
+		}
+		defer src.Close()
+
+		dst, err := openStore(ctx, c.String("to"), c.String("to-type"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open destination store: %s\n", err)
+			os.Exit(123)
+		}
+		defer dst.Close()
+
+		// exporters go oldest first, the others newest first
+		_, oldestFirst := src.(eventstore.Exporter)
+
+		var cp migrationCheckpoint
+		checkpoint := c.String("checkpoint")
+		if checkpoint != "" {
+			if b, err := os.ReadFile(checkpoint); err == nil {
+				if err := json.Unmarshal(b, &cp); err != nil {
+					fmt.Fprintf(os.Stderr, "invalid checkpoint file '%s': %s\n", checkpoint, err)
+					os.Exit(123)
+				}
+				fmt.Fprintf(os.Stderr, "resuming from %d after %d events\n", cp.CreatedAt, cp.Events)
+			} else if !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "failed to read checkpoint file '%s': %s\n", checkpoint, err)
+				os.Exit(123)
+			}
+		}
+
+		// everything before the checkpoint is already there, events at its timestamp are read again
+		// and skipped as duplicates
+		resumed := filter
+		if cp.Events > 0 {
+			ts := cp.CreatedAt
+			if oldestFirst && (filter.Since == nil || *filter.Since < ts) {
+				resumed.Since = &ts
+			} else if !oldestFirst && (filter.Until == nil || *filter.Until > ts) {
+				resumed.Until = &ts
+			}
+		}
+
+		batchSize := int(c.Int("batch-size"))
+		parallel := int(c.Int("parallel"))
+		if batchSize < 1 || parallel < 1 {
+			fmt.Fprintf(os.Stderr, "--batch-size and --parallel must be positive\n")
+			os.Exit(123)
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		type batch struct {
+			seq    int
+			events []*nostr.Event
+		}
+		batches := make(chan batch)
+
+		var (
+			mu       sync.Mutex
+			firstErr error
+			saved    int64
+			dups     int64
+			// batches are done out of order, the checkpoint only moves past a batch when all the
+			// ones before it are done too
+			done     = make(map[int]batch)
+			next     int
+			progress = cp
+		)
+		fail := func(err error) {
+			mu.Lock()
+			if firstErr == nil {
+				firstErr = err
+			}
+			mu.Unlock()
+			cancel()
+		}
+
+		var wg sync.WaitGroup
+		for range parallel {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for b := range batches {
+					s, d, err := eventstore.SaveBatch(ctx, dst, b.events)
+					if err != nil {
+						fail(err)
+						continue
+					}
+
+					mu.Lock()
+					saved += s
+					dups += d
+					done[b.seq] = b
+					for {
+						b, ok := done[next]
+						if !ok {
+							break
+						}
+						delete(done, next)
+						next++
+						progress.Events += int64(len(b.events))
+						progress.CreatedAt = b.events[len(b.events)-1].CreatedAt
+					}
+					fmt.Fprintf(os.Stderr, "%d saved, %d duplicates\n", saved, dups)
+					if checkpoint != "" {
+						if err := writeJSONFile(checkpoint, progress); err != nil {
+							fmt.Fprintf(os.Stderr, "failed to write checkpoint: %s\n", err)
+						}
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+
+		seq := 0
+		current := make([]*nostr.Event, 0, batchSize)
+		send := func() bool {
+			select {
+			case batches <- batch{seq: seq, events: current}:
+				seq++
+				current = make([]*nostr.Event, 0, batchSize)
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for evt, err := range eventstore.AllEvents(ctx, src, resumed) {
+			if err != nil {
+				fail(err)
+				break
+			}
+			current = append(current, evt)
+			if len(current) == batchSize && !send() {
+				break
+			}
+		}
+		if len(current) > 0 {
+			send()
+		}
+		close(batches)
+		wg.Wait()
+
+		if firstErr != nil {
+			fmt.Fprintf(os.Stderr, "error migrating: %s\n", firstErr)
+			os.Exit(123)
+		}
+
+		// everything was copied, now check that it's all there
+		expected, err := countExpected(ctx, src, filter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error counting source events: %s\n", err)
+			os.Exit(123)
+		}
+		var have int64
+		for _, err := range eventstore.AllEvents(ctx, dst, filter) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error counting destination events: %s\n", err)
+				os.Exit(123)
+			}
+			have++
+		}
+		fmt.Fprintf(os.Stderr, "source has %d events (replaceable ones counted once), destination has %d\n", expected, have)
+		if have < expected {
+			fmt.Fprintf(os.Stderr, "destination is missing %d events\n", expected-have)
+			os.Exit(123)
+		}
+
+		if checkpoint != "" {
+			os.Remove(checkpoint)
+		}
+		return nil
+	},
+}
+
+// migrationCheckpoint is how far a migration got, all the events before CreatedAt (in the order
+// they are read from the source) were saved.
+type migrationCheckpoint struct {
+	CreatedAt nostr.Timestamp `json:"created_at"`
+	Events    int64           `json:"events"`
+}
+
+// countExpected counts the events in db matching filter, counting only the newest of each
+// replaceable or addressable event, since that's all a store that replaces them will keep.
+func countExpected(ctx context.Context, db eventstore.Store, filter nostr.Filter) (int64, error) {
+	var count int64
+	addresses := make(map[string]struct{})
+	for evt, err := range eventstore.AllEvents(ctx, db, filter) {
+		if err != nil {
+			return 0, err
+		}
+
+		var address string
+		switch {
+		case nostr.IsReplaceableKind(evt.Kind):
+			address = evt.PubKey + ":" + strconv.Itoa(evt.Kind)
+		case nostr.IsAddressableKind(evt.Kind):
+			address = evt.PubKey + ":" + strconv.Itoa(evt.Kind) + ":" + evt.Tags.GetD()
+		default:
+			count++
+			continue
+		}
+		if _, ok := addresses[address]; !ok {
+			addresses[address] = struct{}{}
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/urfave/cli/v3"
+)
+
+var formatFlag = &cli.StringFlag{
+	Name:    "format",
+	Aliases: []string{"f"},
+	Usage:   "how to print events: 'jsonl' (one per line), 'json' (indented), 'id' (only ids) or 'csv' (a table with a header)",
+	Value:   "jsonl",
+	Validator: func(format string) error {
+		switch format {
+		case "jsonl", "json", "id", "csv":
+			return nil
+		}
+		return fmt.Errorf("unknown format '%s'", format)
+	},
+}
+
+// eventPrinter writes events in one of the formats of formatFlag, Flush must be called at the end.
+type eventPrinter struct {
+	format string
+	w      io.Writer
+	csv    *csv.Writer
+}
+
+func newEventPrinter(w io.Writer, format string) *eventPrinter {
+	p := &eventPrinter{format: format, w: w}
+	if format == "csv" {
+		p.csv = csv.NewWriter(w)
+		p.csv.Write([]string{"id", "pubkey", "created_at", "kind", "tags", "content", "sig"})
+	}
+	return p
+}
+
+func (p *eventPrinter) Print(evt *nostr.Event) {
+	switch p.format {
+	case "json":
+		j, _ := json.MarshalIndent(evt, "", "  ")
+		fmt.Fprintln(p.w, string(j))
+	case "id":
+		fmt.Fprintln(p.w, evt.ID)
+	case "csv":
+		tags, _ := json.Marshal(evt.Tags)
+		p.csv.Write([]string{
+			evt.ID,
+			evt.PubKey,
+			strconv.FormatInt(int64(evt.CreatedAt), 10),
+			strconv.Itoa(evt.Kind),
+			string(tags),
+			evt.Content,
+			evt.Sig,
+		})
+	default:
+		fmt.Fprintln(p.w, evt)
+	}
+}
+
+func (p *eventPrinter) Flush() {
+	if p.csv != nil {
+		p.csv.Flush()
+	}
+}
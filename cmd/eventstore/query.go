@@ -5,22 +5,23 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/mailru/easyjson"
-	"github.com/nbd-wtf/go-nostr"
 	"github.com/urfave/cli/v3"
 )
 
 var query = &cli.Command{
 	Name:        "query",
 	ArgsUsage:   "[<filter-json>]",
-	Usage:       "queries an eventstore for events, takes a filter as argument",
-	Description: "applies the filter to the currently open eventstore, returning up to a million events.\n takes either a filter as an argument or reads a stream of filters from stdin.",
+	Usage:       "queries an eventstore for events, takes a filter as argument or built from flags",
+	Description: "applies the filter to the currently open eventstore, returning up to a million events.\n takes either a filter as an argument or reads a stream of filters from stdin, the filter flags are added to each of them.\nwith no filter and no stdin the filter is made from the flags alone, like in `eventstore -d ./data query -k 1 -a <pubkey> --since 2d`.",
+	Flags:       append(filterFlags, formatFlag),
 	Action: func(ctx context.Context, c *cli.Command) error {
 		hasError := false
-		for line := range getStdinLinesOrFirstArgument(c) {
-			filter := nostr.Filter{}
-			if err := easyjson.Unmarshal([]byte(line), &filter); err != nil {
-				fmt.Fprintf(os.Stderr, "invalid filter '%s': %s\n", line, err)
+		printer := newEventPrinter(os.Stdout, c.String("format"))
+		defer printer.Flush()
+
+		for filter, err := range getFilters(c) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
 				hasError = true
 				continue
 			}
@@ -33,11 +34,12 @@ var query = &cli.Command{
 			}
 
 			for evt := range ch {
-				fmt.Println(evt)
+				printer.Print(evt)
 			}
 		}
 
 		if hasError {
+			printer.Flush()
 			os.Exit(123)
 		}
 		return nil
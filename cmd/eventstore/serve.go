@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/fiatjaf/eventstore"
+	"github.com/fiatjaf/eventstore/wrappers/count"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip77"
+	"github.com/nbd-wtf/go-nostr/nip77/negentropy"
+	"github.com/nbd-wtf/go-nostr/nip77/negentropy/storage/vector"
+	"github.com/urfave/cli/v3"
+)
+
+var serve = &cli.Command{
+	Name:        "serve",
+	Usage:       "exposes the store as a nostr relay over websockets",
+	Description: "accepts REQ, EVENT, CLOSE, COUNT (NIP-45) and negentropy (NIP-77) messages, queries and saves events in the currently open eventstore and sends events to the subscriptions they match as they are published.\nevents are checked for a valid id and signature, ephemeral ones are only sent to subscriptions and replaceable ones replace the older versions.",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "listen",
+			Usage: "address to listen on",
+			Value: "127.0.0.1:7777",
+		},
+	},
+	Action: func(ctx context.Context, c *cli.Command) error {
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		defer stop()
+
+		server := &http.Server{
+			Addr:    c.String("listen"),
+			Handler: newRelay(db),
+			BaseContext: func(_ net.Listener) context.Context {
+				return ctx
+			},
+		}
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+
+		fmt.Fprintf(os.Stderr, "listening on ws://%s\n", server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "error serving: %s\n", err)
+			os.Exit(123)
+		}
+
+		db.Close()
+		return nil
+	},
+}
+
+// negentropyFrameSizeLimit is the largest NEG-MSG we send, the same go-nostr clients use.
+const negentropyFrameSizeLimit = 1024 * 1024
+
+// liveQueueSize is how many published events can be waiting to be sent to a client before it is
+// considered too slow and disconnected.
+const liveQueueSize = 1000
+
+// relay is a minimal NIP-01 relay on top of a store, with no authentication or rate limiting.
+type relay struct {
+	store   eventstore.RelayWrapper
+	counter eventstore.Counter
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+}
+
+func newRelay(db eventstore.Store) *relay {
+	return &relay{
+		store:   eventstore.RelayWrapper{Store: db},
+		counter: count.Wrapper{Store: db},
+		clients: make(map[*client]struct{}),
+	}
+}
+
+func (rl *relay) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Header.Get("Upgrade") == "websocket":
+		rl.handleWebsocket(w, r)
+	case r.Header.Get("Accept") == "application/nostr+json":
+		w.Header().Set("Content-Type", "application/nostr+json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		json.NewEncoder(w).Encode(map[string]any{
+			"name":           "eventstore",
+			"description":    "a relay serving an eventstore",
+			"software":       "https://github.com/fiatjaf/eventstore",
+			"supported_nips": []int{1, 11, 45, 77},
+		})
+	default:
+		fmt.Fprintln(w, "this is a nostr relay, connect to it with a nostr client.")
+	}
+}
+
+func (rl *relay) handleWebsocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{InsecureSkipVerify: true})
+	if err != nil {
+		return
+	}
+	conn.SetReadLimit(4 * 1024 * 1024)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	cl := &client{
+		conn:          conn,
+		ctx:           ctx,
+		cancel:        cancel,
+		replies:       make(chan []byte),
+		live:          make(chan []byte, liveQueueSize),
+		subscriptions: make(map[string]subscription),
+		negentropy:    make(map[string]*negentropy.Negentropy),
+	}
+	go cl.writeLoop()
+	rl.mu.Lock()
+	rl.clients[cl] = struct{}{}
+	rl.mu.Unlock()
+	defer func() {
+		rl.mu.Lock()
+		delete(rl.clients, cl)
+		rl.mu.Unlock()
+		conn.CloseNow()
+	}()
+
+	for {
+		_, msg, err := conn.Read(ctx)
+		if err != nil {
+			return
+		}
+		rl.handleMessage(ctx, cl, string(msg))
+	}
+}
+
+func (rl *relay) handleMessage(ctx context.Context, cl *client, msg string) {
+	envelope := nostr.ParseMessage(msg)
+	if envelope == nil {
+		envelope = nip77.ParseNegMessage(msg)
+	}
+
+	switch env := envelope.(type) {
+	case *nostr.EventEnvelope:
+		rl.handleEvent(ctx, cl, &env.Event)
+	case *nostr.ReqEnvelope:
+		rl.handleReq(ctx, cl, env.SubscriptionID, env.Filters)
+	case *nostr.CloseEnvelope:
+		cl.unsubscribe(string(*env))
+	case *nostr.CountEnvelope:
+		n, err := rl.counter.CountEvents(ctx, env.Filter)
+		if err != nil {
+			cl.write(&nostr.ClosedEnvelope{SubscriptionID: env.SubscriptionID, Reason: "error: " + err.Error()})
+			return
+		}
+		cl.write(&nostr.CountEnvelope{SubscriptionID: env.SubscriptionID, Count: &n})
+	case *nip77.OpenEnvelope:
+		rl.handleNegentropyOpen(ctx, cl, env)
+	case *nip77.MessageEnvelope:
+		cl.mu.Lock()
+		neg, ok := cl.negentropy[env.SubscriptionID]
+		cl.mu.Unlock()
+		if !ok {
+			cl.writeNegentropyError(env.SubscriptionID, "closed: unknown session")
+			return
+		}
+		rl.reconcile(ctx, cl, env.SubscriptionID, neg, env.Message)
+	case *nip77.CloseEnvelope:
+		cl.mu.Lock()
+		delete(cl.negentropy, env.SubscriptionID)
+		cl.mu.Unlock()
+	case nil:
+		notice := nostr.NoticeEnvelope("failed to parse message")
+		cl.write(&notice)
+	default:
+		notice := nostr.NoticeEnvelope(envelope.Label() + " messages are not supported")
+		cl.write(&notice)
+	}
+}
+
+func (rl *relay) handleEvent(ctx context.Context, cl *client, evt *nostr.Event) {
+	if !evt.CheckID() {
+		cl.write(&nostr.OKEnvelope{EventID: evt.ID, OK: false, Reason: "invalid: id doesn't match the event"})
+		return
+	}
+	if ok, _ := evt.CheckSignature(); !ok {
+		cl.write(&nostr.OKEnvelope{EventID: evt.ID, OK: false, Reason: "invalid: bad signature"})
+		return
+	}
+
+	if err := rl.store.Publish(ctx, *evt); err != nil {
+		cl.write(&nostr.OKEnvelope{EventID: evt.ID, OK: false, Reason: "error: " + err.Error()})
+		return
+	}
+	cl.write(&nostr.OKEnvelope{EventID: evt.ID, OK: true})
+
+	rl.broadcast(evt)
+}
+
+func (rl *relay) handleReq(ctx context.Context, cl *client, id string, filters nostr.Filters) {
+	// the subscription is live from the start, so events published while the stored ones are
+	// being sent may come twice, but none are missed
+	ctx, cancel := context.WithCancel(ctx)
+	cl.subscribe(id, subscription{filters: filters, ctx: ctx, cancel: cancel})
+
+	// ctx is cancelled by CLOSE or by a REQ with the same id, then we just stop sending
+	go func() {
+		for _, filter := range filters {
+			ch, err := rl.store.QueryEvents(ctx, filter)
+			if err != nil {
+				if ctx.Err() == nil {
+					cl.write(&nostr.ClosedEnvelope{SubscriptionID: id, Reason: "error: " + err.Error()})
+					cl.unsubscribe(id)
+				}
+				return
+			}
+			for evt := range ch {
+				if ctx.Err() != nil {
+					return
+				}
+				cl.write(&nostr.EventEnvelope{SubscriptionID: &id, Event: *evt})
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		eose := nostr.EOSEEnvelope(id)
+		cl.write(&eose)
+	}()
+}
+
+// broadcast sends evt to all the subscriptions it matches.
+func (rl *relay) broadcast(evt *nostr.Event) {
+	rl.mu.Lock()
+	clients := make([]*client, 0, len(rl.clients))
+	for cl := range rl.clients {
+		clients = append(clients, cl)
+	}
+	rl.mu.Unlock()
+
+	for _, cl := range clients {
+		for id, ctx := range cl.matching(evt) {
+			if ctx.Err() != nil {
+				continue
+			}
+			cl.writeLive(&nostr.EventEnvelope{SubscriptionID: &id, Event: *evt})
+		}
+	}
+}
+
+func (rl *relay) handleNegentropyOpen(ctx context.Context, cl *client, env *nip77.OpenEnvelope) {
+	ctx = eventstore.SetNegentropy(ctx)
+
+	vec := vector.New()
+	if querier, ok := rl.store.Store.(eventstore.IDQuerier); ok {
+		for item, err := range querier.QueryIDs(ctx, env.Filter) {
+			if err != nil {
+				cl.writeNegentropyError(env.SubscriptionID, "error: "+err.Error())
+				return
+			}
+			vec.Insert(item.CreatedAt, item.ID)
+		}
+	} else {
+		ch, err := rl.store.QueryEvents(ctx, env.Filter)
+		if err != nil {
+			cl.writeNegentropyError(env.SubscriptionID, "error: "+err.Error())
+			return
+		}
+		for evt := range ch {
+			vec.Insert(evt.CreatedAt, evt.ID)
+		}
+	}
+	vec.Seal()
+
+	neg := negentropy.New(vec, negentropyFrameSizeLimit)
+	cl.mu.Lock()
+	cl.negentropy[env.SubscriptionID] = neg
+	cl.mu.Unlock()
+
+	rl.reconcile(ctx, cl, env.SubscriptionID, neg, env.Message)
+}
+
+func (rl *relay) reconcile(ctx context.Context, cl *client, id string, neg *negentropy.Negentropy, msg string) {
+	out, err := neg.Reconcile(msg)
+	if err != nil {
+		cl.mu.Lock()
+		delete(cl.negentropy, id)
+		cl.mu.Unlock()
+		cl.writeNegentropyError(id, "error: "+err.Error())
+		return
+	}
+	cl.write(&nip77.MessageEnvelope{SubscriptionID: id, Message: out})
+}
+
+type subscription struct {
+	filters nostr.Filters
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+type client struct {
+	conn *websocket.Conn
+
+	// ctx lasts as long as the connection, cancel ends it. writes must never use a subscription
+	// context, as websocket.Conn.Write closes the whole connection when its context is cancelled.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// everything is written by writeLoop: replies to the client's own messages wait for it, while
+	// events published by others are queued in live, so a slow client can't hold up publishers
+	replies chan []byte
+	live    chan []byte
+
+	mu            sync.Mutex
+	subscriptions map[string]subscription
+	negentropy    map[string]*negentropy.Negentropy
+}
+
+func (cl *client) subscribe(id string, sub subscription) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if previous, ok := cl.subscriptions[id]; ok {
+		previous.cancel()
+	}
+	cl.subscriptions[id] = sub
+}
+
+func (cl *client) unsubscribe(id string) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if sub, ok := cl.subscriptions[id]; ok {
+		sub.cancel()
+		delete(cl.subscriptions, id)
+	}
+}
+
+// matching returns the ids of the subscriptions evt matches, each with its context.
+func (cl *client) matching(evt *nostr.Event) map[string]context.Context {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	ids := make(map[string]context.Context)
+	for id, sub := range cl.subscriptions {
+		if sub.filters.Match(evt) {
+			ids[id] = sub.ctx
+		}
+	}
+	return ids
+}
+
+// writeLoop sends what is written to the client until the connection ends.
+func (cl *client) writeLoop() {
+	for {
+		var b []byte
+		select {
+		case b = <-cl.replies:
+		case b = <-cl.live:
+		case <-cl.ctx.Done():
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(cl.ctx, 10*time.Second)
+		err := cl.conn.Write(ctx, websocket.MessageText, b)
+		cancel()
+		if err != nil {
+			cl.cancel()
+			return
+		}
+	}
+}
+
+// write sends a reply to something the client asked for, waiting for the ones before it.
+func (cl *client) write(env nostr.Envelope) {
+	b, err := env.MarshalJSON()
+	if err != nil {
+		return
+	}
+	cl.send(b)
+}
+
+// writeLive sends an event published by someone else without waiting, the client is disconnected
+// if it has fallen too far behind.
+func (cl *client) writeLive(env nostr.Envelope) {
+	b, err := env.MarshalJSON()
+	if err != nil {
+		return
+	}
+
+	select {
+	case cl.live <- b:
+	default:
+		cl.cancel()
+	}
+}
+
+// writeNegentropyError sends a NEG-ERR, which nip77.ErrorEnvelope would label as NEG-ERROR.
+func (cl *client) writeNegentropyError(id string, reason string) {
+	b, _ := json.Marshal([]string{"NEG-ERR", id, reason})
+	cl.send(b)
+}
+
+func (cl *client) send(b []byte) {
+	select {
+	case cl.replies <- b:
+	case <-cl.ctx.Done():
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/fiatjaf/eventstore/slicestore"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeReqCloseReq(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	store := &slicestore.SliceStore{MaxLimit: 3000}
+	require.NoError(t, store.Init())
+	sk := nostr.GeneratePrivateKey()
+	for i := 0; i < 3000; i++ {
+		evt := &nostr.Event{CreatedAt: nostr.Timestamp(1000 + i), Kind: 1, Tags: nostr.Tags{}, Content: fmt.Sprintf("hello %d", i)}
+		evt.Sign(sk)
+		require.NoError(t, store.SaveEvent(ctx, evt))
+	}
+
+	server := httptest.NewServer(newRelay(store))
+	defer server.Close()
+
+	for i := 0; i < 20; i++ {
+		conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		require.NoError(t, err)
+		conn.SetReadLimit(4 * 1024 * 1024)
+
+		// closing the first subscription while its events are being sent must not affect the
+		// connection, and reusing its id must give a working subscription
+		for _, msg := range []string{
+			`["REQ","sub",{"limit":3000}]`,
+			`["CLOSE","sub"]`,
+			`["REQ","sub",{"limit":3000}]`,
+			`["CLOSE","sub"]`,
+			`["REQ","other",{"limit":3000}]`,
+		} {
+			require.NoError(t, conn.Write(ctx, websocket.MessageText, []byte(msg)))
+		}
+
+		for {
+			_, msg, err := conn.Read(ctx)
+			require.NoError(t, err, "connection %d was closed", i)
+			if string(msg) == `["EOSE","other"]` {
+				break
+			}
+		}
+		conn.Close(websocket.StatusNormalClosure, "")
+	}
+}
+
+func TestServeSlowClientDoesNotBlockPublishers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	store := &slicestore.SliceStore{}
+	require.NoError(t, store.Init())
+	rl := newRelay(store)
+	server := httptest.NewServer(rl)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	// subscribes to everything and never reads
+	slow, _, err := websocket.Dial(ctx, url, nil)
+	require.NoError(t, err)
+	defer slow.CloseNow()
+	require.NoError(t, slow.Write(ctx, websocket.MessageText, []byte(`["REQ","all",{"kinds":[1]}]`)))
+
+	publisher, _, err := websocket.Dial(ctx, url, nil)
+	require.NoError(t, err)
+	defer publisher.CloseNow()
+
+	// enough to fill the socket buffers of the slow client, each publish must still be quick
+	content := strings.Repeat("x", 4096)
+	sk := nostr.GeneratePrivateKey()
+	for i := 0; i < 3000; i++ {
+		evt := nostr.Event{CreatedAt: nostr.Timestamp(1000 + i), Kind: 1, Tags: nostr.Tags{}, Content: content}
+		evt.Sign(sk)
+		b, _ := (&nostr.EventEnvelope{Event: evt}).MarshalJSON()
+
+		start := time.Now()
+		require.NoError(t, publisher.Write(ctx, websocket.MessageText, b))
+		_, msg, err := publisher.Read(ctx)
+		require.NoError(t, err)
+		require.Contains(t, string(msg), `"OK"`)
+		require.Less(t, time.Since(start), 2*time.Second, "publish %d was held up", i)
+	}
+
+	// and the slow client was dropped
+	require.Eventually(t, func() bool {
+		rl.mu.Lock()
+		defer rl.mu.Unlock()
+		return len(rl.clients) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
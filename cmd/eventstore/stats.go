@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/urfave/cli/v3"
+)
+
+var stats = &cli.Command{
+	Name:        "stats",
+	Usage:       "prints how many events the store has and how much space they take",
+	Description: "prints a JSON object with the total number of events, the number of events of each kind, the size on disk, the number of entries and size of each index and the schema version, whenever the store is able to tell.",
+	Action: func(ctx context.Context, c *cli.Command) error {
+		reporter, ok := db.(eventstore.StatsReporter)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "this store doesn't report stats\n")
+			os.Exit(123)
+		}
+
+		st, err := reporter.Stats(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error getting stats: %s\n", err)
+			os.Exit(123)
+		}
+
+		j, _ := json.MarshalIndent(st, "", "  ")
+		fmt.Println(string(j))
+		return nil
+	},
+}
@@ -0,0 +1,164 @@
+package eventstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Config describes a store and the wrappers to put around it, as loaded by [LoadConfig] from a
+// JSON file like
+//
+//	{
+//	  "store": {"uri": "lmdb:///var/lib/relay", "max_limit": 1000},
+//	  "wrappers": [
+//	    {"type": "bluge", "options": {"path": "/var/lib/relay-search"}},
+//	    {"type": "skipevent", "options": {"kinds": [4, 1059]}},
+//	    {"type": "count"}
+//	  ]
+//	}
+type Config struct {
+	Store StoreConfig `json:"store"`
+
+	// Wrappers are applied in order, each one wrapping the store made by the ones before it.
+	Wrappers []WrapperConfig `json:"wrappers"`
+}
+
+// StoreConfig is the store at the bottom of a [Config], opened with [Open].
+type StoreConfig struct {
+	URI      string `json:"uri"`
+	Type     string `json:"type"`
+	MaxLimit int    `json:"max_limit"`
+}
+
+// WrapperConfig is one of the wrappers of a [Config].
+type WrapperConfig struct {
+	// Type is the name the wrapper was registered with in [RegisterWrapper].
+	Type string `json:"type"`
+
+	// Options are decoded by the wrapper, unknown keys in them are an error.
+	Options json.RawMessage `json:"options"`
+}
+
+// WrapperFactory wraps inner in a wrapper, reading its settings with options, which decodes the
+// "options" of its [WrapperConfig] into v and fails on keys v doesn't have.
+type WrapperFactory func(inner Store, options func(v any) error) (Store, error)
+
+var (
+	wrappersMu sync.RWMutex
+	wrappers   = make(map[string]WrapperFactory)
+)
+
+// RegisterWrapper makes a wrapper available to [Config] under name. Wrapper packages register
+// theirs when imported, like backends do with [Register]. It panics if name is already taken.
+func RegisterWrapper(name string, factory WrapperFactory) {
+	wrappersMu.Lock()
+	defer wrappersMu.Unlock()
+
+	if _, ok := wrappers[name]; ok {
+		panic("eventstore: wrapper " + name + " registered twice")
+	}
+	wrappers[name] = factory
+}
+
+// LoadConfig reads the [Config] at path and opens the store it describes, already initialized.
+// Errors tell the file and the key that is wrong, as in "relay.json: wrappers[1].options: ...".
+func LoadConfig(path string) (Store, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := decodeStrict(b, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	db, err := cfg.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, nil
+}
+
+// Open validates cfg, then opens and initializes its store and wraps it.
+func (cfg Config) Open() (Store, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	db, err := Open(cfg.Store.URI, OpenOptions{Type: cfg.Store.Type, MaxLimit: cfg.Store.MaxLimit})
+	if err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+
+	wrappersMu.RLock()
+	defer wrappersMu.RUnlock()
+	for i, w := range cfg.Wrappers {
+		options := func(v any) error {
+			if len(w.Options) == 0 {
+				return nil
+			}
+			return decodeStrict(w.Options, v)
+		}
+
+		wrapped, err := wrappers[w.Type](db, options)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("wrappers[%d].options: %w", i, err)
+		}
+		db = wrapped
+	}
+
+	return db, nil
+}
+
+// validate checks what can be checked without opening anything, so a bad config doesn't leave a
+// store half opened.
+func (cfg Config) validate() error {
+	if cfg.Store.URI == "" {
+		return errors.New("store.uri: missing")
+	}
+	if cfg.Store.MaxLimit < 0 {
+		return errors.New("store.max_limit: can't be negative")
+	}
+	if cfg.Store.Type != "" {
+		if _, err := findDriver(cfg.Store.URI, cfg.Store.Type); err != nil {
+			return fmt.Errorf("store.type: %w", err)
+		}
+	}
+
+	wrappersMu.RLock()
+	defer wrappersMu.RUnlock()
+	for i, w := range cfg.Wrappers {
+		if w.Type == "" {
+			return fmt.Errorf("wrappers[%d].type: missing", i)
+		}
+		if _, ok := wrappers[w.Type]; !ok {
+			return fmt.Errorf("wrappers[%d].type: unknown wrapper '%s', the package for it may not be imported", i, w.Type)
+		}
+	}
+	return nil
+}
+
+// decodeStrict decodes b into v failing on unknown keys, with errors that name the key.
+func decodeStrict(b []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return fmt.Errorf("%s: expected %s, got %s", typeErr.Field, typeErr.Type, typeErr.Value)
+		}
+		return errors.New(strings.TrimPrefix(err.Error(), "json: "))
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("unexpected data after the end")
+	}
+	return nil
+}
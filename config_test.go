@@ -0,0 +1,68 @@
+package eventstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type wrappedStore struct {
+	Store
+	label string
+}
+
+func TestLoadConfig(t *testing.T) {
+	Register(Driver{
+		Name:    "configfake",
+		Schemes: []string{"configfake"},
+		New: func(uri string, opts OpenOptions) (Store, error) {
+			return failingReplaceStore{}, nil
+		},
+	})
+	RegisterWrapper("configlabel", func(inner Store, options func(any) error) (Store, error) {
+		var opts struct {
+			Label string `json:"label"`
+		}
+		if err := options(&opts); err != nil {
+			return nil, err
+		}
+		return wrappedStore{Store: inner, label: opts.Label}, nil
+	})
+
+	dir := t.TempDir()
+	load := func(config string) (Store, error) {
+		path := filepath.Join(dir, "config.json")
+		require.NoError(t, os.WriteFile(path, []byte(config), 0644))
+		return LoadConfig(path)
+	}
+
+	db, err := load(`{
+		"store": {"uri": "configfake://x"},
+		"wrappers": [
+			{"type": "configlabel", "options": {"label": "inner"}},
+			{"type": "configlabel", "options": {"label": "outer"}}
+		]
+	}`)
+	require.NoError(t, err)
+	outer := db.(wrappedStore)
+	require.Equal(t, "outer", outer.label)
+	require.Equal(t, "inner", outer.Store.(wrappedStore).label)
+	require.Equal(t, failingReplaceStore{}, outer.Store.(wrappedStore).Store)
+
+	for config, expected := range map[string]string{
+		`{"store": {}}`: "store.uri: missing",
+		`{"store": {"uri": "configfake://x", "typo": 1}}`:                                                         `unknown field "typo"`,
+		`{"store": {"uri": "configfake://x", "max_limit": "a lot"}}`:                                              "store.max_limit: expected int, got string",
+		`{"store": {"uri": "x", "type": "nothing"}}`:                                                              "store.type: unknown store type 'nothing'",
+		`{"store": {"uri": "configfake://x"}, "wrappers": [{}]}`:                                                  "wrappers[0].type: missing",
+		`{"store": {"uri": "configfake://x"}, "wrappers": [{"type": "nothing"}]}`:                                 "wrappers[0].type: unknown wrapper 'nothing'",
+		`{"store": {"uri": "configfake://x"}, "wrappers": [{"type": "configlabel", "options": {"labels": "a"}}]}`: `wrappers[0].options: unknown field "labels"`,
+		`{"store": {"uri": "configfake://x"}, "wrappers": [{"type": "configlabel", "options": {"label": 1}}]}`:    "wrappers[0].options: label: expected string, got number",
+		`{"store": {"uri": "configfake://x"}} {}`:                                                                 "unexpected data after the end",
+	} {
+		_, err := load(config)
+		require.ErrorContains(t, err, "config.json: "+expected, config)
+	}
+}
@@ -10,8 +10,14 @@ import (
 )
 
 func (d *DynamoDBBackend) DeleteEvent(ctx context.Context, event *nostr.Event) error {
+	ctx, done, err := d.inflight.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	println(event.ID)
-	_, err := d.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+	_, err = d.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
 		TableName: aws.String("events"),
 		Key: map[string]types.AttributeValue{
 			"id": &types.AttributeValueMemberS{
@@ -1,8 +1,12 @@
 package dynamodb
 
 import (
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"context"
+	"log"
 	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/fiatjaf/eventstore/internal/inflight"
 )
 
 type DynamoDBBackend struct {
@@ -14,7 +18,20 @@ type DynamoDBBackend struct {
 	QueryAuthorsLimit int
 	QueryKindsLimit   int
 	QueryTagsLimit    int
+
+	inflight inflight.Tracker
 }
 
+// Close is CloseContext with a timeout of inflight.CloseTimeout.
 func (m *DynamoDBBackend) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), inflight.CloseTimeout)
+	defer cancel()
+	if err := m.CloseContext(ctx); err != nil {
+		log.Printf("dynamodb: failed to close: %s\n", err)
+	}
+}
+
+// CloseContext waits for the running operations, see [eventstore.GracefulCloser].
+func (m *DynamoDBBackend) CloseContext(ctx context.Context) error {
+	return m.inflight.Close(ctx, func() error { return nil })
 }
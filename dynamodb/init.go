@@ -2,13 +2,14 @@ package dynamodb
 
 import (
 	"context"
-	"log"
+	"fmt"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/fiatjaf/eventstore"
 )
 
 const (
@@ -22,7 +23,7 @@ const (
 func (d *DynamoDBBackend) Init() error {
 	cfg, err := config.LoadDefaultConfig(context.TODO())
 	if err != nil {
-		log.Fatalf("unable to load SDK config, %v", err)
+		return fmt.Errorf("unable to load SDK config: %w", err)
 	}
 	client := dynamodb.NewFromConfig(cfg)
 
@@ -74,7 +75,7 @@ func (d *DynamoDBBackend) Init() error {
 	})
 	if err != nil {
 		if strings.Index(err.Error(), "Table already exists") == -1 {
-			return err
+			return fmt.Errorf("%w: failed to create table: %w", eventstore.ErrStorageUnavailable, err)
 		}
 	}
 
@@ -0,0 +1,22 @@
+package dynamodb
+
+import (
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitWithUnreachableServer(t *testing.T) {
+	t.Setenv("AWS_ENDPOINT_URL", "http://127.0.0.1:1")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_MAX_ATTEMPTS", "1")
+
+	backend := &DynamoDBBackend{}
+
+	err := backend.Init()
+	require.ErrorIs(t, err, eventstore.ErrStorageUnavailable)
+	require.Nil(t, backend.Client)
+}
@@ -80,13 +80,25 @@ func buildBuilder(filter nostr.Filter) expression.Builder {
 	return builder
 }
 
-func (d *DynamoDBBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (chan *nostr.Event, error) {
+func (d *DynamoDBBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (ch chan *nostr.Event, err error) {
+	ctx, done, err := d.inflight.AcquireContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// otherwise the goroutine below will release it
+		if err != nil {
+			done()
+		}
+	}()
+
 	limit := filter.Limit
 	if filter.Limit < 1 || filter.Limit > d.QueryLimit {
 		limit = d.QueryLimit
 	}
-	ch := make(chan *nostr.Event)
+	ch = make(chan *nostr.Event)
 	go func() {
+		defer done()
 		defer close(ch)
 
 		expr, err := buildBuilder(filter).Build()
@@ -146,6 +158,12 @@ func (d *DynamoDBBackend) QueryEvents(ctx context.Context, filter nostr.Filter)
 }
 
 func (d *DynamoDBBackend) CountEvents(ctx context.Context, filter nostr.Filter) (int64, error) {
+	ctx, done, err := d.inflight.AcquireContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
 	expr, err := buildBuilder(filter).Build()
 	if err != nil {
 		return 0, err
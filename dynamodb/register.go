@@ -0,0 +1,20 @@
+package dynamodb
+
+import "github.com/fiatjaf/eventstore"
+
+func init() {
+	eventstore.Register(eventstore.Driver{
+		Name:    "dynamodb",
+		Schemes: []string{"dynamodb"},
+		New: func(uri string, opts eventstore.OpenOptions) (eventstore.Store, error) {
+			return &DynamoDBBackend{
+				DatabaseURL:       uri,
+				QueryLimit:        opts.MaxLimit,
+				QueryIDsLimit:     opts.MaxLimit,
+				QueryAuthorsLimit: opts.MaxLimit,
+				QueryKindsLimit:   opts.MaxLimit,
+				QueryTagsLimit:    opts.MaxLimit,
+			}, nil
+		},
+	})
+}
@@ -10,6 +10,12 @@ import (
 )
 
 func (d *DynamoDBBackend) ReplaceEvent(ctx context.Context, evt *nostr.Event) error {
+	ctx, done, err := d.inflight.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	d.Lock()
 	defer d.Unlock()
 
@@ -12,6 +12,12 @@ import (
 )
 
 func (d *DynamoDBBackend) SaveEvent(ctx context.Context, event *nostr.Event) error {
+	ctx, done, err := d.inflight.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	tags, err := json.Marshal(event.Tags)
 	if err != nil {
 		return err
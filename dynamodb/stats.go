@@ -0,0 +1,66 @@
+package dynamodb
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/fiatjaf/eventstore"
+)
+
+// Stats scans the whole table to count the events per kind. Sizes and item counts come from
+// DescribeTable, which DynamoDB only updates every few hours.
+func (d *DynamoDBBackend) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	ctx, done, err := d.inflight.AcquireContext(ctx)
+	if err != nil {
+		return eventstore.StoreStats{}, err
+	}
+	defer done()
+
+	stats := eventstore.StoreStats{
+		EventsPerKind: make(map[int]int64),
+		Indexes:       make(map[string]eventstore.IndexStats),
+	}
+
+	desc, err := d.Client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String("events"),
+	})
+	if err != nil {
+		return stats, err
+	}
+	stats.DiskSize = aws.ToInt64(desc.Table.TableSizeBytes)
+	stats.Indexes["events"] = eventstore.IndexStats{
+		Entries: aws.ToInt64(desc.Table.ItemCount),
+		Size:    aws.ToInt64(desc.Table.TableSizeBytes),
+	}
+	for _, idx := range desc.Table.GlobalSecondaryIndexes {
+		stats.Indexes[aws.ToString(idx.IndexName)] = eventstore.IndexStats{
+			Entries: aws.ToInt64(idx.ItemCount),
+			Size:    aws.ToInt64(idx.IndexSizeBytes),
+		}
+		stats.DiskSize += aws.ToInt64(idx.IndexSizeBytes)
+	}
+
+	paginator := dynamodb.NewScanPaginator(d.Client, &dynamodb.ScanInput{
+		TableName:            aws.String("events"),
+		ProjectionExpression: aws.String("kind"),
+	})
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+		if err != nil {
+			return stats, err
+		}
+		for _, item := range resp.Items {
+			if v, ok := item["kind"].(*types.AttributeValueMemberN); ok {
+				if kind, err := strconv.Atoi(v.Value); err == nil {
+					stats.EventsPerKind[kind]++
+				}
+			}
+			stats.Events++
+		}
+	}
+
+	return stats, nil
+}
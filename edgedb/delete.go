@@ -8,6 +8,12 @@ import (
 
 // DeleteEvent implements the method of the eventstore.Store interface
 func (b *EdgeDBBackend) DeleteEvent(ctx context.Context, event *nostr.Event) error {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	query := "DELETE events::Event FILTER .eventId = <str>$eventId"
 	args := map[string]interface{}{
 		"eventId": event.ID,
@@ -1,9 +1,12 @@
 package edgedb
 
 import (
+	"context"
+	"log"
 	"sync"
 
 	"github.com/edgedb/edgedb-go"
+	"github.com/fiatjaf/eventstore/internal/inflight"
 )
 
 type EdgeDBBackend struct {
@@ -16,9 +19,21 @@ type EdgeDBBackend struct {
 	QueryKindsLimit   int
 	QueryTagsLimit    int
 	QueryLimit        int
+
+	inflight inflight.Tracker
 }
 
-// Close implements the Close method of the eventstore.Store interface
+// Close is CloseContext with a timeout of inflight.CloseTimeout.
 func (b *EdgeDBBackend) Close() {
-	b.Client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), inflight.CloseTimeout)
+	defer cancel()
+	if err := b.CloseContext(ctx); err != nil {
+		log.Printf("edgedb: failed to close: %s\n", err)
+	}
+}
+
+// CloseContext closes the client once the running operations are done.
+// See [eventstore.GracefulCloser].
+func (b *EdgeDBBackend) CloseContext(ctx context.Context) error {
+	return b.inflight.Close(ctx, b.Client.Close)
 }
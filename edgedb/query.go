@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/fiatjaf/eventstore"
 	"github.com/nbd-wtf/go-nostr"
 )
 
@@ -18,7 +19,18 @@ var (
 )
 
 // QueryEvents is an implementation of the QueryEvents method of the eventstore.Store interfac for edgedb
-func (b *EdgeDBBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (chan *nostr.Event, error) {
+func (b *EdgeDBBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (ch chan *nostr.Event, err error) {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// otherwise the goroutine below will release it
+		if err != nil {
+			done()
+		}
+	}()
+
 	query, args, err := b.queryEventsEdgeql(filter, false)
 	if err != nil {
 		return nil, err
@@ -27,14 +39,22 @@ func (b *EdgeDBBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (c
 	if err := b.Query(ctx, query, &events, args); err != nil {
 		return nil, fmt.Errorf("failed to fetch events using query %s: %w", query, err)
 	}
-	ch := make(chan *nostr.Event)
+
+	// convert everything before returning so a bad row becomes an error instead of a crash
+	converted := make([]*nostr.Event, len(events))
+	for i, event := range events {
+		e, err := EdgeDBEventToNostrEvent(event)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to read event %s: %w", eventstore.ErrCorrupted, event.EventID, err)
+		}
+		converted[i] = e
+	}
+
+	ch = make(chan *nostr.Event)
 	go func() {
+		defer done()
 		defer close(ch)
-		for _, event := range events {
-			e, err := EdgeDBEventToNostrEvent(event)
-			if err != nil {
-				panic(fmt.Errorf("failed to fetch events using query %s: %w", query, err))
-			}
+		for _, e := range converted {
 			select {
 			case ch <- e:
 			case <-ctx.Done():
@@ -0,0 +1,20 @@
+package edgedb
+
+import "github.com/fiatjaf/eventstore"
+
+func init() {
+	eventstore.Register(eventstore.Driver{
+		Name:    "edgedb",
+		Schemes: []string{"edgedb", "gel"},
+		New: func(uri string, opts eventstore.OpenOptions) (eventstore.Store, error) {
+			return &EdgeDBBackend{
+				DatabaseURI:       uri,
+				QueryLimit:        opts.MaxLimit,
+				QueryIDsLimit:     opts.MaxLimit,
+				QueryAuthorsLimit: opts.MaxLimit,
+				QueryKindsLimit:   opts.MaxLimit,
+				QueryTagsLimit:    opts.MaxLimit,
+			}, nil
+		},
+	})
+}
@@ -9,6 +9,12 @@ import (
 )
 
 func (b *EdgeDBBackend) SaveEvent(ctx context.Context, event *nostr.Event) error {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	tagsBytes := [][]byte{}
 	for _, t := range event.Tags {
 		tagBytes, err := json.Marshal(t)
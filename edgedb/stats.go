@@ -0,0 +1,38 @@
+package edgedb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fiatjaf/eventstore"
+)
+
+type kindCount struct {
+	Kind  int64 `edgedb:"kind"`
+	Count int64 `edgedb:"count"`
+}
+
+// Stats only counts the events per kind, edgedb doesn't tell how much space they take.
+func (b *EdgeDBBackend) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return eventstore.StoreStats{}, err
+	}
+	defer done()
+
+	stats := eventstore.StoreStats{
+		EventsPerKind: make(map[int]int64),
+	}
+
+	query := "SELECT (GROUP events::Event BY .kind) { kind := .key.kind, count := count(.elements) }"
+	var counts []kindCount
+	if err := b.Query(ctx, query, &counts); err != nil {
+		return stats, fmt.Errorf("failed to count events using query %s: %w", query, err)
+	}
+	for _, c := range counts {
+		stats.EventsPerKind[int(c.Kind)] = c.Count
+		stats.Events += c.Count
+	}
+
+	return stats, nil
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"strings"
 	"sync"
 	"time"
@@ -14,6 +15,7 @@ import (
 	"github.com/elastic/go-elasticsearch/v8/esutil"
 	"github.com/fiatjaf/eventstore"
 	"github.com/fiatjaf/eventstore/internal"
+	"github.com/fiatjaf/eventstore/internal/inflight"
 	"github.com/nbd-wtf/go-nostr"
 )
 
@@ -56,9 +58,24 @@ type ElasticsearchStorage struct {
 
 	es *elasticsearch.Client
 	bi esutil.BulkIndexer
+
+	inflight inflight.Tracker
 }
 
-func (ess *ElasticsearchStorage) Close() {}
+// Close is CloseContext with a timeout of inflight.CloseTimeout.
+func (ess *ElasticsearchStorage) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), inflight.CloseTimeout)
+	defer cancel()
+	if err := ess.CloseContext(ctx); err != nil {
+		log.Printf("elasticsearch: failed to close: %s\n", err)
+	}
+}
+
+// CloseContext flushes and closes the bulk indexer once the running operations are done.
+// See [eventstore.GracefulCloser].
+func (ess *ElasticsearchStorage) CloseContext(ctx context.Context) error {
+	return ess.inflight.Close(ctx, func() error { return ess.bi.Close(context.Background()) })
+}
 
 func (ess *ElasticsearchStorage) Init() error {
 	if ess.IndexName == "" {
@@ -104,8 +121,14 @@ func (ess *ElasticsearchStorage) Init() error {
 }
 
 func (ess *ElasticsearchStorage) DeleteEvent(ctx context.Context, evt *nostr.Event) error {
+	ctx, release, err := ess.inflight.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	done := make(chan error)
-	err := ess.bi.Add(
+	err = ess.bi.Add(
 		ctx,
 		esutil.BulkIndexerItem{
 			Action:     "delete",
@@ -138,6 +161,12 @@ func (ess *ElasticsearchStorage) DeleteEvent(ctx context.Context, evt *nostr.Eve
 }
 
 func (ess *ElasticsearchStorage) ReplaceEvent(ctx context.Context, evt *nostr.Event) error {
+	ctx, release, err := ess.inflight.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	ess.Lock()
 	defer ess.Unlock()
 
@@ -172,6 +201,12 @@ func (ess *ElasticsearchStorage) ReplaceEvent(ctx context.Context, evt *nostr.Ev
 }
 
 func (ess *ElasticsearchStorage) SaveEvent(ctx context.Context, evt *nostr.Event) error {
+	ctx, release, err := ess.inflight.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	ie := &IndexedEvent{
 		Event: *evt,
 	}
@@ -0,0 +1,45 @@
+package elasticsearch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageUnavailable(t *testing.T) {
+	ctx := context.Background()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error":"unavailable"}`))
+	}))
+	defer failing.Close()
+
+	gone := httptest.NewServer(http.NotFoundHandler())
+	gone.Close()
+
+	id := "971b9489b4fd4e41a85951607922b982d981fa9d55318bc304f21f390721404c"
+	for _, url := range []string{failing.URL, gone.URL} {
+		es, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{url}, MaxRetries: 1})
+		require.NoError(t, err)
+		ess := &ElasticsearchStorage{IndexName: "events", es: es}
+
+		for _, filter := range []nostr.Filter{{Kinds: []int{1}}, {IDs: []string{id}}} {
+			_, err = ess.QueryEvents(ctx, filter)
+			require.ErrorIs(t, err, eventstore.ErrStorageUnavailable)
+
+			_, err = ess.CountEvents(ctx, filter)
+			require.ErrorIs(t, err, eventstore.ErrStorageUnavailable)
+		}
+
+		_, err = ess.HasEvents(ctx, []string{id})
+		require.ErrorIs(t, err, eventstore.ErrStorageUnavailable)
+	}
+}
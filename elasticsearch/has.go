@@ -0,0 +1,60 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/elastic/go-elasticsearch/v8/esutil"
+	"github.com/fiatjaf/eventstore"
+)
+
+func (ess *ElasticsearchStorage) HasEvents(ctx context.Context, ids []string) ([]bool, error) {
+	ctx, release, err := ess.inflight.AcquireContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	results := make([]bool, len(ids))
+	if len(ids) == 0 {
+		return results, nil
+	}
+
+	res, err := ess.es.Mget(
+		esutil.NewJSONReader(map[string]any{"ids": ids}),
+		ess.es.Mget.WithContext(ctx),
+		ess.es.Mget.WithIndex(ess.IndexName),
+		ess.es.Mget.WithSource("false"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", eventstore.ErrStorageUnavailable, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, responseError(res)
+	}
+
+	var mgetResponse struct {
+		Docs []struct {
+			ID    string `json:"_id"`
+			Found bool   `json:"found"`
+		} `json:"docs"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&mgetResponse); err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool, len(mgetResponse.Docs))
+	for _, doc := range mgetResponse.Docs {
+		if doc.Found {
+			found[doc.ID] = true
+		}
+	}
+	for i, id := range ids {
+		results[i] = found[id]
+	}
+
+	return results, nil
+}
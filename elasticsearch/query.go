@@ -6,11 +6,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
-	"reflect"
 
 	"github.com/aquasecurity/esquery"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
 	"github.com/elastic/go-elasticsearch/v8/esutil"
+	"github.com/fiatjaf/eventstore"
 	"github.com/nbd-wtf/go-nostr"
 )
 
@@ -89,12 +89,18 @@ func buildDsl(filter nostr.Filter) ([]byte, error) {
 	return json.Marshal(esquery.Query(dsl))
 }
 
-func (ess *ElasticsearchStorage) getByID(filter nostr.Filter) ([]*nostr.Event, error) {
+func (ess *ElasticsearchStorage) getByID(ctx context.Context, filter nostr.Filter) ([]*nostr.Event, error) {
 	got, err := ess.es.Mget(
 		esutil.NewJSONReader(filter),
+		ess.es.Mget.WithContext(ctx),
 		ess.es.Mget.WithIndex(ess.IndexName))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", eventstore.ErrStorageUnavailable, err)
+	}
+	defer got.Body.Close()
+
+	if got.IsError() {
+		return nil, responseError(got)
 	}
 
 	var mgetResponse struct {
@@ -117,13 +123,25 @@ func (ess *ElasticsearchStorage) getByID(filter nostr.Filter) ([]*nostr.Event, e
 	return events, nil
 }
 
-func (ess *ElasticsearchStorage) QueryEvents(ctx context.Context, filter nostr.Filter) (chan *nostr.Event, error) {
-	ch := make(chan *nostr.Event)
+func (ess *ElasticsearchStorage) QueryEvents(ctx context.Context, filter nostr.Filter) (ch chan *nostr.Event, err error) {
+	ctx, release, err := ess.inflight.AcquireContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// otherwise the goroutine below will release it
+		if err != nil {
+			release()
+		}
+	}()
+
+	ch = make(chan *nostr.Event)
 
 	// optimization: get by id
 	if isGetByID(filter) {
-		if evts, err := ess.getByID(filter); err == nil {
+		if evts, err := ess.getByID(ctx, filter); err == nil {
 			go func() {
+				defer release()
 				defer close(ch)
 				for _, evt := range evts {
 					select {
@@ -133,6 +151,7 @@ func (ess *ElasticsearchStorage) QueryEvents(ctx context.Context, filter nostr.F
 					}
 				}
 			}()
+			return ch, nil
 		} else {
 			return nil, fmt.Errorf("error getting by id: %w", err)
 		}
@@ -158,13 +177,12 @@ func (ess *ElasticsearchStorage) QueryEvents(ctx context.Context, filter nostr.F
 		es.Search.WithSort("event.created_at:desc", "event.id"),
 	)
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
+		return nil, fmt.Errorf("%w: %w", eventstore.ErrStorageUnavailable, err)
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
-		txt, _ := io.ReadAll(res.Body)
-		return nil, fmt.Errorf("%s", txt)
+		return nil, responseError(res)
 	}
 
 	var r EsSearchResult
@@ -173,15 +191,30 @@ func (ess *ElasticsearchStorage) QueryEvents(ctx context.Context, filter nostr.F
 	}
 
 	go func() {
+		defer release()
+		defer close(ch)
 		for _, e := range r.Hits.Hits {
-			ch <- &e.Source.Event
+			select {
+			case ch <- &e.Source.Event:
+			case <-ctx.Done():
+				return
+			}
 		}
-		close(ch)
 	}()
 
 	return ch, nil
 }
 
+// responseError turns an error response into an error, server-side failures are treated as
+// the storage being unavailable.
+func responseError(res *esapi.Response) error {
+	txt, _ := io.ReadAll(res.Body)
+	if res.StatusCode >= 500 {
+		return fmt.Errorf("%w: %s", eventstore.ErrStorageUnavailable, txt)
+	}
+	return fmt.Errorf("%s", txt)
+}
+
 func isGetByID(filter nostr.Filter) bool {
 	isGetById := len(filter.IDs) > 0 &&
 		len(filter.Authors) == 0 &&
@@ -201,34 +234,31 @@ func isGetByID(filter nostr.Filter) bool {
 	return isGetById
 }
 
-// from: https://stackoverflow.com/a/12754757
-func toInterfaceSlice(slice interface{}) []interface{} {
-	s := reflect.ValueOf(slice)
-	if s.Kind() != reflect.Slice {
-		panic("InterfaceSlice() given a non-slice type")
-	}
-
-	// Keep the distinction between nil and empty slice input
-	if s.IsNil() {
+func toInterfaceSlice[T any](slice []T) []interface{} {
+	// keep the distinction between nil and empty slice input
+	if slice == nil {
 		return nil
 	}
 
-	ret := make([]interface{}, s.Len())
-
-	for i := 0; i < s.Len(); i++ {
-		ret[i] = s.Index(i).Interface()
+	ret := make([]interface{}, len(slice))
+	for i, v := range slice {
+		ret[i] = v
 	}
 
 	return ret
 }
 
 func (ess *ElasticsearchStorage) CountEvents(ctx context.Context, filter nostr.Filter) (int64, error) {
-	count := int64(0)
+	ctx, release, err := ess.inflight.AcquireContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
 
 	// optimization: get by id
 	if isGetByID(filter) {
-		if evts, err := ess.getByID(filter); err == nil {
-			count += int64(len(evts))
+		if evts, err := ess.getByID(ctx, filter); err == nil {
+			return int64(len(evts)), nil
 		} else {
 			return 0, fmt.Errorf("error getting by id: %w", err)
 		}
@@ -247,13 +277,12 @@ func (ess *ElasticsearchStorage) CountEvents(ctx context.Context, filter nostr.F
 		es.Count.WithBody(bytes.NewReader(dsl)),
 	)
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
+		return 0, fmt.Errorf("%w: %w", eventstore.ErrStorageUnavailable, err)
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
-		txt, _ := io.ReadAll(res.Body)
-		return 0, fmt.Errorf("%s", txt)
+		return 0, responseError(res)
 	}
 
 	var r EsCountResult
@@ -261,5 +290,5 @@ func (ess *ElasticsearchStorage) CountEvents(ctx context.Context, filter nostr.F
 		return 0, err
 	}
 
-	return r.Count + count, nil
+	return r.Count, nil
 }
@@ -0,0 +1,17 @@
+package elasticsearch
+
+import (
+	"strings"
+
+	"github.com/fiatjaf/eventstore"
+)
+
+func init() {
+	eventstore.Register(eventstore.Driver{
+		Name:    "elasticsearch",
+		Schemes: []string{"elasticsearch+https", "elasticsearch+http"},
+		New: func(uri string, opts eventstore.OpenOptions) (eventstore.Store, error) {
+			return &ElasticsearchStorage{URL: strings.TrimPrefix(uri, "elasticsearch+")}, nil
+		},
+	})
+}
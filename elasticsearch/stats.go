@@ -0,0 +1,106 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/fiatjaf/eventstore"
+)
+
+var kindsAggregation = []byte(`{
+	"size": 0,
+	"track_total_hits": true,
+	"aggs": {
+		"kinds": {"terms": {"field": "event.kind", "size": 10000}}
+	}
+}`)
+
+// Stats counts events per kind with a terms aggregation and reports the size of the primary
+// shards of the index.
+func (ess *ElasticsearchStorage) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	ctx, release, err := ess.inflight.AcquireContext(ctx)
+	if err != nil {
+		return eventstore.StoreStats{}, err
+	}
+	defer release()
+
+	stats := eventstore.StoreStats{
+		EventsPerKind: make(map[int]int64),
+		Indexes:       make(map[string]eventstore.IndexStats),
+	}
+
+	es := ess.es
+	res, err := es.Search(
+		es.Search.WithContext(ctx),
+		es.Search.WithIndex(ess.IndexName),
+		es.Search.WithBody(bytes.NewReader(kindsAggregation)),
+	)
+	if err != nil {
+		return stats, fmt.Errorf("%w: %w", eventstore.ErrStorageUnavailable, err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return stats, responseError(res)
+	}
+
+	var r struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+		} `json:"hits"`
+		Aggregations struct {
+			Kinds struct {
+				Buckets []struct {
+					Key      int   `json:"key"`
+					DocCount int64 `json:"doc_count"`
+				} `json:"buckets"`
+			} `json:"kinds"`
+		} `json:"aggregations"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return stats, err
+	}
+	stats.Events = r.Hits.Total.Value
+	for _, b := range r.Aggregations.Kinds.Buckets {
+		stats.EventsPerKind[b.Key] = b.DocCount
+	}
+
+	res, err = es.Indices.Stats(
+		es.Indices.Stats.WithContext(ctx),
+		es.Indices.Stats.WithIndex(ess.IndexName),
+		es.Indices.Stats.WithMetric("docs", "store"),
+	)
+	if err != nil {
+		return stats, fmt.Errorf("%w: %w", eventstore.ErrStorageUnavailable, err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return stats, responseError(res)
+	}
+
+	var is struct {
+		All struct {
+			Primaries struct {
+				Docs struct {
+					Count int64 `json:"count"`
+				} `json:"docs"`
+				Store struct {
+					SizeInBytes int64 `json:"size_in_bytes"`
+				} `json:"store"`
+			} `json:"primaries"`
+		} `json:"_all"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&is); err != nil {
+		return stats, err
+	}
+	stats.DiskSize = is.All.Primaries.Store.SizeInBytes
+	stats.Indexes[ess.IndexName] = eventstore.IndexStats{
+		Entries: is.All.Primaries.Docs.Count,
+		Size:    is.All.Primaries.Store.SizeInBytes,
+	}
+
+	return stats, nil
+}
@@ -3,3 +3,29 @@ package eventstore
 import "errors"
 
 var ErrDupEvent = errors.New("duplicate: event already exists")
+
+// ErrStorageUnavailable is wrapped by errors caused by the underlying storage failing to respond
+// or to perform an operation, these are usually transient.
+var ErrStorageUnavailable = errors.New("storage unavailable")
+
+// ErrCorrupted is wrapped by errors caused by stored data that can't be read back.
+var ErrCorrupted = errors.New("stored data is corrupted")
+
+// ErrClosed is returned by stores when they are called after Close has started.
+var ErrClosed = errors.New("store is closed")
+
+// ErrTransactionsNotSupported is returned by wrappers when asked to run a transaction on top of a
+// store that doesn't implement Transactor.
+var ErrTransactionsNotSupported = errors.New("store doesn't support transactions")
+
+// ErrSerialOverflow is returned by stores that number their events internally when all the
+// numbers have been used, instead of reusing a number that may belong to an existing event.
+var ErrSerialOverflow = errors.New("internal serial numbers exhausted")
+
+// ErrStatsNotSupported is returned by wrappers when asked for the stats of a store that doesn't
+// implement StatsReporter.
+var ErrStatsNotSupported = errors.New("store doesn't report stats")
+
+// ErrUnknownStoreType is returned by [Open] when no registered driver handles the given type,
+// scheme or path.
+var ErrUnknownStoreType = errors.New("unknown store type")
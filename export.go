@@ -0,0 +1,125 @@
+package eventstore
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"iter"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Exporter is implemented by stores that can go through all the events matching a filter,
+// regardless of the limits they apply to queries.
+type Exporter interface {
+	// ExportEvents yields every event matching filter, or only filter.Limit of them if it is set,
+	// oldest first. Stores that can read from a snapshot do so.
+	ExportEvents(ctx context.Context, filter nostr.Filter) iter.Seq2[*nostr.Event, error]
+}
+
+// exportPageSize is how many events are queried at a time from stores that aren't an Exporter.
+const exportPageSize = 500
+
+// AllEvents yields every event in db matching filter, using [Exporter] when db implements it.
+// Other stores are paged through with QueryEvents going back in time, so events come newest
+// first, not from a snapshot, and some can be missed if more of them share the same created_at
+// than a query returns at once.
+func AllEvents(ctx context.Context, db Store, filter nostr.Filter) iter.Seq2[*nostr.Event, error] {
+	if exporter, ok := db.(Exporter); ok {
+		return exporter.ExportEvents(ctx, filter)
+	}
+
+	return func(yield func(*nostr.Event, error) bool) {
+		remaining := filter.Limit
+		page := filter
+		page.Limit = exportPageSize
+
+		// ids already yielded with the created_at the next page starts at
+		seen := make(map[string]struct{})
+		for {
+			if remaining > 0 && remaining < page.Limit {
+				page.Limit = remaining
+			}
+
+			events, err := queryPage(ctx, db, page)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			var oldest nostr.Timestamp
+			var fresh []*nostr.Event
+			for _, evt := range events {
+				if _, ok := seen[evt.ID]; ok {
+					continue
+				}
+				fresh = append(fresh, evt)
+				if oldest == 0 || evt.CreatedAt < oldest {
+					oldest = evt.CreatedAt
+				}
+			}
+			if len(fresh) == 0 {
+				return
+			}
+
+			if page.Until == nil || *page.Until != oldest {
+				clear(seen)
+			}
+			for _, evt := range fresh {
+				if !yield(evt, nil) {
+					return
+				}
+				if evt.CreatedAt == oldest {
+					seen[evt.ID] = struct{}{}
+				}
+				if filter.Limit > 0 {
+					remaining--
+					if remaining == 0 {
+						return
+					}
+				}
+			}
+
+			page.Until = &oldest
+		}
+	}
+}
+
+func queryPage(ctx context.Context, db Store, filter nostr.Filter) ([]*nostr.Event, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ch, err := db.QueryEvents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*nostr.Event, 0, filter.Limit)
+	for evt := range ch {
+		events = append(events, evt)
+	}
+	return events, ctx.Err()
+}
+
+// Export writes every event in db matching filter to w as JSONL, one event per line, and
+// returns how many were written. See [AllEvents] for how they are read.
+func Export(ctx context.Context, db Store, w io.Writer, filter nostr.Filter) (int64, error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+
+	var n int64
+	for evt, err := range AllEvents(ctx, db, filter) {
+		if err != nil {
+			bw.Flush()
+			return n, err
+		}
+		if err := enc.Encode(evt); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, bw.Flush()
+}
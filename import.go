@@ -0,0 +1,198 @@
+package eventstore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// ImportOptions controls how [Import] reads and saves events.
+type ImportOptions struct {
+	// BatchSize is how many events are saved at a time, in a single transaction for stores that
+	// implement Transactor. Defaults to 1000.
+	BatchSize int
+
+	// Skip is how many lines to skip from the start of the input, to resume an import from the
+	// ImportProgress.Lines it was interrupted at.
+	Skip int64
+
+	// CheckSignatures skips events with a bad id or signature instead of saving them.
+	CheckSignatures bool
+
+	// OnProgress is called after each batch is saved.
+	OnProgress func(ImportProgress)
+}
+
+// ImportProgress counts what [Import] did so far.
+type ImportProgress struct {
+	// Lines is how many lines have been read and handled, including skipped ones. All events up
+	// to this line are saved, so it can be given as ImportOptions.Skip to resume.
+	Lines int64 `json:"lines"`
+
+	// Saved is how many events were saved.
+	Saved int64 `json:"saved"`
+
+	// Duplicates is how many events were already in the store or earlier in the input.
+	Duplicates int64 `json:"duplicates"`
+
+	// Invalid is how many lines couldn't be decoded or had events with a bad id or signature.
+	Invalid int64 `json:"invalid"`
+}
+
+// Import reads events from r as JSONL, one event per line, and saves them to db in batches.
+// Events db already has are skipped, using [ExistenceChecker] when db implements it.
+func Import(ctx context.Context, db Store, r io.Reader, opts ImportOptions) (ImportProgress, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+
+	var progress ImportProgress
+	batch := make([]*nostr.Event, 0, opts.BatchSize)
+	flush := func() error {
+		saved, duplicates, err := SaveBatch(ctx, db, batch)
+		progress.Saved += saved
+		progress.Duplicates += duplicates
+		if err != nil {
+			return err
+		}
+		batch = batch[:0]
+		if opts.OnProgress != nil {
+			opts.OnProgress(progress)
+		}
+		return nil
+	}
+
+	// ids in the batch being filled, so the same event twice in the input is saved only once
+	inBatch := make(map[string]struct{}, opts.BatchSize)
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) == 0 && err == io.EOF {
+			break
+		} else if err != nil && err != io.EOF {
+			return progress, err
+		}
+
+		progress.Lines++
+		if progress.Lines <= opts.Skip {
+			continue
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			evt := &nostr.Event{}
+			if err := json.Unmarshal(line, evt); err != nil {
+				progress.Invalid++
+			} else if opts.CheckSignatures && !isValid(evt) {
+				progress.Invalid++
+			} else if _, ok := inBatch[evt.ID]; ok {
+				progress.Duplicates++
+			} else {
+				inBatch[evt.ID] = struct{}{}
+				batch = append(batch, evt)
+			}
+		}
+
+		if len(batch) == opts.BatchSize {
+			if err := ctx.Err(); err != nil {
+				return progress, err
+			}
+			if err := flush(); err != nil {
+				return progress, err
+			}
+			clear(inBatch)
+		}
+	}
+
+	return progress, flush()
+}
+
+func isValid(evt *nostr.Event) bool {
+	if !evt.CheckID() {
+		return false
+	}
+	ok, _ := evt.CheckSignature()
+	return ok
+}
+
+// SaveBatch saves events to db, skipping the ones it already has, and tells how many were saved
+// and how many were duplicates. Regular events are saved in a single transaction when db is a
+// [Transactor]. Replaceable and addressable events go through ReplaceEvent one at a time, so only
+// the newest of each is kept, and they count as saved even if a newer one was already there.
+func SaveBatch(ctx context.Context, db Store, events []*nostr.Event) (saved int64, duplicates int64, err error) {
+	if len(events) == 0 {
+		return 0, 0, nil
+	}
+
+	if checker, ok := db.(ExistenceChecker); ok {
+		ids := make([]string, len(events))
+		for i, evt := range events {
+			ids[i] = evt.ID
+		}
+		has, err := checker.HasEvents(ctx, ids)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		missing := make([]*nostr.Event, 0, len(events))
+		for i, evt := range events {
+			if has[i] {
+				duplicates++
+			} else {
+				missing = append(missing, evt)
+			}
+		}
+		events = missing
+	}
+
+	regular := make([]*nostr.Event, 0, len(events))
+	var replaceable []*nostr.Event
+	for _, evt := range events {
+		if nostr.IsReplaceableKind(evt.Kind) || nostr.IsAddressableKind(evt.Kind) {
+			replaceable = append(replaceable, evt)
+		} else {
+			regular = append(regular, evt)
+		}
+	}
+
+	save := func(save func(*nostr.Event) error) error {
+		saved = 0
+		for _, evt := range regular {
+			if err := save(evt); errors.Is(err, ErrDupEvent) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			saved++
+		}
+		return nil
+	}
+
+	err = ErrTransactionsNotSupported
+	if transactor, ok := db.(Transactor); ok && len(regular) > 0 {
+		err = transactor.Update(ctx, func(tx Tx) error { return save(tx.Save) })
+	}
+	if errors.Is(err, ErrTransactionsNotSupported) {
+		// wrappers are Transactors even when the store they wrap isn't
+		err = save(func(evt *nostr.Event) error { return db.SaveEvent(ctx, evt) })
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	duplicates += int64(len(regular)) - saved
+
+	for _, evt := range replaceable {
+		if err := db.ReplaceEvent(ctx, evt); err != nil {
+			return saved, duplicates, err
+		}
+		saved++
+	}
+
+	return saved, duplicates, nil
+}
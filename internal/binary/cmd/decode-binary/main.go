@@ -28,12 +28,15 @@ func main() {
 		}
 	}
 
+	// try the current format first, then fall back to the old one
 	var evt nostr.Event
-	err = binary.Unmarshal(b, &evt)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to decode: %s\n", err)
-		os.Exit(1)
-		return
+	if err := binary.Decode(b, &evt); err != nil {
+		evt = nostr.Event{}
+		if errv1 := binary.Unmarshal(b, &evt); errv1 != nil {
+			fmt.Fprintf(os.Stderr, "failed to decode: %s (as v1: %s)\n", err, errv1)
+			os.Exit(1)
+			return
+		}
 	}
 	fmt.Println(evt.String())
 }
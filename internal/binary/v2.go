@@ -0,0 +1,287 @@
+package binary
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"iter"
+	"math"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Version2 is the first byte of every event encoded with Encode.
+//
+// the layout is:
+//
+//	[0]       version (2)
+//	[1:33]    id
+//	[33:65]   pubkey
+//	[65:129]  sig
+//	[129:133] created_at (uint32, big endian)
+//	[133:137] kind (uint32, big endian)
+//	uvarint content length, content
+//	uvarint tag count, then for each tag: uvarint item count, then for each item: uvarint length, item
+const Version2 byte = 2
+
+const (
+	IDOffset        = 1
+	PubKeyOffset    = 33
+	SigOffset       = 65
+	CreatedAtOffset = 129
+	KindOffset      = 133
+	headerSizeV2    = 137
+)
+
+var (
+	ErrUnknownVersion = errors.New("unknown binary encoding version")
+	ErrTruncated      = errors.New("encoded event is truncated")
+)
+
+// Encode serializes an event using the v2 format.
+func Encode(evt *nostr.Event) ([]byte, error) {
+	if evt.CreatedAt < 0 || evt.CreatedAt > MaxCreatedAt {
+		return nil, fmt.Errorf("created_at is out of range: %d", evt.CreatedAt)
+	}
+	if evt.Kind < 0 || uint64(evt.Kind) > math.MaxUint32 {
+		return nil, fmt.Errorf("kind is out of range: %d", evt.Kind)
+	}
+
+	size := headerSizeV2 + binary.MaxVarintLen64 + len(evt.Content) + binary.MaxVarintLen64
+	for _, tag := range evt.Tags {
+		size += binary.MaxVarintLen64
+		for _, item := range tag {
+			size += binary.MaxVarintLen64 + len(item)
+		}
+	}
+
+	buf := make([]byte, headerSizeV2, size)
+	buf[0] = Version2
+	if err := decodeHexField(buf[IDOffset:PubKeyOffset], evt.ID); err != nil {
+		return nil, fmt.Errorf("invalid id: %w", err)
+	}
+	if err := decodeHexField(buf[PubKeyOffset:SigOffset], evt.PubKey); err != nil {
+		return nil, fmt.Errorf("invalid pubkey: %w", err)
+	}
+	if err := decodeHexField(buf[SigOffset:CreatedAtOffset], evt.Sig); err != nil {
+		return nil, fmt.Errorf("invalid sig: %w", err)
+	}
+	binary.BigEndian.PutUint32(buf[CreatedAtOffset:KindOffset], uint32(evt.CreatedAt))
+	binary.BigEndian.PutUint32(buf[KindOffset:headerSizeV2], uint32(evt.Kind))
+
+	buf = binary.AppendUvarint(buf, uint64(len(evt.Content)))
+	buf = append(buf, evt.Content...)
+
+	buf = binary.AppendUvarint(buf, uint64(len(evt.Tags)))
+	for _, tag := range evt.Tags {
+		buf = binary.AppendUvarint(buf, uint64(len(tag)))
+		for _, item := range tag {
+			buf = binary.AppendUvarint(buf, uint64(len(item)))
+			buf = append(buf, item...)
+		}
+	}
+
+	return buf, nil
+}
+
+func decodeHexField(dst []byte, src string) error {
+	if len(src) != len(dst)*2 {
+		return fmt.Errorf("expected %d hex chars, got %d", len(dst)*2, len(src))
+	}
+	_, err := hex.Decode(dst, []byte(src))
+	return err
+}
+
+// Decode parses an event encoded with Encode, returning an error (never panicking) on malformed input.
+func Decode(data []byte, evt *nostr.Event) error {
+	v, err := NewView(data)
+	if err != nil {
+		return err
+	}
+	v.Decode(evt)
+	return nil
+}
+
+// Decode fills evt with everything that is in the view, allocating new strings for all fields.
+func (v View) Decode(evt *nostr.Event) {
+	evt.ID = hex.EncodeToString(v.ID())
+	evt.PubKey = hex.EncodeToString(v.PubKey())
+	evt.Sig = hex.EncodeToString(v.Sig())
+	evt.CreatedAt = v.CreatedAt()
+	evt.Kind = int(v.Kind())
+	evt.Content = string(v.Content())
+
+	evt.Tags = make(nostr.Tags, 0, v.TagCount())
+	for tv := range v.Tags() {
+		tag := make(nostr.Tag, 0, tv.Len())
+		for item := range tv.Items() {
+			tag = append(tag, string(item))
+		}
+		evt.Tags = append(evt.Tags, tag)
+	}
+}
+
+// View is a read-only window over an event encoded with Encode. Nothing is copied or allocated
+// when reading fields from it, so slices returned by its methods are only valid for as long as
+// the underlying data is.
+type View struct {
+	data     []byte
+	content  []byte
+	tagCount int
+	tags     []byte // starts at the first tag
+}
+
+// NewView checks that data is a well-formed v2 event (without allocating) and returns a View over it.
+func NewView(data []byte) (View, error) {
+	if len(data) < headerSizeV2 {
+		return View{}, ErrTruncated
+	}
+	if data[0] != Version2 {
+		return View{}, fmt.Errorf("%w: %d", ErrUnknownVersion, data[0])
+	}
+
+	v := View{data: data}
+	rest := data[headerSizeV2:]
+
+	content, rest, err := readChunk(rest)
+	if err != nil {
+		return View{}, fmt.Errorf("bad content: %w", err)
+	}
+	v.content = content
+
+	tagCount, rest, err := readLength(rest)
+	if err != nil {
+		return View{}, fmt.Errorf("bad tag count: %w", err)
+	}
+	v.tagCount = tagCount
+	v.tags = rest
+
+	// walk over all the tags once so the iterators below can assume everything is in bounds
+	for t := 0; t < tagCount; t++ {
+		var itemCount int
+		itemCount, rest, err = readLength(rest)
+		if err != nil {
+			return View{}, fmt.Errorf("bad item count on tag %d: %w", t, err)
+		}
+		for i := 0; i < itemCount; i++ {
+			_, rest, err = readChunk(rest)
+			if err != nil {
+				return View{}, fmt.Errorf("bad item %d on tag %d: %w", i, t, err)
+			}
+		}
+	}
+	if len(rest) != 0 {
+		return View{}, fmt.Errorf("%d trailing bytes after event", len(rest))
+	}
+
+	return v, nil
+}
+
+func readLength(data []byte) (int, []byte, error) {
+	n, size := binary.Uvarint(data)
+	if size <= 0 {
+		return 0, nil, ErrTruncated
+	}
+	// no length can be bigger than what is left, since every tag or item takes at least one byte
+	if n > uint64(len(data)-size) {
+		return 0, nil, ErrTruncated
+	}
+	return int(n), data[size:], nil
+}
+
+func readChunk(data []byte) ([]byte, []byte, error) {
+	n, rest, err := readLength(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rest[0:n], rest[n:], nil
+}
+
+func (v View) ID() []byte      { return v.data[IDOffset:PubKeyOffset] }
+func (v View) PubKey() []byte  { return v.data[PubKeyOffset:SigOffset] }
+func (v View) Sig() []byte     { return v.data[SigOffset:CreatedAtOffset] }
+func (v View) Content() []byte { return v.content }
+func (v View) TagCount() int   { return v.tagCount }
+func (v View) Kind() uint32    { return binary.BigEndian.Uint32(v.data[KindOffset:headerSizeV2]) }
+func (v View) CreatedAt() nostr.Timestamp {
+	return nostr.Timestamp(binary.BigEndian.Uint32(v.data[CreatedAtOffset:KindOffset]))
+}
+
+// Tags iterates over all the tags in the event.
+func (v View) Tags() iter.Seq[TagView] {
+	return func(yield func(TagView) bool) {
+		rest := v.tags
+		for t := 0; t < v.tagCount; t++ {
+			itemCount, afterCount, _ := readLength(rest)
+			tv := TagView{len: itemCount, items: afterCount}
+
+			rest = afterCount
+			for i := 0; i < itemCount; i++ {
+				_, rest, _ = readChunk(rest)
+			}
+			tv.items = tv.items[0 : len(tv.items)-len(rest)]
+
+			if !yield(tv) {
+				return
+			}
+		}
+	}
+}
+
+// ContainsAnyTag is like nostr.Tags.ContainsAny, but it doesn't allocate anything.
+func (v View) ContainsAnyTag(key string, values []string) bool {
+	for tag := range v.Tags() {
+		if tag.Len() < 2 || string(tag.Key()) != key {
+			continue
+		}
+		value := tag.Value()
+		for _, candidate := range values {
+			if string(value) == candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TagView is a read-only window over a single tag inside a View.
+type TagView struct {
+	len   int
+	items []byte
+}
+
+func (tv TagView) Len() int { return tv.len }
+
+// Items iterates over the items of this tag.
+func (tv TagView) Items() iter.Seq[[]byte] {
+	return func(yield func([]byte) bool) {
+		rest := tv.items
+		for i := 0; i < tv.len; i++ {
+			var item []byte
+			item, rest, _ = readChunk(rest)
+			if !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+// Key returns the first item of the tag, or nil if the tag is empty.
+func (tv TagView) Key() []byte {
+	if tv.len < 1 {
+		return nil
+	}
+	key, _, _ := readChunk(tv.items)
+	return key
+}
+
+// Value returns the second item of the tag, or nil if the tag has less than two items.
+func (tv TagView) Value() []byte {
+	if tv.len < 2 {
+		return nil
+	}
+	_, rest, _ := readChunk(tv.items)
+	value, _, _ := readChunk(rest)
+	return value
+}
@@ -0,0 +1,122 @@
+package binary
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleEvent() *nostr.Event {
+	evt := &nostr.Event{
+		CreatedAt: 1700000000,
+		Kind:      70000,
+		Content:   strings.Repeat("x", 70000),
+		Tags: nostr.Tags{
+			{"e", "5c83da77af1dec6d7289834998ad7aafbd9e2191396d75ec3cc27f5a77226f36", "wss://relay.example.com"},
+			{"t", ""},
+			{},
+			{"p", strings.Repeat("y", 70000)},
+		},
+	}
+	evt.Sign("0000000000000000000000000000000000000000000000000000000000000001")
+	return evt
+}
+
+func TestEncodeDecode(t *testing.T) {
+	evt := sampleEvent()
+
+	data, err := Encode(evt)
+	require.NoError(t, err)
+	require.Equal(t, Version2, data[0])
+
+	var decoded nostr.Event
+	require.NoError(t, Decode(data, &decoded))
+	require.Equal(t, evt.ID, decoded.ID)
+	require.Equal(t, evt.PubKey, decoded.PubKey)
+	require.Equal(t, evt.Sig, decoded.Sig)
+	require.Equal(t, evt.CreatedAt, decoded.CreatedAt)
+	require.Equal(t, evt.Kind, decoded.Kind)
+	require.Equal(t, evt.Content, decoded.Content)
+	require.Equal(t, len(evt.Tags), len(decoded.Tags))
+	for i := range evt.Tags {
+		require.Equal(t, []string(evt.Tags[i]), []string(decoded.Tags[i]))
+	}
+	ok, _ := decoded.CheckSignature()
+	require.True(t, ok)
+}
+
+func TestView(t *testing.T) {
+	evt := sampleEvent()
+	data, err := Encode(evt)
+	require.NoError(t, err)
+
+	v, err := NewView(data)
+	require.NoError(t, err)
+	require.Equal(t, uint32(70000), v.Kind())
+	require.Equal(t, evt.CreatedAt, v.CreatedAt())
+	require.Equal(t, 4, v.TagCount())
+
+	keys := make([]string, 0, 4)
+	for tag := range v.Tags() {
+		keys = append(keys, string(tag.Key()))
+	}
+	require.Equal(t, []string{"e", "t", "", "p"}, keys)
+
+	require.True(t, v.ContainsAnyTag("e", []string{"abc", "5c83da77af1dec6d7289834998ad7aafbd9e2191396d75ec3cc27f5a77226f36"}))
+	require.True(t, v.ContainsAnyTag("t", []string{""}))
+	require.False(t, v.ContainsAnyTag("e", []string{"wss://relay.example.com"}))
+	require.False(t, v.ContainsAnyTag("x", []string{""}))
+
+	allocs := testing.AllocsPerRun(100, func() {
+		v, _ := NewView(data)
+		for tag := range v.Tags() {
+			_ = tag.Value()
+		}
+		_ = v.ContainsAnyTag("p", []string{"nothing", "here"})
+	})
+	require.Zero(t, allocs)
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	data, err := Encode(sampleEvent())
+	require.NoError(t, err)
+
+	// every truncation must fail cleanly
+	for i := 0; i < len(data); i += 97 {
+		var evt nostr.Event
+		require.Error(t, Decode(data[0:i], &evt), "truncated at %d", i)
+	}
+
+	// unknown version
+	bad := append([]byte{}, data...)
+	bad[0] = 1
+	require.ErrorIs(t, Decode(bad, &nostr.Event{}), ErrUnknownVersion)
+
+	// trailing garbage
+	require.Error(t, Decode(append(append([]byte{}, data...), 0), &nostr.Event{}))
+
+	// absurd length
+	huge := append([]byte{}, data[0:headerSizeV2]...)
+	huge = append(huge, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01)
+	require.ErrorIs(t, Decode(huge, &nostr.Event{}), ErrTruncated)
+}
+
+func FuzzDecode(f *testing.F) {
+	data, _ := Encode(sampleEvent())
+	f.Add(data)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var evt nostr.Event
+		if err := Decode(data, &evt); err != nil {
+			return
+		}
+		encoded, err := Encode(&evt)
+		require.NoError(t, err)
+
+		var again nostr.Event
+		require.NoError(t, Decode(encoded, &again))
+		require.Equal(t, evt.Serialize(), again.Serialize())
+		require.Equal(t, evt.Sig, again.Sig)
+	})
+}
@@ -0,0 +1,126 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Filter is a bloom filter that can be safely read and written from multiple goroutines.
+// since the things we put here are event ids (which are hashes already) we don't hash them again,
+// we just take the first 8 bytes and derive the other positions from those.
+//
+// it grows as ids are added: once the entries it was sized for are in, a new stage with twice the
+// room and half the false positive rate is started, so the overall rate stays under the one given
+// to New however many ids are added.
+type Filter struct {
+	stages atomic.Pointer[[]*stage]
+	mu     sync.Mutex // held to add stages
+}
+
+type stage struct {
+	words    []uint64
+	nbits    uint64
+	k        int
+	capacity int64
+	rate     float64
+	count    atomic.Int64
+}
+
+// New creates a filter sized for the given number of expected entries and false positive rate.
+func New(expected int, falsePositiveRate float64) *Filter {
+	if expected < 1024 {
+		expected = 1024
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.01
+	}
+
+	// the rates of the stages add up to at most twice the one of the first
+	f := &Filter{}
+	f.stages.Store(&[]*stage{newStage(expected, falsePositiveRate/2)})
+	return f
+}
+
+func newStage(expected int, falsePositiveRate float64) *stage {
+	nbits := uint64(math.Ceil(-float64(expected) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	nbits = (nbits + 63) / 64 * 64
+	k := int(math.Round(float64(nbits) / float64(expected) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	return &stage{
+		words:    make([]uint64, nbits/64),
+		nbits:    nbits,
+		k:        k,
+		capacity: int64(expected),
+		rate:     falsePositiveRate,
+	}
+}
+
+// Add takes at least the first 8 bytes of an id.
+func (f *Filter) Add(id []byte) {
+	stages := *f.stages.Load()
+	last := stages[len(stages)-1]
+	if last.count.Add(1) > last.capacity {
+		last = f.grow(last)
+	}
+
+	h1, h2 := hashes(id)
+	for i := 0; i < last.k; i++ {
+		pos := (h1 + uint64(i)*h2) % last.nbits
+		atomic.OrUint64(&last.words[pos/64], 1<<(pos%64))
+	}
+}
+
+// grow starts a new stage after full, unless another Add already did, and returns it.
+func (f *Filter) grow(full *stage) *stage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stages := *f.stages.Load()
+	last := stages[len(stages)-1]
+	if last == full {
+		last = newStage(int(full.capacity)*2, full.rate/2)
+		next := append(slices.Clip(stages), last)
+		f.stages.Store(&next)
+	}
+	last.count.Add(1)
+	return last
+}
+
+// MayContain returns false only if the id was never added.
+func (f *Filter) MayContain(id []byte) bool {
+	h1, h2 := hashes(id)
+	for _, s := range *f.stages.Load() {
+		if s.mayContain(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *stage) mayContain(h1, h2 uint64) bool {
+	for i := 0; i < s.k; i++ {
+		pos := (h1 + uint64(i)*h2) % s.nbits
+		if atomic.LoadUint64(&s.words[pos/64])&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func hashes(id []byte) (uint64, uint64) {
+	h1 := binary.BigEndian.Uint64(id[0:8])
+
+	// splitmix64 finalizer, just to get a second independent-looking value
+	h2 := h1 + 0x9e3779b97f4a7c15
+	h2 = (h2 ^ (h2 >> 30)) * 0xbf58476d1ce4e5b9
+	h2 = (h2 ^ (h2 >> 27)) * 0x94d049bb133111eb
+	h2 = h2 ^ (h2 >> 31)
+
+	return h1, h2 | 1
+}
@@ -0,0 +1,59 @@
+package bloom
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	f := New(10000, 0.01)
+
+	added := make([][]byte, 10000)
+	for i := range added {
+		added[i] = make([]byte, 32)
+		rand.Read(added[i])
+		f.Add(added[i])
+	}
+
+	for _, id := range added {
+		require.True(t, f.MayContain(id))
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		id := make([]byte, 32)
+		rand.Read(id)
+		if f.MayContain(id) {
+			falsePositives++
+		}
+	}
+	require.Less(t, falsePositives, 300)
+}
+
+func TestFilterGrows(t *testing.T) {
+	// much more than it was sized for, as in a store that started empty
+	f := New(0, 0.01)
+
+	added := make([][]byte, 200_000)
+	for i := range added {
+		added[i] = make([]byte, 32)
+		rand.Read(added[i])
+		f.Add(added[i])
+	}
+
+	for _, id := range added {
+		require.True(t, f.MayContain(id))
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		id := make([]byte, 32)
+		rand.Read(id)
+		if f.MayContain(id) {
+			falsePositives++
+		}
+	}
+	require.Less(t, falsePositives, 300)
+}
@@ -3,20 +3,30 @@ package checks
 import (
 	"github.com/fiatjaf/eventstore"
 	"github.com/fiatjaf/eventstore/badger"
+	"github.com/fiatjaf/eventstore/bbolt"
 	"github.com/fiatjaf/eventstore/bluge"
 	"github.com/fiatjaf/eventstore/edgedb"
+	"github.com/fiatjaf/eventstore/jsonlstore"
 	"github.com/fiatjaf/eventstore/lmdb"
+	"github.com/fiatjaf/eventstore/memstore"
 	"github.com/fiatjaf/eventstore/mongo"
 	"github.com/fiatjaf/eventstore/mysql"
+	"github.com/fiatjaf/eventstore/pebble"
 	"github.com/fiatjaf/eventstore/postgresql"
 	"github.com/fiatjaf/eventstore/sqlite3"
 	"github.com/fiatjaf/eventstore/strfry"
+	"github.com/fiatjaf/eventstore/wrappers/count"
+	"github.com/fiatjaf/eventstore/wrappers/disablesearch"
+	"github.com/fiatjaf/eventstore/wrappers/search"
+	"github.com/fiatjaf/eventstore/wrappers/skipevent"
 )
 
 // compile-time checks to ensure all backends implement Store
 var (
 	_ eventstore.Store = (*badger.BadgerBackend)(nil)
 	_ eventstore.Store = (*lmdb.LMDBBackend)(nil)
+	_ eventstore.Store = (*bbolt.BBoltBackend)(nil)
+	_ eventstore.Store = (*pebble.PebbleBackend)(nil)
 	_ eventstore.Store = (*edgedb.EdgeDBBackend)(nil)
 	_ eventstore.Store = (*postgresql.PostgresBackend)(nil)
 	_ eventstore.Store = (*mongo.MongoDBBackend)(nil)
@@ -24,4 +34,90 @@ var (
 	_ eventstore.Store = (*strfry.StrfryBackend)(nil)
 	_ eventstore.Store = (*bluge.BlugeBackend)(nil)
 	_ eventstore.Store = (*mysql.MySQLBackend)(nil)
+	_ eventstore.Store = (*jsonlstore.JSONLStore)(nil)
+	_ eventstore.Store = (*memstore.MemStore)(nil)
+)
+
+// compile-time checks to ensure backends implement the optional interfaces they claim to
+var (
+	_ eventstore.IDQuerier = (*badger.BadgerBackend)(nil)
+	_ eventstore.IDQuerier = (*lmdb.LMDBBackend)(nil)
+	_ eventstore.IDQuerier = (*bbolt.BBoltBackend)(nil)
+	_ eventstore.IDQuerier = (*pebble.PebbleBackend)(nil)
+	_ eventstore.IDQuerier = (*postgresql.PostgresBackend)(nil)
+	_ eventstore.IDQuerier = (*sqlite3.SQLite3Backend)(nil)
+	_ eventstore.IDQuerier = (*mysql.MySQLBackend)(nil)
+	_ eventstore.IDQuerier = (*jsonlstore.JSONLStore)(nil)
+	_ eventstore.IDQuerier = (*memstore.MemStore)(nil)
+	_ eventstore.IDQuerier = search.Wrapper{}
+
+	_ eventstore.ExistenceChecker = (*badger.BadgerBackend)(nil)
+	_ eventstore.ExistenceChecker = (*lmdb.LMDBBackend)(nil)
+	_ eventstore.ExistenceChecker = (*bbolt.BBoltBackend)(nil)
+	_ eventstore.ExistenceChecker = (*pebble.PebbleBackend)(nil)
+	_ eventstore.ExistenceChecker = (*postgresql.PostgresBackend)(nil)
+	_ eventstore.ExistenceChecker = (*sqlite3.SQLite3Backend)(nil)
+	_ eventstore.ExistenceChecker = (*mysql.MySQLBackend)(nil)
+	_ eventstore.ExistenceChecker = (*jsonlstore.JSONLStore)(nil)
+	_ eventstore.ExistenceChecker = (*memstore.MemStore)(nil)
+	_ eventstore.ExistenceChecker = search.Wrapper{}
+
+	_ eventstore.GracefulCloser = (*badger.BadgerBackend)(nil)
+	_ eventstore.GracefulCloser = (*lmdb.LMDBBackend)(nil)
+	_ eventstore.GracefulCloser = (*bbolt.BBoltBackend)(nil)
+	_ eventstore.GracefulCloser = (*pebble.PebbleBackend)(nil)
+	_ eventstore.GracefulCloser = (*edgedb.EdgeDBBackend)(nil)
+	_ eventstore.GracefulCloser = (*postgresql.PostgresBackend)(nil)
+	_ eventstore.GracefulCloser = (*mongo.MongoDBBackend)(nil)
+	_ eventstore.GracefulCloser = (*sqlite3.SQLite3Backend)(nil)
+	_ eventstore.GracefulCloser = (*bluge.BlugeBackend)(nil)
+	_ eventstore.GracefulCloser = (*mysql.MySQLBackend)(nil)
+	_ eventstore.GracefulCloser = (*jsonlstore.JSONLStore)(nil)
+	_ eventstore.GracefulCloser = (*memstore.MemStore)(nil)
+
+	_ eventstore.Transactor = (*badger.BadgerBackend)(nil)
+	_ eventstore.Transactor = (*lmdb.LMDBBackend)(nil)
+	_ eventstore.Transactor = (*bbolt.BBoltBackend)(nil)
+	_ eventstore.Transactor = (*pebble.PebbleBackend)(nil)
+	_ eventstore.Transactor = (*postgresql.PostgresBackend)(nil)
+	_ eventstore.Transactor = (*sqlite3.SQLite3Backend)(nil)
+	_ eventstore.Transactor = (*mysql.MySQLBackend)(nil)
+	_ eventstore.Transactor = skipevent.Wrapper{}
+	_ eventstore.Transactor = disablesearch.Wrapper{}
+	_ eventstore.Transactor = count.Wrapper{}
+
+	_ eventstore.StatsReporter = (*badger.BadgerBackend)(nil)
+	_ eventstore.StatsReporter = (*lmdb.LMDBBackend)(nil)
+	_ eventstore.StatsReporter = (*bbolt.BBoltBackend)(nil)
+	_ eventstore.StatsReporter = (*pebble.PebbleBackend)(nil)
+	_ eventstore.StatsReporter = (*edgedb.EdgeDBBackend)(nil)
+	_ eventstore.StatsReporter = (*postgresql.PostgresBackend)(nil)
+	_ eventstore.StatsReporter = (*mongo.MongoDBBackend)(nil)
+	_ eventstore.StatsReporter = (*sqlite3.SQLite3Backend)(nil)
+	_ eventstore.StatsReporter = (*strfry.StrfryBackend)(nil)
+	_ eventstore.StatsReporter = (*bluge.BlugeBackend)(nil)
+	_ eventstore.StatsReporter = (*mysql.MySQLBackend)(nil)
+	_ eventstore.StatsReporter = (*jsonlstore.JSONLStore)(nil)
+	_ eventstore.StatsReporter = (*memstore.MemStore)(nil)
+	_ eventstore.StatsReporter = skipevent.Wrapper{}
+	_ eventstore.StatsReporter = disablesearch.Wrapper{}
+	_ eventstore.StatsReporter = count.Wrapper{}
+	_ eventstore.StatsReporter = search.Wrapper{}
+
+	_ eventstore.Verifier = (*badger.BadgerBackend)(nil)
+	_ eventstore.Verifier = (*lmdb.LMDBBackend)(nil)
+
+	_ eventstore.Exporter = (*badger.BadgerBackend)(nil)
+	_ eventstore.Exporter = (*lmdb.LMDBBackend)(nil)
+	_ eventstore.Exporter = (*bbolt.BBoltBackend)(nil)
+	_ eventstore.Exporter = (*pebble.PebbleBackend)(nil)
+	_ eventstore.Exporter = (*postgresql.PostgresBackend)(nil)
+	_ eventstore.Exporter = (*sqlite3.SQLite3Backend)(nil)
+	_ eventstore.Exporter = (*mysql.MySQLBackend)(nil)
+	_ eventstore.Exporter = (*jsonlstore.JSONLStore)(nil)
+	_ eventstore.Exporter = (*memstore.MemStore)(nil)
+	_ eventstore.Exporter = skipevent.Wrapper{}
+	_ eventstore.Exporter = disablesearch.Wrapper{}
+	_ eventstore.Exporter = count.Wrapper{}
+	_ eventstore.Exporter = search.Wrapper{}
 )
@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"io/fs"
+	"path/filepath"
+)
+
+// DirSize adds up the space taken by all the regular files under path. Where the system tells
+// it counts the blocks actually allocated, since some stores preallocate big sparse files.
+func DirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += allocatedSize(info)
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package internal
+
+import "io/fs"
+
+func allocatedSize(info fs.FileInfo) int64 {
+	return info.Size()
+}
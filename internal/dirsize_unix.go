@@ -0,0 +1,15 @@
+//go:build unix
+
+package internal
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+func allocatedSize(info fs.FileInfo) int64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return min(int64(st.Blocks)*512, info.Size())
+	}
+	return info.Size()
+}
@@ -165,6 +165,14 @@ func SwapDelete[A any](arr []A, i int) []A {
 	return arr[:len(arr)-1]
 }
 
+// StorableKinds returns the kinds that fit in the 4 bytes used by the binary encoding and indexes,
+// the others can't have been stored so they would only match other events after being truncated.
+func StorableKinds(kinds []int) []int {
+	return slices.DeleteFunc(slices.Clone(kinds), func(kind int) bool {
+		return kind < 0 || kind > math.MaxUint32
+	})
+}
+
 func compareIterEvent(a, b IterEvent) int {
 	if a.Event == nil {
 		if b.Event == nil {
@@ -0,0 +1,140 @@
+package inflight
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/fiatjaf/eventstore"
+)
+
+// CloseTimeout is how long Close waits for in-flight operations before cancelling them.
+const CloseTimeout = 10 * time.Second
+
+// Tracker counts the operations that are currently using a store, so closing it can wait for
+// them instead of pulling resources from under their feet. The zero value is ready to use.
+type Tracker struct {
+	mu       sync.Mutex
+	count    int
+	closed   bool
+	drained  chan struct{} // closed when count reaches zero after closing started
+	closing  chan struct{} // closed when the first Close returns, with its result in closeErr
+	closeErr error
+
+	abort     context.Context // cancelled when we give up waiting and cancel everything
+	abortFunc context.CancelCauseFunc
+}
+
+func (t *Tracker) init() {
+	if t.abort == nil {
+		t.abort, t.abortFunc = context.WithCancelCause(context.Background())
+		t.drained = make(chan struct{})
+		t.closing = make(chan struct{})
+	}
+}
+
+// Acquire registers an operation, it fails with eventstore.ErrClosed if closing has already started.
+// Release must be called when the operation is done.
+func (t *Tracker) Acquire() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return eventstore.ErrClosed
+	}
+	t.count++
+	return nil
+}
+
+// Release marks an operation registered with Acquire as done.
+func (t *Tracker) Release() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.count--
+	if t.count == 0 && t.closed {
+		close(t.drained)
+	}
+}
+
+// AcquireContext is like Acquire, but also returns a context that is cancelled if the store
+// gives up waiting and has to close while the operation is still running. The returned function
+// must be called (instead of Release) when the operation is done.
+//
+// Operations started with the returned context (i.e. from inside this one) are accepted while
+// there are still operations running, so an operation that is built on top of others can finish
+// even if closing starts midway. Once they have all finished the store is gone and these fail too.
+func (t *Tracker) AcquireContext(ctx context.Context) (context.Context, func(), error) {
+	t.mu.Lock()
+	if t.closed && (ctx.Value(ctxKey{}) != t || t.count == 0) {
+		t.mu.Unlock()
+		return ctx, nil, eventstore.ErrClosed
+	}
+	t.count++
+	t.init()
+	abort := t.abort
+	t.mu.Unlock()
+
+	ctx, cancel := context.WithCancelCause(context.WithValue(ctx, ctxKey{}, t))
+	stop := context.AfterFunc(abort, func() { cancel(eventstore.ErrClosed) })
+
+	return ctx, func() {
+		stop()
+		cancel(nil)
+		t.Release()
+	}, nil
+}
+
+type ctxKey struct{}
+
+// Close rejects new operations and waits for the running ones to finish, then calls release.
+// If ctx is done before that the running operations are cancelled and an error is returned,
+// release is then only called in the background once they have all returned.
+//
+// Calling it again waits for the first call, up to its own ctx, and returns the same result.
+func (t *Tracker) Close(ctx context.Context, release func() error) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		select {
+		case <-t.closing:
+			return t.closeErr
+		case <-ctx.Done():
+			return fmt.Errorf("gave up waiting for the store to close: %w", context.Cause(ctx))
+		}
+	}
+	t.closed = true
+	t.init()
+	if t.count == 0 {
+		close(t.drained)
+	}
+	t.mu.Unlock()
+
+	t.closeErr = t.wait(ctx, release)
+	close(t.closing)
+	return t.closeErr
+}
+
+func (t *Tracker) wait(ctx context.Context, release func() error) error {
+	select {
+	case <-t.drained:
+		return release()
+	case <-ctx.Done():
+	}
+
+	t.mu.Lock()
+	pending := t.count
+	t.mu.Unlock()
+
+	t.abortFunc(eventstore.ErrClosed)
+	go func() {
+		<-t.drained
+		if err := release(); err != nil {
+			log.Printf("failed to close store after in-flight operations finished: %s\n", err)
+		}
+	}()
+
+	return fmt.Errorf("gave up waiting for %d in-flight operations: %w", pending, context.Cause(ctx))
+}
@@ -0,0 +1,150 @@
+package inflight
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloseWaitsForOperations(t *testing.T) {
+	var tracker Tracker
+	require.NoError(t, tracker.Acquire())
+
+	released := make(chan struct{})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		tracker.Release()
+	}()
+
+	require.NoError(t, tracker.Close(context.Background(), func() error {
+		close(released)
+		return nil
+	}))
+	select {
+	case <-released:
+	default:
+		t.Fatal("release wasn't called")
+	}
+
+	require.ErrorIs(t, tracker.Acquire(), eventstore.ErrClosed)
+	_, _, err := tracker.AcquireContext(context.Background())
+	require.ErrorIs(t, err, eventstore.ErrClosed)
+
+	// closing again is a noop
+	require.NoError(t, tracker.Close(context.Background(), nil))
+}
+
+func TestCloseCancelsOperationsAfterDeadline(t *testing.T) {
+	var tracker Tracker
+	opctx, done, err := tracker.AcquireContext(context.Background())
+	require.NoError(t, err)
+
+	released := make(chan struct{})
+	go func() {
+		<-opctx.Done()
+		require.ErrorIs(t, context.Cause(opctx), eventstore.ErrClosed)
+		done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = tracker.Close(ctx, func() error {
+		close(released)
+		return nil
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatal("release wasn't called after the cancelled operation finished")
+	}
+}
+
+func TestNestedOperationsAfterClosing(t *testing.T) {
+	var tracker Tracker
+	opctx, done, err := tracker.AcquireContext(context.Background())
+	require.NoError(t, err)
+
+	closed := make(chan error)
+	go func() { closed <- tracker.Close(context.Background(), func() error { return nil }) }()
+
+	// wait until closing has started
+	for tracker.Acquire() == nil {
+		tracker.Release()
+		time.Sleep(time.Millisecond)
+	}
+
+	_, _, err = tracker.AcquireContext(context.Background())
+	require.ErrorIs(t, err, eventstore.ErrClosed)
+
+	_, nestedDone, err := tracker.AcquireContext(opctx)
+	require.NoError(t, err)
+	nestedDone()
+
+	done()
+	require.NoError(t, <-closed)
+}
+
+func TestNestedOperationsAfterDrained(t *testing.T) {
+	var tracker Tracker
+	opctx, done, err := tracker.AcquireContext(context.Background())
+	require.NoError(t, err)
+	done()
+
+	require.NoError(t, tracker.Close(context.Background(), func() error { return nil }))
+
+	// the operation that owns opctx is over and the store was released, so this can't be let in
+	_, _, err = tracker.AcquireContext(opctx)
+	require.ErrorIs(t, err, eventstore.ErrClosed)
+}
+
+func TestCloseTwiceWaits(t *testing.T) {
+	var tracker Tracker
+	require.NoError(t, tracker.Acquire())
+
+	released := make(chan struct{})
+	first := make(chan error)
+	go func() {
+		first <- tracker.Close(context.Background(), func() error {
+			close(released)
+			return nil
+		})
+	}()
+
+	// wait until closing has started
+	for tracker.Acquire() == nil {
+		tracker.Release()
+		time.Sleep(time.Millisecond)
+	}
+
+	second := make(chan error)
+	go func() { second <- tracker.Close(context.Background(), nil) }()
+	select {
+	case <-second:
+		t.Fatal("second Close returned while an operation was still running")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	tracker.Release()
+	require.NoError(t, <-first)
+	require.NoError(t, <-second)
+	select {
+	case <-released:
+	default:
+		t.Fatal("release wasn't called")
+	}
+
+	// it also gets the error of the first one
+	var other Tracker
+	require.NoError(t, other.Acquire())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := other.Close(ctx, func() error { return nil })
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, err, other.Close(context.Background(), nil))
+	other.Release()
+}
@@ -0,0 +1,154 @@
+package jsonlstore
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+const compactionSuffix = ".compact"
+
+// rename is os.Rename, so tests can make it fail.
+var rename = os.Rename
+
+// Compact rewrites the log with only the events that weren't deleted, in the order they were
+// saved, and rebuilds the index for it. It happens by itself when deletions leave enough garbage,
+// see CompactThreshold.
+//
+// The new files are written next to the old ones and renamed over them, log first. If that is
+// interrupted the old index won't match the new log and is rebuilt on Init.
+func (b *JSONLStore) Compact() error {
+	if err := b.inflight.Acquire(); err != nil {
+		return err
+	}
+	defer b.inflight.Release()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.compact()
+}
+
+// compact must be called with the write lock held.
+func (b *JSONLStore) compact() error {
+	entries := make([]*entry, 0, len(b.byID))
+	for _, e := range b.byID {
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b *entry) int { return cmp.Compare(a.offset, b.offset) })
+
+	logPath := b.Path + compactionSuffix
+	idxPath := b.indexPath() + compactionSuffix
+	newLog, err := os.OpenFile(logPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	newIdx, err := os.OpenFile(idxPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		newLog.Close()
+		os.Remove(logPath)
+		return err
+	}
+
+	// the new files are closed and removed unless they take the place of the old ones
+	var logSwapped, idxSwapped bool
+	defer func() {
+		if !logSwapped {
+			newLog.Close()
+			os.Remove(logPath)
+		}
+		if !idxSwapped {
+			newIdx.Close()
+			os.Remove(idxPath)
+		}
+	}()
+
+	logWriter := bufio.NewWriterSize(newLog, 1<<20)
+	idxWriter := bufio.NewWriterSize(newIdx, 1<<20)
+	idxWriter.WriteString(indexMagic)
+
+	offsets := make([]int64, len(entries))
+	var size int64
+	var line []byte
+	buf := make([]byte, recordSize)
+	for i, e := range entries {
+		line = slices.Grow(line[:0], int(e.length)+1)[:e.length+1]
+		if _, err := b.log.ReadAt(line, e.offset); err != nil {
+			return fmt.Errorf("failed to read event at %d: %w", e.offset, err)
+		}
+		if _, err := logWriter.Write(line); err != nil {
+			return err
+		}
+
+		offsets[i] = size
+		r := record{typ: recordEvent, entry: *e}
+		r.offset = size
+		r.encode(buf)
+		if _, err := idxWriter.Write(buf); err != nil {
+			return err
+		}
+		size += int64(e.length) + 1
+	}
+
+	for _, f := range []struct {
+		w *bufio.Writer
+		f *os.File
+	}{{logWriter, newLog}, {idxWriter, newIdx}} {
+		if err := f.w.Flush(); err != nil {
+			return err
+		}
+		if err := f.f.Sync(); err != nil {
+			return err
+		}
+	}
+
+	if err := rename(logPath, b.Path); err != nil {
+		return err
+	}
+	// from here on the new log is in place, so we must switch to it even if the rest fails
+	logSwapped = true
+	b.log.Close()
+	b.log = newLog
+	for i, e := range entries {
+		e.offset = offsets[i]
+	}
+	b.size = size
+	b.dead = 0
+
+	indexSize := int64(len(indexMagic)) + int64(len(entries))*recordSize
+	if err := rename(idxPath, b.indexPath()); err != nil {
+		// the old index doesn't match the new log anymore and new records would be appended to it,
+		// so we copy the new one over it
+		if cerr := b.replaceIndex(newIdx, indexSize); cerr != nil {
+			// or, if even that fails, make sure it can't be read so it is rebuilt on Init
+			b.idx.Truncate(0)
+			b.idx.WriteAt(make([]byte, len(indexMagic)), 0)
+			b.idxSize = int64(len(indexMagic))
+		}
+		return err
+	}
+	idxSwapped = true
+	b.idx.Close()
+	b.idx = newIdx
+	b.idxSize = indexSize
+
+	return nil
+}
+
+// replaceIndex overwrites the contents of the current index file with the ones of idx.
+func (b *JSONLStore) replaceIndex(idx *os.File, size int64) error {
+	if err := b.idx.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.NewOffsetWriter(b.idx, 0), io.NewSectionReader(idx, 0, size)); err != nil {
+		return err
+	}
+	if err := b.idx.Sync(); err != nil {
+		return err
+	}
+	b.idxSize = size
+	return nil
+}
@@ -0,0 +1,30 @@
+package jsonlstore
+
+import (
+	"context"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// CountEvents only reads events from the file when the filter has tags, everything else is in the
+// index.
+func (b *JSONLStore) CountEvents(ctx context.Context, filter nostr.Filter) (int64, error) {
+	if err := b.inflight.Acquire(); err != nil {
+		return 0, err
+	}
+	defer b.inflight.Release()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if len(filter.Tags) > 0 {
+		events, err := b.query(filter, -1)
+		return int64(len(events)), err
+	}
+
+	var count int64
+	for range b.candidates(filter) {
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,53 @@
+package jsonlstore
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func (b *JSONLStore) DeleteEvent(ctx context.Context, evt *nostr.Event) error {
+	if err := b.inflight.Acquire(); err != nil {
+		return err
+	}
+	defer b.inflight.Release()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.delete(evt); err != nil {
+		return err
+	}
+	b.maybeCompact()
+	return nil
+}
+
+// delete must be called with the write lock held.
+func (b *JSONLStore) delete(evt *nostr.Event) error {
+	var id [32]byte
+	if err := decodeHex32(evt.ID, &id); err != nil {
+		return fmt.Errorf("invalid id: %w", err)
+	}
+	if _, ok := b.byID[id]; !ok {
+		// we don't have this event
+		return nil
+	}
+
+	line := fmt.Appendf(nil, `{"tombstone":"%s"}`, evt.ID)
+	r := record{typ: recordTombstone, entry: entry{id: id, offset: b.size, length: uint32(len(line))}}
+	return b.appendLine(line, r)
+}
+
+// maybeCompact compacts the log if enough of it is garbage, it must be called with the write
+// lock held. Failing to compact is not a failure of whatever deleted events, so it is only logged.
+func (b *JSONLStore) maybeCompact() {
+	if b.CompactThreshold < 0 || b.dead < minCompactionGarbage ||
+		float64(b.dead) < b.CompactThreshold*float64(b.size) {
+		return
+	}
+	if err := b.compact(); err != nil {
+		log.Printf("[jsonl] failed to compact '%s': %s\n", b.Path, err)
+	}
+}
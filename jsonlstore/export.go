@@ -0,0 +1,61 @@
+package jsonlstore
+
+import (
+	"context"
+	"iter"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+var _ eventstore.Exporter = (*JSONLStore)(nil)
+
+// ExportEvents goes through the events oldest first. It doesn't hold the lock between events, so
+// events saved or deleted meanwhile may or may not be included.
+func (b *JSONLStore) ExportEvents(ctx context.Context, filter nostr.Filter) iter.Seq2[*nostr.Event, error] {
+	return func(yield func(*nostr.Event, error) bool) {
+		if filter.Search != "" {
+			return
+		}
+
+		b.mu.RLock()
+		var entries []*entry
+		for e := range b.candidates(filter) {
+			entries = append(entries, e)
+		}
+		b.mu.RUnlock()
+
+		var count int
+		for i := len(entries) - 1; i >= 0; i-- {
+			if i%1000 == 0 {
+				if err := ctx.Err(); err != nil {
+					yield(nil, err)
+					return
+				}
+			}
+
+			b.mu.RLock()
+			var evt *nostr.Event
+			var err error
+			if !entries[i].deleted {
+				evt, err = b.read(entries[i])
+			}
+			b.mu.RUnlock()
+
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if evt == nil || !filter.Matches(evt) {
+				continue
+			}
+			if !yield(evt, nil) {
+				return
+			}
+			count++
+			if count == filter.Limit {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package jsonlstore
+
+import "context"
+
+func (b *JSONLStore) HasEvents(ctx context.Context, ids []string) ([]bool, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	results := make([]bool, len(ids))
+	for i, idHex := range ids {
+		var id [32]byte
+		if decodeHex32(idHex, &id) != nil {
+			continue
+		}
+		_, results[i] = b.byID[id]
+	}
+	return results, nil
+}
@@ -0,0 +1,408 @@
+package jsonlstore
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/google/btree"
+	"github.com/mailru/easyjson"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// entry is what we keep in memory about each event, enough to answer queries without reading
+// events that can't match.
+type entry struct {
+	id        [32]byte
+	pubkey    [32]byte
+	createdAt uint32
+	kind      uint32
+	offset    int64  // of the line in the log
+	length    uint32 // of the line, without the newline
+	deleted   bool
+}
+
+// entryTree keeps entries sorted as compareEntries says, inserting and removing in O(log n) even
+// when there are millions of them.
+type entryTree = btree.BTreeG[*entry]
+
+func newEntryTree() *entryTree {
+	return btree.NewG(32, func(a, b *entry) bool { return compareEntries(a, b) < 0 })
+}
+
+// the index file is a magic header followed by fixed-size records, one for each line of the log:
+//
+//	type (1) | id (32) | pubkey (32) | created_at (4) | kind (4) | offset (8) | length (4)
+//
+// tombstone records only have the id of the deleted event, besides their own offset and length.
+const (
+	indexMagic = "ESJSONL1"
+	recordSize = 1 + 32 + 32 + 4 + 4 + 8 + 4
+
+	recordEvent     = 1
+	recordTombstone = 2
+)
+
+var tombstonePrefix = []byte(`{"tombstone":"`)
+
+type record struct {
+	typ byte
+	entry
+}
+
+func (r record) encode(buf []byte) {
+	buf[0] = r.typ
+	copy(buf[1:33], r.id[:])
+	copy(buf[33:65], r.pubkey[:])
+	binary.BigEndian.PutUint32(buf[65:69], r.createdAt)
+	binary.BigEndian.PutUint32(buf[69:73], r.kind)
+	binary.BigEndian.PutUint64(buf[73:81], uint64(r.offset))
+	binary.BigEndian.PutUint32(buf[81:85], r.length)
+}
+
+func decodeRecord(buf []byte) (record, error) {
+	r := record{typ: buf[0]}
+	if r.typ != recordEvent && r.typ != recordTombstone {
+		return r, fmt.Errorf("%w: invalid index record type %d", eventstore.ErrCorrupted, r.typ)
+	}
+	copy(r.id[:], buf[1:33])
+	copy(r.pubkey[:], buf[33:65])
+	r.createdAt = binary.BigEndian.Uint32(buf[65:69])
+	r.kind = binary.BigEndian.Uint32(buf[69:73])
+	r.offset = int64(binary.BigEndian.Uint64(buf[73:81]))
+	r.length = binary.BigEndian.Uint32(buf[81:85])
+	return r, nil
+}
+
+// end is where the line of this record ends in the log, after its newline.
+func (r record) end() int64 {
+	return r.offset + int64(r.length) + 1
+}
+
+// load reads the index into memory, rebuilding it from the log if it doesn't match, and indexes
+// whatever was appended to the log after it.
+func (b *JSONLStore) load() error {
+	b.resetMemory()
+
+	size, err := b.repairTail()
+	if err != nil {
+		return err
+	}
+	b.size = size
+
+	records, err := b.readIndex()
+	if err != nil {
+		log.Printf("[jsonl] rebuilding index of '%s': %s\n", b.Path, err)
+		records = nil
+	}
+	b.idxSize = int64(len(indexMagic) + len(records)*recordSize)
+	if err := b.idx.Truncate(b.idxSize); err != nil {
+		return fmt.Errorf("failed to truncate index: %w", err)
+	}
+	if len(records) == 0 {
+		if _, err := b.idx.WriteAt([]byte(indexMagic), 0); err != nil {
+			return fmt.Errorf("failed to write index: %w", err)
+		}
+	}
+
+	var indexed int64
+	for _, r := range records {
+		b.apply(r)
+		indexed = r.end()
+	}
+
+	// lines added after the last indexed one, by a crash between writing to the log and to the
+	// index or by hand
+	return b.indexFrom(indexed)
+}
+
+func (b *JSONLStore) resetMemory() {
+	b.dead = 0
+	b.byID = make(map[[32]byte]*entry)
+	b.all = newEntryTree()
+	b.byAuthor = make(map[[32]byte]*entryTree)
+	b.byKind = make(map[uint32]*entryTree)
+}
+
+// repairTail makes the log end in a newline and returns its size. A last line without one is
+// kept if it is a complete event, edited by hand, otherwise it is what was left of an interrupted
+// write and is moved to the end of Path + ".torn".
+func (b *JSONLStore) repairTail() (int64, error) {
+	info, err := b.log.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+
+	buf := make([]byte, 4096)
+	end := size
+	for end > 0 {
+		start := max(0, end-int64(len(buf)))
+		chunk := buf[:end-start]
+		if _, err := b.log.ReadAt(chunk, start); err != nil {
+			return 0, fmt.Errorf("failed to read '%s': %w", b.Path, err)
+		}
+		if i := bytes.LastIndexByte(chunk, '\n'); i != -1 {
+			end = start + int64(i) + 1
+			break
+		}
+		end = start
+	}
+	if end == size {
+		return size, nil
+	}
+
+	tail := make([]byte, size-end)
+	if _, err := b.log.ReadAt(tail, end); err != nil {
+		return 0, fmt.Errorf("failed to read '%s': %w", b.Path, err)
+	}
+
+	if _, err := parseLine(tail, end); err == nil {
+		if _, err := b.log.WriteAt([]byte{'\n'}, size); err != nil {
+			return 0, fmt.Errorf("failed to write to '%s': %w", b.Path, err)
+		}
+		return size + 1, nil
+	}
+
+	tornPath := b.Path + ".torn"
+	log.Printf("[jsonl] moving %d bytes of an incomplete line at the end of '%s' to '%s'\n", len(tail), b.Path, tornPath)
+	torn, err := os.OpenFile(tornPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open '%s': %w", tornPath, err)
+	}
+	_, err = torn.Write(append(tail, '\n'))
+	if err == nil {
+		err = torn.Sync()
+	}
+	if cerr := torn.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write to '%s': %w", tornPath, err)
+	}
+
+	if err := b.log.Truncate(end); err != nil {
+		return 0, fmt.Errorf("failed to truncate '%s': %w", b.Path, err)
+	}
+	return end, nil
+}
+
+// readIndex returns the records in the index, or an error if they don't match the log.
+func (b *JSONLStore) readIndex() ([]record, error) {
+	data, err := io.ReadAll(io.NewSectionReader(b.idx, 0, 1<<62))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if !bytes.HasPrefix(data, []byte(indexMagic)) {
+		return nil, errors.New("unknown index format")
+	}
+	data = data[len(indexMagic):]
+
+	// a record that was only partly written is just dropped, its line is indexed again
+	records := make([]record, 0, len(data)/recordSize)
+	var end int64
+	for len(data) >= recordSize {
+		r, err := decodeRecord(data[:recordSize])
+		if err != nil {
+			return nil, err
+		}
+		if r.offset != end {
+			return nil, fmt.Errorf("index record at %d should be at %d", r.offset, end)
+		}
+		end = r.end()
+		records = append(records, r)
+		data = data[recordSize:]
+	}
+
+	// after a compaction the index may be the one of the old, bigger, log
+	if end > b.size {
+		return nil, fmt.Errorf("index goes up to %d but the file has %d bytes", end, b.size)
+	}
+	if len(records) > 0 {
+		if err := b.checkRecord(records[len(records)-1]); err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+// checkRecord tells if the line at r is the one r says it is.
+func (b *JSONLStore) checkRecord(r record) error {
+	line := make([]byte, r.length+1)
+	if _, err := b.log.ReadAt(line, r.offset); err != nil {
+		return err
+	}
+	if line[r.length] != '\n' {
+		return fmt.Errorf("line at %d doesn't end where the index says", r.offset)
+	}
+
+	rr, err := parseLine(line[:r.length], r.offset)
+	if err != nil {
+		return err
+	}
+	if rr.typ != r.typ || rr.id != r.id {
+		return fmt.Errorf("line at %d isn't the one in the index", r.offset)
+	}
+	return nil
+}
+
+// indexFrom indexes the lines of the log from offset onwards.
+func (b *JSONLStore) indexFrom(offset int64) error {
+	if offset == b.size {
+		return nil
+	}
+
+	reader := bufio.NewReaderSize(io.NewSectionReader(b.log, offset, b.size-offset), 1<<20)
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// a long line, read the rest of it
+			long := slices.Clone(line)
+			for err == bufio.ErrBufferFull {
+				line, err = reader.ReadSlice('\n')
+				long = append(long, line...)
+			}
+			line = long
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read '%s': %w", b.Path, err)
+		}
+
+		r, err := parseLine(line[:len(line)-1], offset)
+		if err != nil {
+			log.Printf("[jsonl] skipping invalid line at %d in '%s': %s\n", offset, b.Path, err)
+			b.dead += int64(len(line))
+		} else {
+			if err := b.writeRecord(r); err != nil {
+				return err
+			}
+			b.apply(r)
+		}
+		offset += int64(len(line))
+	}
+}
+
+// parseLine makes the record of a line of the log found at offset.
+func parseLine(line []byte, offset int64) (record, error) {
+	r := record{entry: entry{offset: offset, length: uint32(len(line))}}
+
+	if bytes.HasPrefix(line, tombstonePrefix) {
+		var tombstone struct {
+			ID string `json:"tombstone"`
+		}
+		if err := json.Unmarshal(line, &tombstone); err != nil {
+			return r, err
+		}
+		if err := decodeHex32(tombstone.ID, &r.id); err != nil {
+			return r, err
+		}
+		r.typ = recordTombstone
+		return r, nil
+	}
+
+	evt := &nostr.Event{}
+	if err := easyjson.Unmarshal(line, evt); err != nil {
+		return r, err
+	}
+	if err := decodeHex32(evt.ID, &r.id); err != nil {
+		return r, fmt.Errorf("invalid id: %w", err)
+	}
+	if err := decodeHex32(evt.PubKey, &r.pubkey); err != nil {
+		return r, fmt.Errorf("invalid pubkey: %w", err)
+	}
+	r.createdAt = uint32(evt.CreatedAt)
+	r.kind = uint32(evt.Kind)
+	r.typ = recordEvent
+	return r, nil
+}
+
+func decodeHex32(s string, dst *[32]byte) error {
+	if len(s) != 64 {
+		return fmt.Errorf("'%s' is not 32 bytes of hex", s)
+	}
+	_, err := hex.Decode(dst[:], []byte(s))
+	return err
+}
+
+// apply updates what we have in memory with a record that was read from or written to the index.
+func (b *JSONLStore) apply(r record) {
+	switch r.typ {
+	case recordEvent:
+		if _, ok := b.byID[r.id]; ok {
+			// the same event twice, only possible if the log was edited by hand
+			b.dead += int64(r.length) + 1
+			return
+		}
+		e := &entry{}
+		*e = r.entry
+		b.byID[e.id] = e
+		b.all.ReplaceOrInsert(e)
+		addToMap(b.byAuthor, e.pubkey, e)
+		addToMap(b.byKind, e.kind, e)
+	case recordTombstone:
+		b.dead += int64(r.length) + 1
+		e, ok := b.byID[r.id]
+		if !ok {
+			return
+		}
+		e.deleted = true
+		b.dead += int64(e.length) + 1
+		delete(b.byID, e.id)
+		b.all.Delete(e)
+		removeFromMap(b.byAuthor, e.pubkey, e)
+		removeFromMap(b.byKind, e.kind, e)
+	}
+}
+
+// writeRecord appends r to the index.
+func (b *JSONLStore) writeRecord(r record) error {
+	buf := make([]byte, recordSize)
+	r.encode(buf)
+	if _, err := b.idx.WriteAt(buf, b.idxSize); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	b.idxSize += recordSize
+	return nil
+}
+
+// entries are sorted oldest first, ties broken by id
+func compareEntries(a, b *entry) int {
+	if c := cmp.Compare(a.createdAt, b.createdAt); c != 0 {
+		return c
+	}
+	return bytes.Compare(a.id[:], b.id[:])
+}
+
+func addToMap[K comparable](m map[K]*entryTree, key K, e *entry) {
+	tree, ok := m[key]
+	if !ok {
+		tree = newEntryTree()
+		m[key] = tree
+	}
+	tree.ReplaceOrInsert(e)
+}
+
+func removeFromMap[K comparable](m map[K]*entryTree, key K, e *entry) {
+	if tree, ok := m[key]; ok {
+		tree.Delete(e)
+		if tree.Len() == 0 {
+			delete(m, key)
+		}
+	}
+}
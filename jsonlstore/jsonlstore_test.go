@@ -0,0 +1,290 @@
+package jsonlstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func makeEvents(t *testing.T, n int) []*nostr.Event {
+	sk := nostr.GeneratePrivateKey()
+	events := make([]*nostr.Event, n)
+	for i := range events {
+		events[i] = &nostr.Event{CreatedAt: nostr.Timestamp(i + 1), Kind: 1 + i%3, Tags: nostr.Tags{{"t", fmt.Sprint(i % 5)}}, Content: fmt.Sprintf("event %d", i)}
+		require.NoError(t, events[i].Sign(sk))
+	}
+	return events
+}
+
+func countAll(t *testing.T, db *JSONLStore) int64 {
+	n, err := db.CountEvents(context.Background(), nostr.Filter{})
+	require.NoError(t, err)
+	return n
+}
+
+func TestPersistenceAndTombstones(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/jsonltest-persistence.jsonl"
+	os.Remove(path)
+	os.Remove(path + ".idx")
+	defer os.Remove(path)
+	defer os.Remove(path + ".idx")
+
+	db := &JSONLStore{Path: path, SyncWrites: true}
+	require.NoError(t, db.Init())
+	events := makeEvents(t, 30)
+	for _, evt := range events {
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+	require.ErrorIs(t, db.SaveEvent(ctx, events[0]), eventstore.ErrDupEvent)
+	require.NoError(t, db.DeleteEvent(ctx, events[3]))
+	db.Close()
+
+	// the file is plain JSONL, with a tombstone at the end
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+	require.Len(t, lines, 31)
+	require.Contains(t, string(lines[0]), `"content":"event 0"`)
+	require.Equal(t, `{"tombstone":"`+events[3].ID+`"}`, string(lines[30]))
+
+	for _, removeIndex := range []bool{false, true} {
+		if removeIndex {
+			require.NoError(t, os.Remove(path+".idx"))
+		}
+
+		db = &JSONLStore{Path: path}
+		require.NoError(t, db.Init())
+		require.Equal(t, int64(29), countAll(t, db))
+
+		res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Kinds: []int{1}, Tags: nostr.TagMap{"t": {"0"}}, Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, res, 2)
+		require.Equal(t, events[15].ID, res[0].ID)
+		require.Equal(t, events[0].ID, res[1].ID)
+
+		has, err := db.HasEvents(ctx, []string{events[2].ID, events[3].ID})
+		require.NoError(t, err)
+		require.Equal(t, []bool{true, false}, has)
+		db.Close()
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/jsonltest-recovery.jsonl"
+	os.Remove(path)
+	os.Remove(path + ".idx")
+	os.Remove(path + ".torn")
+	defer os.Remove(path)
+	defer os.Remove(path + ".idx")
+	defer os.Remove(path + ".torn")
+
+	events := makeEvents(t, 10)
+	db := &JSONLStore{Path: path}
+	require.NoError(t, db.Init())
+	for _, evt := range events[:5] {
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+	db.Close()
+
+	// an event appended by hand and another that was cut in half
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	fmt.Fprintln(f, events[5].String())
+	fmt.Fprint(f, events[6].String()[:50])
+	f.Close()
+
+	// and a record that was cut in half
+	f, err = os.OpenFile(path+".idx", os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	f.Write(make([]byte, recordSize/2))
+	f.Close()
+
+	db = &JSONLStore{Path: path}
+	require.NoError(t, db.Init())
+	require.Equal(t, int64(6), countAll(t, db))
+	torn, err := os.ReadFile(path + ".torn")
+	require.NoError(t, err)
+	require.Equal(t, events[6].String()[:50]+"\n", string(torn))
+	require.NoError(t, db.SaveEvent(ctx, events[6]))
+	db.Close()
+
+	db = &JSONLStore{Path: path}
+	require.NoError(t, db.Init())
+	defer db.Close()
+	require.Equal(t, int64(7), countAll(t, db))
+	res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{IDs: []string{events[6].ID}})
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, events[6].Content, res[0].Content)
+}
+
+func TestLastLineWithoutNewline(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/jsonltest-nonewline.jsonl"
+	os.Remove(path + ".idx")
+	defer os.Remove(path)
+	defer os.Remove(path + ".idx")
+
+	// as a file edited by hand could be
+	events := makeEvents(t, 2)
+	require.NoError(t, os.WriteFile(path, []byte(events[0].String()+"\n"+events[1].String()), 0644))
+
+	db := &JSONLStore{Path: path}
+	require.NoError(t, db.Init())
+	require.Equal(t, int64(2), countAll(t, db))
+	require.NoError(t, db.SaveEvent(ctx, makeEvents(t, 1)[0]))
+	db.Close()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+	require.Len(t, lines, 3)
+	require.Equal(t, events[1].String(), string(lines[1]))
+
+	db = &JSONLStore{Path: path}
+	require.NoError(t, db.Init())
+	defer db.Close()
+	require.Equal(t, int64(3), countAll(t, db))
+}
+
+func TestCompaction(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/jsonltest-compaction.jsonl"
+	os.Remove(path)
+	os.Remove(path + ".idx")
+	defer os.Remove(path)
+	defer os.Remove(path + ".idx")
+
+	db := &JSONLStore{Path: path}
+	require.NoError(t, db.Init())
+	events := makeEvents(t, 2000)
+	for _, evt := range events {
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+	sizeBefore := db.size
+
+	// deleting most of them compacts the file by itself at some point
+	for _, evt := range events[:1500] {
+		require.NoError(t, db.DeleteEvent(ctx, evt))
+	}
+	require.Less(t, db.size, sizeBefore)
+
+	require.NoError(t, db.Compact())
+	require.Equal(t, int64(0), db.dead)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "tombstone")
+	require.Equal(t, 500, bytes.Count(data, []byte("\n")))
+
+	// still works after compacting and after reopening
+	for _, db := range []*JSONLStore{db, {Path: path}} {
+		if db.log == nil {
+			require.NoError(t, db.Init())
+		}
+		require.Equal(t, int64(500), countAll(t, db))
+		res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Kinds: []int{2}, Limit: 3})
+		require.NoError(t, err)
+		require.Len(t, res, 3)
+		require.Equal(t, events[1999].ID, res[0].ID)
+		require.Equal(t, events[1996].ID, res[1].ID)
+		require.NoError(t, db.SaveEvent(ctx, events[0]))
+		require.NoError(t, db.DeleteEvent(ctx, events[0]))
+		db.Close()
+	}
+}
+
+func TestStaleIndexAfterInterruptedCompaction(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/jsonltest-stale.jsonl"
+	os.Remove(path)
+	os.Remove(path + ".idx")
+	defer os.Remove(path)
+	defer os.Remove(path + ".idx")
+
+	db := &JSONLStore{Path: path, CompactThreshold: -1}
+	require.NoError(t, db.Init())
+	events := makeEvents(t, 20)
+	for _, evt := range events {
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+	for _, evt := range events[:10] {
+		require.NoError(t, db.DeleteEvent(ctx, evt))
+	}
+	db.Close()
+
+	// as if the log had been swapped but not the index
+	oldIndex, err := os.ReadFile(path + ".idx")
+	require.NoError(t, err)
+	db = &JSONLStore{Path: path}
+	require.NoError(t, db.Init())
+	require.NoError(t, db.Compact())
+	db.Close()
+	require.NoError(t, os.WriteFile(path+".idx", oldIndex, 0644))
+
+	db = &JSONLStore{Path: path}
+	require.NoError(t, db.Init())
+	defer db.Close()
+	require.Equal(t, int64(10), countAll(t, db))
+	res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{})
+	require.NoError(t, err)
+	require.Len(t, res, 10)
+	require.Equal(t, events[19].ID, res[0].ID)
+}
+
+func TestIndexRenameFailureDuringCompaction(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/jsonltest-renamefailure.jsonl"
+	os.Remove(path)
+	os.Remove(path + ".idx")
+	defer os.Remove(path)
+	defer os.Remove(path + ".idx")
+
+	db := &JSONLStore{Path: path, CompactThreshold: -1}
+	require.NoError(t, db.Init())
+	events := makeEvents(t, 30)
+	for _, evt := range events[:20] {
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+	for _, evt := range events[:10] {
+		require.NoError(t, db.DeleteEvent(ctx, evt))
+	}
+
+	// the log is swapped, but not the index
+	rename = func(from, to string) error {
+		if to == path+".idx" {
+			return os.ErrPermission
+		}
+		return os.Rename(from, to)
+	}
+	defer func() { rename = os.Rename }()
+	require.ErrorIs(t, db.Compact(), os.ErrPermission)
+
+	// the records of these must go in an index that matches the new log
+	for _, evt := range events[20:] {
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+	records, err := db.readIndex()
+	require.NoError(t, err)
+	require.Len(t, records, 20)
+	db.Close()
+
+	db = &JSONLStore{Path: path}
+	require.NoError(t, db.Init())
+	defer db.Close()
+	require.Equal(t, int64(20), countAll(t, db))
+	res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Limit: 100})
+	require.NoError(t, err)
+	require.Len(t, res, 20)
+	for i, evt := range res {
+		require.Equal(t, events[29-i].ID, evt.ID)
+		require.Equal(t, events[29-i].Content, evt.Content)
+	}
+}
@@ -0,0 +1,129 @@
+package jsonlstore
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/fiatjaf/eventstore/internal/inflight"
+)
+
+var _ eventstore.Store = (*JSONLStore)(nil)
+
+// JSONLStore keeps events in a JSONL file, one event per line, that is only ever appended to.
+// Deleting an event appends a tombstone line, {"tombstone":"<id>"}, and the file is rewritten
+// without the deleted events once they take up enough of it.
+//
+// Next to the file, at Path + ".idx", there is a binary index with the position, id, author, kind
+// and created_at of every line, so opening the store doesn't have to decode every event and
+// queries only read the events they may return. The index is rebuilt from the file if it is
+// missing or doesn't match it, so the file can be copied, grepped or appended to by hand.
+//
+// Unless SyncWrites is set, writes are not synced to disk until Close: SaveEvent and DeleteEvent
+// returning doesn't mean they will survive a crash, which can lose the last ones. A line that was
+// only partly written is moved to Path + ".torn" on Init.
+type JSONLStore struct {
+	// Path is the JSONL file, it is created if it doesn't exist.
+	Path string
+
+	MaxLimit int
+
+	// CompactThreshold is the fraction of the file that has to be deleted events and tombstones
+	// for a deletion to trigger a compaction, defaults to 0.5. Negative values disable it, Compact
+	// can still be called.
+	CompactThreshold float64
+
+	// SyncWrites makes each save and deletion be synced to disk before returning, so they aren't
+	// lost to a crash. The index doesn't need it, since it is rebuilt from the file if it falls
+	// behind.
+	SyncWrites bool
+
+	mu       sync.RWMutex
+	inflight inflight.Tracker
+
+	log     *os.File
+	idx     *os.File
+	size    int64 // of the log, where the next line goes
+	idxSize int64 // of the index, where the next record goes
+	dead    int64 // bytes of the log taken by deleted events, tombstones and invalid lines
+
+	byID     map[[32]byte]*entry
+	all      *entryTree // sorted by created_at and id, like the ones below
+	byAuthor map[[32]byte]*entryTree
+	byKind   map[uint32]*entryTree
+}
+
+const (
+	defaultMaxLimit         = 500
+	defaultCompactThreshold = 0.5
+
+	// compactions don't happen for less garbage than this, whatever the threshold
+	minCompactionGarbage = 64 * 1024
+)
+
+func (b *JSONLStore) Init() error {
+	if b.Path == "" {
+		return fmt.Errorf("missing Path")
+	}
+	if b.MaxLimit == 0 {
+		b.MaxLimit = defaultMaxLimit
+	}
+	if b.CompactThreshold == 0 {
+		b.CompactThreshold = defaultCompactThreshold
+	}
+
+	// a compaction may have been interrupted before the swap, in which case the original is still intact
+	os.Remove(b.Path + compactionSuffix)
+	os.Remove(b.indexPath() + compactionSuffix)
+
+	var err error
+	b.log, err = os.OpenFile(b.Path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open '%s': %w", b.Path, err)
+	}
+	b.idx, err = os.OpenFile(b.indexPath(), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		b.log.Close()
+		return fmt.Errorf("failed to open index '%s': %w", b.indexPath(), err)
+	}
+
+	if err := b.load(); err != nil {
+		b.log.Close()
+		b.idx.Close()
+		return err
+	}
+	return nil
+}
+
+func (b *JSONLStore) indexPath() string {
+	return b.Path + ".idx"
+}
+
+// Close is CloseContext with a timeout of inflight.CloseTimeout.
+func (b *JSONLStore) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), inflight.CloseTimeout)
+	defer cancel()
+	if err := b.CloseContext(ctx); err != nil {
+		log.Printf("[jsonl] failed to close: %s\n", err)
+	}
+}
+
+// CloseContext syncs and closes the files once the running operations are done.
+// See [eventstore.GracefulCloser].
+func (b *JSONLStore) CloseContext(ctx context.Context) error {
+	return b.inflight.Close(ctx, func() error {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		err := b.log.Sync()
+		if ierr := b.idx.Sync(); err == nil {
+			err = ierr
+		}
+		b.log.Close()
+		b.idx.Close()
+		return err
+	})
+}
@@ -0,0 +1,281 @@
+package jsonlstore
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"iter"
+	"math"
+	"slices"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/mailru/easyjson"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func (b *JSONLStore) QueryEvents(ctx context.Context, filter nostr.Filter) (chan *nostr.Event, error) {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *nostr.Event)
+	limit := b.getLimit(ctx, filter)
+	if limit == 0 {
+		close(ch)
+		done()
+		return ch, nil
+	}
+
+	// events are read while holding the lock, so a compaction can't move them, and sent after
+	b.mu.RLock()
+	events, err := b.query(filter, limit)
+	b.mu.RUnlock()
+	if err != nil {
+		done()
+		return nil, err
+	}
+
+	go func() {
+		defer done()
+		defer close(ch)
+		for _, evt := range events {
+			select {
+			case ch <- evt:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (b *JSONLStore) QueryIDs(ctx context.Context, filter nostr.Filter) iter.Seq2[eventstore.IDTimestamp, error] {
+	return func(yield func(eventstore.IDTimestamp, error) bool) {
+		ctx, done, err := b.inflight.AcquireContext(ctx)
+		if err != nil {
+			yield(eventstore.IDTimestamp{}, err)
+			return
+		}
+		defer done()
+
+		limit := b.getLimit(ctx, filter)
+		if limit == 0 {
+			return
+		}
+
+		b.mu.RLock()
+		var results []eventstore.IDTimestamp
+		if len(filter.Tags) > 0 {
+			// tags are only in the events themselves
+			var events []*nostr.Event
+			events, err = b.query(filter, limit)
+			for _, evt := range events {
+				results = append(results, eventstore.IDTimestamp{ID: evt.ID, CreatedAt: evt.CreatedAt})
+			}
+		} else {
+			for e := range b.candidates(filter) {
+				results = append(results, eventstore.IDTimestamp{ID: hex.EncodeToString(e.id[:]), CreatedAt: nostr.Timestamp(e.createdAt)})
+				if len(results) == limit {
+					break
+				}
+			}
+		}
+		b.mu.RUnlock()
+
+		if err != nil {
+			yield(eventstore.IDTimestamp{}, err)
+			return
+		}
+		for _, result := range results {
+			if !yield(result, nil) {
+				return
+			}
+		}
+	}
+}
+
+// getLimit returns the maximum number of events we'll return for this filter, 0 means nothing should be returned
+func (b *JSONLStore) getLimit(ctx context.Context, filter nostr.Filter) int {
+	if filter.Search != "" || filter.LimitZero {
+		return 0
+	}
+
+	limit := b.MaxLimit
+	if eventstore.IsNegentropySession(ctx) {
+		limit = math.MaxInt
+	}
+	if filter.Limit > 0 && filter.Limit < limit {
+		limit = filter.Limit
+	}
+	if tlimit := nostr.GetTheoreticalLimit(filter); tlimit >= 0 && tlimit < limit {
+		limit = tlimit
+	}
+	return limit
+}
+
+// query reads the events matching filter, newest first, up to limit of them or all if limit is
+// negative. It must be called with the lock held.
+func (b *JSONLStore) query(filter nostr.Filter, limit int) ([]*nostr.Event, error) {
+	var events []*nostr.Event
+	for e := range b.candidates(filter) {
+		if len(events) == limit {
+			break
+		}
+
+		evt, err := b.read(e)
+		if err != nil {
+			return nil, err
+		}
+		if filter.Matches(evt) {
+			events = append(events, evt)
+		}
+	}
+	return events, nil
+}
+
+// read decodes the event of e from the log, it must be called with the lock held.
+func (b *JSONLStore) read(e *entry) (*nostr.Event, error) {
+	line := make([]byte, e.length)
+	if _, err := b.log.ReadAt(line, e.offset); err != nil {
+		return nil, fmt.Errorf("%w: failed to read event at %d: %w", eventstore.ErrStorageUnavailable, e.offset, err)
+	}
+
+	evt := &nostr.Event{}
+	if err := easyjson.Unmarshal(line, evt); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode event at %d: %w", eventstore.ErrCorrupted, e.offset, err)
+	}
+	return evt, nil
+}
+
+// candidates yields, newest first, the entries that match everything in filter but the tags,
+// which can only be checked in the events. It must be called with the lock held.
+func (b *JSONLStore) candidates(filter nostr.Filter) iter.Seq[*entry] {
+	return func(yield func(*entry) bool) {
+		since := uint32(0)
+		if filter.Since != nil {
+			since = uint32(max(0, *filter.Since))
+		}
+		until := uint32(math.MaxUint32)
+		if filter.Until != nil {
+			until = uint32(max(0, min(math.MaxUint32, *filter.Until)))
+		}
+		if since > until {
+			return
+		}
+
+		var ids, authors map[[32]byte]struct{}
+		if len(filter.IDs) > 0 {
+			ids = decodeSet(filter.IDs)
+		}
+		if len(filter.Authors) > 0 {
+			authors = decodeSet(filter.Authors)
+		}
+		var kinds map[uint32]struct{}
+		if len(filter.Kinds) > 0 {
+			kinds = make(map[uint32]struct{}, len(filter.Kinds))
+			for _, kind := range filter.Kinds {
+				kinds[uint32(kind)] = struct{}{}
+			}
+		}
+
+		// start from whatever gives the fewest entries
+		var trees []*entryTree
+		switch {
+		case ids != nil:
+			found := newEntryTree()
+			for id := range ids {
+				if e, ok := b.byID[id]; ok {
+					found.ReplaceOrInsert(e)
+				}
+			}
+			trees = append(trees, found)
+		case authors != nil || kinds != nil:
+			var byAuthor, byKind []*entryTree
+			var nAuthor, nKind int
+			for author := range authors {
+				if tree, ok := b.byAuthor[author]; ok {
+					byAuthor = append(byAuthor, tree)
+					nAuthor += tree.Len()
+				}
+			}
+			for kind := range kinds {
+				if tree, ok := b.byKind[kind]; ok {
+					byKind = append(byKind, tree)
+					nKind += tree.Len()
+				}
+			}
+			if authors != nil && (kinds == nil || nAuthor <= nKind) {
+				trees = byAuthor
+			} else {
+				trees = byKind
+			}
+		default:
+			trees = append(trees, b.all)
+		}
+
+		// only the part of each tree within since and until is looked at, an id of all 0xff sorts
+		// after every other at until
+		last := &entry{createdAt: until}
+		for i := range last.id {
+			last.id[i] = 0xff
+		}
+		descend := func(tree *entryTree, fn func(*entry) bool) {
+			tree.DescendLessOrEqual(last, func(e *entry) bool {
+				return e.createdAt >= since && fn(e)
+			})
+		}
+
+		matches := func(e *entry) bool {
+			if ids != nil {
+				if _, ok := ids[e.id]; !ok {
+					return false
+				}
+			}
+			if authors != nil {
+				if _, ok := authors[e.pubkey]; !ok {
+					return false
+				}
+			}
+			if kinds != nil {
+				if _, ok := kinds[e.kind]; !ok {
+					return false
+				}
+			}
+			return true
+		}
+
+		if len(trees) == 1 {
+			descend(trees[0], func(e *entry) bool {
+				return !matches(e) || yield(e)
+			})
+			return
+		}
+
+		var merged []*entry
+		for _, tree := range trees {
+			descend(tree, func(e *entry) bool {
+				merged = append(merged, e)
+				return true
+			})
+		}
+		slices.SortFunc(merged, compareEntries)
+		for i := len(merged) - 1; i >= 0; i-- {
+			if matches(merged[i]) && !yield(merged[i]) {
+				return
+			}
+		}
+	}
+}
+
+// decodeSet decodes hex ids or pubkeys, the invalid ones are left out since they can't match.
+func decodeSet(values []string) map[[32]byte]struct{} {
+	set := make(map[[32]byte]struct{}, len(values))
+	for _, v := range values {
+		var b [32]byte
+		if decodeHex32(v, &b) == nil {
+			set[b] = struct{}{}
+		}
+	}
+	return set
+}
@@ -0,0 +1,17 @@
+package jsonlstore
+
+import (
+	"github.com/fiatjaf/eventstore"
+)
+
+func init() {
+	eventstore.Register(eventstore.Driver{
+		Name: "jsonl",
+		// no detection, since opening a file this way writes to it, a plain path to a .jsonl file
+		// has to be given with the scheme or the type
+		Schemes: []string{"jsonl"},
+		New: func(uri string, opts eventstore.OpenOptions) (eventstore.Store, error) {
+			return &JSONLStore{Path: eventstore.TrimScheme(uri, "jsonl"), MaxLimit: opts.MaxLimit}, nil
+		},
+	})
+}
@@ -0,0 +1,50 @@
+package jsonlstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/fiatjaf/eventstore/internal"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func (b *JSONLStore) ReplaceEvent(ctx context.Context, evt *nostr.Event) error {
+	if err := b.inflight.Acquire(); err != nil {
+		return err
+	}
+	defer b.inflight.Release()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	filter := nostr.Filter{Kinds: []int{evt.Kind}, Authors: []string{evt.PubKey}}
+	if nostr.IsAddressableKind(evt.Kind) {
+		filter.Tags = nostr.TagMap{"d": []string{evt.Tags.GetD()}}
+	}
+
+	previous, err := b.query(filter, -1)
+	if err != nil {
+		return fmt.Errorf("failed to query before replacing: %w", err)
+	}
+
+	shouldStore := true
+	for _, prev := range previous {
+		if internal.IsOlder(prev, evt) {
+			if err := b.delete(prev); err != nil {
+				return fmt.Errorf("failed to delete event for replacing: %w", err)
+			}
+		} else {
+			shouldStore = false
+		}
+	}
+
+	if shouldStore {
+		if err := b.save(evt); err != nil && err != eventstore.ErrDupEvent {
+			return fmt.Errorf("failed to save: %w", err)
+		}
+	}
+
+	b.maybeCompact()
+	return nil
+}
@@ -0,0 +1,69 @@
+package jsonlstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/mailru/easyjson"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func (b *JSONLStore) SaveEvent(ctx context.Context, evt *nostr.Event) error {
+	if err := b.inflight.Acquire(); err != nil {
+		return err
+	}
+	defer b.inflight.Release()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.save(evt)
+}
+
+// save must be called with the write lock held.
+func (b *JSONLStore) save(evt *nostr.Event) error {
+	r := record{typ: recordEvent, entry: entry{
+		createdAt: uint32(evt.CreatedAt),
+		kind:      uint32(evt.Kind),
+		offset:    b.size,
+	}}
+	if err := decodeHex32(evt.ID, &r.id); err != nil {
+		return fmt.Errorf("invalid id: %w", err)
+	}
+	if err := decodeHex32(evt.PubKey, &r.pubkey); err != nil {
+		return fmt.Errorf("invalid pubkey: %w", err)
+	}
+	if _, ok := b.byID[r.id]; ok {
+		return eventstore.ErrDupEvent
+	}
+
+	line, err := easyjson.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	r.length = uint32(len(line))
+	return b.appendLine(line, r)
+}
+
+// appendLine writes line to the end of the log and r to the index, r must be the record of line.
+func (b *JSONLStore) appendLine(line []byte, r record) error {
+	if _, err := b.log.WriteAt(append(line, '\n'), b.size); err != nil {
+		b.log.Truncate(b.size)
+		return fmt.Errorf("%w: failed to write to '%s': %w", eventstore.ErrStorageUnavailable, b.Path, err)
+	}
+	if b.SyncWrites {
+		if err := b.log.Sync(); err != nil {
+			b.log.Truncate(b.size)
+			return fmt.Errorf("%w: failed to sync '%s': %w", eventstore.ErrStorageUnavailable, b.Path, err)
+		}
+	}
+	b.size = r.end()
+
+	// if this fails the line will be indexed again on Init
+	if err := b.writeRecord(r); err != nil {
+		return fmt.Errorf("%w: %w", eventstore.ErrStorageUnavailable, err)
+	}
+	b.apply(r)
+	return nil
+}
@@ -0,0 +1,28 @@
+package jsonlstore
+
+import (
+	"context"
+
+	"github.com/fiatjaf/eventstore"
+)
+
+// Stats has the log and its index as the indexes, the log entries include tombstones and events
+// that were deleted but not compacted away yet.
+func (b *JSONLStore) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	stats := eventstore.StoreStats{
+		Events:        int64(len(b.byID)),
+		EventsPerKind: make(map[int]int64, len(b.byKind)),
+		DiskSize:      b.size + b.idxSize,
+		Indexes: map[string]eventstore.IndexStats{
+			"log":   {Entries: (b.idxSize - int64(len(indexMagic))) / recordSize, Size: b.size},
+			"index": {Entries: (b.idxSize - int64(len(indexMagic))) / recordSize, Size: b.idxSize},
+		},
+	}
+	for kind, entries := range b.byKind {
+		stats.EventsPerKind[int(kind)] = int64(entries.Len())
+	}
+	return stats, nil
+}
@@ -0,0 +1,197 @@
+package lmdb
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+const (
+	compactionDir = "compact.tmp"
+
+	// after this many attempts at copying without any writes happening in the meantime
+	// we just pause the writes during the whole copy
+	maxCompactionAttempts = 3
+)
+
+// view wraps a read transaction so the environment can't be resized, swapped or closed while it runs.
+func (b *LMDBBackend) view(fn lmdb.TxnOp) error {
+	if err := b.inflight.Acquire(); err != nil {
+		return err
+	}
+	defer b.inflight.Release()
+
+	b.envLock.RLock()
+	defer b.envLock.RUnlock()
+	return b.lmdbEnv.View(fn)
+}
+
+// update wraps a write transaction so it can be paused by a compaction, and retries it
+// after growing the map whenever it fails because the map is full.
+func (b *LMDBBackend) update(fn lmdb.TxnOp) error {
+	if err := b.inflight.Acquire(); err != nil {
+		return err
+	}
+	defer b.inflight.Release()
+
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	for {
+		b.envLock.RLock()
+		err := b.lmdbEnv.Update(fn)
+		b.envLock.RUnlock()
+
+		if !lmdb.IsMapFull(err) {
+			return err
+		}
+
+		b.envLock.Lock()
+		err = b.resizeMap()
+		b.envLock.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// updateUnlocked is like update, but for when we know nothing else is using the environment
+// (while initializing it).
+func (b *LMDBBackend) updateUnlocked(fn lmdb.TxnOp) error {
+	for {
+		err := b.lmdbEnv.Update(fn)
+		if !lmdb.IsMapFull(err) {
+			return err
+		}
+		if err := b.resizeMap(); err != nil {
+			return err
+		}
+	}
+}
+
+// resizeMap doubles the map size, up to MaxMapSize. There must be no transactions running.
+func (b *LMDBBackend) resizeMap() error {
+	info, err := b.lmdbEnv.Info()
+	if err != nil {
+		return fmt.Errorf("failed to get environment info: %w", err)
+	}
+
+	newSize := info.MapSize * 2
+	if b.MaxMapSize != 0 && newSize > b.MaxMapSize {
+		if info.MapSize >= b.MaxMapSize {
+			return fmt.Errorf("map is full and already at the maximum size of %d bytes", b.MaxMapSize)
+		}
+		newSize = b.MaxMapSize
+	}
+
+	log.Printf("[lmdb] map is full, growing it from %d to %d bytes\n", info.MapSize, newSize)
+	if err := b.lmdbEnv.SetMapSize(newSize); err != nil {
+		return fmt.Errorf("failed to grow map to %d: %w", newSize, err)
+	}
+
+	// so we reopen it with this size next time
+	b.MapSize = newSize
+	return nil
+}
+
+// CompactOnline writes a compacted copy of the database while it keeps serving reads and writes,
+// then pauses everything briefly to swap the files. If the process dies at any point the database
+// is left either as it was or fully compacted, and leftovers are removed on the next Init.
+func (b *LMDBBackend) CompactOnline() error {
+	if err := b.inflight.Acquire(); err != nil {
+		return err
+	}
+	defer b.inflight.Release()
+
+	tmpdir := filepath.Join(b.Path, compactionDir)
+	defer os.RemoveAll(tmpdir)
+
+	for attempt := 1; ; attempt++ {
+		if err := os.RemoveAll(tmpdir); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(tmpdir, 0755); err != nil {
+			return err
+		}
+
+		pauseDuringCopy := attempt >= maxCompactionAttempts
+		if pauseDuringCopy {
+			b.writeLock.Lock()
+		}
+
+		b.envLock.RLock()
+		info, err := b.lmdbEnv.Info()
+		if err == nil {
+			err = b.lmdbEnv.CopyFlag(tmpdir, lmdb.CopyCompact)
+		}
+		b.envLock.RUnlock()
+		if err != nil {
+			if pauseDuringCopy {
+				b.writeLock.Unlock()
+			}
+			return fmt.Errorf("failed to copy: %w", err)
+		}
+
+		if !pauseDuringCopy {
+			b.writeLock.Lock()
+		}
+
+		// if something was written while we were copying the copy is already outdated, try again
+		after, err := b.lmdbEnv.Info()
+		if err != nil {
+			b.writeLock.Unlock()
+			return err
+		}
+		if after.LastTxnID != info.LastTxnID {
+			b.writeLock.Unlock()
+			continue
+		}
+
+		err = b.swapWithCompacted(tmpdir)
+		b.writeLock.Unlock()
+		return err
+	}
+}
+
+// swapWithCompacted must be called with writes paused.
+func (b *LMDBBackend) swapWithCompacted(tmpdir string) error {
+	compacted := filepath.Join(tmpdir, "data.mdb")
+
+	// make sure the copy is entirely on disk before it replaces anything
+	if err := syncPath(compacted); err != nil {
+		return fmt.Errorf("failed to sync compacted copy: %w", err)
+	}
+
+	b.envLock.Lock()
+	defer b.envLock.Unlock()
+
+	b.lmdbEnv.Close()
+
+	// this is the only step that changes the original database, and it is atomic
+	renameErr := os.Rename(compacted, filepath.Join(b.Path, "data.mdb"))
+	if renameErr == nil {
+		renameErr = syncPath(b.Path)
+	}
+
+	// reopen either way, if the rename failed we're still on the original
+	if err := b.initialize(); err != nil {
+		return fmt.Errorf("failed to reopen after compaction: %w", err)
+	}
+	if renameErr != nil {
+		return fmt.Errorf("failed to swap compacted copy: %w", renameErr)
+	}
+
+	return nil
+}
+
+func syncPath(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
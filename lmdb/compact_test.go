@@ -0,0 +1,146 @@
+package lmdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapGrowth(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/lmdbtest-growth"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db := &LMDBBackend{Path: path, MapSize: 1 << 20}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	sk := nostr.GeneratePrivateKey()
+	for i := 0; i < 2000; i++ {
+		evt := &nostr.Event{CreatedAt: nostr.Timestamp(i + 1), Kind: 1, Tags: nostr.Tags{{"t", fmt.Sprint(i)}}, Content: fmt.Sprintf("%0500d", i)}
+		evt.Sign(sk)
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+	require.Greater(t, db.MapSize, int64(1<<20))
+
+	count, err := db.CountEvents(ctx, nostr.Filter{Kinds: []int{1}})
+	require.NoError(t, err)
+	require.Equal(t, int64(2000), count)
+}
+
+func TestMapGrowthLimit(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/lmdbtest-growth-limit"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db := &LMDBBackend{Path: path, MapSize: 1 << 20, MaxMapSize: 1 << 21}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	sk := nostr.GeneratePrivateKey()
+	var err error
+	for i := 0; i < 10000 && err == nil; i++ {
+		evt := &nostr.Event{CreatedAt: nostr.Timestamp(i + 1), Kind: 1, Tags: nostr.Tags{}, Content: fmt.Sprintf("%0500d", i)}
+		evt.Sign(sk)
+		err = db.SaveEvent(ctx, evt)
+	}
+	require.ErrorContains(t, err, "maximum size")
+
+	// still usable for reading
+	_, err = db.CountEvents(ctx, nostr.Filter{Kinds: []int{1}})
+	require.NoError(t, err)
+}
+
+func TestCompactOnline(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/lmdbtest-compact"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db := &LMDBBackend{Path: path}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	sk := nostr.GeneratePrivateKey()
+	makeEvent := func(i int) *nostr.Event {
+		evt := &nostr.Event{CreatedAt: nostr.Timestamp(i + 1), Kind: 1, Tags: nostr.Tags{}, Content: fmt.Sprintf("%0300d", i)}
+		evt.Sign(sk)
+		return evt
+	}
+
+	// create some garbage to be compacted away
+	for i := 0; i < 1000; i++ {
+		evt := makeEvent(i)
+		require.NoError(t, db.SaveEvent(ctx, evt))
+		if i%2 == 0 {
+			require.NoError(t, db.DeleteEvent(ctx, evt))
+		}
+	}
+
+	// keep reading and writing while the compaction happens
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 1000; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			require.NoError(t, db.SaveEvent(ctx, makeEvent(i)))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			_, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Limit: 10})
+			require.NoError(t, err)
+		}
+	}()
+
+	require.NoError(t, db.CompactOnline())
+	close(stop)
+	wg.Wait()
+
+	_, err := os.Stat(filepath.Join(path, compactionDir))
+	require.True(t, os.IsNotExist(err))
+
+	// everything that was saved is still there, and we can keep writing
+	until := nostr.Timestamp(1000)
+	count, err := db.CountEvents(ctx, nostr.Filter{Until: &until})
+	require.NoError(t, err)
+	require.Equal(t, int64(500), count)
+	require.NoError(t, db.SaveEvent(ctx, makeEvent(999999)))
+}
+
+func TestInterruptedCompactionIsCleanedUp(t *testing.T) {
+	path := "/tmp/lmdbtest-compact-leftover"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(path, compactionDir), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(path, compactionDir, "data.mdb"), []byte("partial"), 0644))
+
+	db := &LMDBBackend{Path: path}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	_, err := os.Stat(filepath.Join(path, compactionDir))
+	require.True(t, os.IsNotExist(err))
+}
@@ -5,9 +5,10 @@ import (
 	"context"
 	"encoding/binary"
 	"encoding/hex"
+	"fmt"
 
 	"github.com/PowerDNS/lmdb-go/lmdb"
-	bin "github.com/fiatjaf/eventstore/internal/binary"
+	"github.com/fiatjaf/eventstore"
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip45"
 	"github.com/nbd-wtf/go-nostr/nip45/hyperloglog"
@@ -15,6 +16,17 @@ import (
 )
 
 func (b *LMDBBackend) CountEvents(ctx context.Context, filter nostr.Filter) (int64, error) {
+	var count int64
+	err := b.view(func(txn *lmdb.Txn) error {
+		var err error
+		count, err = b.count(txn, filter)
+		return err
+	})
+	return count, err
+}
+
+// count is like CountEvents, but inside a transaction we already have.
+func (b *LMDBBackend) count(txn *lmdb.Txn, filter nostr.Filter) (int64, error) {
 	var count int64 = 0
 
 	queries, extraAuthors, extraKinds, extraTagKey, extraTagValues, since, err := b.prepareQueries(filter)
@@ -22,82 +34,69 @@ func (b *LMDBBackend) CountEvents(ctx context.Context, filter nostr.Filter) (int
 		return 0, err
 	}
 
-	err = b.lmdbEnv.View(func(txn *lmdb.Txn) error {
-		// actually iterate
-		for _, q := range queries {
-			cursor, err := txn.OpenCursor(q.dbi)
-			if err != nil {
-				continue
-			}
+	// actually iterate
+	for _, q := range queries {
+		cursor, err := txn.OpenCursor(q.dbi)
+		if err != nil {
+			return 0, fmt.Errorf("%w: failed to open cursor: %w", eventstore.ErrStorageUnavailable, err)
+		}
+		defer cursor.Close()
 
-			it := &iterator{cursor: cursor}
-			it.seek(q.startingPoint)
+		it := &iterator{cursor: cursor}
+		it.seek(q.startingPoint)
 
-			for {
-				// we already have a k and a v and an err from the cursor setup, so check and use these
-				if it.err != nil ||
-					len(it.key) != q.keySize ||
-					!bytes.HasPrefix(it.key, q.prefix) {
-					// either iteration has errored or we reached the end of this prefix
-					break // stop this cursor and move to the next one
-				}
+		for {
+			// we already have a k and a v and an err from the cursor setup, so check and use these
+			if err := it.failure(); err != nil {
+				return 0, err
+			}
+			if it.err != nil ||
+				len(it.key) != q.keySize ||
+				!bytes.HasPrefix(it.key, q.prefix) {
+				// either iteration has errored or we reached the end of this prefix
+				break // stop this cursor and move to the next one
+			}
 
-				// "id" indexes don't contain a timestamp
-				if q.timestampSize == 4 {
-					createdAt := binary.BigEndian.Uint32(it.key[len(it.key)-4:])
-					if createdAt < since {
-						break
-					}
+			// "id" indexes don't contain a timestamp
+			if q.timestampSize == 4 {
+				createdAt := binary.BigEndian.Uint32(it.key[len(it.key)-4:])
+				if createdAt < since {
+					break
 				}
+			}
 
-				if extraAuthors == nil && extraKinds == nil && extraTagValues == nil {
-					count++
-				} else {
-					// fetch actual event
-					val, err := txn.Get(b.rawEventStore, it.valIdx)
-					if err != nil {
-						panic(err)
-					}
-
-					// check it against pubkeys without decoding the entire thing
-					if !slices.Contains(extraAuthors, [32]byte(val[32:64])) {
-						it.next()
-						continue
-					}
-
-					// check it against kinds without decoding the entire thing
-					if !slices.Contains(extraKinds, [2]byte(val[132:134])) {
-						it.next()
-						continue
-					}
-
-					evt := &nostr.Event{}
-					if err := bin.Unmarshal(val, evt); err != nil {
-						it.next()
-						continue
-					}
+			if extraAuthors == nil && extraKinds == nil && extraTagValues == nil && q.fullId == nil {
+				count++
+				it.next()
+				continue
+			}
 
-					// if there is still a tag to be checked, do it now
-					if !evt.Tags.ContainsAny(extraTagKey, extraTagValues) {
-						it.next()
-						continue
-					}
+			// fetch actual event
+			v, err := b.getRaw(txn, it.valIdx)
+			if err != nil {
+				return 0, err
+			}
 
-					count++
-				}
+			// check everything against the raw event without decoding it
+			if (q.fullId == nil || bytes.Equal(v.ID(), q.fullId)) &&
+				(extraAuthors == nil || slices.Contains(extraAuthors, [32]byte(v.PubKey()))) &&
+				(extraKinds == nil || slices.Contains(extraKinds, v.Kind())) &&
+				(extraTagValues == nil || v.ContainsAnyTag(extraTagKey, extraTagValues)) {
+				count++
 			}
+			it.next()
 		}
+	}
 
-		return nil
-	})
-
-	return count, err
+	return count, nil
 }
 
 // CountEventsHLL is like CountEvents, but it will build a hyperloglog value while iterating through results, following NIP-45
 func (b *LMDBBackend) CountEventsHLL(ctx context.Context, filter nostr.Filter, offset int) (int64, *hyperloglog.HyperLogLog, error) {
-	if useCache, _ := b.EnableHLLCacheFor(filter.Kinds[0]); useCache {
-		return b.countEventsHLLCached(filter)
+	if b.EnableHLLCacheFor != nil {
+		if useCache, _ := b.EnableHLLCacheFor(filter.Kinds[0]); useCache {
+			return b.countEventsHLLCached(filter)
+		}
 	}
 
 	var count int64 = 0
@@ -110,19 +109,23 @@ func (b *LMDBBackend) CountEventsHLL(ctx context.Context, filter nostr.Filter, o
 
 	hll := hyperloglog.New(offset)
 
-	err = b.lmdbEnv.View(func(txn *lmdb.Txn) error {
+	err = b.view(func(txn *lmdb.Txn) error {
 		// actually iterate
 		for _, q := range queries {
 			cursor, err := txn.OpenCursor(q.dbi)
 			if err != nil {
-				continue
+				return fmt.Errorf("%w: failed to open cursor: %w", eventstore.ErrStorageUnavailable, err)
 			}
+			defer cursor.Close()
 
 			it := &iterator{cursor: cursor}
 			it.seek(q.startingPoint)
 
 			for {
 				// we already have a k and a v and an err from the cursor setup, so check and use these
+				if err := it.failure(); err != nil {
+					return err
+				}
 				if it.err != nil ||
 					len(it.key) != q.keySize ||
 					!bytes.HasPrefix(it.key, q.prefix) {
@@ -139,37 +142,18 @@ func (b *LMDBBackend) CountEventsHLL(ctx context.Context, filter nostr.Filter, o
 				}
 
 				// fetch actual event (we need it regardless because we need the pubkey for the hll)
-				val, err := txn.Get(b.rawEventStore, it.valIdx)
+				v, err := b.getRaw(txn, it.valIdx)
 				if err != nil {
-					panic(err)
+					return err
 				}
 
-				if extraKinds == nil && extraTagValues == nil {
-					// nothing extra to check
-					count++
-					hll.AddBytes(val[32:64])
-				} else {
-					// check it against kinds without decoding the entire thing
-					if !slices.Contains(extraKinds, [2]byte(val[132:134])) {
-						it.next()
-						continue
-					}
-
-					evt := &nostr.Event{}
-					if err := bin.Unmarshal(val, evt); err != nil {
-						it.next()
-						continue
-					}
-
-					// if there is still a tag to be checked, do it now
-					if !evt.Tags.ContainsAny(extraTagKey, extraTagValues) {
-						it.next()
-						continue
-					}
-
+				// check everything against the raw event without decoding it
+				if (extraKinds == nil || slices.Contains(extraKinds, v.Kind())) &&
+					(extraTagValues == nil || v.ContainsAnyTag(extraTagKey, extraTagValues)) {
 					count++
-					hll.Add(evt.PubKey)
+					hll.AddBytes(v.PubKey())
 				}
+				it.next()
 			}
 		}
 
@@ -195,7 +179,7 @@ func (b *LMDBBackend) countEventsHLLCached(filter nostr.Filter) (int64, *hyperlo
 	var count int64
 	var hll *hyperloglog.HyperLogLog
 
-	err := b.lmdbEnv.View(func(txn *lmdb.Txn) error {
+	err := b.view(func(txn *lmdb.Txn) error {
 		val, err := txn.Get(b.hllCache, cacheKey)
 		if err != nil {
 			if lmdb.IsNotFound(err) {
@@ -10,21 +10,21 @@ import (
 )
 
 func (b *LMDBBackend) DeleteEvent(ctx context.Context, evt *nostr.Event) error {
-	return b.lmdbEnv.Update(func(txn *lmdb.Txn) error {
+	return b.update(func(txn *lmdb.Txn) error {
 		return b.delete(txn, evt)
 	})
 }
 
 func (b *LMDBBackend) delete(txn *lmdb.Txn, evt *nostr.Event) error {
-	idPrefix8, _ := hex.DecodeString(evt.ID[0 : 8*2])
-	idx, err := txn.Get(b.indexId, idPrefix8)
-	if lmdb.IsNotFound(err) {
-		// we already do not have this
-		return nil
-	}
+	id, _ := hex.DecodeString(evt.ID)
+	idx, err := b.getIdxForId(txn, id)
 	if err != nil {
 		return fmt.Errorf("failed to get current idx for deleting %x: %w", evt.ID[0:8*2], err)
 	}
+	if idx == nil {
+		// we already do not have this
+		return nil
+	}
 
 	// calculate all index keys we have for this event and delete them
 	for k := range b.getIndexKeysForEvent(evt) {
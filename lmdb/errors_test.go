@@ -0,0 +1,72 @@
+package lmdb
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorruptedEventIsAnError(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/lmdbtest-corrupted"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db := &LMDBBackend{Path: path}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	events := make([]*nostr.Event, 3)
+	for i := range events {
+		evt := &nostr.Event{CreatedAt: nostr.Timestamp(1000 + i), Kind: 1, Tags: nostr.Tags{{"t", "x"}}}
+		evt.Sign(sk)
+		require.NoError(t, db.SaveEvent(ctx, evt))
+		events[i] = evt
+	}
+
+	id, _ := hex.DecodeString(events[1].ID)
+	var idx []byte
+	require.NoError(t, db.update(func(txn *lmdb.Txn) error {
+		var err error
+		idx, err = db.getIdxForId(txn, id)
+		require.NotNil(t, idx)
+		if err != nil {
+			return err
+		}
+		return txn.Put(db.rawEventStore, idx, []byte{2, 0xde, 0xad}, 0)
+	}))
+
+	filters := []nostr.Filter{
+		{Kinds: []int{1}},
+		{Authors: []string{pk}, Kinds: []int{1}, Tags: nostr.TagMap{"t": []string{"x"}}},
+	}
+	for _, filter := range filters {
+		_, err := db.QueryEvents(ctx, filter)
+		require.ErrorIs(t, err, eventstore.ErrCorrupted)
+	}
+
+	// counting only has to read the events when the indexes are not enough
+	_, err := db.CountEvents(ctx, filters[1])
+	require.ErrorIs(t, err, eventstore.ErrCorrupted)
+
+	// an index entry pointing to nothing is also corruption
+	require.NoError(t, db.update(func(txn *lmdb.Txn) error {
+		return txn.Del(db.rawEventStore, idx, nil)
+	}))
+	_, err = db.QueryEvents(ctx, nostr.Filter{Kinds: []int{1}})
+	require.ErrorIs(t, err, eventstore.ErrCorrupted)
+
+	// everything else keeps working
+	res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{IDs: []string{events[2].ID}})
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, events[2].ID, res[0].ID)
+}
@@ -0,0 +1,79 @@
+package lmdb
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"iter"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+	"github.com/fiatjaf/eventstore"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+var _ eventstore.Exporter = (*LMDBBackend)(nil)
+
+// ExportEvents goes through the created_at index oldest first, from a single read transaction,
+// so what it yields is a point-in-time snapshot. The map can't be resized while it runs, so
+// writes that need a bigger map wait until the iteration is done.
+func (b *LMDBBackend) ExportEvents(ctx context.Context, filter nostr.Filter) iter.Seq2[*nostr.Event, error] {
+	return func(yield func(*nostr.Event, error) bool) {
+		if filter.Search != "" {
+			return
+		}
+
+		var count int
+		err := b.view(func(txn *lmdb.Txn) error {
+			cursor, err := txn.OpenCursor(b.indexCreatedAt)
+			if err != nil {
+				return fmt.Errorf("%w: failed to open cursor: %w", eventstore.ErrStorageUnavailable, err)
+			}
+			defer cursor.Close()
+
+			start := make([]byte, 4)
+			if filter.Since != nil {
+				binary.BigEndian.PutUint32(start, uint32(*filter.Since))
+			}
+
+			k, idx, err := cursor.Get(start, nil, lmdb.SetRange)
+			for ; err == nil; k, idx, err = cursor.Get(nil, nil, lmdb.Next) {
+				if filter.Until != nil && nostr.Timestamp(binary.BigEndian.Uint32(k)) > *filter.Until {
+					return nil
+				}
+
+				val, err := txn.Get(b.rawEventStore, idx)
+				if err != nil {
+					return fmt.Errorf("%w: failed to get event %x from the created_at index: %w", eventstore.ErrCorrupted, idx, err)
+				}
+				evt := &nostr.Event{}
+				if err := bin.Decode(val, evt); err != nil {
+					return fmt.Errorf("%w: event %x can't be decoded: %w", eventstore.ErrCorrupted, idx, err)
+				}
+				if !filter.Matches(evt) {
+					continue
+				}
+
+				if !yield(evt, nil) {
+					return nil
+				}
+				count++
+				if count == filter.Limit {
+					return nil
+				}
+				if count%1000 == 0 {
+					if err := ctx.Err(); err != nil {
+						return err
+					}
+				}
+			}
+			if !lmdb.IsNotFound(err) {
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
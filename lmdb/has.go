@@ -0,0 +1,41 @@
+package lmdb
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func (b *LMDBBackend) HasEvents(ctx context.Context, ids []string) ([]bool, error) {
+	results := make([]bool, len(ids))
+
+	err := b.view(func(txn *lmdb.Txn) error {
+		txn.RawRead = true
+
+		for i, idHex := range ids {
+			if len(idHex) != 64 {
+				continue
+			}
+			id, err := hex.DecodeString(idHex)
+			if err != nil {
+				continue
+			}
+
+			if b.bloom != nil && !b.bloom.MayContain(id) {
+				continue
+			}
+
+			idx, err := b.getIdxForId(txn, id)
+			if err != nil {
+				return fmt.Errorf("failed to get idx for %x: %w", id[0:8], err)
+			}
+			results[i] = idx != nil
+		}
+
+		return nil
+	})
+
+	return results, err
+}
@@ -1,6 +1,7 @@
 package lmdb
 
 import (
+	"bytes"
 	"crypto/md5"
 	"encoding/binary"
 	"encoding/hex"
@@ -10,6 +11,8 @@ import (
 	"strings"
 
 	"github.com/PowerDNS/lmdb-go/lmdb"
+	"github.com/fiatjaf/eventstore"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
 	"github.com/nbd-wtf/go-nostr"
 	"golang.org/x/exp/slices"
 )
@@ -24,9 +27,9 @@ type iterator struct {
 
 func (it *iterator) seek(key []byte) {
 	if _, _, errsr := it.cursor.Get(key, nil, lmdb.SetRange); errsr != nil {
-		if operr, ok := errsr.(*lmdb.OpError); !ok || operr.Errno != lmdb.NotFound {
+		if !lmdb.IsNotFound(errsr) {
 			// in this case it's really an error
-			panic(operr)
+			it.err = errsr
 		} else {
 			// we're at the end and we just want notes before this,
 			// so we just need to set the cursor the last key, this is not a real error
@@ -43,6 +46,60 @@ func (it *iterator) next() {
 	it.key, it.valIdx, it.err = it.cursor.Get(nil, nil, lmdb.Prev)
 }
 
+// failure returns the error that stopped the iteration, if it wasn't just the end of the database.
+func (it *iterator) failure() error {
+	if it.err == nil || lmdb.IsNotFound(it.err) {
+		return nil
+	}
+	return fmt.Errorf("%w: cursor failed: %w", eventstore.ErrStorageUnavailable, it.err)
+}
+
+// getRaw fetches the raw event an index entry points to.
+func (b *LMDBBackend) getRaw(txn *lmdb.Txn, idx []byte) (bin.View, error) {
+	val, err := txn.Get(b.rawEventStore, idx)
+	if err != nil {
+		if lmdb.IsNotFound(err) {
+			return bin.View{}, fmt.Errorf("%w: index points to missing event %x", eventstore.ErrCorrupted, idx)
+		}
+		return bin.View{}, fmt.Errorf("%w: failed to get event %x: %w", eventstore.ErrStorageUnavailable, idx, err)
+	}
+
+	v, err := bin.NewView(val)
+	if err != nil {
+		return bin.View{}, fmt.Errorf("%w: event %x can't be read: %w", eventstore.ErrCorrupted, idx, err)
+	}
+
+	return v, nil
+}
+
+// getIdxForId finds the idx of the event with exactly this id, or nil if we don't have it.
+// the id index only has the first 8 bytes of each id, so more than one event may be stored
+// under the same key and we have to check the full id on the raw events.
+func (b *LMDBBackend) getIdxForId(txn *lmdb.Txn, id []byte) ([]byte, error) {
+	cursor, err := txn.OpenCursor(b.indexId)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close()
+
+	_, idx, err := cursor.Get(id[0:8], nil, lmdb.SetKey)
+	for err == nil {
+		val, getErr := txn.Get(b.rawEventStore, idx)
+		if getErr == nil && len(val) >= bin.PubKeyOffset && bytes.Equal(val[bin.IDOffset:bin.PubKeyOffset], id) {
+			return idx, nil
+		} else if getErr != nil && !lmdb.IsNotFound(getErr) {
+			return nil, getErr
+		}
+
+		_, idx, err = cursor.Get(nil, nil, lmdb.NextDup)
+	}
+	if !lmdb.IsNotFound(err) {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
 type key struct {
 	dbi lmdb.DBI
 	key []byte
@@ -75,21 +132,21 @@ func (b *LMDBBackend) getIndexKeysForEvent(evt *nostr.Event) iter.Seq[key] {
 
 		{
 			// ~ by kind+date
-			k := make([]byte, 2+4)
-			binary.BigEndian.PutUint16(k[0:2], uint16(evt.Kind))
-			binary.BigEndian.PutUint32(k[2:2+4], uint32(evt.CreatedAt))
-			if !yield(key{dbi: b.indexKind, key: k[0 : 2+4]}) {
+			k := make([]byte, 4+4)
+			binary.BigEndian.PutUint32(k[0:4], uint32(evt.Kind))
+			binary.BigEndian.PutUint32(k[4:4+4], uint32(evt.CreatedAt))
+			if !yield(key{dbi: b.indexKind, key: k[0 : 4+4]}) {
 				return
 			}
 		}
 
 		{
 			// ~ by pubkey+kind+date
-			k := make([]byte, 8+2+4)
+			k := make([]byte, 8+4+4)
 			hex.Decode(k[0:8], []byte(evt.PubKey[0:8*2]))
-			binary.BigEndian.PutUint16(k[8:8+2], uint16(evt.Kind))
-			binary.BigEndian.PutUint32(k[8+2:8+2+4], uint32(evt.CreatedAt))
-			if !yield(key{dbi: b.indexPubkeyKind, key: k[0 : 8+2+4]}) {
+			binary.BigEndian.PutUint32(k[8:8+4], uint32(evt.Kind))
+			binary.BigEndian.PutUint32(k[8+4:8+4+4], uint32(evt.CreatedAt))
+			if !yield(key{dbi: b.indexPubkeyKind, key: k[0 : 8+4+4]}) {
 				return
 			}
 		}
@@ -118,12 +175,12 @@ func (b *LMDBBackend) getIndexKeysForEvent(evt *nostr.Event) iter.Seq[key] {
 
 			// now the p-tag+kind+date
 			if dbi == b.indexTag32 && tag[0] == "p" {
-				k := make([]byte, 8+2+4)
+				k := make([]byte, 8+4+4)
 				hex.Decode(k[0:8], []byte(tag[1][0:8*2]))
-				binary.BigEndian.PutUint16(k[8:8+2], uint16(evt.Kind))
-				binary.BigEndian.PutUint32(k[8+2:8+2+4], uint32(evt.CreatedAt))
+				binary.BigEndian.PutUint32(k[8:8+4], uint32(evt.Kind))
+				binary.BigEndian.PutUint32(k[8+4:8+4+4], uint32(evt.CreatedAt))
 				dbi := b.indexPTagKind
-				if !yield(key{dbi: dbi, key: k[0 : 8+2+4]}) {
+				if !yield(key{dbi: dbi, key: k[0 : 8+4+4]}) {
 					return
 				}
 			}
@@ -1,13 +1,20 @@
 package lmdb
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
+	"log"
+	"math"
 	"os"
+	"path/filepath"
+	"sync"
 	"sync/atomic"
 
 	"github.com/PowerDNS/lmdb-go/lmdb"
 	"github.com/fiatjaf/eventstore"
+	"github.com/fiatjaf/eventstore/internal/bloom"
+	"github.com/fiatjaf/eventstore/internal/inflight"
 )
 
 var _ eventstore.Store = (*LMDBBackend)(nil)
@@ -18,9 +25,23 @@ type LMDBBackend struct {
 	MaxLimitNegentropy int
 	MapSize            int64
 
+	// MaxMapSize caps how much the map can grow when it gets full, 0 means no limit
+	MaxMapSize int64
+
+	// MigrationBatchSize is how many events each migration transaction goes through before it
+	// commits and saves a checkpoint, defaults to 10000.
+	MigrationBatchSize int
+
+	// OnMigrationProgress, if set, is called by Init after every batch of every migration.
+	OnMigrationProgress func(eventstore.MigrationProgress)
+
 	lmdbEnv    *lmdb.Env
 	extraFlags uint // (for debugging and testing)
 
+	envLock   sync.RWMutex // held for writing only when the environment is being resized or swapped
+	writeLock sync.Mutex   // held by every write transaction, so writes can be paused
+	inflight  inflight.Tracker
+
 	settingsStore   lmdb.DBI
 	rawEventStore   lmdb.DBI
 	indexCreatedAt  lmdb.DBI
@@ -36,7 +57,12 @@ type LMDBBackend struct {
 	hllCache          lmdb.DBI
 	EnableHLLCacheFor func(kind int) (useCache bool, skipSavingActualEvent bool)
 
-	lastId atomic.Uint32
+	// EnableBloomFilter keeps an in-memory bloom filter of all the ids we have so HasEvents
+	// can answer negatively without touching the database.
+	EnableBloomFilter bool
+	bloom             *bloom.Filter
+
+	lastId atomic.Uint64
 }
 
 func (b *LMDBBackend) Init() error {
@@ -54,23 +80,52 @@ func (b *LMDBBackend) Init() error {
 		return err
 	}
 
+	// a compaction may have been interrupted before the swap, in which case the original is still intact
+	if err := os.RemoveAll(filepath.Join(b.Path, compactionDir)); err != nil {
+		return fmt.Errorf("failed to remove leftovers from an interrupted compaction: %w", err)
+	}
+
 	return b.initialize()
 }
 
+// Close is CloseContext with a timeout of inflight.CloseTimeout.
 func (b *LMDBBackend) Close() {
-	b.lmdbEnv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), inflight.CloseTimeout)
+	defer cancel()
+	if err := b.CloseContext(ctx); err != nil {
+		log.Printf("[lmdb] failed to close: %s\n", err)
+	}
+}
+
+// CloseContext closes the environment once the running operations are done.
+// See [eventstore.GracefulCloser].
+func (b *LMDBBackend) CloseContext(ctx context.Context) error {
+	return b.inflight.Close(ctx, func() error {
+		b.envLock.Lock()
+		defer b.envLock.Unlock()
+		b.lmdbEnv.Close()
+		return nil
+	})
 }
 
-func (b *LMDBBackend) Serial() []byte {
-	v := b.lastId.Add(1)
-	vb := make([]byte, 4)
-	binary.BigEndian.PutUint32(vb[:], uint32(v))
-	return vb
+// Serial returns the key for the next raw event, it fails instead of wrapping around when
+// all the serials have been used.
+func (b *LMDBBackend) Serial() ([]byte, error) {
+	for {
+		last := b.lastId.Load()
+		if last == math.MaxUint64 {
+			return nil, eventstore.ErrSerialOverflow
+		}
+		if b.lastId.CompareAndSwap(last, last+1) {
+			return binary.BigEndian.AppendUint64(make([]byte, 0, 8), last+1), nil
+		}
+	}
 }
 
 // Compact can only be called when the database is not being used because it will overwrite everything.
 // It will temporarily move the database to a new location, then move it back.
 // If something goes wrong crash the process and look for the copy of the data on tmppath.
+// Use CompactOnline to compact a database while it is in use.
 func (b *LMDBBackend) Compact(tmppath string) error {
 	if err := os.MkdirAll(tmppath, 0755); err != nil {
 		return err
@@ -112,72 +167,32 @@ func (b *LMDBBackend) initialize() error {
 	}
 	b.lmdbEnv = env
 
-	var multiIndexCreationFlags uint = lmdb.Create | lmdb.DupSort | lmdb.DupFixed
-
 	// open each db
-	if err := b.lmdbEnv.Update(func(txn *lmdb.Txn) error {
-		if dbi, err := txn.OpenDBI("settings", lmdb.Create); err != nil {
-			return err
-		} else {
-			b.settingsStore = dbi
-		}
-		if dbi, err := txn.OpenDBI("raw", lmdb.Create); err != nil {
-			return err
-		} else {
-			b.rawEventStore = dbi
-		}
-		if dbi, err := txn.OpenDBI("created_at", multiIndexCreationFlags); err != nil {
-			return err
-		} else {
-			b.indexCreatedAt = dbi
-		}
-		if dbi, err := txn.OpenDBI("id", lmdb.Create); err != nil {
-			return err
-		} else {
-			b.indexId = dbi
-		}
-		if dbi, err := txn.OpenDBI("kind", multiIndexCreationFlags); err != nil {
-			return err
-		} else {
-			b.indexKind = dbi
-		}
-		if dbi, err := txn.OpenDBI("pubkey", multiIndexCreationFlags); err != nil {
-			return err
-		} else {
-			b.indexPubkey = dbi
-		}
-		if dbi, err := txn.OpenDBI("pubkeyKind", multiIndexCreationFlags); err != nil {
-			return err
-		} else {
-			b.indexPubkeyKind = dbi
-		}
-		if dbi, err := txn.OpenDBI("tag", multiIndexCreationFlags); err != nil {
-			return err
-		} else {
-			b.indexTag = dbi
-		}
-		if dbi, err := txn.OpenDBI("tag32", multiIndexCreationFlags); err != nil {
-			return err
-		} else {
-			b.indexTag32 = dbi
-		}
-		if dbi, err := txn.OpenDBI("tagaddr", multiIndexCreationFlags); err != nil {
-			return err
-		} else {
-			b.indexTagAddr = dbi
-		}
-		if dbi, err := txn.OpenDBI("ptagKind", multiIndexCreationFlags); err != nil {
-			return err
-		} else {
-			b.indexPTagKind = dbi
+	if err := b.updateUnlocked(b.openDatabases); err != nil {
+		return err
+	}
+
+	// databases created before migration 11 have the id index without dupsort (and lmdb keeps the flags
+	// a database was created with), so we drop it here and let the migration rebuild it from the raw events
+	var idFlags uint
+	if err := b.lmdbEnv.View(func(txn *lmdb.Txn) (err error) {
+		idFlags, err = txn.Flags(b.indexId)
+		return err
+	}); err != nil {
+		return err
+	}
+	if idFlags&lmdb.DupSort == 0 {
+		if err := b.lmdbEnv.Update(func(txn *lmdb.Txn) error {
+			return txn.Drop(b.indexId, true)
+		}); err != nil {
+			return fmt.Errorf("failed to drop old id index: %w", err)
 		}
-		if dbi, err := txn.OpenDBI("hllCache", lmdb.Create); err != nil {
+		if err := b.updateUnlocked(b.openDatabases); err != nil {
 			return err
-		} else {
-			b.hllCache = dbi
 		}
-		return nil
-	}); err != nil {
+	}
+
+	if err := b.runMigrations(); err != nil {
 		return err
 	}
 
@@ -197,12 +212,106 @@ func (b *LMDBBackend) initialize() error {
 		if err != nil {
 			return err
 		}
-		b.lastId.Store(binary.BigEndian.Uint32(k))
+		b.lastId.Store(binary.BigEndian.Uint64(k))
 
 		return nil
 	}); err != nil {
 		return err
 	}
 
-	return b.runMigrations()
+	if b.EnableBloomFilter {
+		return b.loadBloomFilter()
+	}
+	return nil
+}
+
+func (b *LMDBBackend) openDatabases(txn *lmdb.Txn) error {
+	var multiIndexCreationFlags uint = lmdb.Create | lmdb.DupSort | lmdb.DupFixed
+
+	if dbi, err := txn.OpenDBI("settings", lmdb.Create); err != nil {
+		return err
+	} else {
+		b.settingsStore = dbi
+	}
+	if dbi, err := txn.OpenDBI("raw", lmdb.Create); err != nil {
+		return err
+	} else {
+		b.rawEventStore = dbi
+	}
+	if dbi, err := txn.OpenDBI("created_at", multiIndexCreationFlags); err != nil {
+		return err
+	} else {
+		b.indexCreatedAt = dbi
+	}
+	if dbi, err := txn.OpenDBI("id", multiIndexCreationFlags); err != nil {
+		return err
+	} else {
+		b.indexId = dbi
+	}
+	if dbi, err := txn.OpenDBI("kind", multiIndexCreationFlags); err != nil {
+		return err
+	} else {
+		b.indexKind = dbi
+	}
+	if dbi, err := txn.OpenDBI("pubkey", multiIndexCreationFlags); err != nil {
+		return err
+	} else {
+		b.indexPubkey = dbi
+	}
+	if dbi, err := txn.OpenDBI("pubkeyKind", multiIndexCreationFlags); err != nil {
+		return err
+	} else {
+		b.indexPubkeyKind = dbi
+	}
+	if dbi, err := txn.OpenDBI("tag", multiIndexCreationFlags); err != nil {
+		return err
+	} else {
+		b.indexTag = dbi
+	}
+	if dbi, err := txn.OpenDBI("tag32", multiIndexCreationFlags); err != nil {
+		return err
+	} else {
+		b.indexTag32 = dbi
+	}
+	if dbi, err := txn.OpenDBI("tagaddr", multiIndexCreationFlags); err != nil {
+		return err
+	} else {
+		b.indexTagAddr = dbi
+	}
+	if dbi, err := txn.OpenDBI("ptagKind", multiIndexCreationFlags); err != nil {
+		return err
+	} else {
+		b.indexPTagKind = dbi
+	}
+	if dbi, err := txn.OpenDBI("hllCache", lmdb.Create); err != nil {
+		return err
+	} else {
+		b.hllCache = dbi
+	}
+	return nil
+}
+
+func (b *LMDBBackend) loadBloomFilter() error {
+	return b.lmdbEnv.View(func(txn *lmdb.Txn) error {
+		txn.RawRead = true
+
+		stat, err := txn.Stat(b.indexId)
+		if err != nil {
+			return err
+		}
+		// leave some room for growth, past that the filter grows by itself
+		b.bloom = bloom.New(int(stat.Entries)*2, 0.01)
+
+		cursor, err := txn.OpenCursor(b.indexId)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close()
+
+		for k, _, err := cursor.Get(nil, nil, lmdb.First); err == nil; k, _, err = cursor.Get(nil, nil, lmdb.Next) {
+			b.bloom.Add(k)
+		}
+
+		return nil
+	})
 }
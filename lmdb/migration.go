@@ -1,101 +1,345 @@
 package lmdb
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"log"
+	"slices"
 
 	"github.com/PowerDNS/lmdb-go/lmdb"
+	"github.com/fiatjaf/eventstore"
 	bin "github.com/fiatjaf/eventstore/internal/binary"
 	"github.com/nbd-wtf/go-nostr"
 )
 
 const (
-	DB_VERSION byte = 'v'
+	DB_VERSION              byte = 'v'
+	DB_MIGRATION_CHECKPOINT byte = 'm'
 )
 
+type migration struct {
+	version     uint16
+	description string
+	steps       []migrationStep
+}
+
+// migrationStep either has a run function, called once in a transaction of its own, or a forEach
+// function, called for every raw event in transactions of MigrationBatchSize events. run must be
+// safe to call again, as it will be if the process stops before the step is marked as done.
+type migrationStep struct {
+	run     func(txn *lmdb.Txn) error
+	forEach func(txn *lmdb.Txn, idx []byte, val []byte) error
+
+	// reindex is set on the steps made by reindexSteps, and reindexAll when they rebuild every index
+	reindex, reindexAll bool
+}
+
+// reindexesAll tells if the migration rebuilds every index from the raw events.
+func (m migration) reindexesAll() bool {
+	return slices.ContainsFunc(m.steps, func(step migrationStep) bool { return step.reindexAll })
+}
+
+// onlyReindexes tells if all the migration does is rebuilding some indexes.
+func (m migration) onlyReindexes() bool {
+	return !slices.ContainsFunc(m.steps, func(step migrationStep) bool { return !step.reindex })
+}
+
+// checkpoint is saved along with each batch of a migration, so it can resume from there.
+type checkpoint struct {
+	version uint16
+	step    uint8
+	done    uint64
+	last    []byte
+}
+
+func (b *LMDBBackend) migrations() []migration {
+	return []migration{
+		// all previous migrations are useless because we will just reindex everything
+		{9, "reindex everything", b.reindexSteps(bin.Unmarshal)},
+		{10, "rewrite raw events using the v2 binary encoding", []migrationStep{
+			{forEach: func(txn *lmdb.Txn, idx []byte, val []byte) error {
+				evt := &nostr.Event{}
+				if err := bin.Unmarshal(val, evt); err != nil {
+					return fmt.Errorf("error decoding event: %w", err)
+				}
+				encoded, err := bin.Encode(evt)
+				if err != nil {
+					return fmt.Errorf("error encoding event %s: %w", evt.ID, err)
+				}
+				return txn.Put(b.rawEventStore, idx, encoded, 0)
+			}},
+		}},
+		{11, "rebuild id index allowing multiple events with the same id prefix", []migrationStep{
+			// older databases had this without dupsort, in that case it was already dropped and
+			// recreated empty when opening it, otherwise we just clear it
+			{run: func(txn *lmdb.Txn) error { return txn.Drop(b.indexId, false) }},
+			{forEach: func(txn *lmdb.Txn, idx []byte, val []byte) error {
+				if len(val) < bin.PubKeyOffset {
+					return fmt.Errorf("event is too short")
+				}
+				return txn.Put(b.indexId, val[bin.IDOffset:bin.IDOffset+8], idx, 0)
+			}},
+		}},
+		{12, "use 8-byte serials as raw event keys", append([]migrationStep{
+			// the new keys all sort before the old ones (serials start at 1), so we never see them
+			// again as we go forward
+			{forEach: func(txn *lmdb.Txn, idx []byte, val []byte) error {
+				if len(idx) == 8 {
+					return nil
+				}
+				newIdx := make([]byte, 8)
+				copy(newIdx[8-len(idx):], idx)
+				if err := txn.Put(b.rawEventStore, newIdx, val, lmdb.NoOverwrite); err != nil {
+					return fmt.Errorf("failed to move event to %x: %w", newIdx, err)
+				}
+				return txn.Del(b.rawEventStore, idx, nil)
+			}},
+		}, b.reindexSteps(bin.Decode)...)}, // all the indexes point to the old keys
+		{13, "use 4 bytes for kinds in indexes", b.reindexSteps(bin.Decode, b.indexKind, b.indexPubkeyKind, b.indexPTagKind)},
+	}
+}
+
+// runMigrations brings the database to the latest version, resuming an interrupted migration
+// from its last checkpoint.
 func (b *LMDBBackend) runMigrations() error {
-	return b.lmdbEnv.Update(func(txn *lmdb.Txn) error {
-		var version uint16
+	if b.MigrationBatchSize == 0 {
+		b.MigrationBatchSize = 10000
+	}
+
+	var version uint16
+	var cp checkpoint
+	if err := b.lmdbEnv.View(func(txn *lmdb.Txn) error {
 		v, err := txn.Get(b.settingsStore, []byte{DB_VERSION})
-		if err != nil {
-			if lmdb.IsNotFound(err) {
-				version = 0
-			} else if v == nil {
-				return fmt.Errorf("failed to read database version: %w", err)
-			}
-		} else {
+		if err == nil {
 			version = binary.BigEndian.Uint16(v)
+		} else if !lmdb.IsNotFound(err) {
+			return fmt.Errorf("failed to read database version: %w", err)
 		}
 
-		// all previous migrations are useless because we will just reindex everything
-		if version < 9 {
-			log.Println("[lmdb] migration 9: reindex everything")
-
-			if err := txn.Drop(b.indexId, false); err != nil {
-				return err
-			}
-			if err := txn.Drop(b.indexCreatedAt, false); err != nil {
-				return err
-			}
-			if err := txn.Drop(b.indexKind, false); err != nil {
-				return err
-			}
-			if err := txn.Drop(b.indexPTagKind, false); err != nil {
-				return err
+		v, err = txn.Get(b.settingsStore, []byte{DB_MIGRATION_CHECKPOINT})
+		if err == nil {
+			if len(v) < 2+1+8 {
+				return fmt.Errorf("%w: migration checkpoint is too short", eventstore.ErrCorrupted)
 			}
-			if err := txn.Drop(b.indexPubkey, false); err != nil {
-				return err
-			}
-			if err := txn.Drop(b.indexPubkeyKind, false); err != nil {
-				return err
-			}
-			if err := txn.Drop(b.indexTag, false); err != nil {
+			cp.version = binary.BigEndian.Uint16(v[0:2])
+			cp.step = v[2]
+			cp.done = binary.BigEndian.Uint64(v[3:11])
+			cp.last = v[11:]
+		} else if !lmdb.IsNotFound(err) {
+			return fmt.Errorf("failed to read migration checkpoint: %w", err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// a full reindex writes the indexes the way the current code does, so after one of those the
+	// migrations that only rebuild some indexes have nothing left to do
+	var reindexed bool
+
+	// do the migrations in increasing steps (there is no rollback)
+	for _, m := range b.migrations() {
+		if m.version <= version {
+			continue
+		}
+
+		if reindexed && m.onlyReindexes() {
+			log.Printf("[lmdb] migration %d: %s (already done by the reindex above)\n", m.version, m.description)
+			if err := b.updateUnlocked(func(txn *lmdb.Txn) error {
+				return b.setVersion(txn, m.version)
+			}); err != nil {
 				return err
 			}
-			if err := txn.Drop(b.indexTag32, false); err != nil {
-				return err
+			continue
+		}
+
+		start := checkpoint{version: m.version}
+		if cp.version == m.version {
+			start = cp
+			log.Printf("[lmdb] migration %d: %s (resuming from step %d, after %d events)\n",
+				m.version, m.description, cp.step+1, cp.done)
+		} else {
+			log.Printf("[lmdb] migration %d: %s\n", m.version, m.description)
+		}
+
+		for s := int(start.step); s < len(m.steps); s++ {
+			step := m.steps[s]
+			if step.forEach != nil {
+				if err := b.migrateEvents(m, s, start); err != nil {
+					return fmt.Errorf("migration %d failed: %w", m.version, err)
+				}
+			} else {
+				if err := b.updateUnlocked(func(txn *lmdb.Txn) error {
+					if err := step.run(txn); err != nil {
+						return err
+					}
+					return b.setCheckpoint(txn, checkpoint{version: m.version, step: uint8(s + 1)})
+				}); err != nil {
+					return fmt.Errorf("migration %d failed: %w", m.version, err)
+				}
+				b.reportMigrationProgress(m, s, 0, 0)
 			}
-			if err := txn.Drop(b.indexTagAddr, false); err != nil {
+			start = checkpoint{version: m.version}
+		}
+		reindexed = reindexed || m.reindexesAll()
+
+		// bump version
+		if err := b.updateUnlocked(func(txn *lmdb.Txn) error {
+			if err := txn.Del(b.settingsStore, []byte{DB_MIGRATION_CHECKPOINT}, nil); err != nil && !lmdb.IsNotFound(err) {
 				return err
 			}
+			return b.setVersion(txn, m.version)
+		}); err != nil {
+			return err
+		}
+	}
 
+	return nil
+}
+
+// migrateEvents calls the forEach function of a step for every raw event after the checkpoint,
+// committing and saving a new checkpoint every MigrationBatchSize events.
+func (b *LMDBBackend) migrateEvents(m migration, s int, from checkpoint) error {
+	var total int64
+	if err := b.lmdbEnv.View(func(txn *lmdb.Txn) error {
+		stat, err := txn.Stat(b.rawEventStore)
+		if err != nil {
+			return err
+		}
+		total = int64(stat.Entries)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	next := from
+	for {
+		finished := false
+		if err := b.updateUnlocked(func(txn *lmdb.Txn) error {
 			cursor, err := txn.OpenCursor(b.rawEventStore)
 			if err != nil {
-				return fmt.Errorf("failed to open cursor in migration 9: %w", err)
+				return err
 			}
 			defer cursor.Close()
 
-			idx, val, err := cursor.Get(nil, nil, lmdb.First)
-			for err == nil {
-				evt := &nostr.Event{}
-				if err := bin.Unmarshal(val, evt); err != nil {
-					return fmt.Errorf("error decoding event %x on migration 5: %w", idx, err)
+			finished = false
+			cp := next
+			var idx, val []byte
+			if cp.last == nil {
+				idx, val, err = cursor.Get(nil, nil, lmdb.First)
+			} else {
+				idx, val, err = cursor.Get(cp.last, nil, lmdb.SetRange)
+				if err == nil && bytes.Equal(idx, cp.last) {
+					idx, val, err = cursor.Get(nil, nil, lmdb.Next)
 				}
+			}
 
-				for key := range b.getIndexKeysForEvent(evt) {
-					if err := txn.Put(key.dbi, key.key, idx, 0); err != nil {
-						return fmt.Errorf("failed to save index %s for event %s (%v) on migration 9: %w",
-							b.keyName(key), evt.ID, idx, err)
-					}
+			for n := 0; err == nil && n < b.MigrationBatchSize; n++ {
+				if err := m.steps[s].forEach(txn, idx, val); err != nil {
+					return fmt.Errorf("event %x: %w", idx, err)
 				}
+				cp.done++
+				cp.last = idx
 
-				// next
 				idx, val, err = cursor.Get(nil, nil, lmdb.Next)
 			}
-			if lmdbErr, ok := err.(*lmdb.OpError); ok && lmdbErr.Errno != lmdb.NotFound {
-				// exited the loop with an error different from NOTFOUND
-				return err
+			if lmdb.IsNotFound(err) {
+				// went through all the events
+				finished = true
+				err = b.setCheckpoint(txn, checkpoint{version: m.version, step: uint8(s + 1)})
+			} else if err == nil {
+				err = b.setCheckpoint(txn, cp)
 			}
-
-			// bump version
-			if err := b.setVersion(txn, 9); err != nil {
+			if err != nil {
 				return err
 			}
+
+			next = cp
+			return nil
+		}); err != nil {
+			return err
 		}
 
-		return nil
-	})
+		b.reportMigrationProgress(m, s, int64(next.done), max(total, int64(next.done)))
+		if finished {
+			return nil
+		}
+	}
+}
+
+func (b *LMDBBackend) reportMigrationProgress(m migration, s int, done int64, total int64) {
+	if b.OnMigrationProgress != nil {
+		b.OnMigrationProgress(eventstore.MigrationProgress{
+			Version:     int(m.version),
+			Description: m.description,
+			Step:        s + 1,
+			Steps:       len(m.steps),
+			Done:        done,
+			Total:       total,
+		})
+	}
+}
+
+func (b *LMDBBackend) setCheckpoint(txn *lmdb.Txn, cp checkpoint) error {
+	buf := make([]byte, 2+1+8, 2+1+8+len(cp.last))
+	binary.BigEndian.PutUint16(buf[0:2], cp.version)
+	buf[2] = cp.step
+	binary.BigEndian.PutUint64(buf[3:11], cp.done)
+	buf = append(buf, cp.last...)
+	return txn.Put(b.settingsStore, []byte{DB_MIGRATION_CHECKPOINT}, buf, 0)
+}
+
+// reindexSteps drops the given indexes (or all of them if none is given) and rebuilds them from the
+// raw events, which are read with decode as the encoding depends on the migration we're in.
+func (b *LMDBBackend) reindexSteps(decode func([]byte, *nostr.Event) error, dbis ...lmdb.DBI) []migrationStep {
+	all := len(dbis) == 0
+	if all {
+		dbis = b.indexDBIs()
+	}
+
+	return []migrationStep{
+		{reindex: true, reindexAll: all, run: func(txn *lmdb.Txn) error {
+			for _, dbi := range dbis {
+				if err := txn.Drop(dbi, false); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{reindex: true, reindexAll: all, forEach: func(txn *lmdb.Txn, idx []byte, val []byte) error {
+			evt := &nostr.Event{}
+			if err := decode(val, evt); err != nil {
+				return fmt.Errorf("error decoding event: %w", err)
+			}
+
+			for key := range b.getIndexKeysForEvent(evt) {
+				if !slices.Contains(dbis, key.dbi) {
+					continue
+				}
+				if err := txn.Put(key.dbi, key.key, idx, 0); err != nil {
+					return fmt.Errorf("failed to save index %s for event %s: %w", b.keyName(key), evt.ID, err)
+				}
+			}
+			return nil
+		}},
+	}
+}
+
+// indexDBIs lists the databases that are built from the raw events by getIndexKeysForEvent.
+func (b *LMDBBackend) indexDBIs() []lmdb.DBI {
+	return []lmdb.DBI{
+		b.indexId,
+		b.indexCreatedAt,
+		b.indexKind,
+		b.indexPTagKind,
+		b.indexPubkey,
+		b.indexPubkeyKind,
+		b.indexTag,
+		b.indexTag32,
+		b.indexTagAddr,
+	}
 }
 
 func (b *LMDBBackend) setVersion(txn *lmdb.Txn, version uint16) error {
@@ -0,0 +1,347 @@
+package lmdb
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+	"github.com/fiatjaf/eventstore"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationToV2Encoding(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/lmdbtest-migration"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db := &LMDBBackend{Path: path}
+	require.NoError(t, db.Init())
+
+	// write events the way older versions did
+	sk := nostr.GeneratePrivateKey()
+	events := make([]*nostr.Event, 10)
+	require.NoError(t, db.lmdbEnv.Update(func(txn *lmdb.Txn) error {
+		for i := range events {
+			evt := &nostr.Event{CreatedAt: nostr.Timestamp(1000 + i), Kind: 1, Tags: nostr.Tags{{"t", "old"}}, Content: "old"}
+			evt.Sign(sk)
+			events[i] = evt
+
+			val, err := bin.Marshal(evt)
+			require.NoError(t, err)
+			idx, err := db.Serial()
+			require.NoError(t, err)
+			require.NoError(t, txn.Put(db.rawEventStore, idx, val, 0))
+			for k := range db.getIndexKeysForEvent(evt) {
+				require.NoError(t, txn.Put(k.dbi, k.key, idx, 0))
+			}
+		}
+		return db.setVersion(txn, 9)
+	}))
+	db.Close()
+
+	db = &LMDBBackend{Path: path}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Tags: nostr.TagMap{"t": []string{"old"}}})
+	require.NoError(t, err)
+	require.Len(t, res, len(events))
+	for i, evt := range res {
+		require.Equal(t, events[len(events)-1-i].ID, evt.ID)
+		ok, _ := evt.CheckSignature()
+		require.True(t, ok)
+	}
+}
+
+func TestMigrationToDupSortIdIndex(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/lmdbtest-migration-id"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db := &LMDBBackend{Path: path}
+	require.NoError(t, db.Init())
+
+	sk := nostr.GeneratePrivateKey()
+	events := make([]*nostr.Event, 10)
+	for i := range events {
+		evt := &nostr.Event{CreatedAt: nostr.Timestamp(1000 + i), Kind: 1, Tags: nostr.Tags{}, Content: "old"}
+		evt.Sign(sk)
+		events[i] = evt
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+
+	// recreate the id index the way older versions had it, without dupsort
+	require.NoError(t, db.lmdbEnv.Update(func(txn *lmdb.Txn) error {
+		require.NoError(t, txn.Drop(db.indexId, true))
+		dbi, err := txn.OpenDBI("id", lmdb.Create)
+		require.NoError(t, err)
+		for i, evt := range events {
+			id, _ := hex.DecodeString(evt.ID)
+			idx := make([]byte, 4)
+			binary.BigEndian.PutUint32(idx, uint32(i+1))
+			require.NoError(t, txn.Put(dbi, id[0:8], idx, 0))
+		}
+		return db.setVersion(txn, 10)
+	}))
+	db.Close()
+
+	db = &LMDBBackend{Path: path}
+	require.NoError(t, db.Init())
+	defer db.Close()
+	require.NoError(t, db.lmdbEnv.View(func(txn *lmdb.Txn) error {
+		flags, err := txn.Flags(db.indexId)
+		require.NotZero(t, flags&lmdb.DupSort)
+		return err
+	}))
+
+	for _, evt := range events {
+		res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{IDs: []string{evt.ID}})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Equal(t, evt.ID, res[0].ID)
+	}
+
+	// and now events with the same id prefix can coexist
+	other := &nostr.Event{CreatedAt: 2000, Kind: 1, Tags: nostr.Tags{}, Content: "new"}
+	other.Sign(sk)
+	other.ID = events[0].ID[0:16] + other.ID[16:]
+	require.NoError(t, db.SaveEvent(ctx, other))
+	require.ErrorIs(t, db.SaveEvent(ctx, events[0]), eventstore.ErrDupEvent)
+}
+
+func TestMigrationTo8ByteSerials(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/lmdbtest-migration-serials"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db := &LMDBBackend{Path: path}
+	require.NoError(t, db.Init())
+
+	// write events with 4-byte serials like older versions did
+	sk := nostr.GeneratePrivateKey()
+	events := make([]*nostr.Event, 10)
+	require.NoError(t, db.lmdbEnv.Update(func(txn *lmdb.Txn) error {
+		for i := range events {
+			evt := &nostr.Event{CreatedAt: nostr.Timestamp(1000 + i), Kind: 1, Tags: nostr.Tags{{"t", "old"}}, Content: "old"}
+			evt.Sign(sk)
+			events[i] = evt
+
+			val, err := bin.Encode(evt)
+			require.NoError(t, err)
+			idx := binary.BigEndian.AppendUint32(nil, uint32(300+i))
+			require.NoError(t, txn.Put(db.rawEventStore, idx, val, 0))
+			for k := range db.getIndexKeysForEvent(evt) {
+				require.NoError(t, txn.Put(k.dbi, k.key, idx, 0))
+			}
+		}
+		return db.setVersion(txn, 11)
+	}))
+	db.Close()
+
+	// small batches so the events are moved over many transactions
+	var progress []eventstore.MigrationProgress
+	db = &LMDBBackend{
+		Path:                path,
+		MigrationBatchSize:  3,
+		OnMigrationProgress: func(p eventstore.MigrationProgress) { progress = append(progress, p) },
+	}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	// the kind indexes were rebuilt along with all the others, so migration 13 had nothing to do
+	require.NotEmpty(t, progress)
+	for _, p := range progress {
+		require.NotEqual(t, 13, p.Version)
+	}
+
+	res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Tags: nostr.TagMap{"t": []string{"old"}}})
+	require.NoError(t, err)
+	require.Len(t, res, len(events))
+	for i, evt := range res {
+		require.Equal(t, events[len(events)-1-i].ID, evt.ID)
+	}
+
+	// new events continue after the highest old serial
+	evt := &nostr.Event{CreatedAt: 2000, Kind: 1, Tags: nostr.Tags{}, Content: "new"}
+	evt.Sign(sk)
+	require.NoError(t, db.SaveEvent(ctx, evt))
+	require.NoError(t, db.DeleteEvent(ctx, events[0]))
+	require.NoError(t, db.lmdbEnv.View(func(txn *lmdb.Txn) error {
+		id, _ := hex.DecodeString(evt.ID)
+		idx, err := db.getIdxForId(txn, id)
+		require.Equal(t, binary.BigEndian.AppendUint64(nil, 310), idx)
+		return err
+	}))
+
+	res, err = eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Kinds: []int{1}})
+	require.NoError(t, err)
+	require.Len(t, res, len(events))
+	require.Equal(t, evt.ID, res[0].ID)
+}
+
+func TestSerialOverflow(t *testing.T) {
+	path := "/tmp/lmdbtest-serial-overflow"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db := &LMDBBackend{Path: path}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	db.lastId.Store(math.MaxUint64 - 1)
+
+	evt := &nostr.Event{CreatedAt: 1000, Kind: 1, Tags: nostr.Tags{}}
+	evt.Sign(nostr.GeneratePrivateKey())
+	require.NoError(t, db.SaveEvent(context.Background(), evt))
+
+	evt = &nostr.Event{CreatedAt: 1001, Kind: 1, Tags: nostr.Tags{}}
+	evt.Sign(nostr.GeneratePrivateKey())
+	require.ErrorIs(t, db.SaveEvent(context.Background(), evt), eventstore.ErrSerialOverflow)
+
+	// a stale counter never overwrites anything
+	db.lastId.Store(math.MaxUint64 - 1)
+	require.ErrorIs(t, db.SaveEvent(context.Background(), evt), eventstore.ErrCorrupted)
+}
+
+func TestMigrationTo4ByteKinds(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/lmdbtest-migration-kinds"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db := &LMDBBackend{Path: path}
+	require.NoError(t, db.Init())
+
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	events := make([]*nostr.Event, 10)
+	for i := range events {
+		evt := &nostr.Event{CreatedAt: nostr.Timestamp(1000 + i), Kind: 1 + i%2, Tags: nostr.Tags{}, Content: "old"}
+		evt.Sign(sk)
+		events[i] = evt
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+
+	// rewrite the kind indexes with 2-byte kinds like older versions did
+	require.NoError(t, db.lmdbEnv.Update(func(txn *lmdb.Txn) error {
+		require.NoError(t, txn.Drop(db.indexKind, false))
+		require.NoError(t, txn.Drop(db.indexPubkeyKind, false))
+		for _, evt := range events {
+			id, _ := hex.DecodeString(evt.ID)
+			idx, err := db.getIdxForId(txn, id)
+			require.NoError(t, err)
+
+			k := binary.BigEndian.AppendUint16(nil, uint16(evt.Kind))
+			k = binary.BigEndian.AppendUint32(k, uint32(evt.CreatedAt))
+			require.NoError(t, txn.Put(db.indexKind, k, idx, 0))
+
+			k, _ = hex.DecodeString(evt.PubKey[0 : 8*2])
+			k = binary.BigEndian.AppendUint16(k, uint16(evt.Kind))
+			k = binary.BigEndian.AppendUint32(k, uint32(evt.CreatedAt))
+			require.NoError(t, txn.Put(db.indexPubkeyKind, k, idx, 0))
+		}
+		return db.setVersion(txn, 12)
+	}))
+	db.Close()
+
+	db = &LMDBBackend{Path: path}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	for _, filter := range []nostr.Filter{{Kinds: []int{2}}, {Kinds: []int{2}, Authors: []string{pk}}} {
+		res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, filter)
+		require.NoError(t, err)
+		require.Len(t, res, len(events)/2)
+		for _, evt := range res {
+			require.Equal(t, 2, evt.Kind)
+		}
+	}
+}
+
+func TestMigrationResume(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/lmdbtest-migration-resume"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db := &LMDBBackend{Path: path}
+	require.NoError(t, db.Init())
+
+	sk := nostr.GeneratePrivateKey()
+	events := make([]*nostr.Event, 10)
+	for i := range events {
+		evt := &nostr.Event{CreatedAt: nostr.Timestamp(1000 + i), Kind: 1 + i%2, Tags: nostr.Tags{}, Content: "old"}
+		evt.Sign(sk)
+		events[i] = evt
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+
+	// pretend we stopped in the middle of migration 13, with only the first 4 events reindexed
+	require.NoError(t, db.lmdbEnv.Update(func(txn *lmdb.Txn) error {
+		require.NoError(t, txn.Drop(db.indexKind, false))
+		require.NoError(t, txn.Drop(db.indexPubkeyKind, false))
+		for _, evt := range events[0:4] {
+			id, _ := hex.DecodeString(evt.ID)
+			idx, err := db.getIdxForId(txn, id)
+			require.NoError(t, err)
+			for k := range db.getIndexKeysForEvent(evt) {
+				if k.dbi == db.indexKind || k.dbi == db.indexPubkeyKind {
+					require.NoError(t, txn.Put(k.dbi, k.key, idx, 0))
+				}
+			}
+		}
+		require.NoError(t, db.setVersion(txn, 12))
+		return db.setCheckpoint(txn, checkpoint{
+			version: 13,
+			step:    1,
+			done:    4,
+			last:    binary.BigEndian.AppendUint64(nil, 4),
+		})
+	}))
+	db.Close()
+
+	var progress []eventstore.MigrationProgress
+	db = &LMDBBackend{
+		Path:                path,
+		MigrationBatchSize:  4,
+		OnMigrationProgress: func(p eventstore.MigrationProgress) { progress = append(progress, p) },
+	}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	// the step that drops the indexes didn't run again, and we went on from the fifth event
+	require.Len(t, progress, 2)
+	for _, p := range progress {
+		require.Equal(t, 13, p.Version)
+		require.Equal(t, 2, p.Step)
+		require.Equal(t, 2, p.Steps)
+		require.Equal(t, int64(10), p.Total)
+	}
+	require.Equal(t, int64(8), progress[0].Done)
+	require.Equal(t, int64(10), progress[1].Done)
+
+	report, err := db.Verify(ctx, eventstore.VerifyOptions{})
+	require.NoError(t, err)
+	require.True(t, report.OK(), report.Problems)
+
+	require.NoError(t, db.lmdbEnv.View(func(txn *lmdb.Txn) error {
+		_, err := txn.Get(db.settingsStore, []byte{DB_MIGRATION_CHECKPOINT})
+		require.True(t, lmdb.IsNotFound(err))
+		v, err := txn.Get(db.settingsStore, []byte{DB_VERSION})
+		require.Equal(t, uint16(13), binary.BigEndian.Uint16(v))
+		return err
+	}))
+
+	res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Kinds: []int{2}})
+	require.NoError(t, err)
+	require.Len(t, res, len(events)/2)
+}
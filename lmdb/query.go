@@ -4,26 +4,86 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
-	"fmt"
+	"encoding/hex"
+	"iter"
 	"log"
 	"slices"
 
 	"github.com/PowerDNS/lmdb-go/lmdb"
 	"github.com/fiatjaf/eventstore"
 	"github.com/fiatjaf/eventstore/internal"
-	bin "github.com/fiatjaf/eventstore/internal/binary"
 	"github.com/nbd-wtf/go-nostr"
 )
 
 func (b *LMDBBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (chan *nostr.Event, error) {
 	ch := make(chan *nostr.Event)
 
-	if filter.Search != "" {
+	limit := b.getLimit(ctx, filter)
+	if limit == 0 {
 		close(ch)
 		return ch, nil
 	}
 
-	// max number of events we'll return
+	// collect everything first so we don't keep the transaction open while the results are consumed,
+	// this way we can also return any error directly
+	var results []internal.IterEvent
+	if err := b.view(func(txn *lmdb.Txn) error {
+		txn.RawRead = true
+		var err error
+		results, err = b.query(txn, filter, limit, false)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer close(ch)
+		for _, ie := range results {
+			select {
+			case ch <- ie.Event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// QueryIDs is like QueryEvents, but only the id and created_at of each event are read from the raw values.
+// Events are only fully decoded when there is a tag that must be checked and couldn't be used as an index.
+func (b *LMDBBackend) QueryIDs(ctx context.Context, filter nostr.Filter) iter.Seq2[eventstore.IDTimestamp, error] {
+	return func(yield func(eventstore.IDTimestamp, error) bool) {
+		limit := b.getLimit(ctx, filter)
+		if limit == 0 {
+			return
+		}
+
+		var results []internal.IterEvent
+		if err := b.view(func(txn *lmdb.Txn) error {
+			txn.RawRead = true
+			var err error
+			results, err = b.query(txn, filter, limit, true)
+			return err
+		}); err != nil {
+			yield(eventstore.IDTimestamp{}, err)
+			return
+		}
+
+		for _, ie := range results {
+			if !yield(eventstore.IDTimestamp{ID: ie.ID, CreatedAt: ie.CreatedAt}, nil) {
+				return
+			}
+		}
+	}
+}
+
+// getLimit returns the maximum number of events we'll return for this filter, 0 means nothing should be returned
+func (b *LMDBBackend) getLimit(ctx context.Context, filter nostr.Filter) int {
+	if filter.Search != "" {
+		return 0
+	}
+
 	maxLimit := b.MaxLimit
 	var limit int
 	if eventstore.IsNegentropySession(ctx) {
@@ -35,29 +95,16 @@ func (b *LMDBBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (cha
 	if filter.Limit > 0 && filter.Limit <= maxLimit {
 		limit = filter.Limit
 	}
-	if tlimit := nostr.GetTheoreticalLimit(filter); tlimit == 0 {
-		close(ch)
-		return ch, nil
-	} else if tlimit > 0 {
+	if tlimit := nostr.GetTheoreticalLimit(filter); tlimit >= 0 {
 		limit = tlimit
 	}
 
-	go b.lmdbEnv.View(func(txn *lmdb.Txn) error {
-		txn.RawRead = true
-		defer close(ch)
-		results, err := b.query(txn, filter, limit)
-
-		for _, ie := range results {
-			ch <- ie.Event
-		}
-
-		return err
-	})
-
-	return ch, nil
+	return limit
 }
 
-func (b *LMDBBackend) query(txn *lmdb.Txn, filter nostr.Filter, limit int) ([]internal.IterEvent, error) {
+// query runs the given filter inside txn. when idsOnly is true the returned events will only have
+// their ID and CreatedAt fields set (unless we had to decode them for some reason).
+func (b *LMDBBackend) query(txn *lmdb.Txn, filter nostr.Filter, limit int, idsOnly bool) ([]internal.IterEvent, error) {
 	queries, extraAuthors, extraKinds, extraTagKey, extraTagValues, since, err := b.prepareQueries(filter)
 	if err != nil {
 		return nil, err
@@ -130,6 +177,9 @@ func (b *LMDBBackend) query(txn *lmdb.Txn, filter nostr.Filter, limit int) ([]in
 
 			for {
 				// we already have a k and a v and an err from the cursor setup, so check and use these
+				if err := it.failure(); err != nil {
+					return nil, err
+				}
 				if it.err != nil ||
 					len(it.key) != query.keySize ||
 					!bytes.HasPrefix(it.key, query.prefix) {
@@ -150,42 +200,49 @@ func (b *LMDBBackend) query(txn *lmdb.Txn, filter nostr.Filter, limit int) ([]in
 				}
 
 				// fetch actual event
-				val, err := txn.Get(b.rawEventStore, it.valIdx)
+				v, err := b.getRaw(txn, it.valIdx)
 				if err != nil {
-					log.Printf(
-						"lmdb: failed to get %x based on prefix %x, index key %x from raw event store: %s\n",
-						it.valIdx, query.prefix, it.key, err)
-					return nil, fmt.Errorf("iteration error: %w", err)
+					log.Printf("lmdb: failed to read event (idx %x) on query prefix %x sp %x dbi %d: %s\n", it.valIdx,
+						query.prefix, query.startingPoint, query.dbi, err)
+					return nil, err
 				}
 
-				// check it against pubkeys without decoding the entire thing
-				if extraAuthors != nil && !slices.Contains(extraAuthors, [32]byte(val[32:64])) {
+				// check if this is really the event we want, not only one with the same id prefix
+				if query.fullId != nil && !bytes.Equal(v.ID(), query.fullId) {
 					it.next()
 					continue
 				}
 
-				// check it against kinds without decoding the entire thing
-				if extraKinds != nil && !slices.Contains(extraKinds, [2]byte(val[132:134])) {
+				// check it against pubkeys without decoding the entire thing
+				if extraAuthors != nil && !slices.Contains(extraAuthors, [32]byte(v.PubKey())) {
 					it.next()
 					continue
 				}
 
-				// decode the entire thing
-				event := &nostr.Event{}
-				if err := bin.Unmarshal(val, event); err != nil {
-					log.Printf("lmdb: value read error (id %x) on query prefix %x sp %x dbi %d: %s\n", val[0:32],
-						query.prefix, query.startingPoint, query.dbi, err)
-					return nil, fmt.Errorf("event read error: %w", err)
+				// check it against kinds without decoding the entire thing
+				if extraKinds != nil && !slices.Contains(extraKinds, v.Kind()) {
+					it.next()
+					continue
 				}
 
-				// fmt.Println("      event", hex.EncodeToString(val[0:4]), "kind", binary.BigEndian.Uint16(val[132:134]), "author", hex.EncodeToString(val[32:36]), "ts", nostr.Timestamp(binary.BigEndian.Uint32(val[128:132])), hex.EncodeToString(it.key), it.valIdx)
-
 				// if there is still a tag to be checked, do it now
-				if extraTagValues != nil && !event.Tags.ContainsAny(extraTagKey, extraTagValues) {
+				if extraTagValues != nil && !v.ContainsAnyTag(extraTagKey, extraTagValues) {
 					it.next()
 					continue
 				}
 
+				// only now that we know we want this event we decode it
+				event := &nostr.Event{}
+				if idsOnly {
+					// we don't need anything else, so just read the id and the timestamp
+					event.ID = hex.EncodeToString(v.ID())
+					event.CreatedAt = v.CreatedAt()
+				} else {
+					v.Decode(event)
+				}
+
+				// fmt.Println("      event", event.ID[0:8], "kind", event.Kind, "author", event.PubKey[0:8], "ts", event.CreatedAt, hex.EncodeToString(it.key), it.valIdx)
+
 				// this event is good to be used
 				evt := internal.IterEvent{Event: event, Q: q}
 				//
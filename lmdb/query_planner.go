@@ -18,12 +18,15 @@ type query struct {
 	keySize       int
 	timestampSize int
 	startingPoint []byte
+
+	// only on id queries, since the index only has the first 8 bytes of the id
+	fullId []byte
 }
 
 func (b *LMDBBackend) prepareQueries(filter nostr.Filter) (
 	queries []query,
 	extraAuthors [][32]byte,
-	extraKinds [][2]byte,
+	extraKinds []uint32,
 	extraTagKey string,
 	extraTagValues []string,
 	since uint32,
@@ -57,15 +60,21 @@ func (b *LMDBBackend) prepareQueries(filter nostr.Filter) (
 			if len(idHex) != 64 {
 				return nil, nil, nil, "", nil, 0, fmt.Errorf("invalid id '%s'", idHex)
 			}
-			prefix := make([]byte, 8)
-			if _, err := hex.Decode(prefix[0:8], []byte(idHex[0:8*2])); err != nil {
+			id := make([]byte, 32)
+			if _, err := hex.Decode(id, []byte(idHex)); err != nil {
 				return nil, nil, nil, "", nil, 0, fmt.Errorf("invalid id '%s'", idHex)
 			}
-			queries[i] = query{i: i, dbi: b.indexId, prefix: prefix[0:8], keySize: 8, timestampSize: 0}
+			queries[i] = query{i: i, dbi: b.indexId, prefix: id[0:8], keySize: 8, timestampSize: 0, fullId: id}
 		}
 		return queries, nil, nil, "", nil, 0, nil
 	}
 
+	if filter.Kinds != nil {
+		if filter.Kinds = internal.StorableKinds(filter.Kinds); len(filter.Kinds) == 0 {
+			return []query{}, nil, nil, "", nil, 0, nil
+		}
+	}
+
 	// this is where we'll end the iteration
 	if filter.Since != nil {
 		if fs := uint32(*filter.Since); fs > since {
@@ -94,12 +103,12 @@ func (b *LMDBBackend) prepareQueries(filter nostr.Filter) (
 					}
 
 					for _, kind := range filter.Kinds {
-						k := make([]byte, 8+2)
+						k := make([]byte, 8+4)
 						if _, err := hex.Decode(k[0:8], []byte(value[0:8*2])); err != nil {
 							return nil, nil, nil, "", nil, 0, fmt.Errorf("invalid 'p' tag '%s'", value)
 						}
-						binary.BigEndian.PutUint16(k[8:8+2], uint16(kind))
-						queries[i] = query{i: i, dbi: b.indexPTagKind, prefix: k[0 : 8+2], keySize: 8 + 2 + 4, timestampSize: 4}
+						binary.BigEndian.PutUint32(k[8:8+4], uint32(kind))
+						queries[i] = query{i: i, dbi: b.indexPTagKind, prefix: k[0 : 8+4], keySize: 8 + 4 + 4, timestampSize: 4}
 						i++
 					}
 				}
@@ -115,7 +124,7 @@ func (b *LMDBBackend) prepareQueries(filter nostr.Filter) (
 					if _, err := hex.Decode(k[0:8], []byte(value[0:8*2])); err != nil {
 						return nil, nil, nil, "", nil, 0, fmt.Errorf("invalid 'p' tag '%s'", value)
 					}
-					queries[i] = query{i: i, dbi: b.indexPTagKind, prefix: k[0:8], keySize: 8 + 2 + 4, timestampSize: 4}
+					queries[i] = query{i: i, dbi: b.indexPTagKind, prefix: k[0:8], keySize: 8 + 4 + 4, timestampSize: 4}
 				}
 			}
 		} else {
@@ -132,9 +141,9 @@ func (b *LMDBBackend) prepareQueries(filter nostr.Filter) (
 
 			// add an extra kind filter if available (only do this on plain tag index, not on ptag-kind index)
 			if filter.Kinds != nil {
-				extraKinds = make([][2]byte, len(filter.Kinds))
+				extraKinds = make([]uint32, len(filter.Kinds))
 				for i, kind := range filter.Kinds {
-					binary.BigEndian.PutUint16(extraKinds[i][0:2], uint16(kind))
+					extraKinds[i] = uint32(kind)
 				}
 			}
 		}
@@ -180,12 +189,12 @@ pubkeyMatching:
 					if len(pubkeyHex) != 64 {
 						return nil, nil, nil, "", nil, 0, fmt.Errorf("invalid author '%s'", pubkeyHex)
 					}
-					prefix := make([]byte, 8+2)
+					prefix := make([]byte, 8+4)
 					if _, err := hex.Decode(prefix[0:8], []byte(pubkeyHex[0:8*2])); err != nil {
 						return nil, nil, nil, "", nil, 0, fmt.Errorf("invalid author '%s'", pubkeyHex)
 					}
-					binary.BigEndian.PutUint16(prefix[8:8+2], uint16(kind))
-					queries[i] = query{i: i, dbi: b.indexPubkeyKind, prefix: prefix[0 : 8+2], keySize: 10 + 4, timestampSize: 4}
+					binary.BigEndian.PutUint32(prefix[8:8+4], uint32(kind))
+					queries[i] = query{i: i, dbi: b.indexPubkeyKind, prefix: prefix[0 : 8+4], keySize: 12 + 4, timestampSize: 4}
 					i++
 				}
 			}
@@ -200,9 +209,9 @@ pubkeyMatching:
 		// will use a kind index
 		queries = make([]query, len(filter.Kinds))
 		for i, kind := range filter.Kinds {
-			prefix := make([]byte, 2)
-			binary.BigEndian.PutUint16(prefix[0:2], uint16(kind))
-			queries[i] = query{i: i, dbi: b.indexKind, prefix: prefix[0:2], keySize: 2 + 4, timestampSize: 4}
+			prefix := make([]byte, 4)
+			binary.BigEndian.PutUint32(prefix[0:4], uint32(kind))
+			queries[i] = query{i: i, dbi: b.indexKind, prefix: prefix[0:4], keySize: 4 + 4, timestampSize: 4}
 		}
 
 		// potentially with an extra useless tag filtering
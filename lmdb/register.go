@@ -0,0 +1,22 @@
+package lmdb
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/fiatjaf/eventstore"
+)
+
+func init() {
+	eventstore.Register(eventstore.Driver{
+		Name:    "lmdb",
+		Schemes: []string{"lmdb"},
+		Detect: func(path string) bool {
+			_, err := os.Stat(filepath.Join(path, "data.mdb"))
+			return err == nil
+		},
+		New: func(uri string, opts eventstore.OpenOptions) (eventstore.Store, error) {
+			return &LMDBBackend{Path: eventstore.TrimScheme(uri, "lmdb"), MaxLimit: opts.MaxLimit}, nil
+		},
+	})
+}
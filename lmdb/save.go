@@ -14,48 +14,59 @@ import (
 
 func (b *LMDBBackend) SaveEvent(ctx context.Context, evt *nostr.Event) error {
 	// sanity checking
-	if evt.CreatedAt > math.MaxUint32 || evt.Kind > math.MaxUint16 {
+	if evt.CreatedAt > math.MaxUint32 || evt.Kind < 0 || evt.Kind > math.MaxUint32 {
 		return fmt.Errorf("event with values out of expected boundaries")
 	}
 
-	return b.lmdbEnv.Update(func(txn *lmdb.Txn) error {
-		if b.EnableHLLCacheFor != nil {
-			// modify hyperloglog caches relative to this
-			useCache, skipSaving := b.EnableHLLCacheFor(evt.Kind)
+	return b.update(func(txn *lmdb.Txn) error {
+		return b.saveEvent(txn, evt)
+	})
+}
 
-			if useCache {
-				err := b.updateHyperLogLogCachedValues(txn, evt)
-				if err != nil {
-					return fmt.Errorf("failed to update hll cache: %w", err)
-				}
-				if skipSaving {
-					return nil
-				}
+// saveEvent does everything SaveEvent does except the sanity checks, inside a transaction we already have.
+func (b *LMDBBackend) saveEvent(txn *lmdb.Txn, evt *nostr.Event) error {
+	if b.EnableHLLCacheFor != nil {
+		// modify hyperloglog caches relative to this
+		useCache, skipSaving := b.EnableHLLCacheFor(evt.Kind)
+
+		if useCache {
+			err := b.updateHyperLogLogCachedValues(txn, evt)
+			if err != nil {
+				return fmt.Errorf("failed to update hll cache: %w", err)
+			}
+			if skipSaving {
+				return nil
 			}
 		}
+	}
 
-		// check if we already have this id
-		id, _ := hex.DecodeString(evt.ID)
-		_, err := txn.Get(b.indexId, id[0:8])
-		if operr, ok := err.(*lmdb.OpError); ok && operr.Errno != lmdb.NotFound {
-			// we will only proceed if we get a NotFound
-			return eventstore.ErrDupEvent
-		}
+	// check if we already have this id
+	id, _ := hex.DecodeString(evt.ID)
+	if idx, err := b.getIdxForId(txn, id); err != nil {
+		return fmt.Errorf("failed to check for duplicates: %w", err)
+	} else if idx != nil {
+		return eventstore.ErrDupEvent
+	}
 
-		return b.save(txn, evt)
-	})
+	return b.save(txn, evt)
 }
 
 func (b *LMDBBackend) save(txn *lmdb.Txn, evt *nostr.Event) error {
 	// encode to binary form so we'll save it
-	bin, err := bin.Marshal(evt)
+	bin, err := bin.Encode(evt)
 	if err != nil {
 		return err
 	}
 
-	idx := b.Serial()
-	// raw event store
-	if err := txn.Put(b.rawEventStore, idx, bin, 0); err != nil {
+	idx, err := b.Serial()
+	if err != nil {
+		return err
+	}
+	// raw event store (refusing to overwrite anything, a serial can never be reused)
+	if err := txn.Put(b.rawEventStore, idx, bin, lmdb.NoOverwrite); err != nil {
+		if lmdb.IsErrno(err, lmdb.KeyExist) {
+			return fmt.Errorf("%w: serial %x is already taken", eventstore.ErrCorrupted, idx)
+		}
 		return err
 	}
 
@@ -67,5 +78,10 @@ func (b *LMDBBackend) save(txn *lmdb.Txn, evt *nostr.Event) error {
 		}
 	}
 
+	if b.bloom != nil {
+		id, _ := hex.DecodeString(evt.ID)
+		b.bloom.Add(id)
+	}
+
 	return nil
 }
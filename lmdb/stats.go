@@ -0,0 +1,98 @@
+package lmdb
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+	"github.com/fiatjaf/eventstore"
+)
+
+// Stats reports the entries and pages used by each database in the environment. Events per kind
+// are counted by walking the kind index, so this takes longer as the database grows.
+func (b *LMDBBackend) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	stats := eventstore.StoreStats{
+		EventsPerKind: make(map[int]int64),
+		Indexes:       make(map[string]eventstore.IndexStats),
+	}
+
+	var pageSize int64
+	err := b.view(func(txn *lmdb.Txn) error {
+		txn.RawRead = true
+
+		for _, dbi := range []lmdb.DBI{
+			b.settingsStore,
+			b.rawEventStore,
+			b.indexCreatedAt,
+			b.indexId,
+			b.indexKind,
+			b.indexPubkey,
+			b.indexPubkeyKind,
+			b.indexTag,
+			b.indexTag32,
+			b.indexTagAddr,
+			b.indexPTagKind,
+			b.hllCache,
+		} {
+			s, err := txn.Stat(dbi)
+			if err != nil {
+				return fmt.Errorf("%w: failed to get stats for %s: %w", eventstore.ErrStorageUnavailable, b.dbiName(dbi), err)
+			}
+			stats.Indexes[b.dbiName(dbi)] = eventstore.IndexStats{
+				Entries: int64(s.Entries),
+				Size:    int64((s.BranchPages + s.LeafPages + s.OverflowPages) * uint64(s.PSize)),
+			}
+			if dbi == b.rawEventStore {
+				stats.Events = int64(s.Entries)
+				pageSize = int64(s.PSize)
+			}
+		}
+
+		if v, err := txn.Get(b.settingsStore, []byte{DB_VERSION}); err == nil {
+			stats.SchemaVersion = int(binary.BigEndian.Uint16(v))
+		} else if !lmdb.IsNotFound(err) {
+			return fmt.Errorf("%w: failed to read database version: %w", eventstore.ErrStorageUnavailable, err)
+		}
+
+		// each event is in the kind index once, under its kind and created_at
+		cursor, err := txn.OpenCursor(b.indexKind)
+		if err != nil {
+			return fmt.Errorf("%w: failed to open cursor: %w", eventstore.ErrStorageUnavailable, err)
+		}
+		defer cursor.Close()
+
+		k, _, err := cursor.Get(nil, nil, lmdb.First)
+		for err == nil {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			n, cerr := cursor.Count()
+			if cerr != nil {
+				return fmt.Errorf("%w: failed to count kind index entries: %w", eventstore.ErrStorageUnavailable, cerr)
+			}
+			stats.EventsPerKind[int(binary.BigEndian.Uint32(k[0:4]))] += int64(n)
+
+			k, _, err = cursor.Get(nil, nil, lmdb.NextNoDup)
+		}
+		if !lmdb.IsNotFound(err) {
+			return fmt.Errorf("%w: failed to iterate kind index: %w", eventstore.ErrStorageUnavailable, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	// the data file is as big as the map, so count only the pages actually in use
+	b.envLock.RLock()
+	info, err := b.lmdbEnv.Info()
+	b.envLock.RUnlock()
+	if err != nil {
+		return stats, fmt.Errorf("%w: failed to get environment info: %w", eventstore.ErrStorageUnavailable, err)
+	}
+	stats.DiskSize = (info.LastPNO + 1) * pageSize
+
+	return stats, nil
+}
@@ -0,0 +1,182 @@
+package lmdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+	"github.com/fiatjaf/eventstore"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+var _ eventstore.Verifier = (*LMDBBackend)(nil)
+
+// Verify checks that every raw event can be decoded and has all the index entries it should,
+// then that every index entry points to an event that would have produced it.
+// With opts.Repair everything happens in a single write transaction, so writes wait until it's done.
+func (b *LMDBBackend) Verify(ctx context.Context, opts eventstore.VerifyOptions) (eventstore.VerifyReport, error) {
+	var report eventstore.VerifyReport
+
+	run := b.view
+	if opts.Repair {
+		run = b.update
+	}
+	err := run(func(txn *lmdb.Txn) error {
+		// start over if the transaction is retried after the map is resized
+		report = eventstore.VerifyReport{}
+
+		if err := b.verifyEvents(ctx, txn, opts, &report); err != nil {
+			return err
+		}
+		for _, dbi := range b.indexDBIs() {
+			if err := b.verifyIndex(ctx, txn, opts, dbi, &report); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	report.Repaired = opts.Repair
+	return report, nil
+}
+
+func (b *LMDBBackend) verifyEvents(ctx context.Context, txn *lmdb.Txn, opts eventstore.VerifyOptions, report *eventstore.VerifyReport) error {
+	cursor, err := txn.OpenCursor(b.rawEventStore)
+	if err != nil {
+		return fmt.Errorf("%w: failed to open cursor: %w", eventstore.ErrStorageUnavailable, err)
+	}
+	defer cursor.Close()
+
+	// one cursor for each index, to look for the exact key and idx pairs
+	indexCursors := make(map[lmdb.DBI]*lmdb.Cursor)
+	defer func() {
+		for _, c := range indexCursors {
+			c.Close()
+		}
+	}()
+
+	idx, val, err := cursor.Get(nil, nil, lmdb.First)
+	for ; err == nil; idx, val, err = cursor.Get(nil, nil, lmdb.Next) {
+		if report.Events%10000 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		report.Events++
+
+		evt := &nostr.Event{}
+		if err := bin.Decode(val, evt); err != nil {
+			report.Undecodable++
+			report.AddProblem("event %x can't be decoded: %s", idx, err)
+			if opts.Repair {
+				if err := cursor.Del(0); err != nil {
+					return fmt.Errorf("%w: failed to delete event %x: %w", eventstore.ErrStorageUnavailable, idx, err)
+				}
+			}
+			continue
+		}
+
+		if opts.CheckSignatures {
+			if !evt.CheckID() {
+				report.InvalidEvents++
+				report.AddProblem("event %x has id %s, which doesn't match its contents", idx, evt.ID)
+			} else if ok, _ := evt.CheckSignature(); !ok {
+				report.InvalidEvents++
+				report.AddProblem("event %s has an invalid signature", evt.ID)
+			}
+		}
+
+		for key := range b.getIndexKeysForEvent(evt) {
+			c, ok := indexCursors[key.dbi]
+			if !ok {
+				var cerr error
+				c, cerr = txn.OpenCursor(key.dbi)
+				if cerr != nil {
+					return fmt.Errorf("%w: failed to open cursor: %w", eventstore.ErrStorageUnavailable, cerr)
+				}
+				indexCursors[key.dbi] = c
+			}
+
+			if _, _, err := c.Get(key.key, idx, lmdb.GetBoth); err == nil {
+				continue
+			} else if !lmdb.IsNotFound(err) {
+				return fmt.Errorf("%w: failed to look for %s: %w", eventstore.ErrStorageUnavailable, b.keyName(key), err)
+			}
+
+			report.MissingIndexEntries++
+			report.AddProblem("event %s is missing index entry %s", evt.ID, b.keyName(key))
+			if opts.Repair {
+				if err := txn.Put(key.dbi, key.key, idx, 0); err != nil {
+					return fmt.Errorf("failed to save index %s for event %s: %w", b.keyName(key), evt.ID, err)
+				}
+			}
+		}
+	}
+	if !lmdb.IsNotFound(err) {
+		return fmt.Errorf("%w: failed to iterate events: %w", eventstore.ErrStorageUnavailable, err)
+	}
+
+	return nil
+}
+
+func (b *LMDBBackend) verifyIndex(ctx context.Context, txn *lmdb.Txn, opts eventstore.VerifyOptions, dbi lmdb.DBI, report *eventstore.VerifyReport) error {
+	cursor, err := txn.OpenCursor(dbi)
+	if err != nil {
+		return fmt.Errorf("%w: failed to open cursor: %w", eventstore.ErrStorageUnavailable, err)
+	}
+	defer cursor.Close()
+
+	i := 0
+	k, idx, err := cursor.Get(nil, nil, lmdb.First)
+	for ; err == nil; k, idx, err = cursor.Get(nil, nil, lmdb.Next) {
+		if i++; i%10000 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		problem := ""
+		if val, err := txn.Get(b.rawEventStore, idx); lmdb.IsNotFound(err) {
+			problem = "points to missing event"
+		} else if err != nil {
+			return fmt.Errorf("%w: failed to get event %x: %w", eventstore.ErrStorageUnavailable, idx, err)
+		} else {
+			evt := &nostr.Event{}
+			if err := bin.Decode(val, evt); err != nil {
+				problem = "points to an event that can't be decoded"
+			} else if !b.hasIndexKey(evt, dbi, k) {
+				problem = fmt.Sprintf("isn't one of the index entries of event %s", evt.ID)
+			}
+		}
+		if problem == "" {
+			continue
+		}
+
+		report.OrphanedIndexEntries++
+		report.AddProblem("%s with idx %x %s", b.keyName(key{dbi: dbi, key: k}), idx, problem)
+		if opts.Repair {
+			if err := cursor.Del(0); err != nil {
+				return fmt.Errorf("%w: failed to delete %s: %w", eventstore.ErrStorageUnavailable, b.keyName(key{dbi: dbi, key: k}), err)
+			}
+		}
+	}
+	if !lmdb.IsNotFound(err) {
+		return fmt.Errorf("%w: failed to iterate %s: %w", eventstore.ErrStorageUnavailable, b.dbiName(dbi), err)
+	}
+
+	return nil
+}
+
+func (b *LMDBBackend) hasIndexKey(evt *nostr.Event, dbi lmdb.DBI, k []byte) bool {
+	for key := range b.getIndexKeysForEvent(evt) {
+		if key.dbi == dbi && bytes.Equal(key.key, k) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package lmdb
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+	"github.com/fiatjaf/eventstore"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/lmdbtest-verify"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db := &LMDBBackend{Path: path}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	sk := nostr.GeneratePrivateKey()
+	events := make([]*nostr.Event, 10)
+	for i := range events {
+		evt := &nostr.Event{CreatedAt: nostr.Timestamp(1000 + i), Kind: 1, Tags: nostr.Tags{{"t", "verify"}}, Content: "hello"}
+		evt.Sign(sk)
+		events[i] = evt
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+
+	report, err := db.Verify(ctx, eventstore.VerifyOptions{CheckSignatures: true})
+	require.NoError(t, err)
+	require.True(t, report.OK(), report.Problems)
+	require.Equal(t, int64(10), report.Events)
+
+	// break things in all the ways we know of
+	require.NoError(t, db.lmdbEnv.Update(func(txn *lmdb.Txn) error {
+		// an event that lost its tag index entry
+		for k := range db.getIndexKeysForEvent(events[3]) {
+			if k.dbi == db.indexTag {
+				cursor, err := txn.OpenCursor(k.dbi)
+				require.NoError(t, err)
+				_, _, err = cursor.Get(k.key, nil, lmdb.SetKey)
+				require.NoError(t, err)
+				require.NoError(t, cursor.Del(0))
+				cursor.Close()
+			}
+		}
+
+		// an index entry pointing nowhere
+		missing := make([]byte, 8)
+		binary.BigEndian.PutUint64(missing, 999999)
+		require.NoError(t, txn.Put(db.indexCreatedAt, []byte{0, 0, 0, 1}, missing, 0))
+
+		// an event that can't be decoded, with an index entry
+		garbage := make([]byte, 8)
+		binary.BigEndian.PutUint64(garbage, 888888)
+		require.NoError(t, txn.Put(db.rawEventStore, garbage, []byte("garbage"), 0))
+		require.NoError(t, txn.Put(db.indexCreatedAt, []byte{0, 0, 0, 2}, garbage, 0))
+
+		// an event with a bad signature
+		forged := *events[5]
+		forged.Content = "forged"
+		val, err := bin.Encode(&forged)
+		require.NoError(t, err)
+		forgedIdx := make([]byte, 8)
+		binary.BigEndian.PutUint64(forgedIdx, 777777)
+		require.NoError(t, txn.Put(db.rawEventStore, forgedIdx, val, 0))
+		for k := range db.getIndexKeysForEvent(&forged) {
+			require.NoError(t, txn.Put(k.dbi, k.key, forgedIdx, 0))
+		}
+
+		return nil
+	}))
+
+	report, err = db.Verify(ctx, eventstore.VerifyOptions{CheckSignatures: true})
+	require.NoError(t, err)
+	require.False(t, report.OK())
+	require.False(t, report.Repaired)
+	require.Equal(t, int64(12), report.Events)
+	require.Equal(t, int64(1), report.Undecodable)
+	require.Equal(t, int64(1), report.InvalidEvents)
+	require.Equal(t, int64(1), report.MissingIndexEntries)
+	require.Equal(t, int64(2), report.OrphanedIndexEntries)
+	require.Len(t, report.Problems, 5)
+
+	report, err = db.Verify(ctx, eventstore.VerifyOptions{Repair: true})
+	require.NoError(t, err)
+	require.True(t, report.Repaired)
+	require.Equal(t, int64(1), report.Undecodable)
+	require.Equal(t, int64(1), report.MissingIndexEntries)
+	require.Equal(t, int64(2), report.OrphanedIndexEntries)
+
+	// only the forged event is left to complain about
+	report, err = db.Verify(ctx, eventstore.VerifyOptions{CheckSignatures: true})
+	require.NoError(t, err)
+	require.Equal(t, int64(11), report.Events)
+	require.Equal(t, int64(1), report.InvalidEvents)
+	require.Zero(t, report.Undecodable)
+	require.Zero(t, report.MissingIndexEntries)
+	require.Zero(t, report.OrphanedIndexEntries)
+
+	res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Tags: nostr.TagMap{"t": []string{"verify"}}})
+	require.NoError(t, err)
+	require.Len(t, res, 11)
+}
@@ -0,0 +1,39 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func (b *MemStore) CountEvents(ctx context.Context, filter nostr.Filter) (int64, error) {
+	if err := b.inflight.Acquire(); err != nil {
+		return 0, err
+	}
+	defer b.inflight.Release()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var count int64
+	for range b.candidates(filter) {
+		count++
+	}
+	return count, nil
+}
+
+func (b *MemStore) HasEvents(ctx context.Context, ids []string) ([]bool, error) {
+	if err := b.inflight.Acquire(); err != nil {
+		return nil, err
+	}
+	defer b.inflight.Release()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	results := make([]bool, len(ids))
+	for i, id := range ids {
+		_, results[i] = b.byID[id]
+	}
+	return results, nil
+}
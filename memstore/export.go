@@ -0,0 +1,42 @@
+package memstore
+
+import (
+	"context"
+	"iter"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+var _ eventstore.Exporter = (*MemStore)(nil)
+
+// ExportEvents goes through a snapshot of the events matching filter, oldest first.
+func (b *MemStore) ExportEvents(ctx context.Context, filter nostr.Filter) iter.Seq2[*nostr.Event, error] {
+	return func(yield func(*nostr.Event, error) bool) {
+		if filter.Search != "" {
+			return
+		}
+
+		if err := b.inflight.Acquire(); err != nil {
+			yield(nil, err)
+			return
+		}
+		b.mu.RLock()
+		var events []*nostr.Event
+		for evt := range b.candidates(filter) {
+			events = append(events, evt)
+		}
+		b.mu.RUnlock()
+		b.inflight.Release()
+
+		// candidates are newest first, so the oldest ones are at the end when there is a limit
+		if filter.Limit > 0 && len(events) > filter.Limit {
+			events = events[len(events)-filter.Limit:]
+		}
+		for i := len(events) - 1; i >= 0; i-- {
+			if !yield(events[i], nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package memstore
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/google/btree"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// eventTree keeps events sorted as compareEvents says, inserting and removing in O(log n) even
+// when there are millions of them.
+type eventTree = btree.BTreeG[*nostr.Event]
+
+func newEventTree() *eventTree {
+	return btree.NewG(32, func(a, b *nostr.Event) bool { return compareEvents(a, b) < 0 })
+}
+
+// add indexes a copy of evt and evicts the oldest events if we are over the limits, it must be
+// called with the write lock held.
+func (b *MemStore) add(evt *nostr.Event) {
+	evt = copyEvent(evt)
+	b.byID[evt.ID] = evt
+	b.all.ReplaceOrInsert(evt)
+	addToMap(b.byAuthor, evt.PubKey, evt)
+	addToMap(b.byKind, evt.Kind, evt)
+	for _, key := range tagKeys(evt) {
+		addToMap(b.byTag, key, evt)
+	}
+	b.bytes += eventSize(evt)
+
+	for b.all.Len() > 0 &&
+		((b.MaxEvents > 0 && b.all.Len() > b.MaxEvents) || (b.MaxBytes > 0 && b.bytes > b.MaxBytes)) {
+		oldest, _ := b.all.Min()
+		b.remove(oldest)
+	}
+}
+
+// remove takes evt out of all the indexes, it must be called with the write lock held.
+func (b *MemStore) remove(evt *nostr.Event) {
+	delete(b.byID, evt.ID)
+	b.all.Delete(evt)
+	removeFromMap(b.byAuthor, evt.PubKey, evt)
+	removeFromMap(b.byKind, evt.Kind, evt)
+	for _, key := range tagKeys(evt) {
+		removeFromMap(b.byTag, key, evt)
+	}
+	b.bytes -= eventSize(evt)
+}
+
+// tagKeys are the keys of evt in byTag, each only once.
+func tagKeys(evt *nostr.Event) []string {
+	var keys []string
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && len(tag[0]) == 1 {
+			key := tagKey(tag[0], tag[1])
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+func tagKey(letter string, value string) string {
+	return letter + ":" + value
+}
+
+// eventSize is about how big evt is when serialized.
+func eventSize(evt *nostr.Event) int64 {
+	size := 64 + len(evt.ID) + len(evt.PubKey) + len(evt.Sig) + len(evt.Content)
+	for _, tag := range evt.Tags {
+		size += 4
+		for _, item := range tag {
+			size += len(item) + 3
+		}
+	}
+	return int64(size)
+}
+
+// events are sorted oldest first, ties broken by id
+func compareEvents(a, b *nostr.Event) int {
+	if c := cmp.Compare(a.CreatedAt, b.CreatedAt); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// copyEvent makes a copy of evt that shares nothing with it, so the caller changing evt later
+// can't move it around in the indexes.
+func copyEvent(evt *nostr.Event) *nostr.Event {
+	c := *evt
+	c.Tags = make(nostr.Tags, len(evt.Tags))
+	for i, tag := range evt.Tags {
+		c.Tags[i] = slices.Clone(tag)
+	}
+	return &c
+}
+
+func addToMap[K comparable](m map[K]*eventTree, key K, evt *nostr.Event) {
+	tree, ok := m[key]
+	if !ok {
+		tree = newEventTree()
+		m[key] = tree
+	}
+	tree.ReplaceOrInsert(evt)
+}
+
+func removeFromMap[K comparable](m map[K]*eventTree, key K, evt *nostr.Event) {
+	if tree, ok := m[key]; ok {
+		tree.Delete(evt)
+		if tree.Len() == 0 {
+			delete(m, key)
+		}
+	}
+}
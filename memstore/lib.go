@@ -0,0 +1,82 @@
+package memstore
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/fiatjaf/eventstore/internal/inflight"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+var _ eventstore.Store = (*MemStore)(nil)
+
+// MemStore keeps events in memory, indexed by id, author, kind and single-letter tags. It is safe
+// to use from multiple goroutines, and can be bounded to work as a cache, in which case the
+// oldest events are evicted first.
+//
+// Saved events are copied, but the ones returned by queries are those in the store and must not
+// be modified.
+type MemStore struct {
+	MaxLimit int
+
+	// MaxEvents is how many events are kept at most, 0 means no limit.
+	MaxEvents int
+
+	// MaxBytes is roughly how much memory the events can take, counting their JSON size, 0 means
+	// no limit.
+	MaxBytes int64
+
+	mu       sync.RWMutex
+	inflight inflight.Tracker
+	bytes    int64
+
+	byID     map[string]*nostr.Event
+	all      *eventTree // sorted by created_at and id, like the ones below
+	byAuthor map[string]*eventTree
+	byKind   map[int]*eventTree
+	byTag    map[string]*eventTree // keyed by tag letter and value, as in "e:<id>"
+}
+
+func (b *MemStore) Init() error {
+	if b.MaxLimit == 0 {
+		b.MaxLimit = 500
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bytes = 0
+	b.byID = make(map[string]*nostr.Event)
+	b.all = newEventTree()
+	b.byAuthor = make(map[string]*eventTree)
+	b.byKind = make(map[int]*eventTree)
+	b.byTag = make(map[string]*eventTree)
+	return nil
+}
+
+// Close is CloseContext with a timeout of inflight.CloseTimeout.
+func (b *MemStore) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), inflight.CloseTimeout)
+	defer cancel()
+	if err := b.CloseContext(ctx); err != nil {
+		log.Printf("[memstore] failed to close: %s\n", err)
+	}
+}
+
+// CloseContext lets go of the events once the running operations are done.
+// See [eventstore.GracefulCloser].
+func (b *MemStore) CloseContext(ctx context.Context) error {
+	return b.inflight.Close(ctx, func() error {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		b.bytes = 0
+		b.byID = nil
+		b.all = newEventTree()
+		b.byAuthor = nil
+		b.byKind = nil
+		b.byTag = nil
+		return nil
+	})
+}
@@ -0,0 +1,164 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func makeEvent(t *testing.T, sk string, ts int, tags nostr.Tags) *nostr.Event {
+	evt := &nostr.Event{CreatedAt: nostr.Timestamp(ts), Kind: 1, Tags: tags, Content: fmt.Sprintf("event %d", ts)}
+	require.NoError(t, evt.Sign(sk))
+	return evt
+}
+
+func TestEviction(t *testing.T) {
+	ctx := context.Background()
+	sk := nostr.GeneratePrivateKey()
+
+	db := &MemStore{MaxEvents: 10}
+	require.NoError(t, db.Init())
+	for i := 1; i <= 25; i++ {
+		require.NoError(t, db.SaveEvent(ctx, makeEvent(t, sk, i, nostr.Tags{{"t", "x"}})))
+	}
+	// one older than everything that's left is evicted right away
+	require.NoError(t, db.SaveEvent(ctx, makeEvent(t, sk, 1, nil)))
+
+	events, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Tags: nostr.TagMap{"t": {"x"}}})
+	require.NoError(t, err)
+	require.Len(t, events, 10)
+	require.Equal(t, nostr.Timestamp(25), events[0].CreatedAt)
+	require.Equal(t, nostr.Timestamp(16), events[9].CreatedAt)
+
+	db = &MemStore{MaxBytes: 5 * eventSize(makeEvent(t, sk, 10, nil))}
+	require.NoError(t, db.Init())
+	for i := 1; i <= 20; i++ {
+		require.NoError(t, db.SaveEvent(ctx, makeEvent(t, sk, i, nil)))
+	}
+	n, err := db.CountEvents(ctx, nostr.Filter{})
+	require.NoError(t, err)
+	require.Equal(t, int64(5), n)
+
+	stats, err := db.Stats(ctx)
+	require.NoError(t, err)
+	require.LessOrEqual(t, stats.Indexes["events"].Size, db.MaxBytes)
+	require.Equal(t, int64(1), stats.Indexes["author"].Entries)
+}
+
+func TestTagIndex(t *testing.T) {
+	ctx := context.Background()
+	sk := nostr.GeneratePrivateKey()
+
+	db := &MemStore{}
+	require.NoError(t, db.Init())
+	a := makeEvent(t, sk, 1, nostr.Tags{{"e", "aa"}, {"e", "bb"}})
+	b := makeEvent(t, sk, 2, nostr.Tags{{"e", "bb"}, {"p", "aa"}})
+	c := makeEvent(t, sk, 3, nostr.Tags{{"e", "cc"}})
+	for _, evt := range []*nostr.Event{a, b, c} {
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+
+	events, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Tags: nostr.TagMap{"e": {"aa", "bb"}}})
+	require.NoError(t, err)
+	require.Equal(t, []*nostr.Event{b, a}, events)
+
+	events, err = eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Tags: nostr.TagMap{"e": {"bb"}, "p": {"aa"}}})
+	require.NoError(t, err)
+	require.Equal(t, []*nostr.Event{b}, events)
+
+	require.NoError(t, db.DeleteEvent(ctx, b))
+	events, err = eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Tags: nostr.TagMap{"e": {"bb"}}})
+	require.NoError(t, err)
+	require.Equal(t, []*nostr.Event{a}, events)
+	require.NotContains(t, db.byTag, "p:aa")
+}
+
+func TestConcurrentUse(t *testing.T) {
+	ctx := context.Background()
+	db := &MemStore{MaxEvents: 500}
+	require.NoError(t, db.Init())
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sk := nostr.GeneratePrivateKey()
+			for i := 0; i < 200; i++ {
+				evt := makeEvent(t, sk, i+1, nostr.Tags{{"t", fmt.Sprint(i % 7)}})
+				require.NoError(t, db.SaveEvent(ctx, evt))
+				ch, err := db.QueryEvents(ctx, nostr.Filter{Tags: nostr.TagMap{"t": {fmt.Sprint(i % 7)}}, Limit: 5})
+				require.NoError(t, err)
+				for range ch {
+				}
+				if i%3 == 0 {
+					require.NoError(t, db.DeleteEvent(ctx, evt))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	n, err := db.CountEvents(ctx, nostr.Filter{})
+	require.NoError(t, err)
+	require.Equal(t, int64(500), n)
+}
+
+// BenchmarkSaveFull saves events in random order into a store that is full, so each save also
+// evicts the oldest event.
+func BenchmarkSaveFull(b *testing.B) {
+	ctx := context.Background()
+	const size = 100_000
+
+	db := &MemStore{MaxEvents: size}
+	require.NoError(b, db.Init())
+	newEvent := func(i int) *nostr.Event {
+		return &nostr.Event{
+			ID:        fmt.Sprintf("%064x", i),
+			PubKey:    fmt.Sprintf("%064x", i%100),
+			CreatedAt: nostr.Timestamp(rand.IntN(size) + i),
+			Kind:      1,
+			Tags:      nostr.Tags{{"t", fmt.Sprint(i % 10)}},
+		}
+	}
+	for i := 0; i < size; i++ {
+		require.NoError(b, db.SaveEvent(ctx, newEvent(i)))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		require.NoError(b, db.SaveEvent(ctx, newEvent(size+i)))
+	}
+}
+
+func TestSavedEventsAreCopied(t *testing.T) {
+	ctx := context.Background()
+	sk := nostr.GeneratePrivateKey()
+
+	db := &MemStore{}
+	require.NoError(t, db.Init())
+	evt := makeEvent(t, sk, 10, nostr.Tags{{"t", "x"}})
+	require.NoError(t, db.SaveEvent(ctx, evt))
+	require.NoError(t, db.SaveEvent(ctx, makeEvent(t, sk, 20, nil)))
+
+	// the caller reusing its event doesn't change what was saved
+	evt.CreatedAt = 30
+	evt.Tags[0][1] = "y"
+
+	events, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, nostr.Timestamp(20), events[0].CreatedAt)
+	require.Equal(t, nostr.Timestamp(10), events[1].CreatedAt)
+
+	events, err = eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Tags: nostr.TagMap{"t": {"x"}}})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "x", events[0].Tags[0][1])
+}
@@ -0,0 +1,215 @@
+package memstore
+
+import (
+	"context"
+	"iter"
+	"math"
+	"slices"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func (b *MemStore) QueryEvents(ctx context.Context, filter nostr.Filter) (chan *nostr.Event, error) {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *nostr.Event)
+	limit := b.getLimit(ctx, filter)
+	if limit == 0 {
+		close(ch)
+		done()
+		return ch, nil
+	}
+
+	// collected while holding the lock and sent after, so slow readers don't block writes
+	b.mu.RLock()
+	events := make([]*nostr.Event, 0, min(limit, 100))
+	for evt := range b.candidates(filter) {
+		events = append(events, evt)
+		if len(events) == limit {
+			break
+		}
+	}
+	b.mu.RUnlock()
+
+	go func() {
+		defer done()
+		defer close(ch)
+		for _, evt := range events {
+			select {
+			case ch <- evt:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (b *MemStore) QueryIDs(ctx context.Context, filter nostr.Filter) iter.Seq2[eventstore.IDTimestamp, error] {
+	return func(yield func(eventstore.IDTimestamp, error) bool) {
+		ctx, done, err := b.inflight.AcquireContext(ctx)
+		if err != nil {
+			yield(eventstore.IDTimestamp{}, err)
+			return
+		}
+		defer done()
+
+		limit := b.getLimit(ctx, filter)
+		if limit == 0 {
+			return
+		}
+
+		b.mu.RLock()
+		var results []eventstore.IDTimestamp
+		for evt := range b.candidates(filter) {
+			results = append(results, eventstore.IDTimestamp{ID: evt.ID, CreatedAt: evt.CreatedAt})
+			if len(results) == limit {
+				break
+			}
+		}
+		b.mu.RUnlock()
+
+		for _, result := range results {
+			if !yield(result, nil) {
+				return
+			}
+		}
+	}
+}
+
+// getLimit returns the maximum number of events we'll return for this filter, 0 means nothing should be returned
+func (b *MemStore) getLimit(ctx context.Context, filter nostr.Filter) int {
+	if filter.Search != "" || filter.LimitZero {
+		return 0
+	}
+
+	limit := b.MaxLimit
+	if eventstore.IsNegentropySession(ctx) {
+		limit = math.MaxInt
+	}
+	if filter.Limit > 0 && filter.Limit < limit {
+		limit = filter.Limit
+	}
+	if tlimit := nostr.GetTheoreticalLimit(filter); tlimit >= 0 && tlimit < limit {
+		limit = tlimit
+	}
+	return limit
+}
+
+// candidates yields the events matching filter, newest first, starting from the index that gives
+// the fewest of them. It must be called with the lock held.
+func (b *MemStore) candidates(filter nostr.Filter) iter.Seq[*nostr.Event] {
+	return func(yield func(*nostr.Event) bool) {
+		var trees []*eventTree
+		if len(filter.IDs) > 0 {
+			found := newEventTree()
+			for _, id := range filter.IDs {
+				if evt, ok := b.byID[id]; ok {
+					found.ReplaceOrInsert(evt)
+				}
+			}
+			trees = []*eventTree{found}
+		} else {
+			best := -1
+			consider := func(candidate []*eventTree) {
+				n := 0
+				for _, tree := range candidate {
+					n += tree.Len()
+				}
+				if best == -1 || n < best {
+					best = n
+					trees = candidate
+				}
+			}
+
+			if len(filter.Authors) > 0 {
+				consider(treesFor(b.byAuthor, dedupe(filter.Authors)))
+			}
+			if len(filter.Kinds) > 0 {
+				consider(treesFor(b.byKind, dedupe(filter.Kinds)))
+			}
+			for letter, values := range filter.Tags {
+				if len(letter) != 1 {
+					continue
+				}
+				keys := make([]string, 0, len(values))
+				for _, value := range dedupe(values) {
+					keys = append(keys, tagKey(letter, value))
+				}
+				consider(treesFor(b.byTag, keys))
+			}
+			if best == -1 {
+				trees = []*eventTree{b.all}
+			}
+		}
+
+		if len(trees) == 1 {
+			descendWithin(trees[0], filter, func(evt *nostr.Event) bool {
+				return !filter.Matches(evt) || yield(evt)
+			})
+			return
+		}
+
+		// an event can be in the trees of more than one tag value
+		var events []*nostr.Event
+		for _, tree := range trees {
+			descendWithin(tree, filter, func(evt *nostr.Event) bool {
+				events = append(events, evt)
+				return true
+			})
+		}
+		slices.SortFunc(events, compareEvents)
+		events = slices.CompactFunc(events, func(a, b *nostr.Event) bool { return a == b })
+
+		for i := len(events) - 1; i >= 0; i-- {
+			if filter.Matches(events[i]) && !yield(events[i]) {
+				return
+			}
+		}
+	}
+}
+
+// descendWithin calls fn with the events of tree between since and until, newest first, until it
+// returns false.
+func descendWithin(tree *eventTree, filter nostr.Filter, fn func(*nostr.Event) bool) {
+	visit := func(evt *nostr.Event) bool {
+		if filter.Since != nil && evt.CreatedAt < *filter.Since {
+			return false
+		}
+		return fn(evt)
+	}
+
+	if filter.Until != nil {
+		// "g" sorts after every hex id, so this comes after all the events at until
+		tree.DescendLessOrEqual(&nostr.Event{CreatedAt: *filter.Until, ID: "g"}, visit)
+	} else {
+		tree.Descend(visit)
+	}
+}
+
+func treesFor[K comparable](m map[K]*eventTree, keys []K) []*eventTree {
+	trees := make([]*eventTree, 0, len(keys))
+	for _, key := range keys {
+		if tree, ok := m[key]; ok {
+			trees = append(trees, tree)
+		}
+	}
+	return trees
+}
+
+func dedupe[K comparable](keys []K) []K {
+	if len(keys) < 2 {
+		return keys
+	}
+	unique := make([]K, 0, len(keys))
+	for _, key := range keys {
+		if !slices.Contains(unique, key) {
+			unique = append(unique, key)
+		}
+	}
+	return unique
+}
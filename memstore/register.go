@@ -0,0 +1,37 @@
+package memstore
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/fiatjaf/eventstore"
+)
+
+func init() {
+	eventstore.Register(eventstore.Driver{
+		Name:    "memory",
+		Schemes: []string{"memory"},
+		// limits go in the uri, as in "memory://?max_events=10000&max_bytes=100000000"
+		New: func(uri string, opts eventstore.OpenOptions) (eventstore.Store, error) {
+			db := &MemStore{MaxLimit: opts.MaxLimit}
+
+			_, query, _ := strings.Cut(uri, "?")
+			params, err := url.ParseQuery(query)
+			if err != nil {
+				return nil, err
+			}
+			if v := params.Get("max_events"); v != "" {
+				if db.MaxEvents, err = strconv.Atoi(v); err != nil {
+					return nil, err
+				}
+			}
+			if v := params.Get("max_bytes"); v != "" {
+				if db.MaxBytes, err = strconv.ParseInt(v, 10, 64); err != nil {
+					return nil, err
+				}
+			}
+			return db, nil
+		},
+	})
+}
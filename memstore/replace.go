@@ -0,0 +1,42 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/fiatjaf/eventstore/internal"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func (b *MemStore) ReplaceEvent(ctx context.Context, evt *nostr.Event) error {
+	if err := b.inflight.Acquire(); err != nil {
+		return err
+	}
+	defer b.inflight.Release()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	filter := nostr.Filter{Kinds: []int{evt.Kind}, Authors: []string{evt.PubKey}}
+	if nostr.IsAddressableKind(evt.Kind) {
+		filter.Tags = nostr.TagMap{"d": []string{evt.Tags.GetD()}}
+	}
+
+	var previous []*nostr.Event
+	for prev := range b.candidates(filter) {
+		previous = append(previous, prev)
+	}
+
+	shouldStore := true
+	for _, prev := range previous {
+		if internal.IsOlder(prev, evt) {
+			b.remove(prev)
+		} else {
+			shouldStore = false
+		}
+	}
+
+	if _, ok := b.byID[evt.ID]; shouldStore && !ok {
+		b.add(evt)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func (b *MemStore) SaveEvent(ctx context.Context, evt *nostr.Event) error {
+	if err := b.inflight.Acquire(); err != nil {
+		return err
+	}
+	defer b.inflight.Release()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.byID[evt.ID]; ok {
+		return eventstore.ErrDupEvent
+	}
+	b.add(evt)
+	return nil
+}
+
+func (b *MemStore) DeleteEvent(ctx context.Context, evt *nostr.Event) error {
+	if err := b.inflight.Acquire(); err != nil {
+		return err
+	}
+	defer b.inflight.Release()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if stored, ok := b.byID[evt.ID]; ok {
+		b.remove(stored)
+	}
+	return nil
+}
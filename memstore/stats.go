@@ -0,0 +1,34 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/fiatjaf/eventstore"
+)
+
+// Stats has the number of entries in each index, the size of the "events" one is the estimate
+// MaxBytes is checked against. Nothing is on disk.
+func (b *MemStore) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	if err := b.inflight.Acquire(); err != nil {
+		return eventstore.StoreStats{}, err
+	}
+	defer b.inflight.Release()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	stats := eventstore.StoreStats{
+		Events:        int64(b.all.Len()),
+		EventsPerKind: make(map[int]int64, len(b.byKind)),
+		Indexes: map[string]eventstore.IndexStats{
+			"events": {Entries: int64(b.all.Len()), Size: b.bytes},
+			"author": {Entries: int64(len(b.byAuthor))},
+			"kind":   {Entries: int64(len(b.byKind))},
+			"tag":    {Entries: int64(len(b.byTag))},
+		},
+	}
+	for kind, events := range b.byKind {
+		stats.EventsPerKind[kind] = int64(events.Len())
+	}
+	return stats, nil
+}
@@ -0,0 +1,19 @@
+package eventstore
+
+// MigrationProgress is passed to the progress callbacks of stores that migrate their data in
+// steps when they are opened.
+type MigrationProgress struct {
+	// Version is the schema version the data is being migrated to.
+	Version     int    `json:"version"`
+	Description string `json:"description"`
+
+	// Step is the step of the migration being run, starting from 1, out of Steps.
+	Step  int `json:"step"`
+	Steps int `json:"steps"`
+
+	// Done is how many events this step has processed so far, including the ones processed before
+	// the migration was interrupted, out of Total. Both are zero for steps that don't go through
+	// the events one by one.
+	Done  int64 `json:"done"`
+	Total int64 `json:"total"`
+}
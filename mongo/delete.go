@@ -7,7 +7,13 @@ import (
 )
 
 func (m *MongoDBBackend) DeleteEvent(ctx context.Context, event *nostr.Event) error {
-	_, err := m.Client.Database("events").Collection("events").DeleteOne(ctx, bson.M{
+	ctx, done, err := m.inflight.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	_, err = m.Client.Database("events").Collection("events").DeleteOne(ctx, bson.M{
 		"id": event.ID,
 	})
 	return err
@@ -2,8 +2,9 @@ package mongo
 
 import (
 	"context"
-	"log"
+	"fmt"
 
+	"github.com/fiatjaf/eventstore"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
@@ -50,7 +51,8 @@ func (m *MongoDBBackend) Init() error {
 	for _, index := range indexs {
 		_, err = collection.Indexes().CreateOne(context.TODO(), index)
 		if err != nil {
-			log.Fatal(err)
+			client.Disconnect(context.Background())
+			return fmt.Errorf("%w: failed to create index: %w", eventstore.ErrStorageUnavailable, err)
 		}
 	}
 	m.Client = client
@@ -0,0 +1,16 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitWithUnreachableServer(t *testing.T) {
+	backend := &MongoDBBackend{DatabaseURL: "mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=100"}
+
+	err := backend.Init()
+	require.ErrorIs(t, err, eventstore.ErrStorageUnavailable)
+	require.Nil(t, backend.Client)
+}
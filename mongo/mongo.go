@@ -2,8 +2,10 @@ package mongo
 
 import (
 	"context"
+	"log"
 	"sync"
 
+	"github.com/fiatjaf/eventstore/internal/inflight"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
@@ -17,8 +19,21 @@ type MongoDBBackend struct {
 	QueryAuthorsLimit int
 	QueryKindsLimit   int
 	QueryTagsLimit    int
+
+	inflight inflight.Tracker
 }
 
+// Close is CloseContext with a timeout of inflight.CloseTimeout.
 func (m *MongoDBBackend) Close() {
-	m.Client.Disconnect(m.ctx)
+	ctx, cancel := context.WithTimeout(context.Background(), inflight.CloseTimeout)
+	defer cancel()
+	if err := m.CloseContext(ctx); err != nil {
+		log.Printf("mongo: failed to close: %s\n", err)
+	}
+}
+
+// CloseContext closes the client once the running operations are done.
+// See [eventstore.GracefulCloser].
+func (m *MongoDBBackend) CloseContext(ctx context.Context) error {
+	return m.inflight.Close(ctx, func() error { return m.Client.Disconnect(context.Background()) })
 }
@@ -18,7 +18,17 @@ var (
 	ErrTooManyTagValues = errors.New("too many tag values")
 )
 
-func (m *MongoDBBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (chan *nostr.Event, error) {
+func (m *MongoDBBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (ch chan *nostr.Event, err error) {
+	ctx, done, err := m.inflight.AcquireContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// otherwise the goroutine below will release it
+		if err != nil {
+			done()
+		}
+	}()
 
 	conditions, projections, err := m.queryEvents(filter, false)
 	if err != nil {
@@ -33,8 +43,9 @@ func (m *MongoDBBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (
 		return nil, err
 	}
 
-	ch := make(chan *nostr.Event)
+	ch = make(chan *nostr.Event)
 	go func() {
+		defer done()
 		defer cursor.Close(ctx)
 		defer close(ch)
 		for cursor.Next(ctx) {
@@ -70,6 +81,12 @@ func (m *MongoDBBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (
 }
 
 func (m *MongoDBBackend) CountEvents(ctx context.Context, filter nostr.Filter) (int64, error) {
+	ctx, done, err := m.inflight.AcquireContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
 	var count int64
 	conditions, projection, err := m.queryEvents(filter, true)
 	if err != nil {
@@ -0,0 +1,20 @@
+package mongo
+
+import "github.com/fiatjaf/eventstore"
+
+func init() {
+	eventstore.Register(eventstore.Driver{
+		Name:    "mongo",
+		Schemes: []string{"mongodb", "mongodb+srv"},
+		New: func(uri string, opts eventstore.OpenOptions) (eventstore.Store, error) {
+			return &MongoDBBackend{
+				DatabaseURL:       uri,
+				QueryLimit:        opts.MaxLimit,
+				QueryIDsLimit:     opts.MaxLimit,
+				QueryAuthorsLimit: opts.MaxLimit,
+				QueryKindsLimit:   opts.MaxLimit,
+				QueryTagsLimit:    opts.MaxLimit,
+			}, nil
+		},
+	})
+}
@@ -7,6 +7,12 @@ import (
 )
 
 func (m *MongoDBBackend) SaveEvent(ctx context.Context, event *nostr.Event) error {
+	ctx, done, err := m.inflight.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	result, err := m.Client.Database("events").Collection("events").InsertOne(ctx, event)
 	if err != nil {
 		return err
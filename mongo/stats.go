@@ -0,0 +1,58 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fiatjaf/eventstore"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Stats groups the events by kind and reports the sizes from the collStats command.
+func (m *MongoDBBackend) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	ctx, done, err := m.inflight.AcquireContext(ctx)
+	if err != nil {
+		return eventstore.StoreStats{}, err
+	}
+	defer done()
+
+	stats := eventstore.StoreStats{
+		EventsPerKind: make(map[int]int64),
+		Indexes:       make(map[string]eventstore.IndexStats),
+	}
+
+	db := m.Client.Database("events")
+	cursor, err := db.Collection("events").Aggregate(ctx, bson.A{
+		bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$kind"}, {Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}}}}},
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to count events per kind: %w", err)
+	}
+	var groups []struct {
+		Kind  int   `bson:"_id"`
+		Count int64 `bson:"count"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return stats, fmt.Errorf("failed to count events per kind: %w", err)
+	}
+	for _, g := range groups {
+		stats.EventsPerKind[g.Kind] = g.Count
+		stats.Events += g.Count
+	}
+
+	var coll struct {
+		StorageSize    int64            `bson:"storageSize"`
+		TotalIndexSize int64            `bson:"totalIndexSize"`
+		IndexSizes     map[string]int64 `bson:"indexSizes"`
+	}
+	if err := db.RunCommand(ctx, bson.D{{Key: "collStats", Value: "events"}}).Decode(&coll); err != nil {
+		return stats, fmt.Errorf("failed to get collection stats: %w", err)
+	}
+	stats.DiskSize = coll.StorageSize + coll.TotalIndexSize
+	stats.Indexes["events"] = eventstore.IndexStats{Entries: stats.Events, Size: coll.StorageSize}
+	for name, size := range coll.IndexSizes {
+		stats.Indexes[name] = eventstore.IndexStats{Entries: stats.Events, Size: size}
+	}
+
+	return stats, nil
+}
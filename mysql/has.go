@@ -0,0 +1,61 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// HasEvents checks for the given ids in chunks of QueryIDsLimit, selecting only the id column.
+func (b *MySQLBackend) HasEvents(ctx context.Context, ids []string) ([]bool, error) {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	results := make([]bool, len(ids))
+	positions := make(map[string][]int, len(ids))
+	for i, id := range ids {
+		positions[id] = append(positions[id], i)
+	}
+
+	chunkSize := b.QueryIDsLimit
+	if chunkSize <= 0 {
+		chunkSize = len(ids)
+	}
+
+	for start := 0; start < len(ids); start += chunkSize {
+		end := min(start+chunkSize, len(ids))
+		chunk := ids[start:end]
+
+		params := make([]any, len(chunk))
+		for i, id := range chunk {
+			params[i] = id
+		}
+		query := sqlx.Rebind(sqlx.BindType("mysql"), `SELECT id FROM event WHERE id IN (`+makePlaceHolders(len(chunk))+`)`)
+		rows, err := b.DB.QueryContext(ctx, query, params...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check ids using query %q: %w", query, err)
+		}
+
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			for _, pos := range positions[id] {
+				results[pos] = true
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/fiatjaf/eventstore"
@@ -24,7 +25,7 @@ var ddls = []string{
        id char(64) NOT NULL primary key,
        pubkey char(64) NOT NULL,
        created_at int NOT NULL,
-       kind integer NOT NULL,
+       kind bigint NOT NULL,
        tags json NOT NULL,
        content text NOT NULL,
        sig text NOT NULL);`,
@@ -53,6 +54,18 @@ func (b *MySQLBackend) Init() error {
 		}
 	}
 
+	// kinds used to be integer, which doesn't fit all of them
+	var kindType string
+	if err := b.DB.Get(&kindType, `SELECT DATA_TYPE FROM information_schema.COLUMNS
+        WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'event' AND COLUMN_NAME = 'kind'`); err != nil {
+		return fmt.Errorf("failed to check the type of the kind column: %w", err)
+	}
+	if kindType == "int" {
+		if _, err := b.DB.Exec(`ALTER TABLE event MODIFY kind bigint NOT NULL`); err != nil {
+			return fmt.Errorf("failed to widen the kind column: %w", err)
+		}
+	}
+
 	if b.QueryLimit == 0 {
 		b.QueryLimit = queryLimit
 	}
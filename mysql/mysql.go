@@ -1,8 +1,11 @@
 package mysql
 
 import (
+	"context"
+	"log"
 	"sync"
 
+	"github.com/fiatjaf/eventstore/internal/inflight"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -15,8 +18,21 @@ type MySQLBackend struct {
 	QueryAuthorsLimit int
 	QueryKindsLimit   int
 	QueryTagsLimit    int
+
+	inflight inflight.Tracker
 }
 
+// Close is CloseContext with a timeout of inflight.CloseTimeout.
 func (b *MySQLBackend) Close() {
-	b.DB.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), inflight.CloseTimeout)
+	defer cancel()
+	if err := b.CloseContext(ctx); err != nil {
+		log.Printf("mysql: failed to close: %s\n", err)
+	}
+}
+
+// CloseContext closes the database once the running operations are done.
+// See [eventstore.GracefulCloser].
+func (b *MySQLBackend) CloseContext(ctx context.Context) error {
+	return b.inflight.Close(ctx, b.DB.Close)
 }
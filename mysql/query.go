@@ -4,13 +4,26 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"iter"
 	"strings"
 
+	"github.com/fiatjaf/eventstore"
 	"github.com/jmoiron/sqlx"
 	"github.com/nbd-wtf/go-nostr"
 )
 
 func (b *MySQLBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (ch chan *nostr.Event, err error) {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// otherwise the goroutine below will release it
+		if err != nil {
+			done()
+		}
+	}()
+
 	ch = make(chan *nostr.Event)
 
 	query, params, err := b.queryEventsSql(filter, false)
@@ -26,6 +39,7 @@ func (b *MySQLBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (ch
 	}
 
 	go func() {
+		defer done()
 		defer rows.Close()
 		defer close(ch)
 		for rows.Next() {
@@ -49,6 +63,12 @@ func (b *MySQLBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (ch
 }
 
 func (b *MySQLBackend) CountEvents(ctx context.Context, filter nostr.Filter) (int64, error) {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
 	query, params, err := b.queryEventsSql(filter, true)
 	if err != nil {
 		return 0, err
@@ -61,6 +81,46 @@ func (b *MySQLBackend) CountEvents(ctx context.Context, filter nostr.Filter) (in
 	return count, nil
 }
 
+// QueryIDs is like QueryEvents, but only selects the id and created_at columns.
+func (b *MySQLBackend) QueryIDs(ctx context.Context, filter nostr.Filter) iter.Seq2[eventstore.IDTimestamp, error] {
+	return func(yield func(eventstore.IDTimestamp, error) bool) {
+		ctx, done, err := b.inflight.AcquireContext(ctx)
+		if err != nil {
+			yield(eventstore.IDTimestamp{}, err)
+			return
+		}
+		defer done()
+
+		query, params, err := b.queryIDsSql(filter)
+		if err != nil {
+			yield(eventstore.IDTimestamp{}, err)
+			return
+		}
+
+		rows, err := b.DB.QueryContext(ctx, query, params...)
+		if err != nil {
+			yield(eventstore.IDTimestamp{}, fmt.Errorf("failed to fetch ids using query %q: %w", query, err))
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id string
+			var timestamp int64
+			if err := rows.Scan(&id, &timestamp); err != nil {
+				yield(eventstore.IDTimestamp{}, err)
+				return
+			}
+			if !yield(eventstore.IDTimestamp{ID: id, CreatedAt: nostr.Timestamp(timestamp)}, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(eventstore.IDTimestamp{}, err)
+		}
+	}
+}
+
 func makePlaceHolders(n int) string {
 	return strings.TrimRight(strings.Repeat("?,", n), ",")
 }
@@ -73,13 +133,25 @@ func escapeLikeString(s string) string {
 }
 
 func (b *MySQLBackend) queryEventsSql(filter nostr.Filter, doCount bool) (string, []any, error) {
+	if doCount {
+		return b.buildQuerySql(filter, "COUNT(*)", false)
+	}
+	return b.buildQuerySql(filter, "id, pubkey, created_at, kind, tags, content, sig", true)
+}
+
+func (b *MySQLBackend) queryIDsSql(filter nostr.Filter) (string, []any, error) {
+	return b.buildQuerySql(filter, "id, created_at", true)
+}
+
+// filterConditions translates filter into SQL conditions, without the limit.
+func (b *MySQLBackend) filterConditions(filter nostr.Filter) ([]string, []any, error) {
 	conditions := make([]string, 0, 7)
 	params := make([]any, 0, 20)
 
 	if len(filter.IDs) > 0 {
 		if len(filter.IDs) > b.QueryIDsLimit {
 			// too many ids, fail everything
-			return "", nil, nil
+			return nil, nil, nil
 		}
 
 		for _, v := range filter.IDs {
@@ -91,7 +163,7 @@ func (b *MySQLBackend) queryEventsSql(filter nostr.Filter, doCount bool) (string
 	if len(filter.Authors) > 0 {
 		if len(filter.Authors) > b.QueryAuthorsLimit {
 			// too many authors, fail everything
-			return "", nil, nil
+			return nil, nil, nil
 		}
 
 		for _, v := range filter.Authors {
@@ -103,7 +175,7 @@ func (b *MySQLBackend) queryEventsSql(filter nostr.Filter, doCount bool) (string
 	if len(filter.Kinds) > 0 {
 		if len(filter.Kinds) > b.QueryKindsLimit {
 			// too many kinds, fail everything
-			return "", nil, nil
+			return nil, nil, nil
 		}
 
 		for _, v := range filter.Kinds {
@@ -117,7 +189,7 @@ func (b *MySQLBackend) queryEventsSql(filter nostr.Filter, doCount bool) (string
 	for key, values := range filter.Tags {
 		if len(values) == 0 {
 			// any tag set to [] is wrong
-			return "", nil, nil
+			return nil, nil, nil
 		}
 
 		tag := `%["` + escapeLikeString(key) + `"`
@@ -133,7 +205,7 @@ func (b *MySQLBackend) queryEventsSql(filter nostr.Filter, doCount bool) (string
 		totalTags += len(values)
 		if totalTags > b.QueryTagsLimit {
 			// too many tags, fail everything
-			return "", nil, nil
+			return nil, nil, nil
 		}
 	}
 
@@ -155,6 +227,16 @@ func (b *MySQLBackend) queryEventsSql(filter nostr.Filter, doCount bool) (string
 		conditions = append(conditions, `true`)
 	}
 
+	return conditions, params, nil
+}
+
+func (b *MySQLBackend) buildQuerySql(filter nostr.Filter, columns string, ordered bool) (string, []any, error) {
+	conditions, params, err := b.filterConditions(filter)
+	if err != nil || conditions == nil {
+		// a filter that exceeds the limits has no conditions and yields an empty query
+		return "", nil, err
+	}
+
 	if filter.Limit < 1 || filter.Limit > b.QueryLimit {
 		params = append(params, b.QueryLimit)
 	} else {
@@ -162,18 +244,18 @@ func (b *MySQLBackend) queryEventsSql(filter nostr.Filter, doCount bool) (string
 	}
 
 	var query string
-	if doCount {
+	if ordered {
 		query = sqlx.Rebind(sqlx.BindType("mysql"), `SELECT
-          COUNT(*)
+          `+columns+`
         FROM event WHERE `+
 			strings.Join(conditions, " AND ")+
-			" LIMIT ?")
+			" ORDER BY created_at DESC, id LIMIT ?")
 	} else {
 		query = sqlx.Rebind(sqlx.BindType("mysql"), `SELECT
-          id, pubkey, created_at, kind, tags, content, sig
+          `+columns+`
         FROM event WHERE `+
 			strings.Join(conditions, " AND ")+
-			" ORDER BY created_at DESC, id LIMIT ?")
+			" LIMIT ?")
 	}
 
 	return query, params, nil
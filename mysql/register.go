@@ -0,0 +1,21 @@
+package mysql
+
+import "github.com/fiatjaf/eventstore"
+
+func init() {
+	eventstore.Register(eventstore.Driver{
+		Name:    "mysql",
+		Schemes: []string{"mysql"},
+		New: func(uri string, opts eventstore.OpenOptions) (eventstore.Store, error) {
+			return &MySQLBackend{
+				// the driver takes a DSN like "user:pass@tcp(host)/db", without the scheme
+				DatabaseURL:       eventstore.TrimScheme(uri, "mysql"),
+				QueryLimit:        opts.MaxLimit,
+				QueryIDsLimit:     opts.MaxLimit,
+				QueryAuthorsLimit: opts.MaxLimit,
+				QueryKindsLimit:   opts.MaxLimit,
+				QueryTagsLimit:    opts.MaxLimit,
+			}, nil
+		},
+	})
+}
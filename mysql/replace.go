@@ -10,6 +10,12 @@ import (
 )
 
 func (b *MySQLBackend) ReplaceEvent(ctx context.Context, evt *nostr.Event) error {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	b.Lock()
 	defer b.Unlock()
 
@@ -0,0 +1,65 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fiatjaf/eventstore"
+)
+
+// Stats counts the events per kind and reports the size of the event table and of each of its
+// indexes as kept by InnoDB, which only refreshes these numbers from time to time.
+func (b *MySQLBackend) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return eventstore.StoreStats{}, err
+	}
+	defer done()
+
+	stats := eventstore.StoreStats{
+		EventsPerKind: make(map[int]int64),
+		Indexes:       make(map[string]eventstore.IndexStats),
+	}
+
+	rows, err := b.DB.QueryContext(ctx, `SELECT kind, count(*) FROM event GROUP BY kind`)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count events per kind: %w", err)
+	}
+	for rows.Next() {
+		var kind int
+		var count int64
+		if err := rows.Scan(&kind, &count); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		stats.EventsPerKind[kind] = count
+		stats.Events += count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	if err := b.DB.QueryRowContext(ctx, `SELECT coalesce(data_length + index_length, 0) FROM information_schema.TABLES
+		WHERE table_schema = DATABASE() AND table_name = 'event'`).Scan(&stats.DiskSize); err != nil {
+		return stats, fmt.Errorf("failed to get table size: %w", err)
+	}
+
+	rows, err = b.DB.QueryContext(ctx, `SELECT index_name, stat_value * @@innodb_page_size FROM mysql.innodb_index_stats
+		WHERE database_name = DATABASE() AND table_name = 'event' AND stat_name = 'size'`)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get index sizes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var size int64
+		if err := rows.Scan(&name, &size); err != nil {
+			return stats, err
+		}
+		// every index has one entry per event, including the clustered one that holds the rows
+		stats.Indexes[name] = eventstore.IndexStats{Entries: stats.Events, Size: size}
+	}
+	return stats, rows.Err()
+}
@@ -34,3 +34,7 @@ func (b NullStore) SaveEvent(ctx context.Context, evt *nostr.Event) error {
 func (b NullStore) ReplaceEvent(ctx context.Context, evt *nostr.Event) error {
 	return nil
 }
+
+func (b NullStore) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	return eventstore.StoreStats{EventsPerKind: map[int]int64{}}, nil
+}
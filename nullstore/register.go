@@ -0,0 +1,13 @@
+package nullstore
+
+import "github.com/fiatjaf/eventstore"
+
+func init() {
+	eventstore.Register(eventstore.Driver{
+		Name:    "null",
+		Schemes: []string{"null"},
+		New: func(uri string, opts eventstore.OpenOptions) (eventstore.Store, error) {
+			return NullStore{}, nil
+		},
+	})
+}
@@ -0,0 +1,48 @@
+package opensearch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/opensearch-project/opensearch-go/v4"
+	"github.com/opensearch-project/opensearch-go/v4/opensearchapi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageUnavailable(t *testing.T) {
+	ctx := context.Background()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error":"unavailable","status":503}`))
+	}))
+	defer failing.Close()
+
+	gone := httptest.NewServer(http.NotFoundHandler())
+	gone.Close()
+
+	id := "971b9489b4fd4e41a85951607922b982d981fa9d55318bc304f21f390721404c"
+	for _, url := range []string{failing.URL, gone.URL} {
+		client, err := opensearchapi.NewClient(opensearchapi.Config{
+			Client: opensearch.Config{Addresses: []string{url}, MaxRetries: 1},
+		})
+		require.NoError(t, err)
+		oss := &OpensearchStorage{IndexName: "events", client: client}
+
+		for _, filter := range []nostr.Filter{{Kinds: []int{1}}, {IDs: []string{id}}} {
+			_, err = oss.QueryEvents(ctx, filter)
+			require.ErrorIs(t, err, eventstore.ErrStorageUnavailable)
+
+			_, err = oss.CountEvents(ctx, filter)
+			require.ErrorIs(t, err, eventstore.ErrStorageUnavailable)
+		}
+
+		_, err = oss.HasEvents(ctx, []string{id})
+		require.ErrorIs(t, err, eventstore.ErrStorageUnavailable)
+	}
+}
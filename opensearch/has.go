@@ -0,0 +1,45 @@
+package opensearch
+
+import (
+	"context"
+
+	"github.com/opensearch-project/opensearch-go/v4/opensearchapi"
+	"github.com/opensearch-project/opensearch-go/v4/opensearchutil"
+)
+
+func (oss *OpensearchStorage) HasEvents(ctx context.Context, ids []string) ([]bool, error) {
+	ctx, release, err := oss.inflight.AcquireContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	results := make([]bool, len(ids))
+	if len(ids) == 0 {
+		return results, nil
+	}
+
+	mgetResponse, err := oss.client.MGet(
+		ctx,
+		opensearchapi.MGetReq{
+			Body:   opensearchutil.NewJSONReader(map[string]any{"ids": ids}),
+			Index:  oss.IndexName,
+			Params: opensearchapi.MGetParams{Source: false},
+		},
+	)
+	if err != nil {
+		return nil, wrapError(mgetResponse.Inspect(), err)
+	}
+
+	found := make(map[string]bool, len(mgetResponse.Docs))
+	for _, doc := range mgetResponse.Docs {
+		if doc.Found {
+			found[doc.ID] = true
+		}
+	}
+	for i, id := range ids {
+		results[i] = found[id]
+	}
+
+	return results, nil
+}
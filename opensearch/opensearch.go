@@ -15,6 +15,7 @@ import (
 
 	"github.com/fiatjaf/eventstore"
 	"github.com/fiatjaf/eventstore/internal"
+	"github.com/fiatjaf/eventstore/internal/inflight"
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/opensearch-project/opensearch-go/v4"
 	"github.com/opensearch-project/opensearch-go/v4/opensearchapi"
@@ -61,9 +62,24 @@ type OpensearchStorage struct {
 
 	client *opensearchapi.Client
 	bi     opensearchutil.BulkIndexer
+
+	inflight inflight.Tracker
 }
 
-func (oss *OpensearchStorage) Close() {}
+// Close is CloseContext with a timeout of inflight.CloseTimeout.
+func (oss *OpensearchStorage) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), inflight.CloseTimeout)
+	defer cancel()
+	if err := oss.CloseContext(ctx); err != nil {
+		log.Printf("opensearch: failed to close: %s\n", err)
+	}
+}
+
+// CloseContext flushes and closes the bulk indexer once the running operations are done.
+// See [eventstore.GracefulCloser].
+func (oss *OpensearchStorage) CloseContext(ctx context.Context) error {
+	return oss.inflight.Close(ctx, func() error { return oss.bi.Close(context.Background()) })
+}
 
 func (oss *OpensearchStorage) Init() error {
 	if oss.IndexName == "" {
@@ -125,8 +141,14 @@ func (oss *OpensearchStorage) Init() error {
 }
 
 func (oss *OpensearchStorage) DeleteEvent(ctx context.Context, evt *nostr.Event) error {
+	ctx, release, err := oss.inflight.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	done := make(chan error)
-	err := oss.bi.Add(
+	err = oss.bi.Add(
 		ctx,
 		opensearchutil.BulkIndexerItem{
 			Action:     "delete",
@@ -159,6 +181,12 @@ func (oss *OpensearchStorage) DeleteEvent(ctx context.Context, evt *nostr.Event)
 }
 
 func (oss *OpensearchStorage) ReplaceEvent(ctx context.Context, evt *nostr.Event) error {
+	ctx, release, err := oss.inflight.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	oss.Lock()
 	defer oss.Unlock()
 
@@ -193,6 +221,12 @@ func (oss *OpensearchStorage) ReplaceEvent(ctx context.Context, evt *nostr.Event
 }
 
 func (oss *OpensearchStorage) SaveEvent(ctx context.Context, evt *nostr.Event) error {
+	ctx, release, err := oss.inflight.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	ie := &IndexedEvent{
 		Event: *evt,
 	}
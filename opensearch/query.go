@@ -5,9 +5,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"reflect"
 
 	"github.com/aquasecurity/esquery"
+	"github.com/fiatjaf/eventstore"
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/opensearch-project/opensearch-go/v4/opensearchapi"
 	"github.com/opensearch-project/opensearch-go/v4/opensearchutil"
@@ -70,8 +70,7 @@ func buildDsl(filter nostr.Filter) ([]byte, error) {
 	return json.Marshal(esquery.Query(dsl))
 }
 
-func (oss *OpensearchStorage) getByID(filter nostr.Filter) ([]*nostr.Event, error) {
-	ctx := context.Background()
+func (oss *OpensearchStorage) getByID(ctx context.Context, filter nostr.Filter) ([]*nostr.Event, error) {
 	mgetResponse, err := oss.client.MGet(
 		ctx,
 		opensearchapi.MGetReq{
@@ -80,7 +79,7 @@ func (oss *OpensearchStorage) getByID(filter nostr.Filter) ([]*nostr.Event, erro
 		},
 	)
 	if err != nil {
-		return nil, err
+		return nil, wrapError(mgetResponse.Inspect(), err)
 	}
 
 	events := make([]*nostr.Event, 0, len(mgetResponse.Docs))
@@ -100,17 +99,35 @@ func (oss *OpensearchStorage) getByID(filter nostr.Filter) ([]*nostr.Event, erro
 	return events, nil
 }
 
-func (oss *OpensearchStorage) QueryEvents(ctx context.Context, filter nostr.Filter) (chan *nostr.Event, error) {
-	ch := make(chan *nostr.Event)
+func (oss *OpensearchStorage) QueryEvents(ctx context.Context, filter nostr.Filter) (ch chan *nostr.Event, err error) {
+	ctx, release, err := oss.inflight.AcquireContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// otherwise the goroutine below will release it
+		if err != nil {
+			release()
+		}
+	}()
+
+	ch = make(chan *nostr.Event)
 
 	// optimization: get by id
 	if isGetByID(filter) {
-		if evts, err := oss.getByID(filter); err == nil {
-			for _, evt := range evts {
-				ch <- evt
-			}
-			close(ch)
-			ch = nil
+		if evts, err := oss.getByID(ctx, filter); err == nil {
+			go func() {
+				defer release()
+				defer close(ch)
+				for _, evt := range evts {
+					select {
+					case ch <- evt:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			return ch, nil
 		} else {
 			return nil, fmt.Errorf("error getting by id: %w", err)
 		}
@@ -126,7 +143,6 @@ func (oss *OpensearchStorage) QueryEvents(ctx context.Context, filter nostr.Filt
 		limit = filter.Limit
 	}
 
-	ctx = context.Background()
 	searchResponse, err := oss.client.Search(
 		ctx,
 		&opensearchapi.SearchReq{
@@ -139,29 +155,40 @@ func (oss *OpensearchStorage) QueryEvents(ctx context.Context, filter nostr.Filt
 		},
 	)
 	if err != nil {
-		return nil, err
+		return nil, wrapError(searchResponse.Inspect(), err)
 	}
 
 	go func() {
+		defer release()
+		defer close(ch)
 		for _, e := range searchResponse.Hits.Hits {
 			if b, err := e.Source.MarshalJSON(); err == nil {
 				var payload struct {
 					Event nostr.Event `json:"event"`
 				}
 				if err = json.Unmarshal(b, &payload); err == nil {
-					ch <- &payload.Event
+					select {
+					case ch <- &payload.Event:
+					case <-ctx.Done():
+						return
+					}
 				}
 			}
 		}
-		if ch != nil {
-			close(ch)
-			ch = nil
-		}
 	}()
 
 	return ch, nil
 }
 
+// wrapError marks errors caused by opensearch being unreachable or failing on its side
+// as ErrStorageUnavailable.
+func wrapError(res opensearchapi.Inspect, err error) error {
+	if res.Response == nil || res.Response.StatusCode >= 500 {
+		return fmt.Errorf("%w: %w", eventstore.ErrStorageUnavailable, err)
+	}
+	return err
+}
+
 func isGetByID(filter nostr.Filter) bool {
 	isGetById := len(filter.IDs) > 0 &&
 		len(filter.Authors) == 0 &&
@@ -181,34 +208,31 @@ func isGetByID(filter nostr.Filter) bool {
 	return isGetById
 }
 
-// from: https://stackoverflow.com/a/12754757
-func toInterfaceSlice(slice interface{}) []interface{} {
-	s := reflect.ValueOf(slice)
-	if s.Kind() != reflect.Slice {
-		panic("InterfaceSlice() given a non-slice type")
-	}
-
-	// Keep the distinction between nil and empty slice input
-	if s.IsNil() {
+func toInterfaceSlice[T any](slice []T) []interface{} {
+	// keep the distinction between nil and empty slice input
+	if slice == nil {
 		return nil
 	}
 
-	ret := make([]interface{}, s.Len())
-
-	for i := 0; i < s.Len(); i++ {
-		ret[i] = s.Index(i).Interface()
+	ret := make([]interface{}, len(slice))
+	for i, v := range slice {
+		ret[i] = v
 	}
 
 	return ret
 }
 
 func (oss *OpensearchStorage) CountEvents(ctx context.Context, filter nostr.Filter) (int64, error) {
-	count := int64(0)
+	ctx, release, err := oss.inflight.AcquireContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
 
 	// optimization: get by id
 	if isGetByID(filter) {
-		if evts, err := oss.getByID(filter); err == nil {
-			count += int64(len(evts))
+		if evts, err := oss.getByID(ctx, filter); err == nil {
+			return int64(len(evts)), nil
 		} else {
 			return 0, fmt.Errorf("error getting by id: %w", err)
 		}
@@ -219,7 +243,6 @@ func (oss *OpensearchStorage) CountEvents(ctx context.Context, filter nostr.Filt
 		return 0, err
 	}
 
-	ctx = context.Background()
 	countRes, err := oss.client.Indices.Count(
 		ctx,
 		&opensearchapi.IndicesCountReq{
@@ -228,8 +251,8 @@ func (oss *OpensearchStorage) CountEvents(ctx context.Context, filter nostr.Filt
 		},
 	)
 	if err != nil {
-		return 0, err
+		return 0, wrapError(countRes.Inspect(), err)
 	}
 
-	return int64(countRes.Count) + count, nil
+	return int64(countRes.Count), nil
 }
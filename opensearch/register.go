@@ -0,0 +1,17 @@
+package opensearch
+
+import (
+	"strings"
+
+	"github.com/fiatjaf/eventstore"
+)
+
+func init() {
+	eventstore.Register(eventstore.Driver{
+		Name:    "opensearch",
+		Schemes: []string{"opensearch+https", "opensearch+http"},
+		New: func(uri string, opts eventstore.OpenOptions) (eventstore.Store, error) {
+			return &OpensearchStorage{URL: strings.TrimPrefix(uri, "opensearch+")}, nil
+		},
+	})
+}
@@ -0,0 +1,81 @@
+package opensearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/opensearch-project/opensearch-go/v4/opensearchapi"
+)
+
+var kindsAggregation = []byte(`{
+	"track_total_hits": true,
+	"aggs": {
+		"kinds": {"terms": {"field": "event.kind", "size": 10000}}
+	}
+}`)
+
+// Stats counts events per kind with a terms aggregation and reports the size of the primary
+// shards of the index.
+func (oss *OpensearchStorage) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	ctx, release, err := oss.inflight.AcquireContext(ctx)
+	if err != nil {
+		return eventstore.StoreStats{}, err
+	}
+	defer release()
+
+	stats := eventstore.StoreStats{
+		EventsPerKind: make(map[int]int64),
+		Indexes:       make(map[string]eventstore.IndexStats),
+	}
+
+	searchResponse, err := oss.client.Search(
+		ctx,
+		&opensearchapi.SearchReq{
+			Indices: []string{oss.IndexName},
+			Body:    bytes.NewReader(kindsAggregation),
+			Params: opensearchapi.SearchParams{
+				Size: opensearchapi.ToPointer(0),
+			},
+		},
+	)
+	if err != nil {
+		return stats, wrapError(searchResponse.Inspect(), err)
+	}
+	stats.Events = int64(searchResponse.Hits.Total.Value)
+
+	var aggs struct {
+		Kinds struct {
+			Buckets []struct {
+				Key      int   `json:"key"`
+				DocCount int64 `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"kinds"`
+	}
+	if err := json.Unmarshal(searchResponse.Aggregations, &aggs); err != nil {
+		return stats, err
+	}
+	for _, b := range aggs.Kinds.Buckets {
+		stats.EventsPerKind[b.Key] = b.DocCount
+	}
+
+	statsResponse, err := oss.client.Indices.Stats(
+		ctx,
+		&opensearchapi.IndicesStatsReq{
+			Indices: []string{oss.IndexName},
+			Metrics: []string{"docs", "store"},
+		},
+	)
+	if err != nil {
+		return stats, wrapError(statsResponse.Inspect(), err)
+	}
+	primaries := statsResponse.All.Primaries
+	stats.DiskSize = primaries.Store.SizeInBytes
+	stats.Indexes[oss.IndexName] = eventstore.IndexStats{
+		Entries: int64(primaries.Docs.Count),
+		Size:    primaries.Store.SizeInBytes,
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,145 @@
+package pebble
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/cockroachdb/pebble/v2"
+	"github.com/fiatjaf/eventstore"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip45/hyperloglog"
+)
+
+func (b *PebbleBackend) CountEvents(ctx context.Context, filter nostr.Filter) (int64, error) {
+	var count int64
+	err := b.view(func(r pebble.Reader) error {
+		var err error
+		count, err = b.count(r, filter)
+		return err
+	})
+	return count, err
+}
+
+// count is like CountEvents, but on a snapshot or batch we already have.
+func (b *PebbleBackend) count(r pebble.Reader, filter nostr.Filter) (int64, error) {
+	var count int64 = 0
+
+	queries, extraFilter, since, err := prepareQueries(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, q := range queries {
+		// when the index alone is enough we don't even have to look at the raw events
+		if extraFilter == nil && q.fullId == nil {
+			err = iterateIndex(r, q, since, func(idx []byte) error {
+				count++
+				return nil
+			})
+		} else {
+			err = iterateMatches(r, q, extraFilter, since, func(idx []byte, v bin.View) {
+				count++
+			})
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return count, nil
+}
+
+func (b *PebbleBackend) CountEventsHLL(ctx context.Context, filter nostr.Filter, offset int) (int64, *hyperloglog.HyperLogLog, error) {
+	var count int64 = 0
+
+	queries, extraFilter, since, err := prepareQueries(filter)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	hll := hyperloglog.New(offset)
+
+	err = b.view(func(r pebble.Reader) error {
+		for _, q := range queries {
+			if err := iterateMatches(r, q, extraFilter, since, func(idx []byte, v bin.View) {
+				hll.AddBytes(v.PubKey())
+				count++
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return count, hll, err
+}
+
+// iterateIndex calls fn with the raw event key of every entry of the query's index, newest first,
+// until since.
+func iterateIndex(r pebble.Reader, q query, since uint32, fn func(idx []byte) error) error {
+	it, err := r.NewIter(prefixBounds(q.prefix))
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	idx := make([]byte, 1+8)
+	idx[0] = rawEventStorePrefix
+	for it.SeekLT(q.startingPoint); it.Valid(); it.Prev() {
+		key := it.Key()
+
+		// keys of longer tag values that start with the one we want are also under the prefix
+		if len(key) != q.keySize() {
+			continue
+		}
+
+		idxOffset := len(key) - 8 // this is where the idx actually starts
+
+		// "id" indexes don't contain a timestamp
+		if !q.skipTimestamp {
+			createdAt := binary.BigEndian.Uint32(key[idxOffset-4 : idxOffset])
+			if createdAt < since {
+				break
+			}
+		}
+
+		copy(idx[1:], key[idxOffset:])
+		if err := fn(idx); err != nil {
+			return err
+		}
+	}
+
+	return it.Error()
+}
+
+// iterateMatches is like iterateIndex, but it reads the raw events and only calls fn with the
+// ones that really match the query and the parts of the filter that were not part of the index.
+// neither idx nor v can be used after fn returns.
+func iterateMatches(r pebble.Reader, q query, extraFilter *nostr.Filter, since uint32, fn func(idx []byte, v bin.View)) error {
+	return iterateIndex(r, q, since, func(idx []byte) error {
+		err := withValue(r, idx, func(val []byte) error {
+			v, err := bin.NewView(val)
+			if err != nil {
+				return fmt.Errorf("%w: event %x can't be read: %w", eventstore.ErrCorrupted, idx[1:], err)
+			}
+
+			// check if this is really the event we want, not only one with the same id prefix
+			if q.fullId != nil && !bytes.Equal(v.ID(), q.fullId) {
+				return nil
+			}
+
+			// check if this matches the other filters that were not part of the index
+			if extraFilter == nil || viewMatchesFilter(extraFilter, v) {
+				fn(idx, v)
+			}
+			return nil
+		})
+		if err == pebble.ErrNotFound {
+			return fmt.Errorf("%w: index points to missing event %x", eventstore.ErrCorrupted, idx[1:])
+		}
+		return err
+	})
+}
@@ -0,0 +1,47 @@
+package pebble
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagValuePrefixes(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/pebbletest-tagprefix"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db := &PebbleBackend{Path: path}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	// the keys of the longer values are under the same prefix as the ones of "foo"
+	sk := nostr.GeneratePrivateKey()
+	for i, value := range []string{"foo", "foobar", "foo", "fooo", "foo"} {
+		evt := &nostr.Event{CreatedAt: nostr.Timestamp(1000 + i), Kind: 1, Tags: nostr.Tags{{"t", value}}}
+		evt.Sign(sk)
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+
+	filter := nostr.Filter{Tags: nostr.TagMap{"t": []string{"foo"}}}
+	n, err := db.CountEvents(ctx, filter)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), n)
+
+	n, hll, err := db.CountEventsHLL(ctx, filter, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), n)
+	require.Equal(t, uint64(1), hll.Count())
+
+	res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, filter)
+	require.NoError(t, err)
+	require.Len(t, res, 3)
+	for _, evt := range res {
+		require.Equal(t, "foo", evt.Tags.GetFirst([]string{"t"}).Value())
+	}
+}
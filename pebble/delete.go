@@ -0,0 +1,135 @@
+package pebble
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"maps"
+	"slices"
+
+	"github.com/cockroachdb/pebble/v2"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func (b *PebbleBackend) DeleteEvent(ctx context.Context, evt *nostr.Event) error {
+	return b.updateEvent(evt, func(batch *pebble.Batch) error {
+		_, err := b.delete(batch, evt)
+		return err
+	})
+}
+
+func (b *PebbleBackend) delete(batch *pebble.Batch, evt *nostr.Event) (bool, error) {
+	// query event by id to get its idx
+	id, _ := hex.DecodeString(evt.ID)
+	idx, err := getIdxForId(batch, id)
+	if err != nil {
+		return false, err
+	}
+
+	// if no idx was found, end here, this event doesn't exist
+	if idx == nil {
+		return false, nil
+	}
+
+	// calculate all index keys we have for this event and delete them
+	for k := range b.getIndexKeysForEvent(evt, idx[1:]) {
+		if err := batch.Delete(k, nil); err != nil {
+			return false, err
+		}
+	}
+
+	// delete the raw event
+	return true, batch.Delete(idx, nil)
+}
+
+// DeleteEvents deletes every event that matches the filter, ignoring its limit, in a single batch,
+// and returns how many there were. Raw events with consecutive serials, as the ones saved
+// together usually are, are deleted with a single range deletion.
+func (b *PebbleBackend) DeleteEvents(ctx context.Context, filter nostr.Filter) (int64, error) {
+	if filter.Search != "" {
+		return 0, nil
+	}
+
+	queries, extraFilter, since, err := prepareQueries(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	err = b.update(func(batch *pebble.Batch) error {
+		// collect everything first, as the same event may come from more than one query
+		events := make(map[uint64]*nostr.Event)
+		for _, q := range queries {
+			if err := iterateMatches(batch, q, extraFilter, since, func(idx []byte, v bin.View) {
+				evt := &nostr.Event{}
+				v.Decode(evt)
+				events[binary.BigEndian.Uint64(idx[1:])] = evt
+			}); err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		serials := slices.Sorted(maps.Keys(events))
+		idx := make([]byte, 1+8)
+		idx[0] = rawEventStorePrefix
+		runStart := 0
+		for i, serial := range serials {
+			binary.BigEndian.PutUint64(idx[1:], serial)
+			for k := range b.getIndexKeysForEvent(events[serial], idx[1:]) {
+				if err := batch.Delete(k, nil); err != nil {
+					return err
+				}
+			}
+
+			// the raw events are deleted once we get to the end of each run of serials
+			if i+1 < len(serials) && serials[i+1] == serial+1 {
+				continue
+			}
+			if err := deleteRawRange(batch, serials[runStart], serial); err != nil {
+				return err
+			}
+			runStart = i + 1
+		}
+
+		deleted = int64(len(serials))
+		return nil
+	})
+
+	return deleted, err
+}
+
+// deleteRawRange deletes the raw events from serial first to serial last, inclusive.
+func deleteRawRange(batch *pebble.Batch, first uint64, last uint64) error {
+	start := binary.BigEndian.AppendUint64([]byte{rawEventStorePrefix}, first)
+	if first == last {
+		return batch.Delete(start, nil)
+	}
+
+	end := prefixEnd(binary.BigEndian.AppendUint64([]byte{rawEventStorePrefix}, last))
+	return batch.DeleteRange(start, end, nil)
+}
+
+// Purge deletes all the events and indexes with range deletions, which is much faster than
+// deleting events one by one, then compacts the database so the disk space is given back.
+func (b *PebbleBackend) Purge(ctx context.Context) error {
+	if err := b.update(func(batch *pebble.Batch) error {
+		for _, prefix := range append([]byte{rawEventStorePrefix}, indexPrefixes...) {
+			if err := batch.DeleteRange([]byte{prefix}, []byte{prefix + 1}, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := b.inflight.Acquire(); err != nil {
+		return err
+	}
+	defer b.inflight.Release()
+	return b.db.Compact(ctx, []byte{rawEventStorePrefix}, []byte{indexTagAddrPrefix + 1}, true)
+}
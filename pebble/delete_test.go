@@ -0,0 +1,70 @@
+package pebble
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteEventsAndPurge(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/pebbletest-delete"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db := &PebbleBackend{Path: path}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	// all in one batch, so the serials are consecutive, with the kind 1 events in runs of 3
+	sk := nostr.GeneratePrivateKey()
+	require.NoError(t, db.Update(ctx, func(tx eventstore.Tx) error {
+		for i := 0; i < 60; i++ {
+			evt := &nostr.Event{CreatedAt: nostr.Timestamp(1000 + i), Kind: 1 + i/3%2, Tags: nostr.Tags{{"t", "bulk"}}}
+			evt.Sign(sk)
+			if err := tx.Save(evt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	deleted, err := db.DeleteEvents(ctx, nostr.Filter{Kinds: []int{1}, Limit: 1})
+	require.NoError(t, err)
+	require.Equal(t, int64(30), deleted, "limit is ignored")
+
+	n, err := db.CountEvents(ctx, nostr.Filter{Tags: nostr.TagMap{"t": []string{"bulk"}}})
+	require.NoError(t, err)
+	require.Equal(t, int64(30), n)
+
+	res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Limit: 100})
+	require.NoError(t, err)
+	require.Len(t, res, 30)
+	for _, evt := range res {
+		require.Equal(t, 2, evt.Kind)
+	}
+
+	stats, err := db.Stats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(30), stats.Events)
+	require.Equal(t, map[int]int64{2: 30}, stats.EventsPerKind)
+
+	require.NoError(t, db.Purge(ctx))
+	stats, err = db.Stats(ctx)
+	require.NoError(t, err)
+	require.Zero(t, stats.Events)
+	require.Empty(t, stats.Indexes)
+	require.Equal(t, 1, stats.SchemaVersion)
+
+	// serials keep going after a purge
+	evt := &nostr.Event{CreatedAt: 3000, Kind: 1, Tags: nostr.Tags{}}
+	evt.Sign(sk)
+	require.NoError(t, db.SaveEvent(ctx, evt))
+	has, err := db.HasEvents(ctx, []string{evt.ID})
+	require.NoError(t, err)
+	require.Equal(t, []bool{true}, has)
+}
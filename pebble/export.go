@@ -0,0 +1,77 @@
+package pebble
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"iter"
+
+	"github.com/cockroachdb/pebble/v2"
+	"github.com/fiatjaf/eventstore"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+var _ eventstore.Exporter = (*PebbleBackend)(nil)
+
+// ExportEvents goes through the created_at index oldest first, from a single snapshot,
+// so what it yields is a point-in-time view of the database.
+func (b *PebbleBackend) ExportEvents(ctx context.Context, filter nostr.Filter) iter.Seq2[*nostr.Event, error] {
+	return func(yield func(*nostr.Event, error) bool) {
+		if filter.Search != "" {
+			return
+		}
+
+		var count int
+		err := b.view(func(r pebble.Reader) error {
+			it, err := r.NewIter(prefixBounds([]byte{indexCreatedAtPrefix}))
+			if err != nil {
+				return err
+			}
+			defer it.Close()
+
+			start := make([]byte, 1+4)
+			start[0] = indexCreatedAtPrefix
+			if filter.Since != nil {
+				binary.BigEndian.PutUint32(start[1:], uint32(*filter.Since))
+			}
+
+			idx := make([]byte, 1+8)
+			idx[0] = rawEventStorePrefix
+			for it.SeekGE(start); it.Valid(); it.Next() {
+				key := it.Key()
+				if filter.Until != nil && nostr.Timestamp(binary.BigEndian.Uint32(key[1:5])) > *filter.Until {
+					return nil
+				}
+				copy(idx[1:], key[5:])
+
+				evt := &nostr.Event{}
+				if err := withValue(r, idx, func(val []byte) error { return bin.Decode(val, evt) }); err == pebble.ErrNotFound {
+					return fmt.Errorf("%w: failed to get event %x from the created_at index", eventstore.ErrCorrupted, idx[1:])
+				} else if err != nil {
+					return fmt.Errorf("%w: event %x can't be decoded: %w", eventstore.ErrCorrupted, idx[1:], err)
+				}
+				if !filter.Matches(evt) {
+					continue
+				}
+
+				if !yield(evt, nil) {
+					return nil
+				}
+				count++
+				if count == filter.Limit {
+					return nil
+				}
+				if count%1000 == 0 {
+					if err := ctx.Err(); err != nil {
+						return err
+					}
+				}
+			}
+			return it.Error()
+		})
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package pebble
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/cockroachdb/pebble/v2"
+)
+
+func (b *PebbleBackend) HasEvents(ctx context.Context, ids []string) ([]bool, error) {
+	results := make([]bool, len(ids))
+
+	err := b.view(func(r pebble.Reader) error {
+		for i, idHex := range ids {
+			if len(idHex) != 64 {
+				continue
+			}
+			id, err := hex.DecodeString(idHex)
+			if err != nil {
+				continue
+			}
+
+			idx, err := getIdxForId(r, id)
+			if err != nil {
+				return fmt.Errorf("failed to get idx for %x: %w", id[0:8], err)
+			}
+			results[i] = idx != nil
+		}
+
+		return nil
+	})
+
+	return results, err
+}
@@ -0,0 +1,270 @@
+package pebble
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"iter"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/pebble/v2"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
+	"github.com/nbd-wtf/go-nostr"
+	"golang.org/x/exp/slices"
+)
+
+// indexPrefixes are all the indexes built from the raw events by getIndexKeysForEvent.
+var indexPrefixes = []byte{
+	indexIdPrefix,
+	indexCreatedAtPrefix,
+	indexKindPrefix,
+	indexPubkeyPrefix,
+	indexPubkeyKindPrefix,
+	indexTagPrefix,
+	indexTag32Prefix,
+	indexTagAddrPrefix,
+}
+
+// prefixBounds makes an iterator only see the keys starting with prefix.
+func prefixBounds(prefix []byte) *pebble.IterOptions {
+	return &pebble.IterOptions{LowerBound: prefix, UpperBound: prefixEnd(prefix)}
+}
+
+// prefixEnd is the first key after all the keys starting with prefix, or nil if there is none.
+func prefixEnd(prefix []byte) []byte {
+	end := bytes.Clone(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] != 0xff {
+			end[i]++
+			return end[0 : i+1]
+		}
+	}
+	return nil
+}
+
+// withValue calls fn with the value stored under key, which is only valid until fn returns.
+// pebble.ErrNotFound is returned when there is nothing there.
+func withValue(r pebble.Reader, key []byte, fn func(val []byte) error) error {
+	val, closer, err := r.Get(key)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	return fn(val)
+}
+
+func getTagIndexPrefix(tagName string, tagValue string) ([]byte, int) {
+	var k []byte   // the key with full length for created_at and idx at the end, but not filled with these
+	var offset int // the offset -- i.e. where the prefix ends and the created_at and idx would start
+
+	letterPrefix := byte(int(tagName[0]) % 256)
+
+	if kind, pkb, d := getAddrTagElements(tagValue); len(pkb) == 32 {
+		// store value in the special "a"-style tag index
+		k = make([]byte, 1+1+2+8+len(d)+4+8)
+		k[0] = indexTagAddrPrefix
+		k[1] = letterPrefix
+		binary.BigEndian.PutUint16(k[1+1:], kind)
+		copy(k[1+1+2:], pkb[0:8])
+		copy(k[1+1+2+8:], d)
+		offset = 1 + 1 + 2 + 8 + len(d)
+	} else if vb, _ := hex.DecodeString(tagValue); len(vb) == 32 {
+		// store value as bytes with tag name prefix
+		k = make([]byte, 1+1+8+4+8)
+		k[0] = indexTag32Prefix
+		k[1] = letterPrefix
+		copy(k[2:], vb[0:8])
+		offset = 1 + 1 + 8
+	} else {
+		// store whatever as utf-8 with tag name prefix
+		k = make([]byte, 1+1+len(tagValue)+4+8)
+		k[0] = indexTagPrefix
+		k[1] = letterPrefix
+		copy(k[2:], tagValue)
+		offset = 1 + 1 + len(tagValue)
+	}
+
+	return k, offset
+}
+
+func (b *PebbleBackend) getIndexKeysForEvent(evt *nostr.Event, idx []byte) iter.Seq[[]byte] {
+	return func(yield func([]byte) bool) {
+		{
+			// ~ by id
+			idPrefix8, _ := hex.DecodeString(evt.ID[0 : 8*2])
+			k := make([]byte, 1+8+8)
+			k[0] = indexIdPrefix
+			copy(k[1:], idPrefix8)
+			copy(k[1+8:], idx)
+			if !yield(k) {
+				return
+			}
+		}
+
+		{
+			// ~ by pubkey+date
+			pubkeyPrefix8, _ := hex.DecodeString(evt.PubKey[0 : 8*2])
+			k := make([]byte, 1+8+4+8)
+			k[0] = indexPubkeyPrefix
+			copy(k[1:], pubkeyPrefix8)
+			binary.BigEndian.PutUint32(k[1+8:], uint32(evt.CreatedAt))
+			copy(k[1+8+4:], idx)
+			if !yield(k) {
+				return
+			}
+		}
+
+		{
+			// ~ by kind+date
+			k := make([]byte, 1+4+4+8)
+			k[0] = indexKindPrefix
+			binary.BigEndian.PutUint32(k[1:], uint32(evt.Kind))
+			binary.BigEndian.PutUint32(k[1+4:], uint32(evt.CreatedAt))
+			copy(k[1+4+4:], idx)
+			if !yield(k) {
+				return
+			}
+		}
+
+		{
+			// ~ by pubkey+kind+date
+			pubkeyPrefix8, _ := hex.DecodeString(evt.PubKey[0 : 8*2])
+			k := make([]byte, 1+8+4+4+8)
+			k[0] = indexPubkeyKindPrefix
+			copy(k[1:], pubkeyPrefix8)
+			binary.BigEndian.PutUint32(k[1+8:], uint32(evt.Kind))
+			binary.BigEndian.PutUint32(k[1+8+4:], uint32(evt.CreatedAt))
+			copy(k[1+8+4+4:], idx)
+			if !yield(k) {
+				return
+			}
+		}
+
+		// ~ by tagvalue+date
+		customIndex := b.IndexLongerTag != nil
+		customSkip := b.SkipIndexingTag != nil
+
+		for i, tag := range evt.Tags {
+			if len(tag) < 2 || len(tag[0]) != 1 || len(tag[1]) == 0 || len(tag[1]) > 100 {
+				if len(tag) < 2 || len(tag[0]) == 0 || !customIndex || !b.IndexLongerTag(evt, tag[0], tag[1]) {
+					// not indexable
+					continue
+				}
+			}
+
+			firstIndex := slices.IndexFunc(evt.Tags, func(t nostr.Tag) bool {
+				return len(t) >= 2 && t[0] == tag[0] && t[1] == tag[1]
+			})
+			if firstIndex != i {
+				// duplicate
+				continue
+			}
+
+			if customSkip && b.SkipIndexingTag(evt, tag[0], tag[1]) {
+				// purposefully skipped
+				continue
+			}
+
+			// get key prefix (with full length) and offset where to write the last parts
+			k, offset := getTagIndexPrefix(tag[0], tag[1])
+
+			// write the last parts (created_at and idx)
+			binary.BigEndian.PutUint32(k[offset:], uint32(evt.CreatedAt))
+			copy(k[offset+4:], idx)
+			if !yield(k) {
+				return
+			}
+		}
+
+		{
+			// ~ by date only
+			k := make([]byte, 1+4+8)
+			k[0] = indexCreatedAtPrefix
+			binary.BigEndian.PutUint32(k[1:], uint32(evt.CreatedAt))
+			copy(k[1+4:], idx)
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+func getAddrTagElements(tagValue string) (kind uint16, pkb []byte, d string) {
+	spl := strings.Split(tagValue, ":")
+	if len(spl) == 3 {
+		if pkb, _ := hex.DecodeString(spl[1]); len(pkb) == 32 {
+			if kind, err := strconv.ParseUint(spl[0], 10, 16); err == nil {
+				return uint16(kind), pkb, spl[2]
+			}
+		}
+	}
+	return 0, nil, ""
+}
+
+// getIdxForId finds the raw event key of the event with exactly this id, or nil if we don't have it.
+// the id index only has the first 8 bytes of each id, so more than one event may be stored
+// under the same prefix and we have to check the full id on the raw events.
+func getIdxForId(r pebble.Reader, id []byte) ([]byte, error) {
+	prefix := make([]byte, 1+8)
+	prefix[0] = indexIdPrefix
+	copy(prefix[1:], id[0:8])
+
+	it, err := r.NewIter(prefixBounds(prefix))
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	for it.First(); it.Valid(); it.Next() {
+		idx := make([]byte, 1+8)
+		idx[0] = rawEventStorePrefix
+		copy(idx[1:], it.Key()[1+8:])
+
+		var matches bool
+		if err := withValue(r, idx, func(val []byte) error {
+			matches = len(val) >= bin.PubKeyOffset && bytes.Equal(val[bin.IDOffset:bin.PubKeyOffset], id)
+			return nil
+		}); err == pebble.ErrNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if matches {
+			return idx, nil
+		}
+	}
+
+	return nil, it.Error()
+}
+
+// viewMatchesFilter checks the parts of the filter that were not part of the index directly
+// against the raw event, so we only have to decode the events that pass.
+func viewMatchesFilter(ef *nostr.Filter, v bin.View) bool {
+	if ef.Kinds != nil && !slices.Contains(ef.Kinds, int(v.Kind())) {
+		return false
+	}
+
+	if ef.Authors != nil {
+		var pubkey [64]byte
+		hex.Encode(pubkey[:], v.PubKey())
+		found := false
+		for _, author := range ef.Authors {
+			if author == string(pubkey[:]) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for f, values := range ef.Tags {
+		if values != nil && !v.ContainsAnyTag(f, values) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,240 @@
+package pebble
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cockroachdb/pebble/v2"
+	"github.com/fiatjaf/eventstore"
+	"github.com/fiatjaf/eventstore/internal/inflight"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	dbVersionKey           byte = 255
+	migrationCheckpointKey byte = 254
+	rawEventStorePrefix    byte = 0
+	indexCreatedAtPrefix   byte = 1
+	indexIdPrefix          byte = 2
+	indexKindPrefix        byte = 3
+	indexPubkeyPrefix      byte = 4
+	indexPubkeyKindPrefix  byte = 5
+	indexTagPrefix         byte = 6
+	indexTag32Prefix       byte = 7
+	indexTagAddrPrefix     byte = 8
+)
+
+var _ eventstore.Store = (*PebbleBackend)(nil)
+
+// PebbleBackend uses the same key layout as the badger backend: raw events are stored under their
+// serial and every index is a key with a one-byte prefix, ending in that serial, with no value.
+// Writes go through batches, so many events can be committed at once with Update, and writes to
+// different events run concurrently, with pebble grouping their commits and syncs.
+type PebbleBackend struct {
+	Path                  string
+	MaxLimit              int
+	MaxLimitNegentropy    int
+	PebbleOptionsModifier func(*pebble.Options)
+
+	// NoSync skips the fsync after each commit, which is much faster but can lose the last
+	// writes if the machine crashes. Commits that happen at the same time share their fsync
+	// either way.
+	NoSync bool
+
+	// MigrationBatchSize is how many events each migration batch goes through before it
+	// commits and saves a checkpoint, defaults to 10000.
+	MigrationBatchSize int
+
+	// OnMigrationProgress, if set, is called by Init after every batch of every migration.
+	OnMigrationProgress func(eventstore.MigrationProgress)
+
+	// Experimental
+	SkipIndexingTag func(event *nostr.Event, tagName string, tagValue string) bool
+	// Experimental
+	IndexLongerTag func(event *nostr.Event, tagName string, tagValue string) bool
+
+	db       *pebble.DB
+	inflight inflight.Tracker
+
+	// pebble batches don't conflict with each other, so writes that look at what is stored
+	// before writing take locks to keep the duplicate and replaceable checks correct: writes of
+	// single events share writeLock and lock the stripes of their ids and replaceable addresses,
+	// while the ones that can touch any event take writeLock for themselves.
+	writeLock sync.RWMutex
+	keyLocks  [64]sync.Mutex
+
+	serial atomic.Uint64
+}
+
+func (b *PebbleBackend) Init() error {
+	opts := &pebble.Options{Logger: quietLogger{}}
+	if b.PebbleOptionsModifier != nil {
+		b.PebbleOptionsModifier(opts)
+	}
+
+	db, err := pebble.Open(b.Path, opts)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", b.Path, err)
+	}
+	b.db = db
+
+	if err := b.runMigrations(); err != nil {
+		b.db.Close()
+		return fmt.Errorf("error running migrations: %w", err)
+	}
+
+	if b.MaxLimit != 0 {
+		b.MaxLimitNegentropy = b.MaxLimit
+	} else {
+		b.MaxLimit = 1000
+		if b.MaxLimitNegentropy == 0 {
+			b.MaxLimitNegentropy = 16777216
+		}
+	}
+
+	it, err := b.db.NewIter(prefixBounds([]byte{rawEventStorePrefix}))
+	if err != nil {
+		b.db.Close()
+		return fmt.Errorf("error initializing serial: %w", err)
+	}
+	if it.Last() {
+		b.serial.Store(binary.BigEndian.Uint64(it.Key()[1:]))
+	}
+	if err := it.Close(); err != nil {
+		b.db.Close()
+		return fmt.Errorf("error initializing serial: %w", err)
+	}
+
+	return nil
+}
+
+// Close is CloseContext with a timeout of inflight.CloseTimeout.
+func (b *PebbleBackend) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), inflight.CloseTimeout)
+	defer cancel()
+	if err := b.CloseContext(ctx); err != nil {
+		log.Printf("[pebble] failed to close: %s\n", err)
+	}
+}
+
+// CloseContext closes the database once the running operations are done.
+// See [eventstore.GracefulCloser].
+func (b *PebbleBackend) CloseContext(ctx context.Context) error {
+	return b.inflight.Close(ctx, b.db.Close)
+}
+
+// view runs fn on a snapshot of the database, so everything it reads is consistent.
+func (b *PebbleBackend) view(fn func(r pebble.Reader) error) error {
+	if err := b.inflight.Acquire(); err != nil {
+		return err
+	}
+	defer b.inflight.Release()
+
+	snapshot := b.db.NewSnapshot()
+	defer snapshot.Close()
+	return fn(snapshot)
+}
+
+// update runs fn with an indexed batch, so it can read its own writes, and commits it if fn
+// doesn't fail. No other write runs at the same time.
+func (b *PebbleBackend) update(fn func(batch *pebble.Batch) error) error {
+	return b.commit(nil, fn)
+}
+
+// updateEvent is like update, but only keeps out the writes that touch the same event, or another
+// version of it if it is replaceable, so the rest can be committed concurrently.
+func (b *PebbleBackend) updateEvent(evt *nostr.Event, fn func(batch *pebble.Batch) error) error {
+	keys := []string{evt.ID}
+	if nostr.IsReplaceableKind(evt.Kind) || nostr.IsAddressableKind(evt.Kind) {
+		keys = append(keys, fmt.Sprintf("%d:%s:%s", evt.Kind, evt.PubKey, evt.Tags.GetD()))
+	}
+	return b.commit(keys, fn)
+}
+
+func (b *PebbleBackend) commit(keys []string, fn func(batch *pebble.Batch) error) error {
+	if err := b.inflight.Acquire(); err != nil {
+		return err
+	}
+	defer b.inflight.Release()
+
+	if keys == nil {
+		b.writeLock.Lock()
+		defer b.writeLock.Unlock()
+	} else {
+		b.writeLock.RLock()
+		defer b.writeLock.RUnlock()
+
+		// always in the same order, so two writes can't wait for each other
+		stripes := make([]int, len(keys))
+		for i, key := range keys {
+			h := fnv.New32a()
+			h.Write([]byte(key))
+			stripes[i] = int(h.Sum32() % uint32(len(b.keyLocks)))
+		}
+		slices.Sort(stripes)
+		for _, stripe := range slices.Compact(stripes) {
+			b.keyLocks[stripe].Lock()
+			defer b.keyLocks[stripe].Unlock()
+		}
+	}
+
+	batch := b.db.NewIndexedBatch()
+	defer batch.Close()
+
+	// serials taken by a batch that isn't committed are just skipped
+	if err := fn(batch); err != nil {
+		return err
+	}
+	if batch.Empty() {
+		return nil
+	}
+	return batch.Commit(b.writeOptions())
+}
+
+func (b *PebbleBackend) writeOptions() *pebble.WriteOptions {
+	if b.NoSync {
+		return pebble.NoSync
+	}
+	return pebble.Sync
+}
+
+// Serial returns the key for the next raw event, it fails instead of wrapping around when
+// all the serials have been used.
+func (b *PebbleBackend) Serial() ([]byte, error) {
+	for {
+		last := b.serial.Load()
+		if last == math.MaxUint64 {
+			return nil, eventstore.ErrSerialOverflow
+		}
+		if b.serial.CompareAndSwap(last, last+1) {
+			vb := make([]byte, 1+8)
+			vb[0] = rawEventStorePrefix
+			binary.BigEndian.PutUint64(vb[1:], last+1)
+			return vb, nil
+		}
+	}
+}
+
+// quietLogger drops the informational messages pebble prints every time it is opened.
+type quietLogger struct{}
+
+func (quietLogger) Infof(format string, args ...any) {}
+
+func (quietLogger) Errorf(format string, args ...any) {
+	log.Printf("[pebble] "+format+"\n", args...)
+}
+
+// Fatalf logs the error and panics, instead of calling os.Exit. The error is still fatal, since
+// pebble also calls it from its background flushes and compactions, where nothing recovers.
+func (quietLogger) Fatalf(format string, args ...any) {
+	msg := fmt.Sprintf("[pebble] "+format, args...)
+	log.Println(msg)
+	panic(msg)
+}
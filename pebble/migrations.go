@@ -0,0 +1,273 @@
+package pebble
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/cockroachdb/pebble/v2"
+	"github.com/fiatjaf/eventstore"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+type migration struct {
+	version     uint16
+	description string
+	steps       []migrationStep
+}
+
+// migrationStep either has a run function, called once in a batch of its own, or a forEach
+// function, called for every raw event in batches of MigrationBatchSize events. run must be
+// safe to call again, as it will be if the process stops before the step is marked as done.
+type migrationStep struct {
+	run     func(batch *pebble.Batch) error
+	forEach func(batch *pebble.Batch, idx []byte, val []byte) error
+}
+
+// checkpoint is saved along with each batch of a migration, so it can resume from there.
+type checkpoint struct {
+	version uint16
+	step    uint8
+	done    uint64
+	last    []byte
+}
+
+// migrations are run in order on databases with a lower version, new databases go through all of
+// them too, which is cheap when there are no events yet.
+func (b *PebbleBackend) migrations() []migration {
+	return []migration{
+		{1, "build all indexes", b.reindexSteps()},
+	}
+}
+
+// runMigrations brings the database to the latest version, resuming an interrupted migration
+// from its last checkpoint.
+func (b *PebbleBackend) runMigrations() error {
+	if b.MigrationBatchSize == 0 {
+		b.MigrationBatchSize = 10000
+	}
+
+	var version uint16
+	if err := withValue(b.db, []byte{dbVersionKey}, func(val []byte) error {
+		version = binary.BigEndian.Uint16(val)
+		return nil
+	}); err != nil && err != pebble.ErrNotFound {
+		return err
+	}
+
+	var cp checkpoint
+	if err := withValue(b.db, []byte{migrationCheckpointKey}, func(val []byte) error {
+		if len(val) < 2+1+8 {
+			return fmt.Errorf("%w: migration checkpoint is too short", eventstore.ErrCorrupted)
+		}
+		cp.version = binary.BigEndian.Uint16(val[0:2])
+		cp.step = val[2]
+		cp.done = binary.BigEndian.Uint64(val[3:11])
+		if len(val) > 11 {
+			cp.last = bytes.Clone(val[11:])
+		}
+		return nil
+	}); err != nil && err != pebble.ErrNotFound {
+		return err
+	}
+
+	// do the migrations in increasing steps (there is no rollback)
+	for _, m := range b.migrations() {
+		if m.version <= version {
+			continue
+		}
+
+		start := checkpoint{version: m.version}
+		if cp.version == m.version {
+			start = cp
+			log.Printf("[pebble] migration %d: %s (resuming from step %d, after %d events)\n",
+				m.version, m.description, cp.step+1, cp.done)
+		} else {
+			log.Printf("[pebble] migration %d: %s\n", m.version, m.description)
+		}
+
+		for s := int(start.step); s < len(m.steps); s++ {
+			step := m.steps[s]
+			if step.forEach != nil {
+				if err := b.migrateEvents(m, s, start); err != nil {
+					return fmt.Errorf("migration %d failed: %w", m.version, err)
+				}
+			} else {
+				batch := b.db.NewBatch()
+				err := step.run(batch)
+				if err == nil {
+					err = setCheckpoint(batch, checkpoint{version: m.version, step: uint8(s + 1)})
+				}
+				if err == nil {
+					err = batch.Commit(pebble.Sync)
+				}
+				batch.Close()
+				if err != nil {
+					return fmt.Errorf("migration %d failed: %w", m.version, err)
+				}
+				b.reportMigrationProgress(m, s, 0, 0)
+			}
+			start = checkpoint{version: m.version}
+		}
+
+		// bump version
+		batch := b.db.NewBatch()
+		err := batch.Delete([]byte{migrationCheckpointKey}, nil)
+		if err == nil {
+			err = setVersion(batch, m.version)
+		}
+		if err == nil {
+			err = batch.Commit(pebble.Sync)
+		}
+		batch.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateEvents calls the forEach function of a step for every raw event after the checkpoint,
+// committing and saving a new checkpoint every MigrationBatchSize events.
+func (b *PebbleBackend) migrateEvents(m migration, s int, from checkpoint) error {
+	bounds := prefixBounds([]byte{rawEventStorePrefix})
+
+	var total int64
+	it, err := b.db.NewIter(bounds)
+	if err != nil {
+		return err
+	}
+	for it.First(); it.Valid(); it.Next() {
+		total++
+	}
+	if err := it.Close(); err != nil {
+		return err
+	}
+
+	next := from
+	for {
+		// the batch is read before anything is written, so forEach can't change what we see
+		type entry struct{ idx, val []byte }
+		entries := make([]entry, 0, b.MigrationBatchSize)
+
+		it, err := b.db.NewIter(bounds)
+		if err != nil {
+			return err
+		}
+		if next.last == nil {
+			it.First()
+		} else if it.SeekGE(next.last) && bytes.Equal(it.Key(), next.last) {
+			it.Next()
+		}
+		for ; it.Valid() && len(entries) < b.MigrationBatchSize; it.Next() {
+			val, err := it.ValueAndErr()
+			if err != nil {
+				it.Close()
+				return fmt.Errorf("failed to read event %x: %w", it.Key(), err)
+			}
+			entries = append(entries, entry{bytes.Clone(it.Key()), bytes.Clone(val)})
+		}
+		finished := !it.Valid()
+		if err := it.Close(); err != nil {
+			return err
+		}
+
+		batch := b.db.NewBatch()
+		cp := next
+		err = func() error {
+			for _, e := range entries {
+				if err := m.steps[s].forEach(batch, e.idx, e.val); err != nil {
+					return fmt.Errorf("event %x: %w", e.idx, err)
+				}
+				cp.done++
+				cp.last = e.idx
+			}
+
+			if finished {
+				// went through all the events
+				return setCheckpoint(batch, checkpoint{version: m.version, step: uint8(s + 1)})
+			}
+			return setCheckpoint(batch, cp)
+		}()
+		if err == nil {
+			err = batch.Commit(pebble.Sync)
+		}
+		batch.Close()
+		if err != nil {
+			return err
+		}
+
+		next = cp
+		b.reportMigrationProgress(m, s, int64(next.done), max(total, int64(next.done)))
+		if finished {
+			return nil
+		}
+	}
+}
+
+func (b *PebbleBackend) reportMigrationProgress(m migration, s int, done int64, total int64) {
+	if b.OnMigrationProgress != nil {
+		b.OnMigrationProgress(eventstore.MigrationProgress{
+			Version:     int(m.version),
+			Description: m.description,
+			Step:        s + 1,
+			Steps:       len(m.steps),
+			Done:        done,
+			Total:       total,
+		})
+	}
+}
+
+func setCheckpoint(batch *pebble.Batch, cp checkpoint) error {
+	buf := make([]byte, 2+1+8, 2+1+8+len(cp.last))
+	binary.BigEndian.PutUint16(buf[0:2], cp.version)
+	buf[2] = cp.step
+	binary.BigEndian.PutUint64(buf[3:11], cp.done)
+	buf = append(buf, cp.last...)
+	return batch.Set([]byte{migrationCheckpointKey}, buf, nil)
+}
+
+// reindexSteps deletes the entries of the indexes with the given prefixes (or of all of them if none
+// is given), each with a single range deletion, and recreates them from the raw events.
+func (b *PebbleBackend) reindexSteps(prefixes ...byte) []migrationStep {
+	if len(prefixes) == 0 {
+		prefixes = indexPrefixes
+	}
+
+	return []migrationStep{
+		{run: func(batch *pebble.Batch) error {
+			for _, prefix := range prefixes {
+				if err := batch.DeleteRange([]byte{prefix}, []byte{prefix + 1}, nil); err != nil {
+					return fmt.Errorf("failed to delete index %d: %w", prefix, err)
+				}
+			}
+			return nil
+		}},
+		{forEach: func(batch *pebble.Batch, idx []byte, val []byte) error {
+			evt := &nostr.Event{}
+			if err := bin.Decode(val, evt); err != nil {
+				return fmt.Errorf("error decoding event: %w", err)
+			}
+
+			for key := range b.getIndexKeysForEvent(evt, idx[1:]) {
+				if !slices.Contains(prefixes, key[0]) {
+					continue
+				}
+				if err := batch.Set(key, nil, nil); err != nil {
+					return fmt.Errorf("failed to save index for event %s: %w", evt.ID, err)
+				}
+			}
+			return nil
+		}},
+	}
+}
+
+func setVersion(batch *pebble.Batch, version uint16) error {
+	buf := make([]byte, 2)
+	binary.BigEndian.PutUint16(buf, version)
+	return batch.Set([]byte{dbVersionKey}, buf, nil)
+}
@@ -0,0 +1,22 @@
+package pebble
+
+import (
+	"path/filepath"
+
+	"github.com/fiatjaf/eventstore"
+)
+
+func init() {
+	eventstore.Register(eventstore.Driver{
+		Name:    "pebble",
+		Schemes: []string{"pebble"},
+		Detect: func(path string) bool {
+			// badger has a MANIFEST too, but never an OPTIONS file
+			matches, _ := filepath.Glob(filepath.Join(path, "OPTIONS-*"))
+			return len(matches) > 0
+		},
+		New: func(uri string, opts eventstore.OpenOptions) (eventstore.Store, error) {
+			return &PebbleBackend{Path: eventstore.TrimScheme(uri, "pebble"), MaxLimit: opts.MaxLimit}, nil
+		},
+	})
+}
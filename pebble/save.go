@@ -0,0 +1,63 @@
+package pebble
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math"
+
+	"github.com/cockroachdb/pebble/v2"
+	"github.com/fiatjaf/eventstore"
+	bin "github.com/fiatjaf/eventstore/internal/binary"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func (b *PebbleBackend) SaveEvent(ctx context.Context, evt *nostr.Event) error {
+	// sanity checking
+	if evt.CreatedAt > math.MaxUint32 || evt.Kind < 0 || evt.Kind > math.MaxUint32 {
+		return fmt.Errorf("event with values out of expected boundaries")
+	}
+
+	return b.updateEvent(evt, func(batch *pebble.Batch) error {
+		return b.saveEvent(batch, evt)
+	})
+}
+
+// saveEvent does everything SaveEvent does except the sanity checks, inside a transaction we already have.
+func (b *PebbleBackend) saveEvent(batch *pebble.Batch, evt *nostr.Event) error {
+	// query event by id to ensure we don't save duplicates
+	id, _ := hex.DecodeString(evt.ID)
+	if idx, err := getIdxForId(batch, id); err != nil {
+		return fmt.Errorf("failed to check for duplicates: %w", err)
+	} else if idx != nil {
+		// event exists
+		return eventstore.ErrDupEvent
+	}
+
+	return b.save(batch, evt)
+}
+
+func (b *PebbleBackend) save(batch *pebble.Batch, evt *nostr.Event) error {
+	// encode to binary
+	bin, err := bin.Encode(evt)
+	if err != nil {
+		return err
+	}
+
+	idx, err := b.Serial()
+	if err != nil {
+		return err
+	}
+	// raw event store
+	if err := batch.Set(idx, bin, nil); err != nil {
+		return err
+	}
+
+	for k := range b.getIndexKeysForEvent(evt, idx[1:]) {
+		if err := batch.Set(k, nil, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package pebble
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	path := "/tmp/pebbletest-concurrent"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db := &PebbleBackend{Path: path}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	sk := nostr.GeneratePrivateKey()
+	dup := &nostr.Event{CreatedAt: 1, Kind: 1, Tags: nostr.Tags{}, Content: "dup"}
+	dup.Sign(sk)
+
+	var wg sync.WaitGroup
+	var saved atomic.Int64
+	for i := 0; i < 50; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if err := db.SaveEvent(ctx, dup); err == nil {
+				saved.Add(1)
+			} else if !errors.Is(err, eventstore.ErrDupEvent) {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			evt := &nostr.Event{CreatedAt: nostr.Timestamp(100 + i), Kind: 1, Tags: nostr.Tags{}}
+			evt.Sign(sk)
+			if err := db.SaveEvent(ctx, evt); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			evt := &nostr.Event{CreatedAt: nostr.Timestamp(100 + i), Kind: 30000, Tags: nostr.Tags{{"d", "x"}}}
+			evt.Sign(sk)
+			if err := db.ReplaceEvent(ctx, evt); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int64(1), saved.Load(), "only one of the duplicates should be saved")
+	n, err := db.CountEvents(ctx, nostr.Filter{Kinds: []int{1}})
+	require.NoError(t, err)
+	require.Equal(t, int64(51), n)
+
+	res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Kinds: []int{30000}})
+	require.NoError(t, err)
+	require.Len(t, res, 1, "only the newest version should be left")
+	require.Equal(t, nostr.Timestamp(149), res[0].CreatedAt)
+}
@@ -0,0 +1,89 @@
+package pebble
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/cockroachdb/pebble/v2"
+	"github.com/fiatjaf/eventstore"
+	"github.com/fiatjaf/eventstore/internal"
+)
+
+var prefixNames = map[byte]string{
+	rawEventStorePrefix:   "raw",
+	indexCreatedAtPrefix:  "created_at",
+	indexIdPrefix:         "id",
+	indexKindPrefix:       "kind",
+	indexPubkeyPrefix:     "pubkey",
+	indexPubkeyKindPrefix: "pubkeyKind",
+	indexTagPrefix:        "tag",
+	indexTag32Prefix:      "tag32",
+	indexTagAddrPrefix:    "tagAddr",
+}
+
+// Stats walks over every key in the database, so it takes longer as the database grows.
+// Index sizes are the space taken by keys and values before compression.
+func (b *PebbleBackend) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	stats := eventstore.StoreStats{
+		EventsPerKind: make(map[int]int64),
+		Indexes:       make(map[string]eventstore.IndexStats),
+	}
+
+	err := b.view(func(r pebble.Reader) error {
+		if err := withValue(r, []byte{dbVersionKey}, func(val []byte) error {
+			stats.SchemaVersion = int(binary.BigEndian.Uint16(val))
+			return nil
+		}); err != nil && err != pebble.ErrNotFound {
+			return err
+		}
+
+		it, err := r.NewIter(nil)
+		if err != nil {
+			return err
+		}
+		defer it.Close()
+
+		i := 0
+		for it.First(); it.Valid(); it.Next() {
+			if i++; i%10000 == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+
+			key := it.Key()
+			name, ok := prefixNames[key[0]]
+			if !ok {
+				continue
+			}
+
+			val := it.LazyValue()
+			s := stats.Indexes[name]
+			s.Entries++
+			s.Size += int64(len(key)) + int64(val.Len())
+			stats.Indexes[name] = s
+
+			switch key[0] {
+			case rawEventStorePrefix:
+				stats.Events++
+			case indexKindPrefix:
+				stats.EventsPerKind[int(binary.BigEndian.Uint32(key[1:5]))]++
+			}
+		}
+
+		return it.Error()
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return stats, err
+		}
+		return stats, fmt.Errorf("%w: %w", eventstore.ErrStorageUnavailable, err)
+	}
+
+	if stats.DiskSize, err = internal.DirSize(b.Path); err != nil {
+		return stats, fmt.Errorf("failed to get the size of %s: %w", b.Path, err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,62 @@
+package pebble
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/cockroachdb/pebble/v2"
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+var _ eventstore.Transactor = (*PebbleBackend)(nil)
+
+// Update runs fn with a single batch, so everything it saves and deletes is committed at once.
+func (b *PebbleBackend) Update(ctx context.Context, fn func(eventstore.Tx) error) error {
+	return b.update(func(batch *pebble.Batch) error {
+		return fn(&tx{b: b, ctx: ctx, batch: batch})
+	})
+}
+
+type tx struct {
+	b     *PebbleBackend
+	ctx   context.Context
+	batch *pebble.Batch
+}
+
+func (t *tx) Query(filter nostr.Filter) ([]*nostr.Event, error) {
+	limit := t.b.getLimit(t.ctx, filter)
+	if limit == 0 {
+		return nil, nil
+	}
+
+	results, err := t.b.query(t.batch, filter, limit, false)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*nostr.Event, len(results))
+	for i, ie := range results {
+		events[i] = ie.Event
+	}
+	return events, nil
+}
+
+func (t *tx) Count(filter nostr.Filter) (int64, error) {
+	return t.b.count(t.batch, filter)
+}
+
+func (t *tx) Save(evt *nostr.Event) error {
+	// sanity checking
+	if evt.CreatedAt > math.MaxUint32 || evt.Kind < 0 || evt.Kind > math.MaxUint32 {
+		return fmt.Errorf("event with values out of expected boundaries")
+	}
+
+	return t.b.saveEvent(t.batch, evt)
+}
+
+func (t *tx) Delete(evt *nostr.Event) error {
+	_, err := t.b.delete(t.batch, evt)
+	return err
+}
@@ -44,7 +44,7 @@ CREATE TABLE IF NOT EXISTS event (
   id text NOT NULL,
   pubkey text NOT NULL,
   created_at integer NOT NULL,
-  kind integer NOT NULL,
+  kind bigint NOT NULL,
   tags jsonb NOT NULL,
   content text NOT NULL,
   sig text NOT NULL,
@@ -52,6 +52,13 @@ CREATE TABLE IF NOT EXISTS event (
   tagvalues text[] GENERATED ALWAYS AS (tags_to_tagvalues(tags)) STORED
 );
 
+-- kinds used to be integer, which doesn't fit all of them
+DO $$ BEGIN
+  IF (SELECT data_type FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = 'event' AND column_name = 'kind') = 'integer' THEN
+    ALTER TABLE event ALTER COLUMN kind TYPE bigint;
+  END IF;
+END $$;
+
 CREATE UNIQUE INDEX IF NOT EXISTS ididx ON event USING btree (id text_pattern_ops);
 CREATE INDEX IF NOT EXISTS pubkeyprefix ON event USING btree (pubkey text_pattern_ops);
 CREATE INDEX IF NOT EXISTS timeidx ON event (created_at DESC);
@@ -1,8 +1,11 @@
 package postgresql
 
 import (
+	"context"
+	"log"
 	"sync"
 
+	"github.com/fiatjaf/eventstore/internal/inflight"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -19,8 +22,21 @@ type PostgresBackend struct {
 	FullTextSearchConfig     string // text search configuration for to_tsvector/to_tsquery, defaults to "simple"
 	FullTextSearchMaxLength  int    // maximum content length for full-text search, 0 means no limit
 	FullTextSearchColumn     string // column to search in, defaults to "content"
+
+	inflight inflight.Tracker
 }
 
+// Close is CloseContext with a timeout of inflight.CloseTimeout.
 func (b *PostgresBackend) Close() {
-	b.DB.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), inflight.CloseTimeout)
+	defer cancel()
+	if err := b.CloseContext(ctx); err != nil {
+		log.Printf("postgresql: failed to close: %s\n", err)
+	}
+}
+
+// CloseContext closes the database once the running operations are done.
+// See [eventstore.GracefulCloser].
+func (b *PostgresBackend) CloseContext(ctx context.Context) error {
+	return b.inflight.Close(ctx, b.DB.Close)
 }
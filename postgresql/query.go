@@ -5,13 +5,26 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"iter"
 	"strings"
 
+	"github.com/fiatjaf/eventstore"
 	"github.com/jmoiron/sqlx"
 	"github.com/nbd-wtf/go-nostr"
 )
 
 func (b *PostgresBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (ch chan *nostr.Event, err error) {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// otherwise the goroutine below will release it
+		if err != nil {
+			done()
+		}
+	}()
+
 	query, params, err := b.queryEventsSql(filter, false)
 	if err != nil {
 		return nil, err
@@ -24,6 +37,7 @@ func (b *PostgresBackend) QueryEvents(ctx context.Context, filter nostr.Filter)
 
 	ch = make(chan *nostr.Event)
 	go func() {
+		defer done()
 		defer rows.Close()
 		defer close(ch)
 		for rows.Next() {
@@ -47,6 +61,12 @@ func (b *PostgresBackend) QueryEvents(ctx context.Context, filter nostr.Filter)
 }
 
 func (b *PostgresBackend) CountEvents(ctx context.Context, filter nostr.Filter) (int64, error) {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
 	query, params, err := b.queryEventsSql(filter, true)
 	if err != nil {
 		return 0, err
@@ -59,6 +79,46 @@ func (b *PostgresBackend) CountEvents(ctx context.Context, filter nostr.Filter)
 	return count, nil
 }
 
+// QueryIDs is like QueryEvents, but only selects the id and created_at columns.
+func (b *PostgresBackend) QueryIDs(ctx context.Context, filter nostr.Filter) iter.Seq2[eventstore.IDTimestamp, error] {
+	return func(yield func(eventstore.IDTimestamp, error) bool) {
+		ctx, done, err := b.inflight.AcquireContext(ctx)
+		if err != nil {
+			yield(eventstore.IDTimestamp{}, err)
+			return
+		}
+		defer done()
+
+		query, params, err := b.queryIDsSql(filter)
+		if err != nil {
+			yield(eventstore.IDTimestamp{}, err)
+			return
+		}
+
+		rows, err := b.DB.QueryContext(ctx, query, params...)
+		if err != nil {
+			yield(eventstore.IDTimestamp{}, fmt.Errorf("failed to fetch ids using query %q: %w", query, err))
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id string
+			var timestamp int64
+			if err := rows.Scan(&id, &timestamp); err != nil {
+				yield(eventstore.IDTimestamp{}, err)
+				return
+			}
+			if !yield(eventstore.IDTimestamp{ID: id, CreatedAt: nostr.Timestamp(timestamp)}, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(eventstore.IDTimestamp{}, err)
+		}
+	}
+}
+
 func makePlaceHolders(n int) string {
 	return strings.TrimRight(strings.Repeat("?,", n), ",")
 }
@@ -72,13 +132,25 @@ var (
 )
 
 func (b *PostgresBackend) queryEventsSql(filter nostr.Filter, doCount bool) (string, []any, error) {
+	if doCount {
+		return b.buildQuerySql(filter, "COUNT(*)", false)
+	}
+	return b.buildQuerySql(filter, "id, pubkey, created_at, kind, tags, content, sig", true)
+}
+
+func (b *PostgresBackend) queryIDsSql(filter nostr.Filter) (string, []any, error) {
+	return b.buildQuerySql(filter, "id, created_at", true)
+}
+
+// filterConditions translates filter into SQL conditions, without the limit.
+func (b *PostgresBackend) filterConditions(filter nostr.Filter) ([]string, []any, error) {
 	conditions := make([]string, 0, 7)
 	params := make([]any, 0, 20)
 
 	if len(filter.IDs) > 0 {
 		if len(filter.IDs) > b.QueryIDsLimit {
 			// too many ids, fail everything
-			return "", nil, TooManyIDs
+			return nil, nil, TooManyIDs
 		}
 
 		for _, v := range filter.IDs {
@@ -90,7 +162,7 @@ func (b *PostgresBackend) queryEventsSql(filter nostr.Filter, doCount bool) (str
 	if len(filter.Authors) > 0 {
 		if len(filter.Authors) > b.QueryAuthorsLimit {
 			// too many authors, fail everything
-			return "", nil, TooManyAuthors
+			return nil, nil, TooManyAuthors
 		}
 
 		for _, v := range filter.Authors {
@@ -102,7 +174,7 @@ func (b *PostgresBackend) queryEventsSql(filter nostr.Filter, doCount bool) (str
 	if len(filter.Kinds) > 0 {
 		if len(filter.Kinds) > b.QueryKindsLimit {
 			// too many kinds, fail everything
-			return "", nil, TooManyKinds
+			return nil, nil, TooManyKinds
 		}
 
 		for _, v := range filter.Kinds {
@@ -115,13 +187,13 @@ func (b *PostgresBackend) queryEventsSql(filter nostr.Filter, doCount bool) (str
 	for tagKey, values := range filter.Tags {
 		if len(values) == 0 {
 			// any tag set to [] is wrong
-			return "", nil, EmptyTagSet
+			return nil, nil, EmptyTagSet
 		}
 
 		totalTags += len(values)
 		if totalTags > b.QueryTagsLimit {
 			// too many tags, fail everything
-			return "", nil, TooManyTagValues
+			return nil, nil, TooManyTagValues
 		}
 
 		for _, tagValue := range values {
@@ -166,6 +238,15 @@ func (b *PostgresBackend) queryEventsSql(filter nostr.Filter, doCount bool) (str
 		conditions = append(conditions, `true`)
 	}
 
+	return conditions, params, nil
+}
+
+func (b *PostgresBackend) buildQuerySql(filter nostr.Filter, columns string, ordered bool) (string, []any, error) {
+	conditions, params, err := b.filterConditions(filter)
+	if err != nil {
+		return "", nil, err
+	}
+
 	if filter.Limit < 1 || filter.Limit > b.QueryLimit {
 		params = append(params, b.QueryLimit)
 	} else {
@@ -173,18 +254,18 @@ func (b *PostgresBackend) queryEventsSql(filter nostr.Filter, doCount bool) (str
 	}
 
 	var query string
-	if doCount {
+	if ordered {
 		query = sqlx.Rebind(sqlx.BindType("postgres"), `SELECT
-          COUNT(*)
+          `+columns+`
         FROM event WHERE `+
 			strings.Join(conditions, " AND ")+
-			" LIMIT ?")
+			" ORDER BY created_at DESC, id LIMIT ?")
 	} else {
 		query = sqlx.Rebind(sqlx.BindType("postgres"), `SELECT
-          id, pubkey, created_at, kind, tags, content, sig
+          `+columns+`
         FROM event WHERE `+
 			strings.Join(conditions, " AND ")+
-			" ORDER BY created_at DESC, id LIMIT ?")
+			" LIMIT ?")
 	}
 
 	return query, params, nil
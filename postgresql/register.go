@@ -0,0 +1,20 @@
+package postgresql
+
+import "github.com/fiatjaf/eventstore"
+
+func init() {
+	eventstore.Register(eventstore.Driver{
+		Name:    "postgres",
+		Schemes: []string{"postgres", "postgresql"},
+		New: func(uri string, opts eventstore.OpenOptions) (eventstore.Store, error) {
+			return &PostgresBackend{
+				DatabaseURL:       uri,
+				QueryLimit:        opts.MaxLimit,
+				QueryIDsLimit:     opts.MaxLimit,
+				QueryAuthorsLimit: opts.MaxLimit,
+				QueryKindsLimit:   opts.MaxLimit,
+				QueryTagsLimit:    opts.MaxLimit,
+			}, nil
+		},
+	})
+}
@@ -5,12 +5,24 @@ import (
 	"encoding/json"
 
 	"github.com/fiatjaf/eventstore"
+	"github.com/jmoiron/sqlx"
 	"github.com/nbd-wtf/go-nostr"
 )
 
 func (b *PostgresBackend) SaveEvent(ctx context.Context, evt *nostr.Event) error {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return saveEvent(ctx, b.DB, evt)
+}
+
+// saveEvent is the actual SaveEvent, db can be either the database or a transaction.
+func saveEvent(ctx context.Context, db sqlx.ExecerContext, evt *nostr.Event) error {
 	sql, params, _ := saveEventSql(evt)
-	res, err := b.DB.ExecContext(ctx, sql, params...)
+	res, err := db.ExecContext(ctx, sql, params...)
 	if err != nil {
 		return err
 	}
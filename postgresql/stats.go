@@ -0,0 +1,67 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fiatjaf/eventstore"
+)
+
+// Stats counts the events per kind and reports the size of the event table and of each of its
+// indexes. Index entries are postgres' own estimates, which are only updated by VACUUM and ANALYZE.
+func (b *PostgresBackend) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return eventstore.StoreStats{}, err
+	}
+	defer done()
+
+	stats := eventstore.StoreStats{
+		EventsPerKind: make(map[int]int64),
+		Indexes:       make(map[string]eventstore.IndexStats),
+	}
+
+	rows, err := b.DB.QueryContext(ctx, `SELECT kind, count(*) FROM event GROUP BY kind`)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count events per kind: %w", err)
+	}
+	for rows.Next() {
+		var kind int
+		var count int64
+		if err := rows.Scan(&kind, &count); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		stats.EventsPerKind[kind] = count
+		stats.Events += count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	var tableSize int64
+	if err := b.DB.QueryRowContext(ctx, `SELECT pg_total_relation_size('event'), pg_relation_size('event')`).
+		Scan(&stats.DiskSize, &tableSize); err != nil {
+		return stats, fmt.Errorf("failed to get table size: %w", err)
+	}
+	stats.Indexes["event"] = eventstore.IndexStats{Entries: stats.Events, Size: tableSize}
+
+	rows, err = b.DB.QueryContext(ctx, `SELECT c.relname, greatest(c.reltuples, 0)::bigint, pg_relation_size(c.oid)
+		FROM pg_index i JOIN pg_class c ON c.oid = i.indexrelid
+		WHERE i.indrelid = 'event'::regclass`)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get index sizes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var s eventstore.IndexStats
+		if err := rows.Scan(&name, &s.Entries, &s.Size); err != nil {
+			return stats, err
+		}
+		stats.Indexes[name] = s
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,143 @@
+package eventstore
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Driver tells [Open] how to recognize and create one kind of store. Backend packages register
+// theirs with [Register] when imported, so a program only has to import the ones it wants, like
+//
+//	import _ "github.com/fiatjaf/eventstore/lmdb"
+type Driver struct {
+	// Name is the store type, as given in OpenOptions.Type.
+	Name string
+
+	// Schemes are the URI schemes, without "://", that open this kind of store.
+	Schemes []string
+
+	// Detect tells if the existing file or directory at path is a store of this kind, it can be
+	// nil for stores that don't live on disk.
+	Detect func(path string) bool
+
+	// New makes the store for uri, which may still have one of Schemes in front, without
+	// initializing it.
+	New func(uri string, opts OpenOptions) (Store, error)
+}
+
+// OpenOptions are the settings [Open] passes to drivers.
+type OpenOptions struct {
+	// Type is the name or one of the schemes of the driver to use, skipping detection. It is
+	// needed to create stores on disk, since there is nothing to detect yet.
+	Type string
+
+	// MaxLimit caps how many events a single query returns, for the stores that have such a
+	// setting. Zero keeps their defaults.
+	MaxLimit int
+}
+
+var (
+	driversMu sync.RWMutex
+	drivers   []Driver
+)
+
+// Register makes a driver available to [Open]. It panics if a driver with the same name or one
+// of the same schemes was already registered.
+func Register(d Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	for _, other := range drivers {
+		if other.Name == d.Name {
+			panic("eventstore: driver " + d.Name + " registered twice")
+		}
+		for _, scheme := range d.Schemes {
+			if slices.Contains(other.Schemes, scheme) {
+				panic("eventstore: scheme " + scheme + " registered by both " + other.Name + " and " + d.Name)
+			}
+		}
+	}
+	drivers = append(drivers, d)
+}
+
+// Drivers returns the names of the registered drivers.
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	names := make([]string, len(drivers))
+	for i, d := range drivers {
+		names[i] = d.Name
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Open creates and initializes the store at uri. The driver is picked, in order, from
+// opts.Type, from the scheme of uri, as in "lmdb:///var/lib/relay" or "postgres://...", or by
+// asking each driver if the file or directory at uri is one of theirs.
+func Open(uri string, opts OpenOptions) (Store, error) {
+	d, err := findDriver(uri, opts.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := d.New(uri, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s store: %w", d.Name, err)
+	}
+	if err := db.Init(); err != nil {
+		return nil, fmt.Errorf("failed to initialize %s store: %w", d.Name, err)
+	}
+	return db, nil
+}
+
+func findDriver(uri string, typ string) (Driver, error) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	if typ != "" {
+		for _, d := range drivers {
+			if d.Name == typ || slices.Contains(d.Schemes, typ) {
+				return d, nil
+			}
+		}
+		return Driver{}, fmt.Errorf("%w '%s', the package for it may not be imported", ErrUnknownStoreType, typ)
+	}
+
+	if scheme, _, ok := strings.Cut(uri, "://"); ok {
+		for _, d := range drivers {
+			if slices.Contains(d.Schemes, scheme) {
+				return d, nil
+			}
+		}
+		return Driver{}, fmt.Errorf("%w for scheme '%s', the package for it may not be imported", ErrUnknownStoreType, scheme)
+	}
+
+	if _, err := os.Stat(uri); err != nil {
+		if os.IsNotExist(err) {
+			return Driver{}, fmt.Errorf("'%s' does not exist, a type must be given to create a store there: %w", uri, err)
+		}
+		return Driver{}, err
+	}
+	for _, d := range drivers {
+		if d.Detect != nil && d.Detect(uri) {
+			return d, nil
+		}
+	}
+	return Driver{}, fmt.Errorf("%w at '%s'", ErrUnknownStoreType, uri)
+}
+
+// TrimScheme removes any of schemes, followed by "://", from the start of uri, for drivers of
+// stores that take plain paths.
+func TrimScheme(uri string, schemes ...string) string {
+	for _, scheme := range schemes {
+		if rest, ok := strings.CutPrefix(uri, scheme+"://"); ok {
+			return rest
+		}
+	}
+	return uri
+}
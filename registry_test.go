@@ -0,0 +1,51 @@
+package eventstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen(t *testing.T) {
+	var opened []string
+	Register(Driver{
+		Name:    "testfake",
+		Schemes: []string{"testfake", "testfake+alt"},
+		Detect: func(path string) bool {
+			_, err := os.Stat(filepath.Join(path, "testfake.marker"))
+			return err == nil
+		},
+		New: func(uri string, opts OpenOptions) (Store, error) {
+			opened = append(opened, TrimScheme(uri, "testfake", "testfake+alt"))
+			return failingReplaceStore{}, nil
+		},
+	})
+	require.Contains(t, Drivers(), "testfake")
+
+	_, err := Open("testfake:///some/where", OpenOptions{})
+	require.NoError(t, err)
+	_, err = Open("testfake+alt://else", OpenOptions{})
+	require.NoError(t, err)
+	_, err = Open("/some/path", OpenOptions{Type: "testfake"})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	_, err = Open(dir, OpenOptions{})
+	require.ErrorIs(t, err, ErrUnknownStoreType)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "testfake.marker"), nil, 0644))
+	_, err = Open(dir, OpenOptions{})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"/some/where", "else", "/some/path", dir}, opened)
+
+	_, err = Open("unknownfake://x", OpenOptions{})
+	require.ErrorIs(t, err, ErrUnknownStoreType)
+	_, err = Open("x", OpenOptions{Type: "unknownfake"})
+	require.ErrorIs(t, err, ErrUnknownStoreType)
+	_, err = Open(filepath.Join(dir, "missing"), OpenOptions{})
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	require.Panics(t, func() { Register(Driver{Name: "other", Schemes: []string{"testfake"}}) })
+}
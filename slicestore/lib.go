@@ -14,6 +14,8 @@ import (
 
 var _ eventstore.Store = (*SliceStore)(nil)
 
+// SliceStore keeps events in a sorted slice and goes through all of them on every query. It isn't
+// safe for concurrent use, memstore.MemStore is the indexed and thread-safe alternative.
 type SliceStore struct {
 	sync.Mutex
 	internal []*nostr.Event
@@ -55,6 +57,7 @@ func (b *SliceStore) QueryEvents(ctx context.Context, filter nostr.Filter) (chan
 
 	count := 0
 	go func() {
+		defer close(ch)
 		for _, event := range b.internal[start:end] {
 			if count == filter.Limit {
 				break
@@ -69,7 +72,6 @@ func (b *SliceStore) QueryEvents(ctx context.Context, filter nostr.Filter) (chan
 				count++
 			}
 		}
-		close(ch)
 	}()
 	return ch, nil
 }
@@ -84,6 +86,18 @@ func (b *SliceStore) CountEvents(ctx context.Context, filter nostr.Filter) (int6
 	return val, nil
 }
 
+// Stats counts the events in memory, there is nothing on disk.
+func (b *SliceStore) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	stats := eventstore.StoreStats{
+		Events:        int64(len(b.internal)),
+		EventsPerKind: make(map[int]int64),
+	}
+	for _, event := range b.internal {
+		stats.EventsPerKind[event.Kind]++
+	}
+	return stats, nil
+}
+
 func (b *SliceStore) SaveEvent(ctx context.Context, evt *nostr.Event) error {
 	idx, found := slices.BinarySearchFunc(b.internal, evt, eventComparator)
 	if found {
@@ -0,0 +1,13 @@
+package slicestore
+
+import "github.com/fiatjaf/eventstore"
+
+func init() {
+	eventstore.Register(eventstore.Driver{
+		Name:    "slicestore",
+		Schemes: []string{"slice"},
+		New: func(uri string, opts eventstore.OpenOptions) (eventstore.Store, error) {
+			return &SliceStore{MaxLimit: opts.MaxLimit}, nil
+		},
+	})
+}
@@ -0,0 +1,38 @@
+package sqlite3
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/fiatjaf/eventstore"
+)
+
+func init() {
+	eventstore.Register(eventstore.Driver{
+		Name:    "sqlite",
+		Schemes: []string{"sqlite", "sqlite3"},
+		Detect: func(path string) bool {
+			f, err := os.Open(path)
+			if err != nil {
+				return false
+			}
+			defer f.Close()
+			header := make([]byte, 16)
+			if _, err := io.ReadFull(f, header); err != nil {
+				return false
+			}
+			return bytes.Equal(header, []byte("SQLite format 3\x00"))
+		},
+		New: func(uri string, opts eventstore.OpenOptions) (eventstore.Store, error) {
+			return &SQLite3Backend{
+				DatabaseURL:       eventstore.TrimScheme(uri, "sqlite", "sqlite3"),
+				QueryLimit:        opts.MaxLimit,
+				QueryIDsLimit:     opts.MaxLimit,
+				QueryAuthorsLimit: opts.MaxLimit,
+				QueryKindsLimit:   opts.MaxLimit,
+				QueryTagsLimit:    opts.MaxLimit,
+			}, nil
+		},
+	})
+}
@@ -5,13 +5,25 @@ import (
 	"encoding/json"
 
 	"github.com/fiatjaf/eventstore"
+	"github.com/jmoiron/sqlx"
 	"github.com/nbd-wtf/go-nostr"
 )
 
 func (b *SQLite3Backend) SaveEvent(ctx context.Context, evt *nostr.Event) error {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return saveEvent(ctx, b.DB, evt)
+}
+
+// saveEvent is the actual SaveEvent, db can be either the database or a transaction.
+func saveEvent(ctx context.Context, db sqlx.ExecerContext, evt *nostr.Event) error {
 	// insert
 	tagsj, _ := json.Marshal(evt.Tags)
-	res, err := b.DB.ExecContext(ctx, `
+	res, err := db.ExecContext(ctx, `
         INSERT OR IGNORE INTO event (id, pubkey, created_at, kind, tags, content, sig)
         VALUES ($1, $2, $3, $4, $5, $6, $7)
     `, evt.ID, evt.PubKey, evt.CreatedAt, evt.Kind, tagsj, evt.Content, evt.Sig)
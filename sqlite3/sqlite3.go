@@ -1,8 +1,11 @@
 package sqlite3
 
 import (
+	"context"
+	"log"
 	"sync"
 
+	"github.com/fiatjaf/eventstore/internal/inflight"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -15,8 +18,21 @@ type SQLite3Backend struct {
 	QueryAuthorsLimit int
 	QueryKindsLimit   int
 	QueryTagsLimit    int
+
+	inflight inflight.Tracker
 }
 
+// Close is CloseContext with a timeout of inflight.CloseTimeout.
 func (b *SQLite3Backend) Close() {
-	b.DB.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), inflight.CloseTimeout)
+	defer cancel()
+	if err := b.CloseContext(ctx); err != nil {
+		log.Printf("sqlite3: failed to close: %s\n", err)
+	}
+}
+
+// CloseContext closes the database once the running operations are done.
+// See [eventstore.GracefulCloser].
+func (b *SQLite3Backend) CloseContext(ctx context.Context) error {
+	return b.inflight.Close(ctx, b.DB.Close)
 }
@@ -0,0 +1,33 @@
+package eventstore
+
+import "context"
+
+// StoreStats is what a store can tell about its contents and about how much space they take.
+// Sizes are in bytes, anything a store has no way of knowing is left empty.
+type StoreStats struct {
+	// Events is the total number of events stored.
+	Events int64 `json:"events"`
+
+	// EventsPerKind has how many events of each kind are stored.
+	EventsPerKind map[int]int64 `json:"events_per_kind"`
+
+	// DiskSize is the total space used by the store, including indexes.
+	DiskSize int64 `json:"disk_size,omitempty"`
+
+	// Indexes is keyed by whatever the store calls each of its indexes: a database for lmdb,
+	// a key prefix for badger, a table or index for the sql databases and so on.
+	Indexes map[string]IndexStats `json:"indexes,omitempty"`
+
+	// SchemaVersion is the version of the data layout, for stores that have migrations.
+	SchemaVersion int `json:"schema_version,omitempty"`
+}
+
+type IndexStats struct {
+	Entries int64 `json:"entries,omitempty"`
+	Size    int64 `json:"size,omitempty"`
+}
+
+// StatsReporter is implemented by stores that can describe their contents.
+type StatsReporter interface {
+	Stats(context.Context) (StoreStats, error)
+}
@@ -2,6 +2,7 @@ package eventstore
 
 import (
 	"context"
+	"iter"
 
 	"github.com/nbd-wtf/go-nostr"
 )
@@ -30,3 +31,54 @@ type Store interface {
 type Counter interface {
 	CountEvents(context.Context, nostr.Filter) (int64, error)
 }
+
+// IDTimestamp is the projection of an event that is enough for negentropy, deduplication and replication.
+type IDTimestamp struct {
+	ID        string
+	CreatedAt nostr.Timestamp
+}
+
+// IDQuerier is implemented by stores that can answer a filter with just ids and timestamps,
+// without decoding whole events.
+type IDQuerier interface {
+	// QueryIDs yields results in the same order and under the same limits as QueryEvents would.
+	QueryIDs(context.Context, nostr.Filter) iter.Seq2[IDTimestamp, error]
+}
+
+// ExistenceChecker is implemented by stores that can tell which of a batch of event ids
+// they already have without loading the events themselves.
+type ExistenceChecker interface {
+	// HasEvents returns a slice with the same length as ids, true meaning we have that event.
+	HasEvents(ctx context.Context, ids []string) ([]bool, error)
+}
+
+// GracefulCloser is implemented by stores that can wait for in-flight operations before closing.
+type GracefulCloser interface {
+	// CloseContext makes new operations fail with ErrClosed and waits for the running ones to
+	// finish before freeing resources. If ctx is done first the running operations are cancelled
+	// and an error is returned, the resources are then only freed once they have all returned.
+	//
+	// Calling it again waits for the first call, up to its own ctx, and returns the same result.
+	CloseContext(ctx context.Context) error
+}
+
+// Tx is the view of a store inside a transaction started with [Transactor.Update].
+// It must not be used after the function it was given to returns.
+type Tx interface {
+	// Query returns all the events matching filter, already collected.
+	Query(nostr.Filter) ([]*nostr.Event, error)
+	// Count counts the events matching filter.
+	Count(nostr.Filter) (int64, error)
+	// Save saves an event, it returns ErrDupEvent if we already have it.
+	Save(*nostr.Event) error
+	// Delete deletes an event, deleting an event we don't have is not an error.
+	Delete(*nostr.Event) error
+}
+
+// Transactor is implemented by stores that can run multiple operations in a single transaction.
+type Transactor interface {
+	// Update calls fn with a Tx, everything it does is committed if it returns nil and rolled back
+	// otherwise. fn may be called more than once if the transaction has to be retried, so it
+	// should not have side-effects outside of the Tx.
+	Update(ctx context.Context, fn func(Tx) error) error
+}
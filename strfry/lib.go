@@ -58,7 +58,11 @@ func (s StrfryBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (ch
 				continue
 			}
 
-			ch <- evt
+			select {
+			case ch <- evt:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 
@@ -162,3 +166,34 @@ func (s StrfryBackend) baseStrfryScan(ctx context.Context, filter nostr.Filter)
 
 	return &stdout, nil
 }
+
+// Stats scans the whole database to count the events per kind, strfry doesn't tell how much
+// space they take.
+func (s StrfryBackend) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	stats := eventstore.StoreStats{
+		EventsPerKind: make(map[int]int64),
+	}
+
+	stdout, err := s.baseStrfryScan(ctx, nostr.Filter{})
+	if err != nil {
+		return stats, err
+	}
+
+	for {
+		line, err := stdout.ReadBytes('\n')
+		if err != nil {
+			break
+		}
+
+		evt := &nostr.Event{}
+		easyjson.Unmarshal(line, evt)
+		if evt.ID == "" {
+			continue
+		}
+
+		stats.EventsPerKind[evt.Kind]++
+		stats.Events++
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,22 @@
+package strfry
+
+import (
+	"os"
+	"strings"
+
+	"github.com/fiatjaf/eventstore"
+)
+
+func init() {
+	eventstore.Register(eventstore.Driver{
+		Name:    "strfry",
+		Schemes: []string{"strfry"},
+		Detect: func(path string) bool {
+			info, err := os.Stat(path)
+			return err == nil && info.Mode().IsRegular() && strings.HasSuffix(path, ".conf")
+		},
+		New: func(uri string, opts eventstore.OpenOptions) (eventstore.Store, error) {
+			return &StrfryBackend{ConfigPath: eventstore.TrimScheme(uri, "strfry")}, nil
+		},
+	})
+}
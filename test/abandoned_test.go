@@ -0,0 +1,53 @@
+package test
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+// consumers that stop reading halfway through (like a client closing a subscription)
+// must not leave producer goroutines (and their transactions) behind once they cancel
+func abandonedQueriesTest(t *testing.T, db eventstore.Store) {
+	db.Init()
+
+	for i := 0; i < 50; i++ {
+		evt := &nostr.Event{CreatedAt: nostr.Timestamp(1000 + i), Kind: 1, Tags: nostr.Tags{{"t", "abandoned"}}}
+		evt.Sign(sk3)
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+
+	// give any background work started by the store some time to settle
+	time.Sleep(100 * time.Millisecond)
+	baseline := runtime.NumGoroutine()
+
+	filters := []nostr.Filter{
+		{Kinds: []int{1}, Limit: 50},
+		{Tags: nostr.TagMap{"t": []string{"abandoned"}}, Limit: 50},
+	}
+	for i := 0; i < 20; i++ {
+		qctx, cancel := context.WithCancel(ctx)
+		ch, err := db.QueryEvents(qctx, filters[i%len(filters)])
+		require.NoError(t, err)
+
+		// read just one and walk away
+		<-ch
+		cancel()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.LessOrEqual(t, runtime.NumGoroutine(), baseline, "query goroutines leaked")
+
+	// and the store is still usable
+	res, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, filters[0])
+	require.NoError(t, err)
+	require.Len(t, res, 50)
+}
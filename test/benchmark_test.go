@@ -9,7 +9,10 @@ import (
 
 	"github.com/fiatjaf/eventstore"
 	"github.com/fiatjaf/eventstore/badger"
+	"github.com/fiatjaf/eventstore/bbolt"
 	"github.com/fiatjaf/eventstore/lmdb"
+	"github.com/fiatjaf/eventstore/memstore"
+	"github.com/fiatjaf/eventstore/pebble"
 	"github.com/fiatjaf/eventstore/slicestore"
 	"github.com/fiatjaf/eventstore/sqlite3"
 	"github.com/nbd-wtf/go-nostr"
@@ -21,6 +24,12 @@ func BenchmarkSliceStore(b *testing.B) {
 	runBenchmarkOn(b, s)
 }
 
+func BenchmarkMemStore(b *testing.B) {
+	s := &memstore.MemStore{}
+	s.Init()
+	runBenchmarkOn(b, s)
+}
+
 func BenchmarkLMDB(b *testing.B) {
 	os.RemoveAll(dbpath + "lmdb")
 	l := &lmdb.LMDBBackend{Path: dbpath + "lmdb"}
@@ -35,6 +44,22 @@ func BenchmarkBadger(b *testing.B) {
 	runBenchmarkOn(b, d)
 }
 
+func BenchmarkBBolt(b *testing.B) {
+	os.Remove(dbpath + "bbolt")
+	d := &bbolt.BBoltBackend{Path: dbpath + "bbolt"}
+	d.Init()
+
+	runBenchmarkOn(b, d)
+}
+
+func BenchmarkPebble(b *testing.B) {
+	os.RemoveAll(dbpath + "pebble")
+	d := &pebble.PebbleBackend{Path: dbpath + "pebble"}
+	d.Init()
+
+	runBenchmarkOn(b, d)
+}
+
 func BenchmarkSQLite(b *testing.B) {
 	os.RemoveAll(dbpath + "sqlite")
 	q := &sqlite3.SQLite3Backend{DatabaseURL: dbpath + "sqlite", QueryTagsLimit: 50}
@@ -93,16 +118,45 @@ func runBenchmarkOn(b *testing.B, db eventstore.Store) {
 	filters = append(filters, nostr.Filter{Tags: nostr.TagMap{"e": eTags}})
 	filters = append(filters, nostr.Filter{Tags: nostr.TagMap{"e": eTags}, Limit: 50})
 
+	// these can't be served by a tag index alone, so most candidates have to be checked against the tags
+	tTags := make([]string, 50)
+	for i := 0; i < 50; i++ {
+		tTags[i] = fmt.Sprintf("t%d", i*7)
+	}
+	filters = append(filters, nostr.Filter{Authors: []string{pk3}, Tags: nostr.TagMap{"t": tTags}})
+	filters = append(filters, nostr.Filter{Kinds: []int{1, 2, 3}, Tags: nostr.TagMap{"e": eTags}})
+	filters = append(filters, nostr.Filter{Authors: []string{pk3, pk4}, Kinds: []int{0, 7}, Tags: nostr.TagMap{"t": tTags}, Limit: 100})
+
 	b.Run("filter", func(b *testing.B) {
 		for q, filter := range filters {
 			b.Run(fmt.Sprintf("q-%d", q), func(b *testing.B) {
+				b.ReportAllocs()
 				for i := 0; i < b.N; i++ {
-					_, _ = db.QueryEvents(ctx, filter)
+					// drain the results so we measure the work done by the query and not only its setup
+					ch, err := db.QueryEvents(ctx, filter)
+					if err != nil {
+						b.Fatal(err)
+					}
+					for range ch {
+					}
 				}
 			})
 		}
 	})
 
+	if counter, ok := db.(eventstore.Counter); ok {
+		b.Run("count", func(b *testing.B) {
+			for q, filter := range filters {
+				b.Run(fmt.Sprintf("q-%d", q), func(b *testing.B) {
+					b.ReportAllocs()
+					for i := 0; i < b.N; i++ {
+						_, _ = counter.CountEvents(ctx, filter)
+					}
+				})
+			}
+		})
+	}
+
 	b.Run("insert", func(b *testing.B) {
 		evt := &nostr.Event{Kind: 788, CreatedAt: nostr.Now(), Content: "blergh", Tags: nostr.Tags{{"t", "spam"}}}
 		evt.Sign(sk4)
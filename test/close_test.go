@@ -0,0 +1,118 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+// closing while other goroutines are still using the store must not crash anything,
+// operations either finish normally or fail with ErrClosed
+func closeTest(t *testing.T, db eventstore.Store) {
+	db.Init()
+
+	closer, ok := db.(eventstore.GracefulCloser)
+	if !ok {
+		t.Skip("store doesn't implement CloseContext")
+	}
+
+	for i := 0; i < 30; i++ {
+		evt := &nostr.Event{CreatedAt: nostr.Timestamp(1000 + i), Kind: 1, Tags: nostr.Tags{}}
+		evt.Sign(sk3)
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+
+	// a subscription that nobody is reading anymore
+	abandoned, err := db.QueryEvents(ctx, nostr.Filter{Kinds: []int{1}})
+	require.NoError(t, err)
+	<-abandoned
+
+	stop := make(chan struct{})
+	unexpected := make(chan error, 100)
+	wg := sync.WaitGroup{}
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				evt := &nostr.Event{CreatedAt: nostr.Timestamp(2000 + i), Kind: 1, Tags: nostr.Tags{}, Content: time.Now().String()}
+				evt.Sign(sk4)
+				if err := db.SaveEvent(ctx, evt); err != nil && !closing(err) {
+					unexpected <- err
+				}
+
+				ch, err := db.QueryEvents(ctx, nostr.Filter{Kinds: []int{1}, Limit: 10})
+				if err != nil {
+					if !closing(err) {
+						unexpected <- err
+					}
+					continue
+				}
+				for range ch {
+				}
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	cctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	closeErr := closer.CloseContext(cctx) // may fail because of the abandoned subscription, that's fine
+
+	close(stop)
+	wg.Wait()
+	close(unexpected)
+	for err := range unexpected {
+		t.Errorf("unexpected error while closing: %s", err)
+	}
+
+	// everything that comes after is rejected
+	evt := &nostr.Event{CreatedAt: 3000, Kind: 1, Tags: nostr.Tags{}}
+	evt.Sign(sk3)
+	require.ErrorIs(t, db.SaveEvent(ctx, evt), eventstore.ErrClosed)
+	_, err = db.QueryEvents(ctx, nostr.Filter{})
+	require.ErrorIs(t, err, eventstore.ErrClosed)
+	if querier, ok := db.(eventstore.IDQuerier); ok {
+		for _, err := range querier.QueryIDs(ctx, nostr.Filter{}) {
+			require.ErrorIs(t, err, eventstore.ErrClosed)
+		}
+	}
+
+	// and the abandoned subscription is cancelled
+	select {
+	case <-drained(abandoned):
+	case <-time.After(2 * time.Second):
+		t.Fatal("abandoned query wasn't cancelled")
+	}
+
+	// closing again is harmless, and says the same as the first time
+	require.Equal(t, closeErr, closer.CloseContext(ctx))
+}
+
+func drained(ch chan *nostr.Event) chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		for range ch {
+		}
+		close(done)
+	}()
+	return done
+}
+
+// closing tells if err is what an operation may get while the store is being closed: either it was
+// rejected or, if it was already running when we gave up waiting, its context was cancelled.
+func closing(err error) bool {
+	return errors.Is(err, eventstore.ErrClosed) || errors.Is(err, context.Canceled)
+}
@@ -0,0 +1,94 @@
+package test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/fiatjaf/eventstore/bluge"
+	"github.com/fiatjaf/eventstore/lmdb"
+	"github.com/fiatjaf/eventstore/wrappers/count"
+	"github.com/fiatjaf/eventstore/wrappers/search"
+	_ "github.com/fiatjaf/eventstore/wrappers/skipevent"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigPipeline(t *testing.T) {
+	os.RemoveAll(dbpath + "configlmdb")
+	os.RemoveAll(dbpath + "configbluge")
+	defer os.RemoveAll(dbpath + "configlmdb")
+	defer os.RemoveAll(dbpath + "configbluge")
+
+	config := dbpath + "config.json"
+	require.NoError(t, os.WriteFile(config, []byte(`{
+		"store": {"uri": "lmdb://`+dbpath+`configlmdb", "type": "lmdb"},
+		"wrappers": [
+			{"type": "bluge", "options": {"path": "`+dbpath+`configbluge"}},
+			{"type": "skipevent", "options": {"kinds": [7]}},
+			{"type": "count"}
+		]
+	}`), 0644))
+	defer os.Remove(config)
+
+	db, err := eventstore.LoadConfig(config)
+	require.NoError(t, err)
+	defer db.Close()
+	require.IsType(t, count.Wrapper{}, db)
+
+	for i, kind := range []int{1, 7, 1} {
+		evt := &nostr.Event{Kind: kind, CreatedAt: nostr.Timestamp(i + 1), Tags: nostr.Tags{}, Content: "hello world"}
+		evt.Sign(sk3)
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+
+	n, err := db.(eventstore.Counter).CountEvents(ctx, nostr.Filter{})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n, "kind 7 should have been skipped")
+
+	events, err := eventstore.RelayWrapper{Store: db}.QuerySync(ctx, nostr.Filter{Search: "hello"})
+	require.NoError(t, err)
+	require.Len(t, events, 2, "search should go through bluge")
+}
+
+func TestSearchWrapperForwardsToStore(t *testing.T) {
+	os.RemoveAll(dbpath + "searchlmdb")
+	os.RemoveAll(dbpath + "searchbluge")
+	defer os.RemoveAll(dbpath + "searchlmdb")
+	defer os.RemoveAll(dbpath + "searchbluge")
+
+	// counting through QueryEvents would stop at MaxLimit
+	store := &lmdb.LMDBBackend{Path: dbpath + "searchlmdb", MaxLimit: 10}
+	wrapper := search.Wrapper{Store: store, Search: &bluge.BlugeBackend{Path: dbpath + "searchbluge", RawEventStore: store}}
+	db := count.Wrapper{Store: wrapper}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	ids := make([]string, 30)
+	for i := range ids {
+		evt := &nostr.Event{Kind: 1, CreatedAt: nostr.Timestamp(i + 1), Tags: nostr.Tags{}, Content: "hello world"}
+		evt.Sign(sk3)
+		require.NoError(t, db.SaveEvent(ctx, evt))
+		ids[i] = evt.ID
+	}
+
+	n, err := db.CountEvents(ctx, nostr.Filter{Kinds: []int{1}})
+	require.NoError(t, err)
+	require.Equal(t, int64(30), n)
+
+	var queried int
+	for item, err := range wrapper.QueryIDs(ctx, nostr.Filter{Limit: 5}) {
+		require.NoError(t, err)
+		require.Equal(t, ids[29-queried], item.ID)
+		queried++
+	}
+	require.Equal(t, 5, queried)
+
+	has, err := wrapper.HasEvents(ctx, []string{ids[0], ids[29], "00"})
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, true, false}, has)
+
+	n, err = db.CountEvents(ctx, nostr.Filter{Search: "hello"})
+	require.NoError(t, err)
+	require.Equal(t, int64(30), n, "search filters still go through bluge, lmdb would find nothing")
+}
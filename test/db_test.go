@@ -8,7 +8,11 @@ import (
 	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
 	"github.com/fiatjaf/eventstore"
 	"github.com/fiatjaf/eventstore/badger"
+	"github.com/fiatjaf/eventstore/bbolt"
+	"github.com/fiatjaf/eventstore/jsonlstore"
 	"github.com/fiatjaf/eventstore/lmdb"
+	"github.com/fiatjaf/eventstore/memstore"
+	"github.com/fiatjaf/eventstore/pebble"
 	"github.com/fiatjaf/eventstore/postgresql"
 	"github.com/fiatjaf/eventstore/slicestore"
 	"github.com/fiatjaf/eventstore/sqlite3"
@@ -30,6 +34,15 @@ var tests = []struct {
 	{"second", runSecondTestOn},
 	{"manyauthors", manyAuthorsTest},
 	{"unbalanced", unbalancedTest},
+	{"queryids", queryIDsTest},
+	{"hasevents", hasEventsTest},
+	{"idcollision", idCollisionTest},
+	{"transaction", transactionTest},
+	{"largekinds", largeKindsTest},
+	{"stats", statsTest},
+	{"exportimport", exportImportTest},
+	{"abandoned", abandonedQueriesTest},
+	{"close", closeTest},
 }
 
 func TestSliceStore(t *testing.T) {
@@ -38,6 +51,12 @@ func TestSliceStore(t *testing.T) {
 	}
 }
 
+func TestMemStore(t *testing.T) {
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) { test.run(t, &memstore.MemStore{}) })
+	}
+}
+
 func TestLMDB(t *testing.T) {
 	for _, test := range tests {
 		os.RemoveAll(dbpath + "lmdb")
@@ -52,6 +71,30 @@ func TestBadger(t *testing.T) {
 	}
 }
 
+func TestBBolt(t *testing.T) {
+	for _, test := range tests {
+		os.Remove(dbpath + "bbolt")
+		t.Run(test.name, func(t *testing.T) { test.run(t, &bbolt.BBoltBackend{Path: dbpath + "bbolt"}) })
+	}
+}
+
+func TestPebble(t *testing.T) {
+	for _, test := range tests {
+		// pebble won't open a path that is still open in this process, and not all tests close
+		path := dbpath + "pebble-" + test.name
+		os.RemoveAll(path)
+		t.Run(test.name, func(t *testing.T) { test.run(t, &pebble.PebbleBackend{Path: path}) })
+	}
+}
+
+func TestJSONL(t *testing.T) {
+	for _, test := range tests {
+		os.Remove(dbpath + "jsonl")
+		os.Remove(dbpath + "jsonl.idx")
+		t.Run(test.name, func(t *testing.T) { test.run(t, &jsonlstore.JSONLStore{Path: dbpath + "jsonl"}) })
+	}
+}
+
 func TestSQLite(t *testing.T) {
 	for _, test := range tests {
 		os.RemoveAll(dbpath + "sqlite")
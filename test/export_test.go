@@ -0,0 +1,128 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func exportImportTest(t *testing.T, db eventstore.Store) {
+	db.Init()
+
+	// more events than any store returns in a single query, three for each timestamp
+	events := make([]*nostr.Event, 1200)
+	for i := range events {
+		evt := &nostr.Event{
+			CreatedAt: nostr.Timestamp(1000 + i/3),
+			Kind:      1 + i%2,
+			Tags:      nostr.Tags{},
+			Content:   fmt.Sprintf("export %d", i),
+		}
+		evt.Sign(sk3)
+		require.NoError(t, db.SaveEvent(ctx, evt))
+		events[i] = evt
+	}
+
+	exportIDs := func(filter nostr.Filter) (*bytes.Buffer, map[string]bool) {
+		buf := &bytes.Buffer{}
+		n, err := eventstore.Export(ctx, db, buf, filter)
+		require.NoError(t, err)
+
+		_, ordered := db.(eventstore.Exporter)
+		var last nostr.Timestamp
+		ids := make(map[string]bool)
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			evt := &nostr.Event{}
+			require.NoError(t, json.Unmarshal([]byte(line), evt))
+			require.False(t, ids[evt.ID], "event %s exported twice", evt.ID)
+			ids[evt.ID] = true
+			if ordered {
+				require.GreaterOrEqual(t, evt.CreatedAt, last, "exporters go oldest first")
+				last = evt.CreatedAt
+			}
+		}
+		require.Equal(t, int64(len(ids)), n)
+		return buf, ids
+	}
+
+	backup, ids := exportIDs(nostr.Filter{})
+	require.Len(t, ids, len(events))
+	for _, evt := range events {
+		require.True(t, ids[evt.ID], "event %s missing from the export", evt.ID)
+	}
+
+	_, ids = exportIDs(nostr.Filter{Kinds: []int{2}})
+	require.Len(t, ids, len(events)/2)
+
+	_, ids = exportIDs(nostr.Filter{Limit: 10})
+	require.Len(t, ids, 10)
+
+	// lose some events and get them back from the backup
+	for _, evt := range events[100:400] {
+		require.NoError(t, db.DeleteEvent(ctx, evt))
+	}
+
+	// the same event twice and a broken line
+	input := backup.String() + events[0].String() + "\n{not json\n"
+
+	var calls int
+	progress, err := eventstore.Import(ctx, db, strings.NewReader(input), eventstore.ImportOptions{
+		BatchSize:       100,
+		CheckSignatures: true,
+		OnProgress:      func(eventstore.ImportProgress) { calls++ },
+	})
+	require.NoError(t, err)
+	require.Equal(t, eventstore.ImportProgress{
+		Lines:      int64(len(events) + 2),
+		Saved:      300,
+		Duplicates: int64(len(events)) - 300 + 1,
+		Invalid:    1,
+	}, progress)
+	require.Equal(t, 13, calls)
+
+	_, ids = exportIDs(nostr.Filter{})
+	require.Len(t, ids, len(events))
+
+	// resuming after the last line has nothing left to do
+	progress, err = eventstore.Import(ctx, db, strings.NewReader(input), eventstore.ImportOptions{Skip: progress.Lines})
+	require.NoError(t, err)
+	require.Equal(t, eventstore.ImportProgress{Lines: int64(len(events) + 2)}, progress)
+
+	// a forged event isn't imported when checking signatures
+	forged := *events[0]
+	forged.Content = "forged"
+	forged.ID = forged.GetID()
+	progress, err = eventstore.Import(ctx, db, strings.NewReader(forged.String()), eventstore.ImportOptions{CheckSignatures: true})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), progress.Invalid)
+	require.Zero(t, progress.Saved)
+
+	// only the newest version of a replaceable event is kept, whatever the order they come in
+	var profiles strings.Builder
+	for _, ts := range []nostr.Timestamp{20, 30, 10} {
+		evt := &nostr.Event{CreatedAt: ts, Kind: 0, Tags: nostr.Tags{}, Content: fmt.Sprintf("profile %d", ts)}
+		evt.Sign(sk4)
+		profiles.WriteString(evt.String() + "\n")
+	}
+	progress, err = eventstore.Import(ctx, db, strings.NewReader(profiles.String()), eventstore.ImportOptions{})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), progress.Saved)
+
+	ch, err := db.QueryEvents(ctx, nostr.Filter{Kinds: []int{0}})
+	require.NoError(t, err)
+	var kept []*nostr.Event
+	for evt := range ch {
+		kept = append(kept, evt)
+	}
+	require.Len(t, kept, 1)
+	require.Equal(t, nostr.Timestamp(30), kept[0].CreatedAt)
+}
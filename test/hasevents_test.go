@@ -0,0 +1,114 @@
+package test
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/fiatjaf/eventstore/badger"
+	"github.com/fiatjaf/eventstore/lmdb"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func hasEventsTest(t *testing.T, db eventstore.Store) {
+	db.Init()
+
+	checker, ok := db.(eventstore.ExistenceChecker)
+	if !ok {
+		t.Skip("store doesn't implement HasEvents")
+	}
+
+	saved := saveEventsForHasEvents(t, db, 40)
+
+	// deleted events must not be reported
+	require.NoError(t, db.DeleteEvent(ctx, saved[0]))
+
+	// enough ids to go over the default QueryIDsLimit of the sql backends
+	ids := make([]string, 0, 700)
+	expected := make([]bool, 0, 700)
+	for i, evt := range saved {
+		ids = append(ids, evt.ID)
+		expected = append(expected, i != 0)
+	}
+	for len(ids) < 698 {
+		ids = append(ids, randomID())
+		expected = append(expected, false)
+	}
+	ids = append(ids, "not an id", saved[5].ID)
+	expected = append(expected, false, true)
+
+	results, err := checker.HasEvents(ctx, ids)
+	require.NoError(t, err)
+	require.Equal(t, expected, results)
+
+	results, err = checker.HasEvents(ctx, nil)
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
+
+func TestHasEventsBloomFilter(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		make func() eventstore.Store
+	}{
+		{"lmdb", func() eventstore.Store {
+			return &lmdb.LMDBBackend{Path: dbpath + "lmdb-bloom", EnableBloomFilter: true}
+		}},
+		{"badger", func() eventstore.Store {
+			return &badger.BadgerBackend{Path: dbpath + "badger-bloom", EnableBloomFilter: true}
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			os.RemoveAll(dbpath + tc.name + "-bloom")
+
+			db := tc.make()
+			require.NoError(t, db.Init())
+			saved := saveEventsForHasEvents(t, db, 20)
+			db.Close()
+
+			// reopen so the filter gets loaded from what is on disk
+			db = tc.make()
+			require.NoError(t, db.Init())
+			defer db.Close()
+			more := saveEventsForHasEvents(t, db, 20)
+
+			ids := []string{randomID()}
+			for _, evt := range append(saved, more...) {
+				ids = append(ids, evt.ID)
+			}
+
+			results, err := db.(eventstore.ExistenceChecker).HasEvents(ctx, ids)
+			require.NoError(t, err)
+			require.False(t, results[0])
+			for i := 1; i < len(results); i++ {
+				require.True(t, results[i], "missing %s", ids[i])
+			}
+		})
+	}
+}
+
+func saveEventsForHasEvents(t *testing.T, db eventstore.Store, n int) []*nostr.Event {
+	events := make([]*nostr.Event, n)
+	for i := range events {
+		evt := &nostr.Event{
+			CreatedAt: nostr.Now() - nostr.Timestamp(i),
+			Content:   fmt.Sprintf("exists %d %s", i, randomID()),
+			Tags:      nostr.Tags{},
+			Kind:      1,
+		}
+		evt.Sign(sk3)
+		require.NoError(t, db.SaveEvent(ctx, evt))
+		events[i] = evt
+	}
+	return events
+}
+
+func randomID() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,58 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+// lmdb and badger only index the first 8 bytes of ids, so make sure events that share
+// those are still treated as different events
+func idCollisionTest(t *testing.T, db eventstore.Store) {
+	db.Init()
+
+	prefix := "c0111de5c0111de5"
+	events := make([]*nostr.Event, 3)
+	for i := range events {
+		evt := &nostr.Event{CreatedAt: nostr.Timestamp(100 + i), Kind: 1, Tags: nostr.Tags{}, Content: "collision"}
+		evt.Sign(sk3)
+		evt.ID = prefix + evt.ID[len(prefix):]
+		events[i] = evt
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+
+	// a real duplicate is still detected
+	if err := db.SaveEvent(ctx, events[1]); err != nil {
+		require.ErrorIs(t, err, eventstore.ErrDupEvent)
+	}
+
+	w := eventstore.RelayWrapper{Store: db}
+	for _, evt := range events {
+		res, err := w.QuerySync(ctx, nostr.Filter{IDs: []string{evt.ID}})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Equal(t, evt.ID, res[0].ID)
+	}
+
+	// deleting one must not delete the others
+	require.NoError(t, db.DeleteEvent(ctx, events[1]))
+
+	res, err := w.QuerySync(ctx, nostr.Filter{IDs: []string{events[0].ID, events[1].ID, events[2].ID}})
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	require.ElementsMatch(t, []string{events[0].ID, events[2].ID}, []string{res[0].ID, res[1].ID})
+
+	if counter, ok := db.(eventstore.Counter); ok {
+		count, err := counter.CountEvents(ctx, nostr.Filter{IDs: []string{events[1].ID, events[2].ID}})
+		require.NoError(t, err)
+		require.Equal(t, int64(1), count)
+	}
+
+	if checker, ok := db.(eventstore.ExistenceChecker); ok {
+		results, err := checker.HasEvents(ctx, []string{events[0].ID, events[1].ID, events[2].ID})
+		require.NoError(t, err)
+		require.Equal(t, []bool{true, false, true}, results)
+	}
+}
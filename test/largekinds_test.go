@@ -0,0 +1,65 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func largeKindsTest(t *testing.T, db eventstore.Store) {
+	db.Init()
+
+	pk3, _ := nostr.GetPublicKey(sk3)
+	pk4, _ := nostr.GetPublicKey(sk4)
+
+	kinds := []int{1, 65535, 65536, 70001, 1<<31 + 5, 1<<32 - 1}
+	for i, kind := range kinds {
+		for j, sk := range []string{sk3, sk4} {
+			evt := &nostr.Event{
+				CreatedAt: nostr.Timestamp(1000 + i*10 + j),
+				Kind:      kind,
+				Tags:      nostr.Tags{{"p", pk4}, {"t", "large"}},
+				Content:   fmt.Sprintf("kind %d", kind),
+			}
+			evt.Sign(sk)
+			require.NoError(t, db.SaveEvent(ctx, evt))
+		}
+	}
+
+	for _, kind := range kinds {
+		res := queryAll(t, db, nostr.Filter{Kinds: []int{kind}})
+		require.Len(t, res, 2, "kind %d", kind)
+		for _, evt := range res {
+			require.Equal(t, kind, evt.Kind)
+		}
+
+		res = queryAll(t, db, nostr.Filter{Kinds: []int{kind}, Authors: []string{pk3}})
+		require.Len(t, res, 1, "kind %d", kind)
+		require.Equal(t, kind, res[0].Kind)
+		require.Equal(t, pk3, res[0].PubKey)
+
+		res = queryAll(t, db, nostr.Filter{Kinds: []int{kind}, Tags: nostr.TagMap{"p": []string{pk4}}})
+		require.Len(t, res, 2, "kind %d", kind)
+
+		res = queryAll(t, db, nostr.Filter{Kinds: []int{kind}, Tags: nostr.TagMap{"t": []string{"large"}}})
+		require.Len(t, res, 2, "kind %d", kind)
+	}
+
+	// kinds only differing in the upper bytes don't get mixed up
+	res := queryAll(t, db, nostr.Filter{Kinds: []int{65536 + 1}})
+	require.Empty(t, res)
+	res = queryAll(t, db, nostr.Filter{Kinds: []int{1<<32 + 1}})
+	require.Empty(t, res)
+	res = queryAll(t, db, nostr.Filter{Kinds: []int{1<<32 + 1, 70001}, Authors: []string{pk4}})
+	require.Len(t, res, 1)
+	require.Equal(t, 70001, res[0].Kind)
+
+	if counter, ok := db.(eventstore.Counter); ok {
+		count, err := counter.CountEvents(ctx, nostr.Filter{Kinds: []int{1<<31 + 5, 1}})
+		require.NoError(t, err)
+		require.Equal(t, int64(4), count)
+	}
+}
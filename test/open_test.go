@@ -0,0 +1,57 @@
+package test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/fiatjaf/eventstore/badger"
+	"github.com/fiatjaf/eventstore/bbolt"
+	"github.com/fiatjaf/eventstore/lmdb"
+	"github.com/fiatjaf/eventstore/pebble"
+	"github.com/fiatjaf/eventstore/sqlite3"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenDetects(t *testing.T) {
+	for _, typ := range []string{"lmdb", "badger", "bbolt", "pebble", "sqlite"} {
+		t.Run(typ, func(t *testing.T) {
+			path := dbpath + "open" + typ
+			os.RemoveAll(path)
+			defer os.RemoveAll(path)
+
+			_, err := eventstore.Open(path, eventstore.OpenOptions{})
+			require.ErrorIs(t, err, os.ErrNotExist, "nothing to detect yet")
+
+			db, err := eventstore.Open(path, eventstore.OpenOptions{Type: typ})
+			require.NoError(t, err)
+			evt := &nostr.Event{Kind: 1, CreatedAt: 1, Tags: nostr.Tags{}, Content: "hello"}
+			evt.Sign(sk3)
+			require.NoError(t, db.SaveEvent(ctx, evt))
+			db.Close()
+
+			for _, uri := range []string{path, typ + "://" + path} {
+				db, err = eventstore.Open(uri, eventstore.OpenOptions{})
+				require.NoError(t, err)
+				switch typ {
+				case "lmdb":
+					require.IsType(t, &lmdb.LMDBBackend{}, db)
+				case "badger":
+					require.IsType(t, &badger.BadgerBackend{}, db)
+				case "bbolt":
+					require.IsType(t, &bbolt.BBoltBackend{}, db)
+				case "pebble":
+					require.IsType(t, &pebble.PebbleBackend{}, db)
+				case "sqlite":
+					require.IsType(t, &sqlite3.SQLite3Backend{}, db)
+				}
+
+				has, err := db.(eventstore.ExistenceChecker).HasEvents(ctx, []string{evt.ID})
+				require.NoError(t, err)
+				require.Equal(t, []bool{true}, has)
+				db.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func queryIDsTest(t *testing.T, db eventstore.Store) {
+	db.Init()
+
+	querier, ok := db.(eventstore.IDQuerier)
+	if !ok {
+		t.Skip("store doesn't implement QueryIDs")
+	}
+
+	pk3, _ := nostr.GetPublicKey(sk3)
+	for i := 0; i < 60; i++ {
+		evt := &nostr.Event{
+			CreatedAt: nostr.Timestamp(i*5 + 1),
+			Content:   fmt.Sprintf("projection %d", i),
+			Tags:      nostr.Tags{{"t", fmt.Sprintf("t%d", i%4)}, {"x", fmt.Sprintf("x%d", i%3)}},
+			Kind:      i % 3,
+		}
+		sk := sk3
+		if i%2 == 0 {
+			sk = sk4
+		}
+		evt.Sign(sk)
+		require.NoError(t, db.SaveEvent(ctx, evt))
+	}
+
+	w := eventstore.RelayWrapper{Store: db}
+	for _, filter := range []nostr.Filter{
+		{Limit: 20},
+		{Kinds: []int{1, 2}, Limit: 15},
+		{Authors: []string{pk3}, Limit: 40},
+		{Tags: nostr.TagMap{"t": []string{"t1"}}},
+		{Tags: nostr.TagMap{"t": []string{"t2"}, "x": []string{"x0"}}, Limit: 50},
+	} {
+		events, err := w.QuerySync(ctx, filter)
+		require.NoError(t, err)
+
+		expected := make([]eventstore.IDTimestamp, len(events))
+		for i, evt := range events {
+			expected[i] = eventstore.IDTimestamp{ID: evt.ID, CreatedAt: evt.CreatedAt}
+		}
+
+		results := make([]eventstore.IDTimestamp, 0, len(events))
+		for it, err := range querier.QueryIDs(ctx, filter) {
+			require.NoError(t, err)
+			results = append(results, it)
+		}
+
+		require.Equal(t, expected, results, "mismatch on %s", filter)
+	}
+}
@@ -0,0 +1,50 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func statsTest(t *testing.T, db eventstore.Store) {
+	db.Init()
+
+	reporter, ok := db.(eventstore.StatsReporter)
+	if !ok {
+		t.Skip("store doesn't report stats")
+	}
+
+	stats, err := reporter.Stats(ctx)
+	require.NoError(t, err)
+	require.Zero(t, stats.Events)
+	require.Empty(t, stats.EventsPerKind)
+
+	var first *nostr.Event
+	for i, kind := range []int{1, 1, 1, 7, 7, 70001} {
+		evt := &nostr.Event{
+			CreatedAt: nostr.Timestamp(1000 + i),
+			Kind:      kind,
+			Tags:      nostr.Tags{},
+			Content:   fmt.Sprintf("stats %d", i),
+		}
+		evt.Sign(sk3)
+		require.NoError(t, db.SaveEvent(ctx, evt))
+		if first == nil {
+			first = evt
+		}
+	}
+	require.NoError(t, db.DeleteEvent(ctx, first))
+
+	stats, err = reporter.Stats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), stats.Events)
+	require.Equal(t, map[int]int64{1: 2, 7: 2, 70001: 1}, stats.EventsPerKind)
+	require.GreaterOrEqual(t, stats.DiskSize, int64(0))
+	for name, idx := range stats.Indexes {
+		require.GreaterOrEqual(t, idx.Entries, int64(0), name)
+		require.GreaterOrEqual(t, idx.Size, int64(0), name)
+	}
+}
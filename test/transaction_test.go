@@ -0,0 +1,94 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func transactionTest(t *testing.T, db eventstore.Store) {
+	db.Init()
+
+	transactor, ok := db.(eventstore.Transactor)
+	if !ok {
+		t.Skip("store doesn't implement Transactor")
+	}
+
+	events := make([]*nostr.Event, 3)
+	for i := range events {
+		evt := &nostr.Event{
+			CreatedAt: nostr.Timestamp(1000 + i),
+			Content:   fmt.Sprintf("tx %d", i),
+			Tags:      nostr.Tags{},
+			Kind:      1,
+		}
+		evt.Sign(sk3)
+		events[i] = evt
+	}
+	filter := nostr.Filter{Kinds: []int{1}}
+
+	// everything done inside a transaction is visible inside it and committed at the end
+	err := transactor.Update(ctx, func(tx eventstore.Tx) error {
+		for _, evt := range events[0:2] {
+			if err := tx.Save(evt); err != nil {
+				return err
+			}
+		}
+		require.ErrorIs(t, tx.Save(events[0]), eventstore.ErrDupEvent)
+
+		results, err := tx.Query(filter)
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		require.Equal(t, events[1].ID, results[0].ID)
+
+		count, err := tx.Count(filter)
+		require.NoError(t, err)
+		require.Equal(t, int64(2), count)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, queryAll(t, db, filter), 2)
+
+	// a failed transaction leaves nothing behind
+	failure := errors.New("failure")
+	err = transactor.Update(ctx, func(tx eventstore.Tx) error {
+		require.NoError(t, tx.Delete(events[0]))
+		require.NoError(t, tx.Save(events[2]))
+		return failure
+	})
+	require.ErrorIs(t, err, failure)
+
+	results := queryAll(t, db, filter)
+	require.Len(t, results, 2)
+	require.Equal(t, events[1].ID, results[0].ID)
+	require.Equal(t, events[0].ID, results[1].ID)
+
+	// a successful one applies everything together
+	err = transactor.Update(ctx, func(tx eventstore.Tx) error {
+		if err := tx.Delete(events[0]); err != nil {
+			return err
+		}
+		return tx.Save(events[2])
+	})
+	require.NoError(t, err)
+
+	results = queryAll(t, db, filter)
+	require.Len(t, results, 2)
+	require.Equal(t, events[2].ID, results[0].ID)
+	require.Equal(t, events[1].ID, results[1].ID)
+}
+
+func queryAll(t *testing.T, db eventstore.Store, filter nostr.Filter) []*nostr.Event {
+	ch, err := db.QueryEvents(ctx, filter)
+	require.NoError(t, err)
+
+	var results []*nostr.Event
+	for evt := range ch {
+		results = append(results, evt)
+	}
+	return results
+}
@@ -7,6 +7,12 @@ import (
 )
 
 func (b *TursoBackend) DeleteEvent(ctx context.Context, evt *nostr.Event) error {
-	_, err := b.DB.ExecContext(ctx, "DELETE FROM event WHERE id = ?", evt.ID)
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	_, err = b.DB.ExecContext(ctx, "DELETE FROM event WHERE id = ?", evt.ID)
 	return err
 }
@@ -0,0 +1,98 @@
+package turso
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/jmoiron/sqlx"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+var _ eventstore.Exporter = (*TursoBackend)(nil)
+
+// exportPageSize is how many events each query fetches when exporting.
+const exportPageSize = 1000
+
+// ExportEvents pages through the events oldest first with a keyset cursor on (created_at, id),
+// so it isn't bound by QueryLimit and never holds more than a page of rows at a time.
+func (b *TursoBackend) ExportEvents(ctx context.Context, filter nostr.Filter) iter.Seq2[*nostr.Event, error] {
+	return func(yield func(*nostr.Event, error) bool) {
+		ctx, done, err := b.inflight.AcquireContext(ctx)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer done()
+
+		conditions, params, err := b.filterConditions(filter)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		var (
+			count         int
+			lastCreatedAt int64
+			lastID        string
+		)
+		for {
+			pageConditions := slices.Clip(conditions)
+			pageParams := slices.Clip(params)
+			if lastID != "" {
+				pageConditions = append(pageConditions, `(created_at > ? OR (created_at = ? AND id > ?))`)
+				pageParams = append(pageParams, lastCreatedAt, lastCreatedAt, lastID)
+			}
+			pageParams = append(pageParams, exportPageSize)
+
+			query := sqlx.Rebind(sqlx.QUESTION, `SELECT
+          id, pubkey, created_at, kind, tags, content, sig
+        FROM event WHERE `+
+				strings.Join(pageConditions, " AND ")+
+				" ORDER BY created_at, id LIMIT ?")
+
+			rows, err := b.DB.QueryContext(ctx, query, pageParams...)
+			if err != nil {
+				yield(nil, fmt.Errorf("failed to fetch events using query %q: %w", query, err))
+				return
+			}
+
+			n := 0
+			for rows.Next() {
+				var evt nostr.Event
+				err := rows.Scan(&evt.ID, &evt.PubKey, &lastCreatedAt,
+					&evt.Kind, &evt.Tags, &evt.Content, &evt.Sig)
+				if err != nil {
+					rows.Close()
+					yield(nil, err)
+					return
+				}
+				evt.CreatedAt = nostr.Timestamp(lastCreatedAt)
+				lastID = evt.ID
+				n++
+
+				if !yield(&evt, nil) {
+					rows.Close()
+					return
+				}
+				count++
+				if count == filter.Limit {
+					rows.Close()
+					return
+				}
+			}
+			err = rows.Err()
+			rows.Close()
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			if n < exportPageSize {
+				return
+			}
+		}
+	}
+}
@@ -12,6 +12,17 @@ import (
 )
 
 func (b *TursoBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (ch chan *nostr.Event, err error) {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// otherwise the goroutine below will release it
+		if err != nil {
+			done()
+		}
+	}()
+
 	query, params, err := b.queryEventsSql(filter, false)
 	if err != nil {
 		return nil, err
@@ -24,6 +35,7 @@ func (b *TursoBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (ch
 
 	ch = make(chan *nostr.Event)
 	go func() {
+		defer done()
 		defer rows.Close()
 		defer close(ch)
 		for rows.Next() {
@@ -47,6 +59,12 @@ func (b *TursoBackend) QueryEvents(ctx context.Context, filter nostr.Filter) (ch
 }
 
 func (b *TursoBackend) CountEvents(ctx context.Context, filter nostr.Filter) (int64, error) {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
 	query, params, err := b.queryEventsSql(filter, true)
 	if err != nil {
 		return 0, err
@@ -71,14 +89,15 @@ func makePlaceHolders(n int) string {
 	return strings.TrimRight(strings.Repeat("?,", n), ",")
 }
 
-func (b *TursoBackend) queryEventsSql(filter nostr.Filter, doCount bool) (string, []any, error) {
+// filterConditions translates filter into SQL conditions, without the limit.
+func (b *TursoBackend) filterConditions(filter nostr.Filter) ([]string, []any, error) {
 	conditions := make([]string, 0, 7)
 	params := make([]any, 0, 20)
 
 	if len(filter.IDs) > 0 {
 		if len(filter.IDs) > 500 {
 			// too many ids, fail everything
-			return "", nil, TooManyIDs
+			return nil, nil, TooManyIDs
 		}
 
 		for _, v := range filter.IDs {
@@ -90,7 +109,7 @@ func (b *TursoBackend) queryEventsSql(filter nostr.Filter, doCount bool) (string
 	if len(filter.Authors) > 0 {
 		if len(filter.Authors) > b.QueryAuthorsLimit {
 			// too many authors, fail everything
-			return "", nil, TooManyAuthors
+			return nil, nil, TooManyAuthors
 		}
 
 		for _, v := range filter.Authors {
@@ -102,7 +121,7 @@ func (b *TursoBackend) queryEventsSql(filter nostr.Filter, doCount bool) (string
 	if len(filter.Kinds) > 0 {
 		if len(filter.Kinds) > b.QueryKindsLimit {
 			// too many kinds, fail everything
-			return "", nil, TooManyKinds
+			return nil, nil, TooManyKinds
 		}
 
 		for _, v := range filter.Kinds {
@@ -117,7 +136,7 @@ func (b *TursoBackend) queryEventsSql(filter nostr.Filter, doCount bool) (string
 	for _, values := range filter.Tags {
 		if len(values) == 0 {
 			// any tag set to [] is wrong
-			return "", nil, EmptyTagSet
+			return nil, nil, EmptyTagSet
 		}
 
 		orTag := make([]string, len(values))
@@ -132,7 +151,7 @@ func (b *TursoBackend) queryEventsSql(filter nostr.Filter, doCount bool) (string
 		totalTags += len(values)
 		if totalTags > b.QueryTagsLimit {
 			// too many tags, fail everything
-			return "", nil, TooManyTagValues
+			return nil, nil, TooManyTagValues
 		}
 	}
 
@@ -154,6 +173,15 @@ func (b *TursoBackend) queryEventsSql(filter nostr.Filter, doCount bool) (string
 		conditions = append(conditions, `true`)
 	}
 
+	return conditions, params, nil
+}
+
+func (b *TursoBackend) queryEventsSql(filter nostr.Filter, doCount bool) (string, []any, error) {
+	conditions, params, err := b.filterConditions(filter)
+	if err != nil {
+		return "", nil, err
+	}
+
 	if filter.Limit < 1 || filter.Limit > b.QueryLimit {
 		params = append(params, b.QueryLimit)
 	} else {
@@ -0,0 +1,20 @@
+package turso
+
+import "github.com/fiatjaf/eventstore"
+
+func init() {
+	eventstore.Register(eventstore.Driver{
+		Name:    "turso",
+		Schemes: []string{"libsql"},
+		New: func(uri string, opts eventstore.OpenOptions) (eventstore.Store, error) {
+			return &TursoBackend{
+				DatabaseURL:       uri,
+				QueryLimit:        opts.MaxLimit,
+				QueryIDsLimit:     opts.MaxLimit,
+				QueryAuthorsLimit: opts.MaxLimit,
+				QueryKindsLimit:   opts.MaxLimit,
+				QueryTagsLimit:    opts.MaxLimit,
+			}, nil
+		},
+	})
+}
@@ -0,0 +1,85 @@
+package turso
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fiatjaf/eventstore"
+)
+
+// Stats counts the events per kind and reports the size of the database file. Sizes of tables
+// and indexes are only known when sqlite was built with the dbstat virtual table, otherwise
+// only their number of entries is reported.
+func (b *TursoBackend) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return eventstore.StoreStats{}, err
+	}
+	defer done()
+
+	stats := eventstore.StoreStats{
+		EventsPerKind: make(map[int]int64),
+		Indexes:       make(map[string]eventstore.IndexStats),
+	}
+
+	rows, err := b.DB.QueryContext(ctx, `SELECT kind, count(*) FROM event GROUP BY kind`)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count events per kind: %w", err)
+	}
+	for rows.Next() {
+		var kind int
+		var count int64
+		if err := rows.Scan(&kind, &count); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		stats.EventsPerKind[kind] = count
+		stats.Events += count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	var pageCount, pageSize int64
+	if err := b.DB.QueryRowContext(ctx, `PRAGMA page_count`).Scan(&pageCount); err != nil {
+		return stats, fmt.Errorf("failed to get page count: %w", err)
+	}
+	if err := b.DB.QueryRowContext(ctx, `PRAGMA page_size`).Scan(&pageSize); err != nil {
+		return stats, fmt.Errorf("failed to get page size: %w", err)
+	}
+	stats.DiskSize = pageCount * pageSize
+
+	rows, err = b.DB.QueryContext(ctx, `SELECT name, sum(CASE WHEN pagetype = 'leaf' THEN ncell ELSE 0 END), sum(pgsize)
+		FROM dbstat WHERE name = 'event' OR name IN (SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'event')
+		GROUP BY name`)
+	if err != nil {
+		// no dbstat, all we know is that every index has one entry per event
+		rows, err = b.DB.QueryContext(ctx, `SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'event'`)
+		if err != nil {
+			return stats, fmt.Errorf("failed to list indexes: %w", err)
+		}
+		defer rows.Close()
+
+		stats.Indexes["event"] = eventstore.IndexStats{Entries: stats.Events}
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return stats, err
+			}
+			stats.Indexes[name] = eventstore.IndexStats{Entries: stats.Events}
+		}
+		return stats, rows.Err()
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var s eventstore.IndexStats
+		if err := rows.Scan(&name, &s.Entries, &s.Size); err != nil {
+			return stats, err
+		}
+		stats.Indexes[name] = s
+	}
+	return stats, rows.Err()
+}
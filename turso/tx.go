@@ -0,0 +1,90 @@
+package turso
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/jmoiron/sqlx"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+var _ eventstore.Transactor = (*TursoBackend)(nil)
+
+// Update runs fn inside a single SQL transaction.
+func (b *TursoBackend) Update(ctx context.Context, fn func(eventstore.Tx) error) error {
+	ctx, done, err := b.inflight.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	txn, err := b.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(&tx{b: b, ctx: ctx, txn: txn}); err != nil {
+		txn.Rollback()
+		return err
+	}
+
+	return txn.Commit()
+}
+
+type tx struct {
+	b   *TursoBackend
+	ctx context.Context
+	txn *sqlx.Tx
+}
+
+func (t *tx) Query(filter nostr.Filter) ([]*nostr.Event, error) {
+	query, params, err := t.b.queryEventsSql(filter, false)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := t.txn.QueryContext(t.ctx, query, params...)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to fetch events using query %q: %w", query, err)
+	}
+	defer rows.Close()
+
+	var events []*nostr.Event
+	for rows.Next() {
+		var evt nostr.Event
+		var timestamp int64
+		err := rows.Scan(&evt.ID, &evt.PubKey, &timestamp,
+			&evt.Kind, &evt.Tags, &evt.Content, &evt.Sig)
+		if err != nil {
+			return nil, err
+		}
+		evt.CreatedAt = nostr.Timestamp(timestamp)
+		events = append(events, &evt)
+	}
+
+	return events, rows.Err()
+}
+
+func (t *tx) Count(filter nostr.Filter) (int64, error) {
+	query, params, err := t.b.queryEventsSql(filter, true)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	if err = t.txn.QueryRowContext(t.ctx, query, params...).Scan(&count); err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to fetch events using query %q: %w", query, err)
+	}
+	return count, nil
+}
+
+func (t *tx) Save(evt *nostr.Event) error {
+	return saveEvent(t.ctx, t.txn, evt)
+}
+
+func (t *tx) Delete(evt *nostr.Event) error {
+	_, err := t.txn.ExecContext(t.ctx, "DELETE FROM event WHERE id = ?", evt.ID)
+	return err
+}
@@ -0,0 +1,64 @@
+package eventstore
+
+import (
+	"context"
+	"fmt"
+)
+
+// MaxReportedProblems is how many problem descriptions a VerifyReport keeps, the counters go on
+// counting after that.
+const MaxReportedProblems = 100
+
+// VerifyOptions controls what a Verifier checks and whether it fixes what it finds.
+type VerifyOptions struct {
+	// CheckSignatures also checks that each event id matches the event contents and that the
+	// signature is valid, which is much slower than checking the indexes alone.
+	CheckSignatures bool
+
+	// Repair adds the missing index entries, removes the orphaned ones and deletes the raw events
+	// that can't be decoded. Events with a bad id or signature are only reported.
+	Repair bool
+}
+
+// VerifyReport is what a Verifier found, and fixed if it was asked to.
+type VerifyReport struct {
+	// Events is the number of raw events checked.
+	Events int64 `json:"events"`
+
+	// Undecodable is the number of raw events that couldn't be decoded.
+	Undecodable int64 `json:"undecodable"`
+
+	// InvalidEvents is the number of events with a bad id or signature, only checked when
+	// VerifyOptions.CheckSignatures is set.
+	InvalidEvents int64 `json:"invalid_events"`
+
+	// MissingIndexEntries is the number of index entries an event should have but doesn't.
+	MissingIndexEntries int64 `json:"missing_index_entries"`
+
+	// OrphanedIndexEntries is the number of index entries that point to events that don't exist,
+	// can't be read or wouldn't have been indexed under that entry.
+	OrphanedIndexEntries int64 `json:"orphaned_index_entries"`
+
+	// Repaired is set when the problems that can be fixed were fixed.
+	Repaired bool `json:"repaired"`
+
+	// Problems describes the first MaxReportedProblems problems found.
+	Problems []string `json:"problems,omitempty"`
+}
+
+// AddProblem records the description of a problem, if there is still room for it.
+func (r *VerifyReport) AddProblem(format string, args ...any) {
+	if len(r.Problems) < MaxReportedProblems {
+		r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+	}
+}
+
+// OK tells if no problems were found.
+func (r VerifyReport) OK() bool {
+	return r.Undecodable == 0 && r.InvalidEvents == 0 && r.MissingIndexEntries == 0 && r.OrphanedIndexEntries == 0
+}
+
+// Verifier is implemented by stores that can check the consistency of their own data.
+type Verifier interface {
+	Verify(context.Context, VerifyOptions) (VerifyReport, error)
+}
@@ -2,6 +2,7 @@ package count
 
 import (
 	"context"
+	"iter"
 
 	"github.com/fiatjaf/eventstore"
 	"github.com/nbd-wtf/go-nostr"
@@ -32,3 +33,24 @@ func (w Wrapper) CountEvents(ctx context.Context, filter nostr.Filter) (int64, e
 	}
 	return count, nil
 }
+
+// Update runs fn in a transaction of the underlying store, if it supports them.
+func (w Wrapper) Update(ctx context.Context, fn func(eventstore.Tx) error) error {
+	if transactor, ok := w.Store.(eventstore.Transactor); ok {
+		return transactor.Update(ctx, fn)
+	}
+	return eventstore.ErrTransactionsNotSupported
+}
+
+// Stats reports the stats of the underlying store, if it supports them.
+func (w Wrapper) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	if reporter, ok := w.Store.(eventstore.StatsReporter); ok {
+		return reporter.Stats(ctx)
+	}
+	return eventstore.StoreStats{}, eventstore.ErrStatsNotSupported
+}
+
+// ExportEvents goes through the events of the underlying store with eventstore.AllEvents.
+func (w Wrapper) ExportEvents(ctx context.Context, filter nostr.Filter) iter.Seq2[*nostr.Event, error] {
+	return eventstore.AllEvents(ctx, w.Store, filter)
+}
@@ -0,0 +1,12 @@
+package count
+
+import "github.com/fiatjaf/eventstore"
+
+func init() {
+	eventstore.RegisterWrapper("count", func(inner eventstore.Store, options func(any) error) (eventstore.Store, error) {
+		if err := options(&struct{}{}); err != nil {
+			return nil, err
+		}
+		return Wrapper{Store: inner}, nil
+	})
+}
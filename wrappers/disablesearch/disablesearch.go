@@ -2,6 +2,7 @@ package disablesearch
 
 import (
 	"context"
+	"iter"
 
 	"github.com/fiatjaf/eventstore"
 	"github.com/nbd-wtf/go-nostr"
@@ -21,3 +22,43 @@ func (w Wrapper) QueryEvents(ctx context.Context, filter nostr.Filter) (chan *no
 	}
 	return w.Store.QueryEvents(ctx, filter)
 }
+
+// Update runs fn in a transaction of the underlying store, search queries made through it return nothing.
+func (w Wrapper) Update(ctx context.Context, fn func(eventstore.Tx) error) error {
+	transactor, ok := w.Store.(eventstore.Transactor)
+	if !ok {
+		return eventstore.ErrTransactionsNotSupported
+	}
+
+	return transactor.Update(ctx, func(tx eventstore.Tx) error {
+		return fn(noSearchTx{tx})
+	})
+}
+
+type noSearchTx struct {
+	eventstore.Tx
+}
+
+func (t noSearchTx) Query(filter nostr.Filter) ([]*nostr.Event, error) {
+	if filter.Search != "" {
+		return nil, nil
+	}
+	return t.Tx.Query(filter)
+}
+
+// Stats reports the stats of the underlying store, if it supports them.
+func (w Wrapper) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	if reporter, ok := w.Store.(eventstore.StatsReporter); ok {
+		return reporter.Stats(ctx)
+	}
+	return eventstore.StoreStats{}, eventstore.ErrStatsNotSupported
+}
+
+// ExportEvents goes through the events of the underlying store with eventstore.AllEvents, search
+// filters yield nothing.
+func (w Wrapper) ExportEvents(ctx context.Context, filter nostr.Filter) iter.Seq2[*nostr.Event, error] {
+	if filter.Search != "" {
+		return func(yield func(*nostr.Event, error) bool) {}
+	}
+	return eventstore.AllEvents(ctx, w.Store, filter)
+}
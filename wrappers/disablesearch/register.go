@@ -0,0 +1,12 @@
+package disablesearch
+
+import "github.com/fiatjaf/eventstore"
+
+func init() {
+	eventstore.RegisterWrapper("disablesearch", func(inner eventstore.Store, options func(any) error) (eventstore.Store, error) {
+		if err := options(&struct{}{}); err != nil {
+			return nil, err
+		}
+		return Wrapper{Store: inner}, nil
+	})
+}
@@ -0,0 +1,150 @@
+package search
+
+import (
+	"context"
+	"iter"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Wrapper sends filters with a search to Search and everything else to Store. Events are saved
+// and deleted in both, Store first. It doesn't run transactions, since the index can't be part of
+// them.
+type Wrapper struct {
+	eventstore.Store
+
+	// Search is a search index like bluge, it only has to answer filters with a search.
+	Search eventstore.Store
+}
+
+var _ eventstore.Store = (*Wrapper)(nil)
+
+// Init initializes Store and then Search.
+func (w Wrapper) Init() error {
+	if err := w.Store.Init(); err != nil {
+		return err
+	}
+	return w.Search.Init()
+}
+
+// Close closes Search and then Store.
+func (w Wrapper) Close() {
+	w.Search.Close()
+	w.Store.Close()
+}
+
+func (w Wrapper) QueryEvents(ctx context.Context, filter nostr.Filter) (chan *nostr.Event, error) {
+	return w.storeFor(filter).QueryEvents(ctx, filter)
+}
+
+func (w Wrapper) SaveEvent(ctx context.Context, evt *nostr.Event) error {
+	if err := w.Store.SaveEvent(ctx, evt); err != nil {
+		return err
+	}
+	return w.Search.SaveEvent(ctx, evt)
+}
+
+func (w Wrapper) ReplaceEvent(ctx context.Context, evt *nostr.Event) error {
+	if err := w.Store.ReplaceEvent(ctx, evt); err != nil {
+		return err
+	}
+	return w.Search.ReplaceEvent(ctx, evt)
+}
+
+func (w Wrapper) DeleteEvent(ctx context.Context, evt *nostr.Event) error {
+	if err := w.Store.DeleteEvent(ctx, evt); err != nil {
+		return err
+	}
+	return w.Search.DeleteEvent(ctx, evt)
+}
+
+// CountEvents counts with the CountEvents of Store, or of Search for filters with a search, and
+// by going through the results of QueryEvents when they don't have one.
+func (w Wrapper) CountEvents(ctx context.Context, filter nostr.Filter) (int64, error) {
+	store := w.storeFor(filter)
+	if counter, ok := store.(eventstore.Counter); ok {
+		return counter.CountEvents(ctx, filter)
+	}
+
+	ch, err := store.QueryEvents(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	for range ch {
+		count++
+	}
+	return count, nil
+}
+
+// QueryIDs uses the QueryIDs of Store, or of Search for filters with a search, and takes the ids
+// from the results of QueryEvents when they don't have one.
+func (w Wrapper) QueryIDs(ctx context.Context, filter nostr.Filter) iter.Seq2[eventstore.IDTimestamp, error] {
+	store := w.storeFor(filter)
+	if querier, ok := store.(eventstore.IDQuerier); ok {
+		return querier.QueryIDs(ctx, filter)
+	}
+
+	return func(yield func(eventstore.IDTimestamp, error) bool) {
+		// so the query stops if we stop early
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		ch, err := store.QueryEvents(ctx, filter)
+		if err != nil {
+			yield(eventstore.IDTimestamp{}, err)
+			return
+		}
+		for evt := range ch {
+			if !yield(eventstore.IDTimestamp{ID: evt.ID, CreatedAt: evt.CreatedAt}, nil) {
+				return
+			}
+		}
+	}
+}
+
+// HasEvents tells which of ids are in Store.
+func (w Wrapper) HasEvents(ctx context.Context, ids []string) ([]bool, error) {
+	if checker, ok := w.Store.(eventstore.ExistenceChecker); ok {
+		return checker.HasEvents(ctx, ids)
+	}
+
+	ch, err := w.Store.QueryEvents(ctx, nostr.Filter{IDs: ids})
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool, len(ids))
+	for evt := range ch {
+		found[evt.ID] = true
+	}
+	has := make([]bool, len(ids))
+	for i, id := range ids {
+		has[i] = found[id]
+	}
+	return has, nil
+}
+
+func (w Wrapper) storeFor(filter nostr.Filter) eventstore.Store {
+	if filter.Search != "" {
+		return w.Search
+	}
+	return w.Store
+}
+
+// Stats reports the stats of Store, if it supports them.
+func (w Wrapper) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	if reporter, ok := w.Store.(eventstore.StatsReporter); ok {
+		return reporter.Stats(ctx)
+	}
+	return eventstore.StoreStats{}, eventstore.ErrStatsNotSupported
+}
+
+// ExportEvents goes through the events of Store with eventstore.AllEvents, search filters go
+// through Search.
+func (w Wrapper) ExportEvents(ctx context.Context, filter nostr.Filter) iter.Seq2[*nostr.Event, error] {
+	if filter.Search != "" {
+		return eventstore.AllEvents(ctx, w.Search, filter)
+	}
+	return eventstore.AllEvents(ctx, w.Store, filter)
+}
@@ -0,0 +1,37 @@
+package skipevent
+
+import (
+	"context"
+	"slices"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Options are what the "skipevent" wrapper takes in a config, events matching any of them are
+// skipped.
+type Options struct {
+	Kinds   []int    `json:"kinds"`
+	Authors []string `json:"authors"`
+
+	// Ephemeral skips ephemeral events, which relays usually don't store.
+	Ephemeral bool `json:"ephemeral"`
+}
+
+func init() {
+	eventstore.RegisterWrapper("skipevent", func(inner eventstore.Store, options func(any) error) (eventstore.Store, error) {
+		var opts Options
+		if err := options(&opts); err != nil {
+			return nil, err
+		}
+
+		return Wrapper{
+			Store: inner,
+			Skip: func(ctx context.Context, evt *nostr.Event) bool {
+				return slices.Contains(opts.Kinds, evt.Kind) ||
+					slices.Contains(opts.Authors, evt.PubKey) ||
+					(opts.Ephemeral && nostr.IsEphemeralKind(evt.Kind))
+			},
+		}, nil
+	})
+}
@@ -2,6 +2,7 @@ package skipevent
 
 import (
 	"context"
+	"iter"
 
 	"github.com/fiatjaf/eventstore"
 	"github.com/nbd-wtf/go-nostr"
@@ -30,3 +31,44 @@ func (w Wrapper) ReplaceEvent(ctx context.Context, evt *nostr.Event) error {
 
 	return w.Store.ReplaceEvent(ctx, evt)
 }
+
+// Update runs fn in a transaction of the underlying store, events saved through it are skipped
+// just like in SaveEvent.
+func (w Wrapper) Update(ctx context.Context, fn func(eventstore.Tx) error) error {
+	transactor, ok := w.Store.(eventstore.Transactor)
+	if !ok {
+		return eventstore.ErrTransactionsNotSupported
+	}
+
+	return transactor.Update(ctx, func(tx eventstore.Tx) error {
+		return fn(skipTx{Tx: tx, ctx: ctx, skip: w.Skip})
+	})
+}
+
+type skipTx struct {
+	eventstore.Tx
+
+	ctx  context.Context
+	skip func(ctx context.Context, evt *nostr.Event) bool
+}
+
+func (t skipTx) Save(evt *nostr.Event) error {
+	if t.skip(t.ctx, evt) {
+		return nil
+	}
+
+	return t.Tx.Save(evt)
+}
+
+// Stats reports the stats of the underlying store, if it supports them.
+func (w Wrapper) Stats(ctx context.Context) (eventstore.StoreStats, error) {
+	if reporter, ok := w.Store.(eventstore.StatsReporter); ok {
+		return reporter.Stats(ctx)
+	}
+	return eventstore.StoreStats{}, eventstore.ErrStatsNotSupported
+}
+
+// ExportEvents goes through the events of the underlying store with eventstore.AllEvents.
+func (w Wrapper) ExportEvents(ctx context.Context, filter nostr.Filter) iter.Seq2[*nostr.Event, error] {
+	return eventstore.AllEvents(ctx, w.Store, filter)
+}
@@ -32,3 +32,42 @@ func TestReplaceEventCanBeSkipped(t *testing.T) {
 	require.NoError(t, err)
 	require.Zero(t, store.replaces)
 }
+
+type savingTx struct {
+	eventstore.Tx
+	saved []*nostr.Event
+}
+
+func (tx *savingTx) Save(evt *nostr.Event) error {
+	tx.saved = append(tx.saved, evt)
+	return nil
+}
+
+type transactorStore struct {
+	nullstore.NullStore
+	tx *savingTx
+}
+
+func (s transactorStore) Update(ctx context.Context, fn func(eventstore.Tx) error) error {
+	return fn(s.tx)
+}
+
+func TestTransactionSavesCanBeSkipped(t *testing.T) {
+	tx := &savingTx{}
+	w := Wrapper{
+		Store: transactorStore{tx: tx},
+		Skip:  func(_ context.Context, evt *nostr.Event) bool { return evt.Kind == 1 },
+	}
+
+	err := w.Update(context.Background(), func(tx eventstore.Tx) error {
+		require.NoError(t, tx.Save(&nostr.Event{Kind: 1}))
+		return tx.Save(&nostr.Event{Kind: 7})
+	})
+
+	require.NoError(t, err)
+	require.Len(t, tx.saved, 1)
+	require.Equal(t, 7, tx.saved[0].Kind)
+
+	err = Wrapper{Store: nullstore.NullStore{}}.Update(context.Background(), func(eventstore.Tx) error { return nil })
+	require.ErrorIs(t, err, eventstore.ErrTransactionsNotSupported)
+}